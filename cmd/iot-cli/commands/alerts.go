@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	natsclient "github.com/alejandro/technical_test_uvigo/internal/nats"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "Consultar alertas de umbral",
+	Long:  `Comandos para consultar las alertas persistidas por el servidor`,
+}
+
+var listAlertsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Listar alertas",
+	Long:  `Lista las alertas persistidas, la más reciente primero, filtrando por sensor, gravedad y ventana de tiempo`,
+	Args:  cobra.NoArgs,
+	Example: `  iot-cli alerts list
+  iot-cli alerts list --sensor temp-001 --since 2h
+  iot-cli alerts list --severity critical --limit 100 --json
+  iot-cli alerts list --unit °F`,
+	RunE: listAlerts,
+}
+
+// Flags para list
+var (
+	alertsSensor   string
+	alertsSeverity string
+	alertsSince    time.Duration
+	alertsLimit    int
+	alertsUnit     string
+)
+
+func init() {
+	listAlertsCmd.Flags().StringVarP(&alertsSensor, "sensor", "s", "", "Filtrar por ID de sensor")
+	listAlertsCmd.Flags().StringVar(&alertsSeverity, "severity", "", "Filtrar por gravedad: warning, critical")
+	listAlertsCmd.Flags().DurationVar(&alertsSince, "since", 0, "Ventana de tiempo hacia atrás desde ahora (0 = sin límite)")
+	listAlertsCmd.Flags().IntVarP(&alertsLimit, "limit", "l", 50, "Número máximo de alertas a obtener")
+	listAlertsCmd.Flags().StringVarP(&alertsUnit, "unit", "u", "", "Unidad de valor y umbral de las alertas compatibles (ej: °F); por defecto la del perfil")
+
+	alertsCmd.AddCommand(listAlertsCmd)
+}
+
+func listAlerts(cmd *cobra.Command, args []string) error {
+	if _, err := sensor.ParseAlertSeverity(alertsSeverity); err != nil {
+		return fmt.Errorf("--severity inválido: %w", err)
+	}
+	if alertsSince < 0 {
+		return fmt.Errorf("--since no puede ser negativo")
+	}
+	units, err := resolveUnits(alertsUnit)
+	if err != nil {
+		return err
+	}
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	// Preparar request
+	requestData := withUnits(map[string]interface{}{
+		"sensor_id": alertsSensor,
+		"severity":  alertsSeverity,
+		"limit":     alertsLimit,
+	}, units)
+	if alertsSince > 0 {
+		requestData["start"] = time.Now().UTC().Add(-alertsSince)
+	}
+	data, err := json.Marshal(requestData)
+	if err != nil {
+		return fmt.Errorf("error preparando request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg, err := client.Request(ctx, natsclient.AlertsQuerySubject(), data)
+	if err != nil {
+		return fmt.Errorf("error consultando alertas: %w", err)
+	}
+
+	// Parsear respuesta
+	var alerts []*sensor.Alert
+	if err := json.Unmarshal(msg.Data, &alerts); err != nil {
+		var errResp map[string]string
+		if json.Unmarshal(msg.Data, &errResp) == nil {
+			if errMsg, ok := errResp["error"]; ok {
+				return fmt.Errorf("error del servidor: %s", errMsg)
+			}
+		}
+		return fmt.Errorf("error parseando alertas: %w", err)
+	}
+
+	if outputJSON {
+		jsonOutput, _ := json.MarshalIndent(alerts, "", "  ")
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+
+	if len(alerts) == 0 {
+		fmt.Print("\n✅ No hay alertas que cumplan los filtros\n\n")
+		return nil
+	}
+
+	fmt.Printf("\n🚨 Alertas (%d):\n\n", len(alerts))
+
+	tbl := table.New("Fecha", "Sensor", "Tipo", "Límite", "Gravedad", "Valor", "Umbral")
+	for _, alert := range alerts {
+		// Las alertas de variación son por minuto
+		unit := alert.Unit
+		if alert.Kind == sensor.AlertKindRate {
+			unit += "/min"
+		}
+		tbl.AddRow(
+			alert.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			alert.SensorID,
+			alert.Type,
+			map[sensor.AlertKind]string{"": "superior", sensor.AlertKindHigh: "superior", sensor.AlertKindLow: "inferior", sensor.AlertKindRate: "variación"}[alert.Kind],
+			map[sensor.AlertSeverity]string{sensor.AlertSeverityWarning: "⚠️  warning", sensor.AlertSeverityCritical: "🔴 critical"}[alert.Severity],
+			fmt.Sprintf("%.2f %s", alert.Value, unit),
+			fmt.Sprintf("%.2f %s", alert.Threshold, unit),
+		)
+	}
+	tbl.Print()
+	fmt.Println()
+
+	return nil
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
 	"time"
 
 	natsclient "github.com/alejandro/technical_test_uvigo/internal/nats"
 	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	natslib "github.com/nats-io/nats.go"
 	"github.com/rodaine/table"
 	"github.com/spf13/cobra"
 )
@@ -24,25 +29,81 @@ var getConfigCmd = &cobra.Command{
 	Long:  `Obtiene la configuración actual de un sensor específico`,
 	Args:  cobra.ExactArgs(1),
 	Example: `  iot-cli config get temp-001
-  iot-cli config get temp-001 --json`,
+  iot-cli config get temp-001 --json
+  iot-cli config get temp-001 --unit °F`,
 	RunE: getConfig,
 }
 
 var setConfigCmd = &cobra.Command{
 	Use:   "set [sensor-id]",
 	Short: "Actualizar configuración de un sensor",
-	Long:  `Actualiza la configuración de un sensor específico`,
-	Args:  cobra.ExactArgs(1),
+	Long: `Actualiza la configuración de un sensor específico. Solo cambian los parámetros indicados.
+
+Límites de alerta: --threshold (superior) y --low-threshold (inferior) alertan cuando el valor
+sale del rango; con --hysteresis un límite disparado no vuelve a alertar hasta que el valor
+regresa al rango con ese margen. --max-rate alerta si el valor varía más de esa cantidad
+por minuto entre lecturas. Con --unit los límites se indican en esa unidad y el servidor
+los guarda en la del sensor.
+
+Calibración: --offset y --gain corrigen el valor bruto (gain·bruto + offset); --polynomial
+indica los coeficientes c0,c1,c2... de un polinomio en su lugar. Los parámetros están
+siempre en la unidad del sensor, aunque se indique --unit. --no-calibration la quita.`,
+	Args: cobra.ExactArgs(1),
 	Example: `  iot-cli config set temp-001 --interval 3000 --threshold 28.5
-  iot-cli config set temp-001 --interval 2000 --threshold 32.0 --enabled=false`,
+  iot-cli config set temp-001 --interval 2000 --threshold 32.0 --enabled=false
+  iot-cli config set temp-001 --threshold 86 --unit °F
+  iot-cli config set temp-001 --low-threshold 0 --hysteresis 0.5
+  iot-cli config set press-001 --max-rate 2 --no-low-threshold
+  iot-cli config set co2-001 --offset -35 --gain 1.05
+  iot-cli config set temp-001 --polynomial 0.2,1.01,0.0003
+  iot-cli config set temp-001 --no-calibration`,
 	RunE: setConfig,
 }
 
+var historyConfigCmd = &cobra.Command{
+	Use:   "history [sensor-id]",
+	Short: "Historial de cambios de configuración",
+	Long:  `Muestra las revisiones de configuración de un sensor, la más reciente primero, con autor y motivo`,
+	Args:  cobra.ExactArgs(1),
+	Example: `  iot-cli config history temp-001
+  iot-cli config history temp-001 --limit 20 --json`,
+	RunE: getConfigHistory,
+}
+
+var rollbackConfigCmd = &cobra.Command{
+	Use:   "rollback [sensor-id]",
+	Short: "Restaurar una revisión anterior de configuración",
+	Long:  `Restaura la configuración de una revisión del historial (por defecto la anterior) y la aplica al sensor en ejecución`,
+	Args:  cobra.ExactArgs(1),
+	Example: `  iot-cli config rollback temp-001
+  iot-cli config rollback temp-001 --revision 3`,
+	RunE: rollbackConfig,
+}
+
 // Flags para set
 var (
 	setInterval  int
 	setThreshold float64
 	setEnabled   bool
+
+	setLowThreshold   float64
+	setNoLowThreshold bool
+	setHysteresis     float64
+	setMaxRate        float64
+
+	setOffset        float64
+	setGain          float64
+	setPolynomial    []float64
+	setNoCalibration bool
+)
+
+// configUnit es la unidad del umbral en get y set
+var configUnit string
+
+// Flags para history y rollback
+var (
+	historyLimit     int
+	rollbackRevision int
 )
 
 func init() {
@@ -50,15 +111,42 @@ func init() {
 	setConfigCmd.Flags().IntVar(&setInterval, "interval", 0, "Intervalo de muestreo en milisegundos")
 	setConfigCmd.Flags().Float64Var(&setThreshold, "threshold", 0, "Umbral de alerta")
 	setConfigCmd.Flags().BoolVar(&setEnabled, "enabled", true, "Habilitar/deshabilitar sensor")
+	setConfigCmd.Flags().Float64Var(&setLowThreshold, "low-threshold", 0, "Umbral de alerta inferior")
+	setConfigCmd.Flags().BoolVar(&setNoLowThreshold, "no-low-threshold", false, "Quitar el umbral inferior")
+	setConfigCmd.Flags().Float64Var(&setHysteresis, "hysteresis", 0, "Banda muerta para rearmar las alertas de umbral (0 = alerta en cada lectura)")
+	setConfigCmd.Flags().Float64Var(&setMaxRate, "max-rate", 0, "Variación máxima por minuto entre lecturas (0 = sin límite)")
+	setConfigCmd.Flags().Float64Var(&setOffset, "offset", 0, "Offset de calibración en la unidad del sensor")
+	setConfigCmd.Flags().Float64Var(&setGain, "gain", 0, "Ganancia de calibración (0 = 1)")
+	setConfigCmd.Flags().Float64SliceVar(&setPolynomial, "polynomial", nil, "Coeficientes del polinomio de calibración de menor a mayor grado (ej: 0.2,1.01)")
+	setConfigCmd.Flags().BoolVar(&setNoCalibration, "no-calibration", false, "Quitar la calibración")
+	setConfigCmd.MarkFlagsMutuallyExclusive("low-threshold", "no-low-threshold")
+	setConfigCmd.MarkFlagsMutuallyExclusive("polynomial", "offset")
+	setConfigCmd.MarkFlagsMutuallyExclusive("polynomial", "gain")
+	for _, flag := range []string{"offset", "gain", "polynomial"} {
+		setConfigCmd.MarkFlagsMutuallyExclusive(flag, "no-calibration")
+	}
+	for _, c := range []*cobra.Command{getConfigCmd, setConfigCmd} {
+		c.Flags().StringVarP(&configUnit, "unit", "u", "", "Unidad del umbral (ej: °F, psi); por defecto la del perfil o la del sensor")
+	}
+
+	historyConfigCmd.Flags().IntVarP(&historyLimit, "limit", "l", 10, "Número máximo de revisiones a mostrar")
+	rollbackConfigCmd.Flags().IntVar(&rollbackRevision, "revision", 0, "Revisión a restaurar (0 = la anterior a la actual)")
 
 	// Añadir subcomandos
 	configCmd.AddCommand(getConfigCmd)
 	configCmd.AddCommand(setConfigCmd)
+	configCmd.AddCommand(historyConfigCmd)
+	configCmd.AddCommand(rollbackConfigCmd)
 }
 
 func getConfig(cmd *cobra.Command, args []string) error {
 	sensorID := args[0]
 
+	units, err := resolveUnits(configUnit)
+	if err != nil {
+		return err
+	}
+
 	// Conectar a NATS
 	client, err := natsclient.NewClient(natsURL)
 	if err != nil {
@@ -70,24 +158,19 @@ func getConfig(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	subject := natsclient.ConfigGetSubject(sensorID)
-	msg, err := client.Request(ctx, subject, nil)
+	msg, err := requestConfig(ctx, client, sensorID, units)
 	if err != nil {
 		return fmt.Errorf("error obteniendo configuración: %w", err)
 	}
 
-	// Parsear respuesta
-	var config sensor.SensorConfig
+	// Parsear respuesta (el umbral viene en la unidad indicada en unit)
+	var config configResponse
 	if err := json.Unmarshal(msg.Data, &config); err != nil {
-		// Verificar si es un mensaje de error
-		var errResp map[string]string
-		if json.Unmarshal(msg.Data, &errResp) == nil {
-			if errMsg, ok := errResp["error"]; ok {
-				return fmt.Errorf("error del servidor: %s", errMsg)
-			}
-		}
 		return fmt.Errorf("error parseando configuración: %w", err)
 	}
+	if config.Error != "" {
+		return fmt.Errorf("error del servidor: %s", config.Error)
+	}
 
 	if outputJSON {
 		jsonOutput, _ := json.MarshalIndent(config, "", "  ")
@@ -98,7 +181,11 @@ func getConfig(cmd *cobra.Command, args []string) error {
 		tbl := table.New("Parámetro", "Valor")
 		tbl.AddRow("Sensor ID", config.SensorID)
 		tbl.AddRow("Intervalo", fmt.Sprintf("%d ms", config.Interval))
-		tbl.AddRow("Threshold", fmt.Sprintf("%.2f", config.Threshold))
+		tbl.AddRow("Threshold", formatLimit(config.Threshold, config.Unit))
+		tbl.AddRow("Threshold inferior", formatOptionalLimit(config.LowThreshold, config.Unit))
+		tbl.AddRow("Histéresis", formatLimit(config.Hysteresis, config.Unit))
+		tbl.AddRow("Variación máx.", formatRate(config.MaxRate, config.Unit))
+		tbl.AddRow("Calibración", formatCalibration(config.Calibration))
 		tbl.AddRow("Estado", map[bool]string{true: "✅ Habilitado", false: "❌ Deshabilitado"}[config.Enabled])
 		tbl.Print()
 		fmt.Println()
@@ -110,6 +197,11 @@ func getConfig(cmd *cobra.Command, args []string) error {
 func setConfig(cmd *cobra.Command, args []string) error {
 	sensorID := args[0]
 
+	units, err := resolveUnits(configUnit)
+	if err != nil {
+		return err
+	}
+
 	// Primero obtener configuración actual
 	client, err := natsclient.NewClient(natsURL)
 	if err != nil {
@@ -120,17 +212,20 @@ func setConfig(cmd *cobra.Command, args []string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Obtener config actual
-	subject := natsclient.ConfigGetSubject(sensorID)
-	msg, err := client.Request(ctx, subject, nil)
+	// Obtener config actual, con el umbral en la unidad en la que se indica --threshold
+	msg, err := requestConfig(ctx, client, sensorID, units)
 	if err != nil {
 		return fmt.Errorf("error obteniendo configuración actual: %w", err)
 	}
 
-	var currentConfig sensor.SensorConfig
-	if err := json.Unmarshal(msg.Data, &currentConfig); err != nil {
+	var current configResponse
+	if err := json.Unmarshal(msg.Data, &current); err != nil {
 		return fmt.Errorf("error parseando configuración actual: %w", err)
 	}
+	if current.Error != "" {
+		return fmt.Errorf("error del servidor: %s", current.Error)
+	}
+	currentConfig := current.SensorConfig
 
 	// Actualizar solo los valores que se especificaron
 	if cmd.Flags().Changed("interval") {
@@ -142,14 +237,50 @@ func setConfig(cmd *cobra.Command, args []string) error {
 	if cmd.Flags().Changed("enabled") {
 		currentConfig.Enabled = setEnabled
 	}
+	if cmd.Flags().Changed("low-threshold") {
+		currentConfig.LowThreshold = &setLowThreshold
+	}
+	if setNoLowThreshold {
+		currentConfig.LowThreshold = nil
+	}
+	if cmd.Flags().Changed("hysteresis") {
+		currentConfig.Hysteresis = setHysteresis
+	}
+	if cmd.Flags().Changed("max-rate") {
+		currentConfig.MaxRate = setMaxRate
+	}
+	if cmd.Flags().Changed("offset") || cmd.Flags().Changed("gain") {
+		// Calibración lineal: conserva el parámetro no indicado y descarta el polinomio
+		calibration := &sensor.Calibration{}
+		if currentConfig.Calibration != nil {
+			calibration.Offset, calibration.Gain = currentConfig.Calibration.Offset, currentConfig.Calibration.Gain
+		}
+		if cmd.Flags().Changed("offset") {
+			calibration.Offset = setOffset
+		}
+		if cmd.Flags().Changed("gain") {
+			calibration.Gain = setGain
+		}
+		currentConfig.Calibration = calibration
+	}
+	if cmd.Flags().Changed("polynomial") {
+		currentConfig.Calibration = &sensor.Calibration{Polynomial: setPolynomial}
+	}
+	if setNoCalibration {
+		currentConfig.Calibration = nil
+	}
 
 	// Validar
 	if err := currentConfig.Validate(); err != nil {
 		return fmt.Errorf("configuración inválida: %w", err)
 	}
 
-	// Enviar nueva configuración
-	data, err := json.Marshal(currentConfig)
+	// Enviar nueva configuración junto con el autor del cambio y la unidad del umbral
+	data, err := json.Marshal(struct {
+		sensor.SensorConfig
+		ChangedBy string `json:"changed_by"`
+		Unit      string `json:"unit,omitempty"`
+	}{currentConfig, currentUser(), current.Unit})
 	if err != nil {
 		return fmt.Errorf("error serializando configuración: %w", err)
 	}
@@ -179,10 +310,220 @@ func setConfig(cmd *cobra.Command, args []string) error {
 	} else {
 		printSuccess(fmt.Sprintf("Configuración del sensor '%s' actualizada", sensorID))
 		fmt.Printf("\n⚙️  Nueva configuración:\n")
-		fmt.Printf("  Interval:  %dms\n", currentConfig.Interval)
-		fmt.Printf("  Threshold: %.2f\n", currentConfig.Threshold)
-		fmt.Printf("  Estado:    %s\n", map[bool]string{true: "Habilitado", false: "Deshabilitado"}[currentConfig.Enabled])
+		fmt.Printf("  Interval:    %dms\n", currentConfig.Interval)
+		fmt.Printf("  Threshold:   %s\n", formatLimit(currentConfig.Threshold, current.Unit))
+		fmt.Printf("  Inferior:    %s\n", formatOptionalLimit(currentConfig.LowThreshold, current.Unit))
+		fmt.Printf("  Histéresis:  %s\n", formatLimit(currentConfig.Hysteresis, current.Unit))
+		fmt.Printf("  Var. máx.:   %s\n", formatRate(currentConfig.MaxRate, current.Unit))
+		fmt.Printf("  Calibración: %s\n", formatCalibration(currentConfig.Calibration))
+		fmt.Printf("  Estado:      %s\n", map[bool]string{true: "Habilitado", false: "Deshabilitado"}[currentConfig.Enabled])
 	}
 
 	return nil
 }
+
+func getConfigHistory(cmd *cobra.Command, args []string) error {
+	sensorID := args[0]
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	data, err := json.Marshal(map[string]int{"limit": historyLimit})
+	if err != nil {
+		return fmt.Errorf("error preparando request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subject := natsclient.ConfigHistorySubject(sensorID)
+	msg, err := client.Request(ctx, subject, data)
+	if err != nil {
+		return fmt.Errorf("error obteniendo historial: %w", err)
+	}
+
+	// Parsear respuesta
+	var history []*sensor.ConfigRevision
+	if err := json.Unmarshal(msg.Data, &history); err != nil {
+		var errResp map[string]string
+		if json.Unmarshal(msg.Data, &errResp) == nil {
+			if errMsg, ok := errResp["error"]; ok {
+				return fmt.Errorf("error del servidor: %s", errMsg)
+			}
+		}
+		return fmt.Errorf("error parseando historial: %w", err)
+	}
+
+	if outputJSON {
+		jsonOutput, _ := json.MarshalIndent(history, "", "  ")
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("\n⚠️  No hay historial de configuración para el sensor '%s'\n\n", sensorID)
+		return nil
+	}
+
+	fmt.Printf("\n🕘 Historial de configuración del sensor '%s':\n\n", sensorID)
+
+	tbl := table.New("Revisión", "Intervalo", "Threshold", "Inferior", "Histéresis", "Var. máx.", "Calibración", "Estado", "Cambiado por", "Motivo", "Fecha")
+	for _, rev := range history {
+		tbl.AddRow(
+			rev.Revision,
+			fmt.Sprintf("%d ms", rev.Config.Interval),
+			fmt.Sprintf("%.2f", rev.Config.Threshold),
+			formatOptionalLimit(rev.Config.LowThreshold, ""),
+			fmt.Sprintf("%.2f", rev.Config.Hysteresis),
+			formatRate(rev.Config.MaxRate, ""),
+			formatCalibration(rev.Config.Calibration),
+			map[bool]string{true: "✅ Habilitado", false: "❌ Deshabilitado"}[rev.Config.Enabled],
+			rev.ChangedBy,
+			rev.Reason,
+			rev.ChangedAt.Local().Format("2006-01-02 15:04:05"),
+		)
+	}
+	tbl.Print()
+	fmt.Println()
+
+	return nil
+}
+
+func rollbackConfig(cmd *cobra.Command, args []string) error {
+	sensorID := args[0]
+
+	if rollbackRevision < 0 {
+		return fmt.Errorf("--revision no puede ser negativo")
+	}
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	data, err := json.Marshal(map[string]interface{}{
+		"revision":   rollbackRevision,
+		"changed_by": currentUser(),
+	})
+	if err != nil {
+		return fmt.Errorf("error preparando request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subject := natsclient.ConfigRollbackSubject(sensorID)
+	msg, err := client.Request(ctx, subject, data)
+	if err != nil {
+		return fmt.Errorf("error restaurando configuración: %w", err)
+	}
+
+	// Verificar respuesta
+	var response struct {
+		Status       string              `json:"status"`
+		Error        string              `json:"error"`
+		SensorID     string              `json:"sensor_id"`
+		RolledBackTo int                 `json:"rolled_back_to"`
+		Config       sensor.SensorConfig `json:"config"`
+	}
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		return fmt.Errorf("error parseando respuesta: %w", err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("error del servidor: %s", response.Error)
+	}
+
+	if outputJSON {
+		fmt.Println(string(msg.Data))
+		return nil
+	}
+
+	printSuccess(fmt.Sprintf("Configuración del sensor '%s' restaurada a la revisión %d", sensorID, response.RolledBackTo))
+	fmt.Printf("\n⚙️  Configuración aplicada:\n")
+	fmt.Printf("  Interval:    %dms\n", response.Config.Interval)
+	fmt.Printf("  Threshold:   %.2f\n", response.Config.Threshold)
+	fmt.Printf("  Inferior:    %s\n", formatOptionalLimit(response.Config.LowThreshold, ""))
+	fmt.Printf("  Histéresis:  %.2f\n", response.Config.Hysteresis)
+	fmt.Printf("  Var. máx.:   %s\n", formatRate(response.Config.MaxRate, ""))
+	fmt.Printf("  Calibración: %s\n", formatCalibration(response.Config.Calibration))
+	fmt.Printf("  Estado:      %s\n", map[bool]string{true: "Habilitado", false: "Deshabilitado"}[response.Config.Enabled])
+
+	return nil
+}
+
+// configResponse es la respuesta de sensor.config.get: la configuración y la unidad del
+// umbral, o el error del servidor
+type configResponse struct {
+	sensor.SensorConfig
+	Unit  string `json:"unit,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// requestConfig pide la configuración de un sensor con el umbral en las unidades pedidas
+func requestConfig(ctx context.Context, client *natsclient.Client, sensorID string, units natsclient.UnitRequest) (*natslib.Msg, error) {
+	var data []byte
+	if units.Unit != "" || len(units.Units) > 0 {
+		var err error
+		if data, err = json.Marshal(units); err != nil {
+			return nil, fmt.Errorf("error preparando request: %w", err)
+		}
+	}
+	return client.Request(ctx, natsclient.ConfigGetSubject(sensorID), data)
+}
+
+// formatLimit formatea un límite de alerta con su unidad si se conoce
+func formatLimit(value float64, unit string) string {
+	return strings.TrimSpace(fmt.Sprintf("%.2f %s", value, unit))
+}
+
+// formatOptionalLimit formatea el umbral inferior ("-" si no hay)
+func formatOptionalLimit(value *float64, unit string) string {
+	if value == nil {
+		return "-"
+	}
+	return formatLimit(*value, unit)
+}
+
+// formatRate formatea la variación máxima por minuto ("-" si no hay límite)
+func formatRate(rate float64, unit string) string {
+	if rate == 0 {
+		return "-"
+	}
+	return formatLimit(rate, unit) + "/min"
+}
+
+// formatCalibration formatea los parámetros de calibración ("-" si no hay)
+func formatCalibration(c *sensor.Calibration) string {
+	if c.IsIdentity() {
+		return "-"
+	}
+	if len(c.Polynomial) > 0 {
+		coefs := make([]string, len(c.Polynomial))
+		for i, coef := range c.Polynomial {
+			coefs[i] = strconv.FormatFloat(coef, 'g', -1, 64)
+		}
+		return "polinomio [" + strings.Join(coefs, ", ") + "]"
+	}
+	gain := c.Gain
+	if gain == 0 {
+		gain = 1
+	}
+	return fmt.Sprintf("ganancia %g, offset %+g", gain, c.Offset)
+}
+
+// currentUser retorna el usuario del sistema para registrarlo como autor de los cambios
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "iot-cli"
+}
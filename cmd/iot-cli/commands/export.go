@@ -0,0 +1,375 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/config"
+	natsclient "github.com/alejandro/technical_test_uvigo/internal/nats"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/parquet-go/parquet-go"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var readingsExportCmd = &cobra.Command{
+	Use:   "export [sensor-id]",
+	Short: "Exportar lecturas a CSV, NDJSON o Parquet",
+	Long: `Exporta las lecturas crudas de un sensor (o de todos con --all) en el rango [--from, --to],
+de la más antigua a la más reciente. Las lecturas se piden al servidor por trozos y se escriben
+según llegan, así que el tamaño del rango no afecta a la memoria usada.
+
+--from y --to aceptan una fecha RFC3339, una fecha YYYY-MM-DD o una duración hacia atrás desde ahora (ej: 24h).`,
+	Example: `  iot-cli readings export temp-001 --from 24h -o temp-001.csv
+  iot-cli readings export --all --from 2025-01-01 --to 2025-02-01 --format parquet -o enero.parquet
+  iot-cli readings export hum-001 --from 168h --format ndjson | jq .value
+  iot-cli readings export --all --from 24h --unit °F -o lecturas.csv`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: exportReadings,
+}
+
+// Flags para export
+var (
+	exportAll       bool
+	exportFrom      string
+	exportTo        string
+	exportFormat    string
+	exportOutput    string
+	exportChunkSize int
+)
+
+// Filas por row group de Parquet: limita lo que el writer acumula en memoria
+const parquetRowGroupSize = 64 * 1024
+
+func init() {
+	readingsExportCmd.Flags().BoolVar(&exportAll, "all", false, "Exportar las lecturas de todos los sensores")
+	readingsExportCmd.Flags().StringVar(&exportFrom, "from", "", "Inicio del rango (requerido)")
+	readingsExportCmd.Flags().StringVar(&exportTo, "to", "", "Fin del rango (por defecto ahora)")
+	readingsExportCmd.Flags().StringVarP(&exportFormat, "format", "f", "csv", "Formato: csv, ndjson, parquet")
+	readingsExportCmd.Flags().StringVarP(&exportOutput, "output", "o", "-", "Fichero de salida (- = stdout)")
+	readingsExportCmd.Flags().IntVar(&exportChunkSize, "chunk-size", 1000, "Lecturas por petición al servidor (máximo 2000; el servidor puede enviar menos)")
+
+	readingsExportCmd.MarkFlagRequired("from")
+
+	readingsCmd.AddCommand(readingsExportCmd)
+}
+
+func exportReadings(cmd *cobra.Command, args []string) error {
+	if exportAll == (len(args) == 1) {
+		return fmt.Errorf("indica un sensor-id o --all")
+	}
+	if exportChunkSize <= 0 {
+		return fmt.Errorf("--chunk-size debe ser mayor que 0")
+	}
+	// Validar el formato antes de crear el fichero de salida
+	if _, err := newReadingWriter(exportFormat, io.Discard); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	from, err := parseTimeFlag(exportFrom, now)
+	if err != nil {
+		return fmt.Errorf("--from inválido: %w", err)
+	}
+	to := now
+	if exportTo != "" {
+		if to, err = parseTimeFlag(exportTo, now); err != nil {
+			return fmt.Errorf("--to inválido: %w", err)
+		}
+	}
+	if from.After(to) {
+		return fmt.Errorf("--from debe ser anterior a --to")
+	}
+	units, err := resolveUnits(readingsUnit)
+	if err != nil {
+		return err
+	}
+	if exportAll && units.Unit != "" {
+		// Con varios sensores --unit solo convierte los de su misma magnitud
+		units = natsclient.UnitRequest{Units: map[string]string{sensor.UnitDimension(units.Unit): units.Unit}}
+	}
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	sensorIDs := args
+	if exportAll {
+		if sensorIDs, err = fetchSensorIDs(client); err != nil {
+			return err
+		}
+	}
+
+	// Abrir salida
+	var out io.Writer = os.Stdout
+	if exportOutput != "-" {
+		file, err := os.Create(exportOutput)
+		if err != nil {
+			return fmt.Errorf("error creando %s: %w", exportOutput, err)
+		}
+		defer file.Close()
+		out = file
+	}
+	buffered := bufio.NewWriter(out)
+
+	writer, err := newReadingWriter(exportFormat, buffered)
+	if err != nil {
+		return err
+	}
+
+	total, err := streamReadings(client, writer, sensorIDs, from, to, units)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		// Un fichero a medias (sobre todo Parquet, sin footer) no es utilizable
+		if exportOutput != "-" {
+			os.Remove(exportOutput)
+		}
+		return err
+	}
+
+	if exportOutput != "-" {
+		printSuccess(fmt.Sprintf("Exportadas %d lecturas de %d sensores a %s", total, len(sensorIDs), exportOutput))
+	}
+	return nil
+}
+
+// streamReadings pide cada sensor a sensor.readings.export.<id> por trozos y los escribe según llegan
+func streamReadings(client *natsclient.Client, writer readingWriter, sensorIDs []string, from, to time.Time, units natsclient.UnitRequest) (int, error) {
+	total := 0
+	for _, sensorID := range sensorIDs {
+		cursor := ""
+		for {
+			page, err := fetchExportChunk(client, sensorID, from, to, cursor, units)
+			if err != nil {
+				return total, err
+			}
+			if err := writer.Write(page.Readings); err != nil {
+				return total, fmt.Errorf("error escribiendo lecturas: %w", err)
+			}
+			total += len(page.Readings)
+
+			log.WithFields(logrus.Fields{
+				"sensor_id": sensorID,
+				"chunk":     len(page.Readings),
+				"total":     total,
+			}).Debug("Trozo exportado")
+
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+	}
+	return total, nil
+}
+
+// fetchExportChunk pide el trozo de lecturas que sigue a cursor
+func fetchExportChunk(client *natsclient.Client, sensorID string, from, to time.Time, cursor string, units natsclient.UnitRequest) (*natsclient.ReadingsPage, error) {
+	data, err := json.Marshal(withUnits(map[string]interface{}{
+		"from":   from,
+		"to":     to,
+		"limit":  exportChunkSize,
+		"cursor": cursor,
+	}, units))
+	if err != nil {
+		return nil, fmt.Errorf("error preparando request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	msg, err := client.Request(ctx, natsclient.ReadingsExportSubject(sensorID), data)
+	if err != nil {
+		return nil, fmt.Errorf("error exportando lecturas de '%s': %w", sensorID, err)
+	}
+
+	var page struct {
+		natsclient.ReadingsPage
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(msg.Data, &page); err != nil {
+		return nil, fmt.Errorf("error parseando lecturas: %w", err)
+	}
+	if page.Error != "" {
+		return nil, fmt.Errorf("error del servidor: %s", page.Error)
+	}
+
+	return &page.ReadingsPage, nil
+}
+
+// fetchSensorIDs obtiene los IDs de todos los sensores de sensor.list, ordenados
+func fetchSensorIDs(client *natsclient.Client) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg, err := client.Request(ctx, natsclient.ListSubject(), []byte{})
+	if err != nil {
+		return nil, fmt.Errorf("error al listar sensores: %w", err)
+	}
+
+	var sensors []config.SensorDef
+	if err := json.Unmarshal(msg.Data, &sensors); err != nil {
+		return nil, fmt.Errorf("error parseando respuesta: %w", err)
+	}
+
+	ids := make([]string, 0, len(sensors))
+	for _, s := range sensors {
+		ids = append(ids, s.ID)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// parseTimeFlag acepta RFC3339, YYYY-MM-DD (UTC) o una duración hacia atrás desde now
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q no es una fecha RFC3339, YYYY-MM-DD ni una duración", value)
+}
+
+// readingWriter escribe lecturas en un formato de exportación
+type readingWriter interface {
+	Write(readings []*sensor.SensorReading) error
+	Close() error // Completa el fichero (cabeceras finales, footer...)
+}
+
+// newReadingWriter crea el writer del formato indicado sobre w
+func newReadingWriter(format string, w io.Writer) (readingWriter, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return newCSVReadingWriter(w)
+	case "ndjson":
+		return &ndjsonReadingWriter{enc: json.NewEncoder(w)}, nil
+	case "parquet":
+		return &parquetReadingWriter{w: parquet.NewGenericWriter[parquetReading](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize))}, nil
+	default:
+		return nil, fmt.Errorf("formato inválido: %s (debe ser: csv, ndjson, parquet)", format)
+	}
+}
+
+// csvReadingWriter escribe una fila por lectura con cabecera
+type csvReadingWriter struct {
+	w *csv.Writer
+}
+
+func newCSVReadingWriter(w io.Writer) (*csvReadingWriter, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "sensor_id", "type", "value", "raw_value", "unit", "error", "timestamp"}); err != nil {
+		return nil, err
+	}
+	return &csvReadingWriter{w: cw}, nil
+}
+
+func (c *csvReadingWriter) Write(readings []*sensor.SensorReading) error {
+	for _, r := range readings {
+		errorMsg := ""
+		if r.Error != nil {
+			errorMsg = *r.Error
+		}
+		raw := ""
+		if r.RawValue != nil {
+			raw = strconv.FormatFloat(*r.RawValue, 'f', -1, 64)
+		}
+		record := []string{
+			r.ID,
+			r.SensorID,
+			string(r.Type),
+			strconv.FormatFloat(r.Value, 'f', -1, 64),
+			raw,
+			r.Unit,
+			errorMsg,
+			r.Timestamp.UTC().Format(time.RFC3339Nano),
+		}
+		if err := c.w.Write(record); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvReadingWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonReadingWriter escribe una lectura JSON por línea
+type ndjsonReadingWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonReadingWriter) Write(readings []*sensor.SensorReading) error {
+	for _, r := range readings {
+		if err := n.enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n *ndjsonReadingWriter) Close() error {
+	return nil
+}
+
+// parquetReading es el schema de las filas Parquet
+type parquetReading struct {
+	ID        string    `parquet:"id"`
+	SensorID  string    `parquet:"sensor_id,dict"`
+	Type      string    `parquet:"type,dict"`
+	Value     float64   `parquet:"value"`
+	RawValue  *float64  `parquet:"raw_value,optional"`
+	Unit      string    `parquet:"unit,dict"`
+	Error     *string   `parquet:"error,optional"`
+	Timestamp time.Time `parquet:"timestamp,timestamp(nanosecond)"`
+}
+
+// parquetReadingWriter escribe row groups de como mucho parquetRowGroupSize filas
+type parquetReadingWriter struct {
+	w *parquet.GenericWriter[parquetReading]
+}
+
+func (p *parquetReadingWriter) Write(readings []*sensor.SensorReading) error {
+	rows := make([]parquetReading, len(readings))
+	for i, r := range readings {
+		rows[i] = parquetReading{
+			ID:        r.ID,
+			SensorID:  r.SensorID,
+			Type:      string(r.Type),
+			Value:     r.Value,
+			RawValue:  r.RawValue,
+			Unit:      r.Unit,
+			Error:     r.Error,
+			Timestamp: r.Timestamp.UTC(),
+		}
+	}
+	_, err := p.w.Write(rows)
+	return err
+}
+
+func (p *parquetReadingWriter) Close() error {
+	return p.w.Close()
+}
@@ -0,0 +1,340 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	natsclient "github.com/alejandro/technical_test_uvigo/internal/nats"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+var readingsImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Importar lecturas históricas desde CSV o NDJSON",
+	Long: `Importa lecturas históricas (por ejemplo, volcados del sistema anterior) en lotes.
+El servidor valida cada registro, descarta los ids repetidos o ya guardados y escribe cada
+lote en una transacción. Al final se muestran las lecturas aceptadas y los motivos de rechazo
+por línea.
+
+El CSV debe tener cabecera con las columnas id, sensor_id, type, value, unit, timestamp y,
+opcionalmente, raw_value y error (el formato de 'readings export'). En NDJSON cada línea es una lectura JSON.`,
+	Example: `  iot-cli readings import legacy-temp.csv
+  iot-cli readings import dump.ndjson --batch-size 500
+  cat dump.csv | iot-cli readings import - --format csv`,
+	Args: cobra.ExactArgs(1),
+	RunE: importReadings,
+}
+
+// Flags para import
+var (
+	importFormat    string
+	importBatchSize int
+)
+
+// Rechazos que se muestran en la tabla; el resto solo con --json
+const maxPrintedRejections = 20
+
+func init() {
+	readingsImportCmd.Flags().StringVarP(&importFormat, "format", "f", "", "Formato: csv, ndjson (por defecto según la extensión)")
+	readingsImportCmd.Flags().IntVar(&importBatchSize, "batch-size", 1000,
+		fmt.Sprintf("Lecturas por lote (máximo %d; los registros grandes se envían en lotes menores)", natsclient.MaxImportRecords))
+
+	readingsCmd.AddCommand(readingsImportCmd)
+}
+
+func importReadings(cmd *cobra.Command, args []string) error {
+	path := args[0]
+	if importBatchSize <= 0 || importBatchSize > natsclient.MaxImportRecords {
+		return fmt.Errorf("--batch-size debe estar entre 1 y %d", natsclient.MaxImportRecords)
+	}
+
+	format := strings.ToLower(importFormat)
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".csv":
+			format = "csv"
+		case ".ndjson", ".jsonl":
+			format = "ndjson"
+		default:
+			return fmt.Errorf("no se puede deducir el formato de '%s', usa --format", path)
+		}
+	}
+
+	// Abrir entrada
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error abriendo %s: %w", path, err)
+		}
+		defer file.Close()
+		in = file
+	}
+
+	reader, err := newReadingReader(format, bufio.NewReader(in))
+	if err != nil {
+		return err
+	}
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	total := natsclient.ImportResult{Rejected: []natsclient.ImportRejection{}}
+	batch := make([]natsclient.ImportRecord, 0, importBatchSize)
+	batchBytes := 0 // JSON de los registros del lote, para no superar el max_payload de NATS
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := sendImportBatch(client, batch)
+		if err != nil {
+			return err
+		}
+		total.Accepted += result.Accepted
+		total.Rejected = append(total.Rejected, result.Rejected...)
+		log.WithField("accepted", total.Accepted).Debug("Lote importado")
+		batch = batch[:0]
+		batchBytes = 0
+		return nil
+	}
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var lineErr *importLineError
+		if errors.As(err, &lineErr) {
+			// Registro ilegible: se rechaza sin enviarlo y se sigue con el resto
+			total.Rejected = append(total.Rejected, natsclient.ImportRejection{Line: lineErr.line, ID: lineErr.id, Reason: lineErr.reason})
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error leyendo %s: %w", path, err)
+		}
+
+		encoded, err := json.Marshal(record)
+		if err != nil {
+			total.Rejected = append(total.Rejected, natsclient.ImportRejection{Line: record.Line, ID: record.ID, Reason: fmt.Sprintf("invalid record: %v", err)})
+			continue
+		}
+		size := len(encoded) + 1 // Separador del array
+		if size > natsclient.MaxImportBytes {
+			total.Rejected = append(total.Rejected, natsclient.ImportRejection{Line: record.Line, ID: record.ID, Reason: fmt.Sprintf("record too large: %d bytes (max %d)", size, natsclient.MaxImportBytes)})
+			continue
+		}
+		if batchBytes+size > natsclient.MaxImportBytes {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+
+		batch = append(batch, *record)
+		batchBytes += size
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	sort.SliceStable(total.Rejected, func(i, j int) bool { return total.Rejected[i].Line < total.Rejected[j].Line })
+
+	if outputJSON {
+		jsonOutput, _ := json.MarshalIndent(total, "", "  ")
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+
+	printSuccess(fmt.Sprintf("Importadas %d lecturas (%d rechazadas)", total.Accepted, len(total.Rejected)))
+	if len(total.Rejected) == 0 {
+		return nil
+	}
+
+	fmt.Printf("\n⚠️  Registros rechazados:\n\n")
+	tbl := table.New("Línea", "ID", "Motivo")
+	for i, rejection := range total.Rejected {
+		if i == maxPrintedRejections {
+			break
+		}
+		tbl.AddRow(rejection.Line, rejection.ID, rejection.Reason)
+	}
+	tbl.Print()
+	if len(total.Rejected) > maxPrintedRejections {
+		fmt.Printf("\n... y %d más (usa --json para verlos todos)\n", len(total.Rejected)-maxPrintedRejections)
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// sendImportBatch envía un lote a sensor.readings.import
+func sendImportBatch(client *natsclient.Client, records []natsclient.ImportRecord) (*natsclient.ImportResult, error) {
+	data, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return nil, fmt.Errorf("error preparando request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	msg, err := client.Request(ctx, natsclient.ReadingsImportSubject(), data)
+	if err != nil {
+		return nil, fmt.Errorf("error importando lote (líneas %d-%d): %w", records[0].Line, records[len(records)-1].Line, err)
+	}
+
+	var result struct {
+		natsclient.ImportResult
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(msg.Data, &result); err != nil {
+		return nil, fmt.Errorf("error parseando respuesta: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("error del servidor: %s", result.Error)
+	}
+
+	return &result.ImportResult, nil
+}
+
+// importLineError indica un registro que no se ha podido parsear
+type importLineError struct {
+	line   int
+	id     string // Vacío si no se ha podido leer
+	reason string
+}
+
+func (e *importLineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.reason)
+}
+
+// readingReader lee registros de un fichero de importación. Next retorna io.EOF al
+// terminar y un *importLineError si un registro concreto no se puede parsear.
+type readingReader interface {
+	Next() (*natsclient.ImportRecord, error)
+}
+
+// newReadingReader crea el reader del formato indicado sobre r
+func newReadingReader(format string, r io.Reader) (readingReader, error) {
+	switch format {
+	case "csv":
+		return newCSVReadingReader(r)
+	case "ndjson":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		return &ndjsonReadingReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("formato inválido: %s (debe ser: csv, ndjson)", format)
+	}
+}
+
+// csvReadingReader lee filas con las columnas de la cabecera, en cualquier orden
+type csvReadingReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReadingReader(r io.Reader) (*csvReadingReader, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo la cabecera CSV: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, required := range []string{"id", "sensor_id", "type", "value", "unit", "timestamp"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("falta la columna '%s' en la cabecera CSV", required)
+		}
+	}
+
+	return &csvReadingReader{r: cr, columns: columns}, nil
+}
+
+func (c *csvReadingReader) Next() (*natsclient.ImportRecord, error) {
+	row, err := c.r.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &importLineError{line: parseErr.StartLine, reason: parseErr.Err.Error()}
+		}
+		return nil, err
+	}
+	line, _ := c.r.FieldPos(0)
+
+	record := &natsclient.ImportRecord{Line: line}
+	record.ID = row[c.columns["id"]]
+	record.SensorID = row[c.columns["sensor_id"]]
+	record.Type = sensor.SensorType(row[c.columns["type"]])
+	record.Unit = row[c.columns["unit"]]
+
+	if record.Value, err = strconv.ParseFloat(row[c.columns["value"]], 64); err != nil {
+		return nil, &importLineError{line: line, id: record.ID, reason: fmt.Sprintf("invalid value %q", row[c.columns["value"]])}
+	}
+	if i, ok := c.columns["raw_value"]; ok && row[i] != "" {
+		raw, err := strconv.ParseFloat(row[i], 64)
+		if err != nil {
+			return nil, &importLineError{line: line, id: record.ID, reason: fmt.Sprintf("invalid raw_value %q", row[i])}
+		}
+		record.RawValue = &raw
+	}
+	if ts := row[c.columns["timestamp"]]; ts != "" {
+		if record.Timestamp, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			return nil, &importLineError{line: line, id: record.ID, reason: fmt.Sprintf("invalid timestamp %q", ts)}
+		}
+	}
+	if i, ok := c.columns["error"]; ok && row[i] != "" {
+		errorMsg := row[i]
+		record.Error = &errorMsg
+	}
+
+	return record, nil
+}
+
+// ndjsonReadingReader lee una lectura JSON por línea, ignorando las líneas vacías
+type ndjsonReadingReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReadingReader) Next() (*natsclient.ImportRecord, error) {
+	for n.scanner.Scan() {
+		n.line++
+		text := strings.TrimSpace(n.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		record := &natsclient.ImportRecord{Line: n.line}
+		if err := json.Unmarshal([]byte(text), &record.SensorReading); err != nil {
+			return nil, &importLineError{line: n.line, reason: fmt.Sprintf("invalid JSON: %v", err)}
+		}
+		return record, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -42,9 +42,15 @@ func runInteractive(cmd *cobra.Command, args []string) error {
 	fmt.Println("\nComandos disponibles:")
 	fmt.Println("  sensor list")
 	fmt.Println("  sensor register --type <type> --id <id>")
+	fmt.Println("  sensor calibrate <sensor-id> --reference <valor>")
+	fmt.Println("  sensor remove <sensor-id> [--purge]")
 	fmt.Println("  config get <sensor-id>")
 	fmt.Println("  config set <sensor-id> --enabled=true --interval=3000")
+	fmt.Println("  config history <sensor-id>")
+	fmt.Println("  config rollback <sensor-id> [--revision N]")
 	fmt.Println("  readings latest <sensor-id> [limit]")
+	fmt.Println("  readings stats <sensor-id> --bucket 5m --since 2h")
+	fmt.Println("  alerts list --sensor <sensor-id> --severity critical")
 	fmt.Println("  help               - Mostrar ayuda")
 	fmt.Println("  exit               - Salir del modo interactivo")
 	fmt.Println()
@@ -137,17 +143,30 @@ func executeCommand(args []string) {
 }
 
 func showInteractiveHelp() {
-	fmt.Println("\n📖 Ayuda - Comandos disponibles:\n")
+	fmt.Print("\n📖 Ayuda - Comandos disponibles:\n\n")
 	fmt.Println("Sensores:")
 	fmt.Println("  sensor list                           - Listar todos los sensores")
 	fmt.Println("  sensor register --type TYPE --id ID   - Registrar nuevo sensor")
+	fmt.Println("  sensor calibrate SENSOR_ID --reference V | --point B:R --point B:R - Calibrar")
+	fmt.Println("  sensor remove SENSOR_ID [--purge]     - Dar de baja (y borrar datos)")
 	fmt.Println()
 	fmt.Println("Configuración:")
 	fmt.Println("  config get SENSOR_ID                  - Obtener config de un sensor")
 	fmt.Println("  config set SENSOR_ID [opciones]       - Actualizar config")
+	fmt.Println("  config history SENSOR_ID [--limit N]  - Historial de cambios")
+	fmt.Println("  config rollback SENSOR_ID [--revision N] - Restaurar revisión")
 	fmt.Println()
 	fmt.Println("Lecturas:")
 	fmt.Println("  readings latest SENSOR_ID [LIMIT]     - Últimas N lecturas")
+	fmt.Println("  readings SENSOR_ID --page N | --all   - Páginas anteriores / todas")
+	fmt.Println("  readings stats SENSOR_ID [opciones]   - Estadísticas por bucket")
+	fmt.Println("  readings export SENSOR_ID|--all --from 24h -o FILE - Exportar a CSV/NDJSON/Parquet")
+	fmt.Println("  readings import FILE [--format csv|ndjson] - Importar lecturas históricas")
+	fmt.Println("  readings search [--type T] [--location L] [--tag X] [--since 1h] - Buscar en varios sensores")
+	fmt.Println("  readings ... --unit °F|K|psi|kPa...   - Valores en otra unidad")
+	fmt.Println()
+	fmt.Println("Alertas:")
+	fmt.Println("  alerts list [opciones]                - Alertas persistidas")
 	fmt.Println()
 	fmt.Println("Otros:")
 	fmt.Println("  help, ?                               - Mostrar esta ayuda")
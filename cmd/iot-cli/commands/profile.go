@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	natsclient "github.com/alejandro/technical_test_uvigo/internal/nats"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/spf13/viper"
+)
+
+// cliProfile son las preferencias de un perfil del fichero de configuración del CLI
+// ($IOT_CLI_CONFIG o ~/.iot-cli.yaml):
+//
+//	default_profile: us
+//	profiles:
+//	  us:
+//	    units:
+//	      temperature: °F
+//	      pressure: psi
+type cliProfile struct {
+	Units map[string]string `mapstructure:"units"` // Unidad por magnitud (temperature, pressure, humidity)
+}
+
+// cliConfigPath retorna la ruta del fichero de configuración del CLI
+func cliConfigPath() string {
+	if path := os.Getenv("IOT_CLI_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".iot-cli.yaml")
+}
+
+// loadProfile carga el perfil indicado con --profile o, si no se indica, el
+// default_profile del fichero. Sin fichero ni --profile no hay perfil (nil).
+func loadProfile(name string) (*cliProfile, error) {
+	path := cliConfigPath()
+	if _, err := os.Stat(path); err != nil {
+		if name != "" {
+			return nil, fmt.Errorf("no se encuentra el perfil '%s': no existe el fichero de configuración %s", name, path)
+		}
+		return nil, nil
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error leyendo %s: %w", path, err)
+	}
+
+	if name == "" {
+		if name = v.GetString("default_profile"); name == "" {
+			return nil, nil
+		}
+	}
+
+	var profiles map[string]*cliProfile
+	if err := v.UnmarshalKey("profiles", &profiles); err != nil {
+		return nil, fmt.Errorf("error leyendo los perfiles de %s: %w", path, err)
+	}
+	profile, ok := profiles[name]
+	if !ok || profile == nil {
+		return nil, fmt.Errorf("el perfil '%s' no existe en %s", name, path)
+	}
+	return profile, nil
+}
+
+// resolveUnits retorna las unidades a pedir al servidor: la de --unit o, si no se
+// indica, las unidades por magnitud del perfil activo
+func resolveUnits(unit string) (natsclient.UnitRequest, error) {
+	if unit != "" {
+		parsed, err := sensor.ParseUnit(unit)
+		if err != nil {
+			return natsclient.UnitRequest{}, fmt.Errorf("--unit inválido: %w", err)
+		}
+		return natsclient.UnitRequest{Unit: parsed}, nil
+	}
+
+	profile, err := loadProfile(profileName)
+	if err != nil || profile == nil {
+		return natsclient.UnitRequest{}, err
+	}
+	for dimension, unit := range profile.Units {
+		parsed, err := sensor.ParseUnit(unit)
+		if err != nil {
+			return natsclient.UnitRequest{}, fmt.Errorf("unidad inválida en el perfil para %s: %w", dimension, err)
+		}
+		if sensor.UnitDimension(parsed) != dimension {
+			return natsclient.UnitRequest{}, fmt.Errorf("unidad inválida en el perfil: %s no es una unidad de %s", unit, dimension)
+		}
+		profile.Units[dimension] = parsed
+	}
+	return natsclient.UnitRequest{Units: profile.Units}, nil
+}
+
+// withUnits añade las unidades pedidas al cuerpo de una petición
+func withUnits(requestData map[string]interface{}, units natsclient.UnitRequest) map[string]interface{} {
+	if units.Unit != "" {
+		requestData["unit"] = units.Unit
+	}
+	if len(units.Units) > 0 {
+		requestData["units"] = units.Units
+	}
+	return requestData
+}
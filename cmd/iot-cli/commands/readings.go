@@ -15,58 +15,102 @@ import (
 var readingsCmd = &cobra.Command{
 	Use:   "readings [sensor-id]",
 	Short: "Consultar lecturas de un sensor",
-	Long:  `Obtiene las últimas N lecturas de un sensor específico`,
+	Long:  `Obtiene las lecturas de un sensor específico de la más reciente a la más antigua, en páginas de --limit lecturas`,
 	Args:  cobra.ExactArgs(1),
 	Example: `  iot-cli readings temp-001
   iot-cli readings temp-001 --limit 20
-  iot-cli readings temp-001 --json`,
+  iot-cli readings temp-001 --limit 20 --page 3
+  iot-cli readings temp-001 --all --json
+  iot-cli readings temp-001 --unit °F`,
 	RunE: getReadings,
 }
 
-var limit int
+var readingsStatsCmd = &cobra.Command{
+	Use:   "stats [sensor-id]",
+	Short: "Estadísticas agregadas por intervalo de tiempo",
+	Long:  `Obtiene min/max/avg/count/stddev de un sensor agrupados en buckets (1m, 5m, 1h, 1d), excluyendo lecturas con error`,
+	Args:  cobra.ExactArgs(1),
+	Example: `  iot-cli readings stats temp-001
+  iot-cli readings stats temp-001 --bucket 5m --since 2h
+  iot-cli readings stats temp-001 --bucket 1d --since 168h --json
+  iot-cli readings stats press-001 --unit psi`,
+	RunE: getReadingsStats,
+}
+
+// Flags para readings
+var (
+	limit       int
+	readingPage int
+	readingsAll bool
+)
+
+// readingsUnit es la unidad en la que mostrar las lecturas (común a los subcomandos)
+var readingsUnit string
+
+// Flags para stats
+var (
+	statsBucket string
+	statsSince  time.Duration
+)
 
 func init() {
-	readingsCmd.Flags().IntVarP(&limit, "limit", "l", 10, "Número máximo de lecturas a obtener")
+	readingsCmd.Flags().IntVarP(&limit, "limit", "l", 10, "Número máximo de lecturas por página")
+	readingsCmd.Flags().IntVarP(&readingPage, "page", "p", 1, "Página a obtener (1 = las más recientes)")
+	readingsCmd.Flags().BoolVar(&readingsAll, "all", false, "Recorrer todas las páginas hasta la lectura más antigua")
+	readingsCmd.MarkFlagsMutuallyExclusive("page", "all")
+	readingsCmd.PersistentFlags().StringVarP(&readingsUnit, "unit", "u", "", "Unidad de los valores (ej: °F, K, psi, kPa); por defecto la del perfil o la del sensor")
+
+	readingsStatsCmd.Flags().StringVarP(&statsBucket, "bucket", "b", "1h", "Tamaño del bucket: 1m, 5m, 1h, 1d")
+	readingsStatsCmd.Flags().DurationVar(&statsSince, "since", 24*time.Hour, "Ventana de tiempo hacia atrás desde ahora")
+
+	readingsCmd.AddCommand(readingsStatsCmd)
 }
 
 func getReadings(cmd *cobra.Command, args []string) error {
 	sensorID := args[0]
 
-	// Conectar a NATS
-	client, err := natsclient.NewClient(natsURL)
-	if err != nil {
-		return fmt.Errorf("error conectando a NATS: %w", err)
+	if limit <= 0 {
+		return fmt.Errorf("--limit debe ser mayor que 0")
+	}
+	if readingPage < 1 {
+		return fmt.Errorf("--page debe ser mayor o igual que 1")
 	}
-	defer client.Close()
 
-	// Preparar request
-	requestData := map[string]int{"limit": limit}
-	data, err := json.Marshal(requestData)
+	units, err := resolveUnits(readingsUnit)
 	if err != nil {
-		return fmt.Errorf("error preparando request: %w", err)
+		return err
 	}
 
-	// Enviar request a NATS
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	subject := natsclient.ReadingsQuerySubject(sensorID)
-	msg, err := client.Request(ctx, subject, data)
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
 	if err != nil {
-		return fmt.Errorf("error consultando lecturas: %w", err)
+		return fmt.Errorf("error conectando a NATS: %w", err)
 	}
+	defer client.Close()
 
-	// Parsear respuesta
+	// Recorrer páginas con el cursor hasta la pedida (o hasta el final con --all)
 	var readings []*sensor.SensorReading
-	if err := json.Unmarshal(msg.Data, &readings); err != nil {
-		// Verificar si es un mensaje de error
-		var errResp map[string]string
-		if json.Unmarshal(msg.Data, &errResp) == nil {
-			if errMsg, ok := errResp["error"]; ok {
-				return fmt.Errorf("error del servidor: %s", errMsg)
+	var page *natsclient.ReadingsPage
+	cursor := ""
+	for n := 1; ; n++ {
+		page, err = fetchReadingsPage(client, sensorID, cursor, limit, units)
+		if err != nil {
+			return err
+		}
+		if readingsAll {
+			readings = append(readings, page.Readings...)
+		} else if n == readingPage {
+			readings = page.Readings
+			break
+		}
+
+		if page.NextCursor == "" {
+			if !readingsAll && n < readingPage {
+				return fmt.Errorf("la página %d no existe: el sensor '%s' solo tiene %d páginas de %d lecturas", readingPage, sensorID, n, limit)
 			}
+			break
 		}
-		return fmt.Errorf("error parseando lecturas: %w", err)
+		cursor = page.NextCursor
 	}
 
 	if len(readings) == 0 {
@@ -75,23 +119,35 @@ func getReadings(cmd *cobra.Command, args []string) error {
 	}
 
 	if outputJSON {
-		jsonOutput, _ := json.MarshalIndent(readings, "", "  ")
+		jsonOutput, _ := json.MarshalIndent(natsclient.ReadingsPage{Readings: readings, NextCursor: page.NextCursor}, "", "  ")
 		fmt.Println(string(jsonOutput))
 	} else {
-		fmt.Printf("\n📈 Últimas %d lecturas del sensor '%s':\n\n", len(readings), sensorID)
+		switch {
+		case readingsAll:
+			fmt.Printf("\n📈 Todas las lecturas del sensor '%s' (%d):\n\n", sensorID, len(readings))
+		case readingPage > 1:
+			fmt.Printf("\n📈 Lecturas del sensor '%s' (página %d, %d lecturas):\n\n", sensorID, readingPage, len(readings))
+		default:
+			fmt.Printf("\n📈 Últimas %d lecturas del sensor '%s':\n\n", len(readings), sensorID)
+		}
 
-		tbl := table.New("ID", "Tipo", "Valor", "Unidad", "Timestamp", "Error")
+		tbl := table.New("ID", "Tipo", "Valor", "Bruto", "Unidad", "Timestamp", "Error")
 		for _, reading := range readings {
 			timestamp := reading.Timestamp.Format("2006-01-02 15:04:05")
 			errorMsg := "-"
 			if reading.Error != nil {
 				errorMsg = *reading.Error
 			}
+			raw := "-"
+			if reading.RawValue != nil {
+				raw = fmt.Sprintf("%.2f", *reading.RawValue)
+			}
 
 			tbl.AddRow(
 				reading.ID,
 				string(reading.Type),
 				fmt.Sprintf("%.2f", reading.Value),
+				raw,
 				reading.Unit,
 				timestamp,
 				errorMsg,
@@ -129,15 +185,142 @@ func getReadings(cmd *cobra.Command, args []string) error {
 		if validReadings > 0 {
 			avg := sum / float64(validReadings)
 			fmt.Printf("📊 Estadísticas:\n")
-			fmt.Printf("  Promedio: %.2f\n", avg)
-			fmt.Printf("  Máximo:   %.2f\n", max)
-			fmt.Printf("  Mínimo:   %.2f\n", min)
+			unit := readings[0].Unit
+			fmt.Printf("  Promedio: %.2f %s\n", avg, unit)
+			fmt.Printf("  Máximo:   %.2f %s\n", max, unit)
+			fmt.Printf("  Mínimo:   %.2f %s\n", min, unit)
 			if errorCount > 0 {
 				fmt.Printf("  Errores:  %d/%d (%.1f%%)\n", errorCount, len(readings), float64(errorCount)/float64(len(readings))*100)
 			}
 			fmt.Println()
 		}
+
+		if page.NextCursor != "" {
+			fmt.Printf("➡️  Hay lecturas más antiguas: usa --page %d o --all\n\n", readingPage+1)
+		}
+	}
+
+	return nil
+}
+
+// fetchReadingsPage pide a sensor.readings.query.<id> la página que empieza tras cursor
+func fetchReadingsPage(client *natsclient.Client, sensorID, cursor string, limit int, units natsclient.UnitRequest) (*natsclient.ReadingsPage, error) {
+	// Preparar request
+	requestData := withUnits(map[string]interface{}{"limit": limit}, units)
+	if cursor != "" {
+		requestData["cursor"] = cursor
+	}
+	data, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, fmt.Errorf("error preparando request: %w", err)
+	}
+
+	// Enviar request a NATS
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subject := natsclient.ReadingsQuerySubject(sensorID)
+	msg, err := client.Request(ctx, subject, data)
+	if err != nil {
+		return nil, fmt.Errorf("error consultando lecturas: %w", err)
+	}
+
+	// Parsear respuesta (los errores llegan como {"error": "..."})
+	var page struct {
+		natsclient.ReadingsPage
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(msg.Data, &page); err != nil {
+		return nil, fmt.Errorf("error parseando lecturas: %w", err)
+	}
+	if page.Error != "" {
+		return nil, fmt.Errorf("error del servidor: %s", page.Error)
+	}
+
+	return &page.ReadingsPage, nil
+}
+
+func getReadingsStats(cmd *cobra.Command, args []string) error {
+	sensorID := args[0]
+
+	if statsSince <= 0 {
+		return fmt.Errorf("--since debe ser mayor que 0")
+	}
+	units, err := resolveUnits(readingsUnit)
+	if err != nil {
+		return err
+	}
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	// Preparar request
+	end := time.Now().UTC()
+	requestData := withUnits(map[string]interface{}{
+		"bucket": statsBucket,
+		"start":  end.Add(-statsSince),
+		"end":    end,
+	}, units)
+	data, err := json.Marshal(requestData)
+	if err != nil {
+		return fmt.Errorf("error preparando request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	subject := natsclient.ReadingsStatsSubject(sensorID)
+	msg, err := client.Request(ctx, subject, data)
+	if err != nil {
+		return fmt.Errorf("error consultando estadísticas: %w", err)
+	}
+
+	// Parsear respuesta
+	var aggregates []*sensor.ReadingAggregate
+	if err := json.Unmarshal(msg.Data, &aggregates); err != nil {
+		var errResp map[string]string
+		if json.Unmarshal(msg.Data, &errResp) == nil {
+			if errMsg, ok := errResp["error"]; ok {
+				return fmt.Errorf("error del servidor: %s", errMsg)
+			}
+		}
+		return fmt.Errorf("error parseando estadísticas: %w", err)
+	}
+
+	if outputJSON {
+		jsonOutput, _ := json.MarshalIndent(aggregates, "", "  ")
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+
+	if len(aggregates) == 0 {
+		fmt.Printf("\n⚠️  No hay lecturas válidas para el sensor '%s' en las últimas %s\n\n", sensorID, statsSince)
+		return nil
+	}
+
+	unit := ""
+	if aggregates[0].Unit != "" {
+		unit = ", en " + aggregates[0].Unit
+	}
+	fmt.Printf("\n📊 Estadísticas del sensor '%s' (bucket %s, últimas %s%s):\n\n", sensorID, statsBucket, statsSince, unit)
+
+	tbl := table.New("Bucket", "Lecturas", "Mínimo", "Máximo", "Promedio", "Desv. típica")
+	for _, agg := range aggregates {
+		tbl.AddRow(
+			agg.BucketStart.Local().Format("2006-01-02 15:04"),
+			agg.Count,
+			fmt.Sprintf("%.2f", agg.Min),
+			fmt.Sprintf("%.2f", agg.Max),
+			fmt.Sprintf("%.2f", agg.Avg),
+			fmt.Sprintf("%.2f", agg.StdDev),
+		)
 	}
+	tbl.Print()
+	fmt.Println()
 
 	return nil
 }
@@ -9,10 +9,11 @@ import (
 )
 
 var (
-	natsURL    string
-	outputJSON bool
-	debug      bool
-	log        *logrus.Logger
+	natsURL     string
+	outputJSON  bool
+	debug       bool
+	profileName string
+	log         *logrus.Logger
 )
 
 var rootCmd *cobra.Command
@@ -32,6 +33,8 @@ func createRootCommand() *cobra.Command {
 	if envURL := os.Getenv("NATS_URL"); envURL != "" {
 		defaultNatsURL = envURL
 	}
+	// Perfil de preferencias (unidades) de IOT_CLI_PROFILE si existe
+	defaultProfile := os.Getenv("IOT_CLI_PROFILE")
 
 	cmd := &cobra.Command{
 		Use:   "iot-cli",
@@ -54,11 +57,13 @@ y gestionar el sistema de forma remota.`,
 	cmd.PersistentFlags().StringVar(&natsURL, "nats-url", defaultNatsURL, "URL del servidor NATS")
 	cmd.PersistentFlags().BoolVar(&outputJSON, "json", false, "Output en formato JSON")
 	cmd.PersistentFlags().BoolVar(&debug, "debug", false, "Activar modo debug (logs verbosos)")
+	cmd.PersistentFlags().StringVar(&profileName, "profile", defaultProfile, "Perfil de ~/.iot-cli.yaml (o $IOT_CLI_CONFIG) con las unidades por defecto")
 
 	// Añadir subcomandos
 	cmd.AddCommand(sensorCmd)
 	cmd.AddCommand(configCmd)
 	cmd.AddCommand(readingsCmd)
+	cmd.AddCommand(alertsCmd)
 
 	return cmd
 }
@@ -0,0 +1,165 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	natsclient "github.com/alejandro/technical_test_uvigo/internal/nats"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+)
+
+var readingsSearchCmd = &cobra.Command{
+	Use:   "search",
+	Short: "Buscar lecturas por tipo, ubicación y etiquetas de sensor",
+	Long: `Busca lecturas de todos los sensores cuyos metadatos cumplen los filtros, de la más
+reciente a la más antigua. Con varias --tag el sensor debe tener todas. Solo aparecen los
+sensores con metadatos guardados: los registrados con 'sensor register' y los del YAML.
+Con --unit solo se convierten las lecturas de magnitudes compatibles con esa unidad.`,
+	Example: `  iot-cli readings search --type temperature --location almacen --since 1h
+  iot-cli readings search --tag critico --tag norte --limit 100
+  iot-cli readings search --type humidity --since 24h --all --json
+  iot-cli readings search --type temperature --unit °F`,
+	Args: cobra.NoArgs,
+	RunE: searchReadings,
+}
+
+// Flags para search
+var (
+	searchType     string
+	searchLocation string
+	searchTags     []string
+	searchSince    time.Duration
+	searchLimit    int
+	searchAll      bool
+)
+
+func init() {
+	readingsSearchCmd.Flags().StringVar(&searchType, "type", "", fmt.Sprintf("Tipo de sensor: %s o uno declarado en sensor_types del servidor", strings.Join(sensor.TypeNames(), ", ")))
+	readingsSearchCmd.Flags().StringVar(&searchLocation, "location", "", "Ubicación del sensor")
+	readingsSearchCmd.Flags().StringArrayVar(&searchTags, "tag", nil, "Etiqueta requerida (repetible)")
+	readingsSearchCmd.Flags().DurationVar(&searchSince, "since", time.Hour, "Ventana de tiempo hacia atrás desde ahora (0 = sin límite)")
+	readingsSearchCmd.Flags().IntVarP(&searchLimit, "limit", "l", 50, "Número máximo de lecturas por página (máximo 1000)")
+	readingsSearchCmd.Flags().BoolVar(&searchAll, "all", false, "Recorrer todas las páginas")
+
+	readingsCmd.AddCommand(readingsSearchCmd)
+}
+
+func searchReadings(cmd *cobra.Command, args []string) error {
+	if searchLimit <= 0 || searchLimit > 1000 {
+		return fmt.Errorf("--limit debe estar entre 1 y 1000")
+	}
+	if searchSince < 0 {
+		return fmt.Errorf("--since no puede ser negativo")
+	}
+
+	units, err := resolveUnits(readingsUnit)
+	if err != nil {
+		return err
+	}
+
+	requestData := withUnits(map[string]interface{}{"limit": searchLimit}, units)
+	if searchType != "" {
+		requestData["type"] = searchType
+	}
+	if searchLocation != "" {
+		requestData["location"] = searchLocation
+	}
+	if len(searchTags) > 0 {
+		requestData["tags"] = searchTags
+	}
+	if searchSince > 0 {
+		requestData["start"] = time.Now().UTC().Add(-searchSince)
+	}
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	// Primera página y, con --all, el resto siguiendo el cursor
+	var readings []*sensor.SensorReading
+	var page *natsclient.ReadingsPage
+	for {
+		page, err = fetchSearchPage(client, requestData)
+		if err != nil {
+			return err
+		}
+		readings = append(readings, page.Readings...)
+		if !searchAll || page.NextCursor == "" {
+			break
+		}
+		requestData["cursor"] = page.NextCursor
+	}
+
+	if outputJSON {
+		jsonOutput, _ := json.MarshalIndent(natsclient.ReadingsPage{Readings: readings, NextCursor: page.NextCursor}, "", "  ")
+		fmt.Println(string(jsonOutput))
+		return nil
+	}
+
+	if len(readings) == 0 {
+		fmt.Printf("\n⚠️  No hay lecturas que cumplan los filtros\n\n")
+		return nil
+	}
+
+	fmt.Printf("\n🔎 Lecturas encontradas (%d):\n\n", len(readings))
+	tbl := table.New("Sensor", "Tipo", "Valor", "Unidad", "Timestamp", "Error")
+	for _, reading := range readings {
+		errorMsg := "-"
+		if reading.Error != nil {
+			errorMsg = *reading.Error
+		}
+		tbl.AddRow(
+			reading.SensorID,
+			string(reading.Type),
+			fmt.Sprintf("%.2f", reading.Value),
+			reading.Unit,
+			reading.Timestamp.Format("2006-01-02 15:04:05"),
+			errorMsg,
+		)
+	}
+	tbl.Print()
+
+	if page.NextCursor != "" {
+		fmt.Printf("\nHay más lecturas: usa --all o un --limit mayor\n")
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// fetchSearchPage envía una búsqueda a sensor.readings.search
+func fetchSearchPage(client *natsclient.Client, requestData map[string]interface{}) (*natsclient.ReadingsPage, error) {
+	data, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, fmt.Errorf("error preparando request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	msg, err := client.Request(ctx, natsclient.ReadingsSearchSubject(), data)
+	if err != nil {
+		return nil, fmt.Errorf("error buscando lecturas: %w", err)
+	}
+
+	var page struct {
+		natsclient.ReadingsPage
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(msg.Data, &page); err != nil {
+		return nil, fmt.Errorf("error parseando lecturas: %w", err)
+	}
+	if page.Error != "" {
+		return nil, fmt.Errorf("error del servidor: %s", page.Error)
+	}
+
+	return &page.ReadingsPage, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alejandro/technical_test_uvigo/internal/config"
@@ -17,7 +19,7 @@ import (
 var sensorCmd = &cobra.Command{
 	Use:   "sensor",
 	Short: "Gestionar sensores",
-	Long:  `Comandos para listar, registrar y eliminar sensores del sistema`,
+	Long:  `Comandos para listar, registrar, calibrar y eliminar sensores del sistema`,
 }
 
 var registerSensorCmd = &cobra.Command{
@@ -25,7 +27,8 @@ var registerSensorCmd = &cobra.Command{
 	Short: "Registrar un nuevo sensor",
 	Long:  `Registra un nuevo sensor en el sistema de forma dinámica`,
 	Example: `  iot-cli sensor register --id temp-005 --type temperature --name "Sala 5" --interval 5000 --threshold 30.0
-  iot-cli sensor register --id hum-003 --type humidity --interval 3000 --threshold 70`,
+  iot-cli sensor register --id hum-003 --type humidity --interval 3000
+  iot-cli sensor register --id temp-006 --type temperature --location almacen --tags critico,norte`,
 	RunE: registerSensor,
 }
 
@@ -36,31 +39,94 @@ var listSensorsCmd = &cobra.Command{
 	RunE:  listSensors,
 }
 
+var removeSensorCmd = &cobra.Command{
+	Use:   "remove <sensor-id>",
+	Short: "Dar de baja un sensor",
+	Long: `Detiene el sensor en el simulador y elimina su registro y configuración.
+Con --purge se borran también sus lecturas, alertas e historial de configuración.`,
+	Example: `  iot-cli sensor remove temp-005
+  iot-cli sensor remove temp-005 --purge`,
+	Args: cobra.ExactArgs(1),
+	RunE: removeSensor,
+}
+
+var calibrateSensorCmd = &cobra.Command{
+	Use:   "calibrate <sensor-id>",
+	Short: "Calibrar un sensor con medidas de referencia",
+	Long: `Calcula la calibración de un sensor a partir de medidas de referencia y la guarda como
+una nueva revisión de su configuración.
+
+Con --reference se ajusta el offset para que el valor bruto (--raw o, si no se indica, la
+media de las últimas --samples lecturas) dé la medida de referencia. Con dos --point
+bruto:referencia se calculan la ganancia y el offset. Con --unit las medidas se indican en
+esa unidad; la calibración se guarda en la del sensor.`,
+	Example: `  iot-cli sensor calibrate temp-001 --reference 21.5
+  iot-cli sensor calibrate temp-001 --reference 70.7 --unit °F --samples 10
+  iot-cli sensor calibrate co2-001 --point 410:400 --point 1950:2000
+  iot-cli sensor calibrate temp-001 --reference 21.5 --raw 22.1 --dry-run`,
+	Args: cobra.ExactArgs(1),
+	RunE: calibrateSensor,
+}
+
 // Flags para register
 var (
 	sensorID   string
 	sensorType string
 	sensorName string
+	location   string
+	sensorTags []string
 	interval   int
 	threshold  float64
 	enabled    bool
 )
 
+// Flags para remove
+var purgeData bool
+
+// Flags para calibrate
+var (
+	calibrateReference float64
+	calibrateRaw       float64
+	calibrateSamples   int
+	calibratePoints    []string
+	calibrateUnit      string
+	calibrateDryRun    bool
+)
+
 func init() {
 	// Flags para register
 	registerSensorCmd.Flags().StringVar(&sensorID, "id", "", "ID único del sensor (requerido)")
-	registerSensorCmd.Flags().StringVar(&sensorType, "type", "", "Tipo de sensor: temperature, humidity, pressure (requerido)")
+	registerSensorCmd.Flags().StringVar(&sensorType, "type", "", fmt.Sprintf("Tipo de sensor: %s o uno declarado en sensor_types del servidor (requerido)", strings.Join(sensor.TypeNames(), ", ")))
 	registerSensorCmd.Flags().StringVar(&sensorName, "name", "", "Nombre descriptivo del sensor")
+	registerSensorCmd.Flags().StringVar(&location, "location", "", "Ubicación del sensor")
+	registerSensorCmd.Flags().StringSliceVar(&sensorTags, "tags", nil, "Etiquetas del sensor separadas por comas")
 	registerSensorCmd.Flags().IntVar(&interval, "interval", 5000, "Intervalo de muestreo en milisegundos")
-	registerSensorCmd.Flags().Float64Var(&threshold, "threshold", 30.0, "Umbral de alerta")
+	registerSensorCmd.Flags().Float64Var(&threshold, "threshold", 0, "Umbral de alerta (por defecto el del tipo de sensor)")
 	registerSensorCmd.Flags().BoolVar(&enabled, "enabled", true, "Habilitar sensor")
 
 	registerSensorCmd.MarkFlagRequired("id")
 	registerSensorCmd.MarkFlagRequired("type")
 
+	// Flags para remove
+	removeSensorCmd.Flags().BoolVar(&purgeData, "purge", false, "Borrar también lecturas, alertas e historial del sensor")
+
+	// Flags para calibrate
+	calibrateSensorCmd.Flags().Float64Var(&calibrateReference, "reference", 0, "Medida de referencia (calibración de un punto)")
+	calibrateSensorCmd.Flags().Float64Var(&calibrateRaw, "raw", 0, "Valor bruto medido junto a la referencia (por defecto la media de las últimas lecturas)")
+	calibrateSensorCmd.Flags().IntVar(&calibrateSamples, "samples", 1, "Lecturas a promediar si no se indica --raw")
+	calibrateSensorCmd.Flags().StringArrayVar(&calibratePoints, "point", nil, "Punto bruto:referencia (calibración de dos puntos, indicar dos veces)")
+	calibrateSensorCmd.Flags().StringVarP(&calibrateUnit, "unit", "u", "", "Unidad de las medidas (ej: °F); por defecto la del sensor")
+	calibrateSensorCmd.Flags().BoolVar(&calibrateDryRun, "dry-run", false, "Calcular la calibración sin guardarla")
+	calibrateSensorCmd.MarkFlagsOneRequired("reference", "point")
+	calibrateSensorCmd.MarkFlagsMutuallyExclusive("reference", "point")
+	calibrateSensorCmd.MarkFlagsMutuallyExclusive("raw", "point")
+	calibrateSensorCmd.MarkFlagsMutuallyExclusive("samples", "point")
+
 	// Añadir subcomandos
 	sensorCmd.AddCommand(registerSensorCmd)
 	sensorCmd.AddCommand(listSensorsCmd)
+	sensorCmd.AddCommand(calibrateSensorCmd)
+	sensorCmd.AddCommand(removeSensorCmd)
 }
 
 func registerSensor(cmd *cobra.Command, args []string) error {
@@ -69,24 +135,13 @@ func registerSensor(cmd *cobra.Command, args []string) error {
 		"type":      sensorType,
 	}).Debug("Registrando nuevo sensor")
 
-	// Validar tipo de sensor
-	var st sensor.SensorType
-	switch sensorType {
-	case "temperature":
-		st = sensor.SensorTypeTemperature
-	case "humidity":
-		st = sensor.SensorTypeHumidity
-	case "pressure":
-		st = sensor.SensorTypePressure
-	default:
-		return fmt.Errorf("tipo de sensor inválido: %s (debe ser: temperature, humidity, pressure)", sensorType)
-	}
-
-	// Crear definición del sensor
+	// El servidor valida el tipo contra su catálogo (puede declarar tipos en sensor_types)
 	sensorDef := config.SensorDef{
-		ID:   sensorID,
-		Type: st,
-		Name: sensorName,
+		ID:       sensorID,
+		Type:     sensor.SensorType(sensorType),
+		Name:     sensorName,
+		Location: location,
+		Tags:     sensorTags,
 		Config: sensor.SensorConfig{
 			SensorID:  sensorID,
 			Interval:  interval,
@@ -105,8 +160,16 @@ func registerSensor(cmd *cobra.Command, args []string) error {
 	defer client.Close()
 	log.Debug("Conexión a NATS establecida")
 
-	// Serializar sensor
-	data, err := json.Marshal(sensorDef)
+	// Serializar sensor. Sin --threshold se omite el umbral para que el servidor aplique
+	// el del tipo (el campo Config exterior prevalece sobre el del SensorDef embebido).
+	var payload interface{} = sensorDef
+	if !cmd.Flags().Changed("threshold") {
+		payload = struct {
+			config.SensorDef
+			Config map[string]interface{}
+		}{sensorDef, map[string]interface{}{"sensor_id": sensorID, "interval": interval, "enabled": enabled}}
+	}
+	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("error serializando sensor: %w", err)
 	}
@@ -141,7 +204,16 @@ func registerSensor(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  ID:        %s\n", sensorID)
 		fmt.Printf("  Tipo:      %s\n", sensorType)
 		fmt.Printf("  Nombre:    %s\n", sensorName)
+		if location != "" {
+			fmt.Printf("  Ubicación: %s\n", location)
+		}
+		if len(sensorTags) > 0 {
+			fmt.Printf("  Etiquetas: %s\n", strings.Join(sensorTags, ", "))
+		}
 		fmt.Printf("  Interval:  %dms\n", interval)
+		if t, ok := response["threshold"].(float64); ok {
+			threshold = t
+		}
 		fmt.Printf("  Threshold: %.2f\n", threshold)
 		fmt.Printf("  Estado:    %s\n", map[bool]string{true: "Habilitado", false: "Deshabilitado"}[enabled])
 	}
@@ -219,3 +291,173 @@ func listSensors(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+func removeSensor(cmd *cobra.Command, args []string) error {
+	sensorID := args[0]
+
+	log.WithFields(logrus.Fields{
+		"sensor_id": sensorID,
+		"purge":     purgeData,
+	}).Debug("Dando de baja sensor")
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	data, err := json.Marshal(map[string]interface{}{
+		"purge":      purgeData,
+		"changed_by": currentUser(),
+	})
+	if err != nil {
+		return fmt.Errorf("error preparando request: %w", err)
+	}
+
+	// El purge puede tardar en sensores con muchas lecturas
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	subject := natsclient.RemoveSubject(sensorID)
+	msg, err := client.Request(ctx, subject, data)
+	if err != nil {
+		return fmt.Errorf("error dando de baja el sensor: %w", err)
+	}
+
+	// Verificar respuesta
+	var response struct {
+		Error           string `json:"error"`
+		Purged          bool   `json:"purged"`
+		ReadingsDeleted int64  `json:"readings_deleted"`
+	}
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		return fmt.Errorf("error parseando respuesta: %w", err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("error del servidor: %s", response.Error)
+	}
+
+	if outputJSON {
+		fmt.Println(string(msg.Data))
+		return nil
+	}
+
+	printSuccess(fmt.Sprintf("Sensor '%s' dado de baja", sensorID))
+	if response.Purged {
+		fmt.Printf("  🗑️  Lecturas eliminadas: %d (también alertas e historial de configuración)\n", response.ReadingsDeleted)
+	} else {
+		fmt.Println("  ℹ️  Los datos históricos se conservan (usa --purge para borrarlos)")
+	}
+	fmt.Println("  ⚠️  Si el sensor está definido en el YAML del servidor volverá a crearse al reiniciar")
+
+	return nil
+}
+
+func calibrateSensor(cmd *cobra.Command, args []string) error {
+	sensorID := args[0]
+
+	req := natsclient.CalibrateRequest{
+		DryRun:    calibrateDryRun,
+		ChangedBy: currentUser(),
+	}
+	if calibrateUnit != "" {
+		unit, err := sensor.ParseUnit(calibrateUnit)
+		if err != nil {
+			return fmt.Errorf("--unit inválido: %w", err)
+		}
+		req.Unit = unit
+	}
+	if cmd.Flags().Changed("reference") {
+		req.Reference = &calibrateReference
+		req.Samples = calibrateSamples
+		if cmd.Flags().Changed("raw") {
+			req.Raw = &calibrateRaw
+		}
+	} else {
+		if len(calibratePoints) != 2 {
+			return fmt.Errorf("la calibración de dos puntos requiere exactamente dos --point")
+		}
+		for _, p := range calibratePoints {
+			point, err := parseCalibrationPoint(p)
+			if err != nil {
+				return err
+			}
+			req.Points = append(req.Points, point)
+		}
+	}
+
+	log.WithFields(logrus.Fields{
+		"sensor_id": sensorID,
+		"dry_run":   calibrateDryRun,
+	}).Debug("Calibrando sensor")
+
+	// Conectar a NATS
+	client, err := natsclient.NewClient(natsURL)
+	if err != nil {
+		return fmt.Errorf("error conectando a NATS: %w", err)
+	}
+	defer client.Close()
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("error preparando request: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msg, err := client.Request(ctx, natsclient.CalibrateSubject(sensorID), data)
+	if err != nil {
+		return fmt.Errorf("error calibrando el sensor: %w", err)
+	}
+
+	// Verificar respuesta
+	var response natsclient.CalibrateResponse
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		return fmt.Errorf("error parseando respuesta: %w", err)
+	}
+	if response.Error != "" {
+		return fmt.Errorf("error del servidor: %s", response.Error)
+	}
+
+	if outputJSON {
+		fmt.Println(string(msg.Data))
+		return nil
+	}
+
+	if response.Applied {
+		printSuccess(fmt.Sprintf("Sensor '%s' calibrado", sensorID))
+	} else {
+		fmt.Printf("\n🧪 Calibración calculada para el sensor '%s' (no se ha guardado)\n", sensorID)
+	}
+	fmt.Println()
+	if response.Raw != nil {
+		fmt.Printf("  Valor bruto: %s", formatLimit(*response.Raw, response.Unit))
+		if response.Samples > 0 {
+			fmt.Printf(" (media de %d lecturas)", response.Samples)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("  Anterior:    %s\n", formatCalibration(response.Previous))
+	fmt.Printf("  Nueva:       %s\n", formatCalibration(response.Calibration))
+
+	return nil
+}
+
+// parseCalibrationPoint parsea un punto de calibración con el formato bruto:referencia
+func parseCalibrationPoint(s string) (natsclient.CalibrationPoint, error) {
+	rawStr, refStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return natsclient.CalibrationPoint{}, fmt.Errorf("--point inválido '%s': el formato es bruto:referencia", s)
+	}
+	raw, err := strconv.ParseFloat(strings.TrimSpace(rawStr), 64)
+	if err != nil {
+		return natsclient.CalibrationPoint{}, fmt.Errorf("--point inválido '%s': valor bruto no numérico", s)
+	}
+	reference, err := strconv.ParseFloat(strings.TrimSpace(refStr), 64)
+	if err != nil {
+		return natsclient.CalibrationPoint{}, fmt.Errorf("--point inválido '%s': referencia no numérica", s)
+	}
+	return natsclient.CalibrationPoint{Raw: raw, Reference: reference}, nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/alejandro/technical_test_uvigo/internal/app"
 	"github.com/alejandro/technical_test_uvigo/internal/config"
 	"github.com/alejandro/technical_test_uvigo/internal/logger"
@@ -19,6 +21,22 @@ func main() {
 	// 3. Reconfigurar logger con valores del config
 	logger.Init(cfg.Logging.Level, cfg.Logging.Format)
 
+	// Subcomando de mantenimiento: iot-server migrate <status|up|down>
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(cfg, os.Args[2:]); err != nil {
+			logger.Fatalf("Migration error: %v", err)
+		}
+		return
+	}
+
+	// Subcomando de mantenimiento: iot-server restore <snapshot>
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		if err := runRestore(cfg, os.Args[2:]); err != nil {
+			logger.Fatalf("Restore error: %v", err)
+		}
+		return
+	}
+
 	// 4. Crear y ejecutar servidor
 	server := app.NewServer(cfg)
 	if err := server.Run(); err != nil {
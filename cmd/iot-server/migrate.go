@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/alejandro/technical_test_uvigo/internal/config"
+	"github.com/alejandro/technical_test_uvigo/internal/storage"
+)
+
+const migrateUsage = `Uso: iot-server migrate <status|up|down> [opciones]
+
+  status            Muestra las migraciones aplicadas y pendientes
+  up [-to N]        Aplica las migraciones pendientes (hasta la versión N)
+  down [-steps N]   Revierte las últimas N migraciones (por defecto 1)`
+
+// runMigrate gestiona las migraciones del schema SQLite configurado
+func runMigrate(cfg *config.Config, args []string) error {
+	if cfg.Database.Type != "sqlite" {
+		return fmt.Errorf("migrations are only supported for sqlite (database.type=%s)", cfg.Database.Type)
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return fmt.Errorf("missing migrate action")
+	}
+
+	action := args[0]
+	flags := flag.NewFlagSet("migrate "+action, flag.ContinueOnError)
+	to := flags.Int("to", 0, "Versión objetivo para up (0 = todas)")
+	steps := flags.Int("steps", 1, "Número de migraciones a revertir con down")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	migrator, err := storage.OpenMigrator(cfg.Database.Path)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	ctx := context.Background()
+
+	switch action {
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range status {
+			state, appliedAt := "pending", "-"
+			if s.Applied {
+				state, appliedAt = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+		}
+		return w.Flush()
+
+	case "up":
+		applied, err := migrator.Up(ctx, *to)
+		for _, m := range applied {
+			fmt.Printf("✓ Applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		return nil
+
+	case "down":
+		reverted, err := migrator.Down(ctx, *steps)
+		for _, m := range reverted {
+			fmt.Printf("✓ Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No migrations to roll back")
+		}
+		return nil
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/alejandro/technical_test_uvigo/internal/backup"
+	"github.com/alejandro/technical_test_uvigo/internal/config"
+	"github.com/alejandro/technical_test_uvigo/internal/storage"
+)
+
+const restoreUsage = `Uso: iot-server restore [-check] <snapshot>
+
+  Sin argumentos lista los snapshots de database.backup.dir.
+  El servidor debe estar parado; la base de datos actual se conserva como <path>.pre-restore.
+
+  -check   Solo valida el snapshot, sin restaurarlo`
+
+// runRestore valida un snapshot y lo restaura sobre la base de datos SQLite configurada
+func runRestore(cfg *config.Config, args []string) error {
+	if cfg.Database.Type != "sqlite" {
+		return fmt.Errorf("restore is only supported for sqlite (database.type=%s)", cfg.Database.Type)
+	}
+
+	flags := flag.NewFlagSet("restore", flag.ContinueOnError)
+	check := flags.Bool("check", false, "Solo validar el snapshot")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, restoreUsage)
+		return listSnapshots(cfg.Database.Backup.Dir)
+	}
+
+	snapshotPath := flags.Arg(0)
+	ctx := context.Background()
+
+	version, err := storage.ValidateSnapshot(ctx, snapshotPath)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot %s: %w", snapshotPath, err)
+	}
+	fmt.Printf("✓ Snapshot %s is valid (schema version %04d)\n", snapshotPath, version)
+
+	if *check {
+		return nil
+	}
+
+	if err := storage.RestoreSnapshot(ctx, snapshotPath, cfg.Database.Path); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Restored %s (previous database kept as %s.pre-restore)\n", cfg.Database.Path, cfg.Database.Path)
+	return nil
+}
+
+// listSnapshots muestra los snapshots disponibles en dir
+func listSnapshots(dir string) error {
+	if dir == "" {
+		return fmt.Errorf("missing snapshot path (database.backup.dir not set)")
+	}
+
+	snapshots, err := backup.List(dir)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		return fmt.Errorf("no snapshots found in %s", dir)
+	}
+
+	fmt.Fprintf(os.Stderr, "\nSnapshots en %s:\n\n", dir)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tSIZE\tCREATED AT")
+	for _, s := range snapshots {
+		fmt.Fprintf(w, "%s\t%d\t%s\n", s.Path, s.SizeBytes, s.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return fmt.Errorf("missing snapshot path")
+}
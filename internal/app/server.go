@@ -1,18 +1,25 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/alejandro/technical_test_uvigo/internal/backup"
+	"github.com/alejandro/technical_test_uvigo/internal/cache"
 	"github.com/alejandro/technical_test_uvigo/internal/config"
+	"github.com/alejandro/technical_test_uvigo/internal/instrument"
 	"github.com/alejandro/technical_test_uvigo/internal/logger"
 	natsclient "github.com/alejandro/technical_test_uvigo/internal/nats"
 	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/retention"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
 	"github.com/alejandro/technical_test_uvigo/internal/simulator"
 	"github.com/alejandro/technical_test_uvigo/internal/storage"
+	"github.com/alejandro/technical_test_uvigo/internal/writer"
 	"github.com/sirupsen/logrus"
 )
 
@@ -21,7 +28,11 @@ type Server struct {
 	config     *config.Config
 	natsClient *natsclient.Client
 	repo       repository.Repository
+	cache      *cache.Repository      // nil si database.cache está deshabilitada
+	instrument *instrument.Repository // nil si database.instrumentation está deshabilitada
 	simulator  *simulator.Simulator
+	retention  *retention.Job
+	backup     *backup.Job
 	log        *logrus.Logger
 }
 
@@ -51,8 +62,23 @@ func (s *Server) Run() error {
 	}
 	defer s.repo.Close()
 
-	// 3. Inicializar simulador
-	s.simulator = simulator.New(s.repo, s.natsClient)
+	// 2b. Backups de la base de datos (si hay directorio configurado)
+	if err := s.initBackup(); err != nil {
+		return fmt.Errorf("failed to initialize backups: %w", err)
+	}
+
+	// 3. Registrar los tipos de sensor declarados en la configuración e inicializar simulador
+	if err := s.registerSensorTypes(); err != nil {
+		return fmt.Errorf("failed to register sensor types: %w", err)
+	}
+	s.simulator = simulator.NewWithOptions(s.repo, s.natsClient, simulator.Options{
+		Batch: writer.Options{
+			MaxBatchSize:  s.config.Database.BatchSize,
+			FlushInterval: s.config.Database.FlushInterval,
+			MaxPending:    s.config.Database.MaxPending,
+			MaxRetries:    s.config.Database.FlushRetries,
+		},
+	})
 
 	// 4. Registrar handlers NATS
 	if err := s.registerNATSHandlers(); err != nil {
@@ -64,10 +90,13 @@ func (s *Server) Run() error {
 		return fmt.Errorf("failed to load sensors: %w", err)
 	}
 
-	// 6. Mostrar banner
+	// 6. Iniciar job de retención (si está habilitado)
+	s.startRetention()
+
+	// 7. Mostrar banner
 	s.printBanner()
 
-	// 7. Esperar señal de terminación
+	// 8. Esperar señal de terminación
 	return s.waitForShutdown()
 }
 
@@ -92,7 +121,25 @@ func (s *Server) initDatabase() error {
 
 	switch s.config.Database.Type {
 	case "sqlite":
-		repo, err = storage.NewSQLiteRepository(s.config.Database.Path)
+		opts := s.config.Database.SQLite
+		repo, err = storage.NewSQLiteRepositoryWithOptions(s.config.Database.Path, storage.SQLiteOptions{
+			BusyTimeout: opts.BusyTimeout,
+			Synchronous: opts.Synchronous,
+			CacheSize:   opts.CacheSize,
+			ReadConns:   opts.ReadConns,
+		})
+	case "influxdb":
+		db := s.config.Database
+		repo, err = storage.NewInfluxDBRepository(db.URL, db.Token, db.Org, db.Bucket)
+	case "memory":
+		repo, err = storage.NewMemoryRepository(s.config.Database.Capacity, s.config.Database.Snapshot)
+	case "tsfile":
+		opts := s.config.Database.TSFile
+		repo, err = storage.NewTSFileRepository(s.config.Database.Path, storage.TSFileOptions{
+			BlockSize:   opts.BlockSize,
+			SegmentSize: opts.SegmentSize,
+			WALSize:     opts.WALSize,
+		})
 	default:
 		return fmt.Errorf("unsupported database type: %s", s.config.Database.Type)
 	}
@@ -103,6 +150,24 @@ func (s *Server) initDatabase() error {
 
 	s.repo = repo
 	s.log.Info("✓ Database initialized")
+
+	// La instrumentación va debajo de la caché para medir solo las consultas reales
+	if cfg := s.config.Database.Instrumentation; cfg.Enabled {
+		s.instrument = instrument.New(s.repo, instrument.Options{SlowThreshold: cfg.SlowQueryThreshold})
+		s.repo = s.instrument
+		s.log.WithFields(logrus.Fields{
+			"slow_query_threshold": cfg.SlowQueryThreshold,
+		}).Info("✓ Repository instrumentation enabled")
+	}
+
+	if cfg := s.config.Database.Cache; cfg.Enabled {
+		s.cache = cache.New(s.repo, cache.Options{Size: cfg.Size, TTL: cfg.TTL})
+		s.repo = s.cache
+		s.log.WithFields(logrus.Fields{
+			"size": cfg.Size,
+			"ttl":  cfg.TTL,
+		}).Info("✓ Repository cache enabled")
+	}
 	return nil
 }
 
@@ -112,8 +177,20 @@ func (s *Server) registerNATSHandlers() error {
 
 	handler := natsclient.NewHandler(s.natsClient, s.repo)
 	handler.SetAddSensorCallback(s.simulator.AddSensor)
+	handler.SetRemoveSensorCallback(s.simulator.RemoveSensor)
 	handler.SetListSensorsCallback(s.simulator.GetAllSensors)
 	handler.SetUpdateConfigCallback(s.simulator.UpdateConfig)
+	handler.SetMetricsCallback(s.metrics)
+	if s.backup != nil {
+		handler.SetBackupCallback(s.backup.RunOnce)
+	}
+	if s.cache != nil {
+		handler.SetCacheStatsCallback(s.cache.Stats)
+		// Cambios de configuración hechos por otras instancias que comparten la base de datos
+		handler.SetConfigChangedCallback(func(event natsclient.ConfigChangedEvent) {
+			s.cache.InvalidateConfig(event.SensorID)
+		})
+	}
 
 	if err := handler.HandleConfigRequests(); err != nil {
 		return err
@@ -122,10 +199,57 @@ func (s *Server) registerNATSHandlers() error {
 	s.log.Info("✓ NATS handlers registered:")
 	s.log.Info("  - sensor.config.get.*")
 	s.log.Info("  - sensor.config.set.*")
+	s.log.Info("  - sensor.config.history.*")
+	s.log.Info("  - sensor.config.rollback.*")
+	s.log.Info("  - sensor.alerts.query")
 	s.log.Info("  - sensor.readings.query.*")
+	s.log.Info("  - sensor.readings.stats.*")
+	s.log.Info("  - sensor.readings.export.*")
+	s.log.Info("  - sensor.readings.import")
+	s.log.Info("  - sensor.readings.search")
 	s.log.Info("  - sensor.register")
+	s.log.Info("  - sensor.remove.*")
 	s.log.Info("  - sensor.list")
+	s.log.Info("  - sensor.metrics")
+	s.log.Info("  - sensor.admin.backup")
+	s.log.Info("  - sensor.cache.stats")
+	if s.cache != nil {
+		s.log.Info("  - sensor.config.changed.* (cache invalidation)")
+	}
+
+	return nil
+}
+
+// metrics agrupa las métricas internas expuestas en sensor.metrics
+func (s *Server) metrics() map[string]interface{} {
+	metrics := map[string]interface{}{
+		"writer": s.simulator.WriterStats(),
+	}
+	// InfluxDB no detecta duplicados: sobrescribe el punto con la misma serie y timestamp
+	if counter, ok := repository.Unwrap(s.repo).(repository.DuplicateCounter); ok {
+		metrics["duplicate_readings"] = counter.DuplicateReadings()
+	}
+	if s.cache != nil {
+		metrics["cache"] = s.cache.Stats()
+	}
+	if s.instrument != nil {
+		metrics["repository"] = s.instrument.Stats()
+	}
+	return metrics
+}
 
+// registerSensorTypes añade al catálogo de tipos los declarados en sensor_types
+func (s *Server) registerSensorTypes() error {
+	for _, spec := range s.config.SensorTypes {
+		if err := sensor.RegisterType(spec); err != nil {
+			return err
+		}
+		s.log.WithFields(logrus.Fields{
+			"type": spec.Type,
+			"unit": spec.Unit,
+		}).Infof("  - sensor type %s (%s): range=[%g, %g], default threshold=%g",
+			spec.Type, spec.Unit, spec.Min, spec.Max, spec.DefaultThreshold)
+	}
 	return nil
 }
 
@@ -138,6 +262,8 @@ func (s *Server) loadSensors() error {
 			return fmt.Errorf("failed to add sensor %s: %w", sensorDef.ID, err)
 		}
 
+		s.saveConfigSensor(sensorDef)
+
 		status := map[bool]string{true: "ENABLED", false: "DISABLED"}[sensorDef.Config.Enabled]
 		s.log.WithFields(logrus.Fields{
 			"sensor_id": sensorDef.ID,
@@ -148,10 +274,161 @@ func (s *Server) loadSensors() error {
 			sensorDef.ID, sensorDef.Type, sensorDef.Config.Interval, sensorDef.Config.Threshold, status)
 	}
 
+	// Dispositivos multicanal: un sensor lógico por canal
+	for _, deviceDef := range s.config.Devices {
+		if err := s.simulator.AddDevice(deviceDef); err != nil {
+			return fmt.Errorf("failed to add device %s: %w", deviceDef.ID, err)
+		}
+		for _, sensorDef := range deviceDef.SensorDefs() {
+			s.saveConfigSensor(sensorDef)
+		}
+
+		status := map[bool]string{true: "ENABLED", false: "DISABLED"}[deviceDef.Enabled]
+		s.log.WithFields(logrus.Fields{
+			"device_id": deviceDef.ID,
+			"channels":  len(deviceDef.Channels),
+			"interval":  deviceDef.Interval,
+			"status":    status,
+		}).Infof("  - device %s: %d channels, interval=%dms [%s]",
+			deviceDef.ID, len(deviceDef.Channels), deviceDef.Interval, status)
+	}
+
+	// Rehidratar los sensores registrados dinámicamente en ejecuciones anteriores
+	if err := s.loadRegisteredSensors(); err != nil {
+		return err
+	}
+
 	s.log.Infof("✓ %d sensors ready", s.simulator.GetSensorCount())
 	return nil
 }
 
+// saveConfigSensor guarda los metadatos de un sensor del YAML para que sus lecturas
+// aparezcan en las búsquedas
+func (s *Server) saveConfigSensor(sensorDef config.SensorDef) {
+	meta := &sensor.Sensor{
+		ID:       sensorDef.ID,
+		Type:     sensorDef.Type,
+		Name:     sensorDef.Name,
+		Location: sensorDef.Location,
+		Tags:     sensorDef.Tags,
+		Source:   sensor.SensorSourceConfig,
+	}
+	if err := s.repo.SaveSensor(context.Background(), meta); err != nil {
+		s.log.WithField("sensor_id", sensorDef.ID).Warnf("Failed to persist sensor metadata: %v", err)
+	}
+}
+
+// loadRegisteredSensors añade al simulador los sensores persistidos en BD que no
+// están definidos en el YAML. Si un sensor existe en ambos, prevalece el YAML. Los
+// metadatos guardados desde el YAML no se restauran: si se quitó del fichero, sigue fuera.
+func (s *Server) loadRegisteredSensors() error {
+	ctx := context.Background()
+
+	registered, err := s.repo.ListSensors(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list registered sensors: %w", err)
+	}
+
+	fromYAML := make(map[string]bool, len(s.config.Sensors))
+	for _, sensorDef := range s.config.Sensors {
+		fromYAML[sensorDef.ID] = true
+	}
+	for _, deviceDef := range s.config.Devices {
+		for _, ch := range deviceDef.Channels {
+			fromYAML[ch.SensorID] = true
+		}
+	}
+
+	restored := 0
+	for _, reg := range registered {
+		if fromYAML[reg.ID] {
+			s.log.WithField("sensor_id", reg.ID).Debug("Registered sensor also defined in YAML, using YAML definition")
+			continue
+		}
+		if reg.Source == sensor.SensorSourceConfig {
+			continue
+		}
+
+		cfg, err := s.repo.GetConfig(ctx, reg.ID)
+		if err != nil || cfg == nil {
+			s.log.WithField("sensor_id", reg.ID).Warnf("Skipping registered sensor without stored config: %v", err)
+			continue
+		}
+		if _, ok := sensor.LookupType(reg.Type); !ok {
+			// Tipo declarado en un sensor_types que ya no está en el YAML
+			s.log.WithField("sensor_id", reg.ID).Warnf("Skipping registered sensor of unknown type %q", reg.Type)
+			continue
+		}
+
+		sensorDef := config.SensorDef{
+			ID:       reg.ID,
+			Type:     reg.Type,
+			Name:     reg.Name,
+			Location: reg.Location,
+			Tags:     reg.Tags,
+			Config:   *cfg,
+		}
+		if err := s.simulator.AddSensor(sensorDef); err != nil {
+			return fmt.Errorf("failed to restore sensor %s: %w", reg.ID, err)
+		}
+		restored++
+
+		s.log.WithFields(logrus.Fields{
+			"sensor_id": reg.ID,
+			"type":      reg.Type,
+			"interval":  cfg.Interval,
+		}).Infof("  - %s (%s): interval=%dms, threshold=%.2f [RESTORED]",
+			reg.ID, reg.Type, cfg.Interval, cfg.Threshold)
+	}
+
+	if restored > 0 {
+		s.log.Infof("✓ %d registered sensors restored from database", restored)
+	}
+	return nil
+}
+
+// startRetention arranca el job de retención de lecturas si está habilitado
+func (s *Server) startRetention() {
+	cfg := s.config.Database.Retention
+	if !cfg.Enabled {
+		s.log.Info("Retention policy disabled: raw readings are kept forever")
+		return
+	}
+
+	s.retention = retention.New(s.repo, cfg)
+	s.retention.Start()
+
+	s.log.WithFields(logrus.Fields{
+		"max_age": cfg.MaxAge,
+		"by_type": cfg.ByType,
+		"rollup":  cfg.Rollup,
+	}).Info("✓ Retention policy enabled")
+}
+
+// initBackup crea el job de backups y arranca los programados si hay intervalo
+func (s *Server) initBackup() error {
+	cfg := s.config.Database.Backup
+	if cfg.Dir == "" {
+		s.log.Info("Backups disabled: database.backup.dir not set")
+		return nil
+	}
+
+	target, ok := repository.Unwrap(s.repo).(backup.Backuper)
+	if !ok {
+		return fmt.Errorf("database type %s does not support backups", s.config.Database.Type)
+	}
+
+	s.backup = backup.New(target, cfg)
+	s.backup.Start()
+
+	s.log.WithFields(logrus.Fields{
+		"dir":      cfg.Dir,
+		"keep":     cfg.Keep,
+		"interval": cfg.Interval,
+	}).Info("✓ Backups enabled")
+	return nil
+}
+
 // printBanner muestra el banner del sistema
 func (s *Server) printBanner() {
 	s.log.Info("═══════════════════════════════════════════════════════")
@@ -162,17 +439,31 @@ func (s *Server) printBanner() {
 	s.log.Infof("   • NATS:      %s ✓", s.config.NATS.URL)
 	s.log.Infof("   • Database:  %s ✓", s.config.Database.Type)
 	s.log.Infof("   • Sensors:   %d active", s.simulator.GetSensorCount())
+	s.log.Infof("   • Devices:   %d multi-channel", s.simulator.GetDeviceCount())
 	s.log.Info("")
 	s.log.Info("📡 Publishing to NATS subjects:")
 	s.log.Info("   • sensor.readings.<type>.<id>   (sensor readings)")
+	s.log.Info("   • sensor.devices.readings.<id>  (multi-channel device samples)")
 	s.log.Info("   • sensor.alerts.<type>.<id>     (threshold alerts)")
+	s.log.Info("   • sensor.config.changed.<id>    (config change events)")
 	s.log.Info("")
 	s.log.Info("🔧 NATS request/reply endpoints:")
 	s.log.Info("   • sensor.config.get.<id>        (get sensor config)")
 	s.log.Info("   • sensor.config.set.<id>        (update sensor config)")
-	s.log.Info("   • sensor.readings.query.<id>    (query latest readings)")
+	s.log.Info("   • sensor.config.history.<id>    (config change history)")
+	s.log.Info("   • sensor.config.rollback.<id>   (restore config revision)")
+	s.log.Info("   • sensor.alerts.query           (query persisted alerts)")
+	s.log.Info("   • sensor.readings.query.<id>    (query readings, paginated)")
+	s.log.Info("   • sensor.readings.stats.<id>    (aggregated stats per bucket)")
+	s.log.Info("   • sensor.readings.export.<id>   (chunked export, ascending)")
+	s.log.Info("   • sensor.readings.import        (bulk import of historical readings)")
+	s.log.Info("   • sensor.readings.search        (search by type, location and tags)")
 	s.log.Info("   • sensor.register               (register new sensors)")
+	s.log.Info("   • sensor.remove.<id>            (decommission sensor)")
 	s.log.Info("   • sensor.list                   (list all sensors)")
+	s.log.Info("   • sensor.metrics                (internal metrics)")
+	s.log.Info("   • sensor.admin.backup           (online database backup)")
+	s.log.Info("   • sensor.cache.stats            (cache hit/miss stats)")
 	s.log.Info("")
 	s.log.Info("Press Ctrl+C to stop...")
 	s.log.Info("═══════════════════════════════════════════════════════")
@@ -201,15 +492,32 @@ func (s *Server) shutdown() error {
 	s.simulator.Stop()
 	s.log.Info("[Shutdown] ✓ Simulator stopped")
 
-	// 2. Cerrar conexión NATS
+	// 2. Detener job de retención
+	if s.retention != nil {
+		s.log.Info("[Shutdown] Stopping retention job...")
+		s.retention.Stop()
+		s.log.Info("[Shutdown] ✓ Retention job stopped")
+	}
+
+	// 3. Detener backups programados (espera al backup en curso)
+	if s.backup != nil {
+		s.log.Info("[Shutdown] Stopping backup job...")
+		s.backup.Stop()
+		s.log.Info("[Shutdown] ✓ Backup job stopped")
+	}
+
+	// 4. Cerrar conexión NATS
 	s.log.Info("[Shutdown] Closing NATS connection...")
 	s.natsClient.Close()
 	s.log.Info("[Shutdown] ✓ NATS connection closed")
 
-	// 3. Cerrar base de datos
+	// 5. Cerrar base de datos
 	s.log.Info("[Shutdown] Closing database...")
-	s.repo.Close()
-	s.log.Info("[Shutdown] ✓ Database closed")
+	if err := s.repo.Close(); err != nil {
+		s.log.WithField("error", err).Error("[Shutdown] Error closing database")
+	} else {
+		s.log.Info("[Shutdown] ✓ Database closed")
+	}
 
 	// Pequeña pausa para asegurar que todos los logs se escriben
 	time.Sleep(100 * time.Millisecond)
@@ -0,0 +1,195 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/config"
+	"github.com/alejandro/technical_test_uvigo/internal/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultKeep = 7
+
+	// Los snapshots se nombran sensors-<UTC>.db; el orden lexicográfico es el cronológico
+	snapshotPrefix = "sensors-"
+	snapshotSuffix = ".db"
+	snapshotLayout = "20060102T150405.000Z"
+)
+
+// Backuper genera una copia consistente de la base de datos en destPath
+type Backuper interface {
+	Backup(ctx context.Context, destPath string) error
+}
+
+// Snapshot describe un backup generado en el directorio de snapshots
+type Snapshot struct {
+	Path      string    `json:"path"`
+	SizeBytes int64     `json:"size_bytes"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Job genera snapshots bajo demanda o periódicamente y conserva los cfg.Keep más recientes
+type Job struct {
+	target Backuper
+	cfg    config.BackupConfig
+	now    func() time.Time // Inyectable para tests
+	mu     sync.Mutex       // Serializa backups programados y bajo demanda
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New crea un job de backups sobre target
+func New(target Backuper, cfg config.BackupConfig) *Job {
+	if cfg.Keep == 0 {
+		cfg.Keep = defaultKeep
+	}
+	return &Job{
+		target: target,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Start lanza los backups programados cada cfg.Interval (no hace nada si es 0)
+func (j *Job) Start() {
+	if j.cfg.Interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+
+	// Registrar antes de lanzar el loop: el logger global se inicializa en el primer uso
+	logger.Infof("[Backup] Job started (interval=%s, keep=%d, dir=%s)", j.cfg.Interval, j.cfg.Keep, j.cfg.Dir)
+
+	j.wg.Add(1)
+	go j.loop(ctx)
+}
+
+// Stop detiene los backups programados y espera a que termine el backup en curso
+func (j *Job) Stop() {
+	if j.cancel == nil {
+		return
+	}
+	j.cancel()
+	j.wg.Wait()
+	logger.Info("[Backup] Job stopped")
+}
+
+// loop ejecuta RunOnce en cada tick hasta que se cancela el context
+func (j *Job) loop(ctx context.Context) {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			logger.WithField("error", err).Error("[Backup] Error creating snapshot")
+		}
+	}
+}
+
+// RunOnce genera un snapshot y elimina los más antiguos que excedan cfg.Keep
+func (j *Job) RunOnce(ctx context.Context) (*Snapshot, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := os.MkdirAll(j.cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup dir: %w", err)
+	}
+
+	createdAt := j.now().UTC()
+	path := filepath.Join(j.cfg.Dir, snapshotPrefix+createdAt.Format(snapshotLayout)+snapshotSuffix)
+
+	start := time.Now()
+	if err := j.target.Backup(ctx, path); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat snapshot: %w", err)
+	}
+	snapshot := &Snapshot{Path: path, SizeBytes: info.Size(), CreatedAt: createdAt}
+
+	logger.WithFields(logrus.Fields{
+		"path":     path,
+		"size":     info.Size(),
+		"duration": time.Since(start),
+	}).Info("[Backup] Snapshot created")
+
+	if err := j.rotate(); err != nil {
+		// El snapshot es válido aunque falle la rotación
+		logger.WithField("error", err).Warn("[Backup] Error rotating snapshots")
+	}
+
+	return snapshot, nil
+}
+
+// rotate elimina los snapshots más antiguos que excedan cfg.Keep
+func (j *Job) rotate() error {
+	snapshots, err := List(j.cfg.Dir)
+	if err != nil {
+		return err
+	}
+
+	for len(snapshots) > j.cfg.Keep {
+		oldest := snapshots[len(snapshots)-1]
+		if err := os.Remove(oldest.Path); err != nil {
+			return fmt.Errorf("failed to remove snapshot %s: %w", oldest.Path, err)
+		}
+		logger.WithField("path", oldest.Path).Debug("[Backup] Old snapshot removed")
+		snapshots = snapshots[:len(snapshots)-1]
+	}
+	return nil
+}
+
+// List retorna los snapshots de dir, del más reciente al más antiguo
+func List(dir string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, snapshotPrefix) || !strings.HasSuffix(name, snapshotSuffix) {
+			continue
+		}
+		createdAt, err := time.Parse(snapshotLayout, strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotSuffix))
+		if err != nil {
+			continue // Fichero ajeno con el mismo prefijo
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat snapshot %s: %w", name, err)
+		}
+		snapshots = append(snapshots, Snapshot{
+			Path:      filepath.Join(dir, name),
+			SizeBytes: info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, k int) bool { return snapshots[i].CreatedAt.After(snapshots[k].CreatedAt) })
+	return snapshots, nil
+}
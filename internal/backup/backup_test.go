@@ -0,0 +1,101 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/config"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/alejandro/technical_test_uvigo/internal/storage"
+)
+
+func TestJob_RunOnceRotates(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(filepath.Join(t.TempDir(), "sensors.db"))
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	repo.SaveReading(ctx, &sensor.SensorReading{ID: "r1", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 21, Unit: "°C", Timestamp: time.Now()})
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	job := New(repo, config.BackupConfig{Dir: dir, Keep: 2})
+
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		job.now = func() time.Time { return now.Add(time.Duration(i) * time.Hour) }
+		snapshot, err := job.RunOnce(ctx)
+		if err != nil {
+			t.Fatalf("RunOnce failed: %v", err)
+		}
+		if snapshot.SizeBytes == 0 {
+			t.Errorf("expected a non empty snapshot")
+		}
+		if _, err := storage.ValidateSnapshot(ctx, snapshot.Path); err != nil {
+			t.Errorf("snapshot is not restorable: %v", err)
+		}
+	}
+
+	// Un fichero ajeno en el directorio no cuenta para la rotación
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644)
+
+	snapshots, err := List(dir)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots after rotation, got %d", len(snapshots))
+	}
+	if !snapshots[0].CreatedAt.Equal(now.Add(2*time.Hour)) || !snapshots[1].CreatedAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expected the 2 newest snapshots, got %v", snapshots)
+	}
+}
+
+func TestList_MissingDir(t *testing.T) {
+	snapshots, err := List(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(snapshots) != 0 {
+		t.Errorf("expected no snapshots and no error, got %v, %v", snapshots, err)
+	}
+}
+
+func TestJob_StartStop(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(filepath.Join(t.TempDir(), "sensors.db"))
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	dir := filepath.Join(t.TempDir(), "backups")
+	job := New(repo, config.BackupConfig{Dir: dir, Interval: 20 * time.Millisecond})
+
+	job.Start()
+
+	// Esperar al primer snapshot programado antes de parar: Stop cancela el backup en curso
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if snapshots, _ := List(dir); len(snapshots) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			job.Stop()
+			t.Fatal("expected a scheduled snapshot")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		job.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop() did not return")
+	}
+}
@@ -0,0 +1,231 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+const (
+	defaultSize = 1000             // Elementos por caché
+	defaultTTL  = 30 * time.Second // Vida máxima de un elemento
+)
+
+// Options configura el tamaño y la expiración de las cachés
+type Options struct {
+	Size int           // Elementos por caché (configs y últimas lecturas)
+	TTL  time.Duration // Tiempo máximo que un elemento se sirve sin volver a la base de datos
+}
+
+// Stats contiene las métricas acumuladas de una caché
+type Stats struct {
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	HitRatio      float64 `json:"hit_ratio"`
+	Evictions     int64   `json:"evictions"`
+	Expirations   int64   `json:"expirations"`
+	Invalidations int64   `json:"invalidations"`
+	Size          int     `json:"size"`
+	Capacity      int     `json:"capacity"`
+}
+
+// RepositoryStats agrupa las métricas de las cachés del decorador
+type RepositoryStats struct {
+	Configs        Stats   `json:"configs"`
+	LatestReadings Stats   `json:"latest_readings"`
+	TTLSeconds     float64 `json:"ttl_seconds"`
+}
+
+// latest es la primera página cacheada de las lecturas de un sensor, en orden (timestamp, id)
+// descendente. complete indica que no hay más lecturas que las guardadas.
+type latest struct {
+	readings []sensor.SensorReading
+	complete bool
+}
+
+// covers indica si la entrada basta para servir una primera página de limit lecturas
+func (l latest) covers(limit int) bool {
+	return len(l.readings) >= limit || l.complete
+}
+
+// Repository decora un repository.Repository con cachés LRU+TTL en proceso para GetConfig
+// y las últimas lecturas de cada sensor (GetLatestReadings y la primera página de
+// GetReadingsPage, la que sirve sensor.readings.query.<id>). Las escrituras que pasan por el decorador invalidan las entradas
+// afectadas; los cambios hechos por otros procesos se invalidan con InvalidateConfig
+// (eventos sensor.config.changed.<id>) o al vencer el TTL. El resto de métodos se delegan.
+type Repository struct {
+	repository.Repository
+	opts     Options
+	configs  *lru[string, sensor.SensorConfig]
+	readings *lru[string, latest]
+}
+
+// Asegurar que Repository implementa repository.Repository
+var _ repository.Repository = (*Repository)(nil)
+
+// New crea el decorador sobre repo. Los valores de opts <= 0 se sustituyen por los
+// valores por defecto.
+func New(repo repository.Repository, opts Options) *Repository {
+	if opts.Size <= 0 {
+		opts.Size = defaultSize
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = defaultTTL
+	}
+
+	return &Repository{
+		Repository: repo,
+		opts:       opts,
+		configs:    newLRU[string, sensor.SensorConfig](opts.Size, opts.TTL, time.Now),
+		readings:   newLRU[string, latest](opts.Size, opts.TTL, time.Now),
+	}
+}
+
+// Unwrap retorna el repositorio decorado
+func (r *Repository) Unwrap() repository.Repository {
+	return r.Repository
+}
+
+// Stats retorna las métricas de las cachés
+func (r *Repository) Stats() RepositoryStats {
+	return RepositoryStats{
+		Configs:        r.configs.snapshot(),
+		LatestReadings: r.readings.snapshot(),
+		TTLSeconds:     r.opts.TTL.Seconds(),
+	}
+}
+
+// InvalidateConfig descarta la configuración cacheada de un sensor
+func (r *Repository) InvalidateConfig(sensorID string) {
+	r.configs.remove(sensorID)
+}
+
+// GetConfig sirve la configuración desde la caché o la lee del repositorio decorado.
+// Los errores (p. ej. sensor sin configuración) no se cachean.
+func (r *Repository) GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
+	if config, ok := r.configs.get(sensorID); ok {
+		r.configs.record(true)
+		return &config, nil
+	}
+	r.configs.record(false)
+
+	gen := r.configs.generation()
+	config, err := r.Repository.GetConfig(ctx, sensorID)
+	if err != nil || config == nil {
+		return config, err
+	}
+	r.configs.putIf(sensorID, *config, gen)
+	return config, nil
+}
+
+// SaveConfig guarda la configuración e invalida la entrada cacheada
+func (r *Repository) SaveConfig(ctx context.Context, config *sensor.SensorConfig) error {
+	defer r.configs.remove(config.SensorID)
+	return r.Repository.SaveConfig(ctx, config)
+}
+
+// DeleteConfig elimina la configuración e invalida la entrada cacheada
+func (r *Repository) DeleteConfig(ctx context.Context, sensorID string) error {
+	defer r.configs.remove(sensorID)
+	return r.Repository.DeleteConfig(ctx, sensorID)
+}
+
+// GetLatestReadings sirve las últimas lecturas desde la caché. En un fallo se resuelve con
+// la primera página de GetReadingsPage para que ambas consultas compartan la entrada.
+func (r *Repository) GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error) {
+	if limit <= 0 {
+		return r.Repository.GetLatestReadings(ctx, sensorID, limit)
+	}
+	readings, _, err := r.GetReadingsPage(ctx, sensorID, nil, limit)
+	return readings, err
+}
+
+// GetReadingsPage sirve la primera página (cursor nil) desde la caché si la entrada la
+// cubre; las páginas siguientes se delegan sin cachear
+func (r *Repository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if cursor != nil || limit <= 0 {
+		return r.Repository.GetReadingsPage(ctx, sensorID, cursor, limit)
+	}
+
+	if cached, ok := r.readings.get(sensorID); ok && cached.covers(limit) {
+		r.readings.record(true)
+		page := copyReadings(cached.readings, limit)
+		var next *repository.ReadingCursor
+		if len(cached.readings) > limit || (len(cached.readings) == limit && !cached.complete) {
+			last := page[len(page)-1]
+			next = &repository.ReadingCursor{Timestamp: last.Timestamp, ID: last.ID}
+		}
+		return page, next, nil
+	}
+	r.readings.record(false)
+
+	gen := r.readings.generation()
+	readings, next, err := r.Repository.GetReadingsPage(ctx, sensorID, nil, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cached := latest{readings: make([]sensor.SensorReading, len(readings)), complete: next == nil}
+	for i, reading := range readings {
+		cached.readings[i] = *reading
+	}
+	r.readings.putIf(sensorID, cached, gen)
+	return readings, next, nil
+}
+
+// SaveReading guarda la lectura e invalida las últimas lecturas del sensor
+func (r *Repository) SaveReading(ctx context.Context, reading *sensor.SensorReading) error {
+	defer r.readings.remove(reading.SensorID)
+	return r.Repository.SaveReading(ctx, reading)
+}
+
+// SaveReadings guarda el lote e invalida las últimas lecturas de sus sensores
+func (r *Repository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	defer r.invalidateReadings(readings)
+	return r.Repository.SaveReadings(ctx, readings)
+}
+
+// ImportReadings importa el lote e invalida las últimas lecturas de sus sensores
+func (r *Repository) ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]repository.SkippedReading, error) {
+	defer r.invalidateReadings(readings)
+	return r.Repository.ImportReadings(ctx, readings)
+}
+
+// ApplyRetention aplica la política y vacía la caché de lecturas
+func (r *Repository) ApplyRetention(ctx context.Context, policy repository.RetentionPolicy) (int64, error) {
+	defer r.readings.purge()
+	return r.Repository.ApplyRetention(ctx, policy)
+}
+
+// PurgeSensorData borra los datos del sensor e invalida sus entradas cacheadas
+func (r *Repository) PurgeSensorData(ctx context.Context, sensorID string) (int64, error) {
+	defer r.readings.remove(sensorID)
+	defer r.configs.remove(sensorID)
+	return r.Repository.PurgeSensorData(ctx, sensorID)
+}
+
+// invalidateReadings invalida una vez cada sensor presente en readings
+func (r *Repository) invalidateReadings(readings []*sensor.SensorReading) {
+	seen := make(map[string]bool)
+	for _, reading := range readings {
+		if !seen[reading.SensorID] {
+			seen[reading.SensorID] = true
+			r.readings.remove(reading.SensorID)
+		}
+	}
+}
+
+// copyReadings copia hasta limit lecturas para que los llamantes no modifiquen la caché
+func copyReadings(cached []sensor.SensorReading, limit int) []*sensor.SensorReading {
+	if limit > len(cached) {
+		limit = len(cached)
+	}
+	readings := make([]*sensor.SensorReading, limit)
+	for i := range readings {
+		reading := cached[i]
+		readings[i] = &reading
+	}
+	return readings
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/alejandro/technical_test_uvigo/internal/storage"
+)
+
+// countingRepository cuenta las consultas que llegan al repositorio decorado
+type countingRepository struct {
+	repository.Repository
+	configReads int
+	pageReads   int
+}
+
+func (c *countingRepository) GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
+	c.configReads++
+	return c.Repository.GetConfig(ctx, sensorID)
+}
+
+func (c *countingRepository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	c.pageReads++
+	return c.Repository.GetReadingsPage(ctx, sensorID, cursor, limit)
+}
+
+func newTestCache(t *testing.T) (*Repository, *countingRepository) {
+	t.Helper()
+
+	repo, err := storage.NewMemoryRepository(100, "")
+	if err != nil {
+		t.Fatalf("failed to create memory repository: %v", err)
+	}
+	inner := &countingRepository{Repository: repo}
+	cached := New(inner, Options{Size: 10, TTL: time.Minute})
+	t.Cleanup(func() { cached.Close() })
+
+	return cached, inner
+}
+
+func TestRepository_GetConfig(t *testing.T) {
+	cached, inner := newTestCache(t)
+	ctx := context.Background()
+
+	config := &sensor.SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30, Enabled: true}
+	if err := cached.SaveConfig(ctx, config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		got, err := cached.GetConfig(ctx, "temp-001")
+		if err != nil || got.Threshold != 30 {
+			t.Fatalf("GetConfig = %+v, %v", got, err)
+		}
+		got.Threshold = 99 // Los llamantes no modifican la copia cacheada
+	}
+	if inner.configReads != 1 {
+		t.Errorf("expected 1 repository read, got %d", inner.configReads)
+	}
+
+	// SaveConfig invalida
+	config.Threshold = 25
+	cached.SaveConfig(ctx, config)
+	if got, _ := cached.GetConfig(ctx, "temp-001"); got.Threshold != 25 || inner.configReads != 2 {
+		t.Errorf("expected the updated config from the repository, got %+v after %d reads", got, inner.configReads)
+	}
+
+	// Un evento de otro proceso invalida
+	cached.InvalidateConfig("temp-001")
+	cached.GetConfig(ctx, "temp-001")
+	if inner.configReads != 3 {
+		t.Errorf("expected a repository read after InvalidateConfig, got %d", inner.configReads)
+	}
+
+	// Los errores no se cachean
+	for i := 0; i < 2; i++ {
+		if _, err := cached.GetConfig(ctx, "missing"); err == nil {
+			t.Error("expected error for missing config")
+		}
+	}
+	if inner.configReads != 5 {
+		t.Errorf("expected errors to reach the repository, got %d reads", inner.configReads)
+	}
+
+	stats := cached.Stats().Configs
+	if stats.Hits != 2 || stats.Misses != 5 || stats.Invalidations != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestRepository_LatestReadings(t *testing.T) {
+	cached, inner := newTestCache(t)
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		cached.SaveReading(ctx, &sensor.SensorReading{ID: fmt.Sprintf("r%d", i), SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: float64(i), Timestamp: base.Add(time.Duration(i) * time.Second)})
+	}
+
+	page, next, err := cached.GetReadingsPage(ctx, "temp-001", nil, 3)
+	if err != nil || len(page) != 3 || next == nil || next.ID != "r2" {
+		t.Fatalf("unexpected first page: %v, %+v, %v", page, next, err)
+	}
+
+	// Misma página y límites menores desde la caché, con el mismo cursor siguiente
+	page, next, _ = cached.GetReadingsPage(ctx, "temp-001", nil, 3)
+	if len(page) != 3 || page[0].ID != "r4" || next == nil || next.ID != "r2" {
+		t.Errorf("unexpected cached page: %v, %+v", page, next)
+	}
+	if latest, _ := cached.GetLatestReadings(ctx, "temp-001", 2); len(latest) != 2 || latest[1].ID != "r3" {
+		t.Errorf("unexpected latest readings: %v", latest)
+	}
+	if inner.pageReads != 1 {
+		t.Errorf("expected 1 repository read, got %d", inner.pageReads)
+	}
+
+	// Un límite mayor que lo cacheado vuelve al repositorio; la entrada completa sirve cualquiera
+	page, next, _ = cached.GetReadingsPage(ctx, "temp-001", nil, 10)
+	if len(page) != 5 || next != nil {
+		t.Errorf("expected every reading and no cursor, got %v, %+v", page, next)
+	}
+	if page, next, _ = cached.GetReadingsPage(ctx, "temp-001", nil, 5); len(page) != 5 || next != nil || inner.pageReads != 2 {
+		t.Errorf("expected the complete entry to serve limit 5, got %d readings, %+v, %d reads", len(page), next, inner.pageReads)
+	}
+
+	// Las páginas siguientes no se cachean
+	cached.GetReadingsPage(ctx, "temp-001", &repository.ReadingCursor{Timestamp: base.Add(3 * time.Second), ID: "r3"}, 2)
+	if inner.pageReads != 3 {
+		t.Errorf("expected pages after a cursor to reach the repository, got %d reads", inner.pageReads)
+	}
+
+	// Una lectura nueva invalida
+	cached.SaveReadings(ctx, []*sensor.SensorReading{{ID: "r5", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Timestamp: base.Add(time.Minute)}})
+	if latest, _ := cached.GetLatestReadings(ctx, "temp-001", 1); len(latest) != 1 || latest[0].ID != "r5" || inner.pageReads != 4 {
+		t.Errorf("expected the new reading after invalidation, got %v", latest)
+	}
+}
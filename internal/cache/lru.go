@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lru es una caché LRU con expiración por TTL, segura para uso concurrente.
+// Cada invalidación incrementa gen: putIf descarta los valores leídos de la base de
+// datos antes de una invalidación para no volver a cachear un dato ya obsoleto.
+type lru[K comparable, V any] struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+	items    map[K]*list.Element
+	order    *list.List // Frente = usado más recientemente
+	gen      uint64
+	stats    Stats
+}
+
+// entry es un elemento de la lista LRU
+type entry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+func newLRU[K comparable, V any](capacity int, ttl time.Duration, now func() time.Time) *lru[K, V] {
+	return &lru[K, V]{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      now,
+		items:    make(map[K]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// get retorna el valor de key si existe y no ha expirado. Los aciertos y fallos los
+// registra quien llama con record, que sabe si el valor le sirve.
+func (c *lru[K, V]) get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if ok && c.now().After(elem.Value.(*entry[K, V]).expires) {
+		c.removeElement(elem)
+		c.stats.Expirations++
+		ok = false
+	}
+	if !ok {
+		return zero, false
+	}
+
+	c.order.MoveToFront(elem)
+	return elem.Value.(*entry[K, V]).value, true
+}
+
+// record contabiliza un acierto o un fallo
+func (c *lru[K, V]) record(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hit {
+		c.stats.Hits++
+	} else {
+		c.stats.Misses++
+	}
+}
+
+// generation retorna la generación actual, que se pasa a putIf tras leer de la base de datos
+func (c *lru[K, V]) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// putIf guarda value si no ha habido invalidaciones desde la generación gen,
+// expulsando el elemento menos usado si se supera la capacidad
+func (c *lru[K, V]) putIf(key K, value V, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		e := elem.Value.(*entry[K, V])
+		e.value, e.expires = value, expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&entry[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.capacity {
+		c.removeElement(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// remove invalida key
+func (c *lru[K, V]) remove(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+		c.stats.Invalidations++
+	}
+}
+
+// purge invalida todos los elementos
+func (c *lru[K, V]) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.stats.Invalidations += int64(len(c.items))
+	c.items = make(map[K]*list.Element)
+	c.order.Init()
+}
+
+func (c *lru[K, V]) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*entry[K, V]).key)
+}
+
+// snapshot retorna las estadísticas acumuladas con el tamaño actual
+func (c *lru[K, V]) snapshot() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = len(c.items)
+	stats.Capacity = c.capacity
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRU_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRU[string, int](2, time.Minute, time.Now)
+
+	c.putIf("a", 1, c.generation())
+	c.putIf("b", 2, c.generation())
+	c.get("a") // "b" pasa a ser el menos usado
+	c.putIf("c", 3, c.generation())
+
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if v, ok := c.get("a"); !ok || v != 1 {
+		t.Errorf("expected a=1, got %d (%v)", v, ok)
+	}
+	if stats := c.snapshot(); stats.Evictions != 1 || stats.Size != 2 || stats.Capacity != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestLRU_TTL(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	c := newLRU[string, int](10, 30*time.Second, func() time.Time { return now })
+
+	c.putIf("a", 1, c.generation())
+	now = now.Add(30 * time.Second)
+	if _, ok := c.get("a"); !ok {
+		t.Error("expected a to be alive at exactly the TTL")
+	}
+
+	now = now.Add(time.Nanosecond)
+	if _, ok := c.get("a"); ok {
+		t.Error("expected a to be expired")
+	}
+	if stats := c.snapshot(); stats.Expirations != 1 || stats.Size != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestLRU_StaleWriteAfterInvalidation(t *testing.T) {
+	c := newLRU[string, int](10, time.Minute, time.Now)
+
+	// Una lectura de la base de datos empieza, otra goroutine invalida y la lectura termina
+	gen := c.generation()
+	c.remove("a")
+	c.putIf("a", 1, gen)
+	if _, ok := c.get("a"); ok {
+		t.Error("expected the stale value not to be cached")
+	}
+
+	c.putIf("a", 2, c.generation())
+	c.purge()
+	if _, ok := c.get("a"); ok {
+		t.Error("expected purge to remove every entry")
+	}
+	if stats := c.snapshot(); stats.Invalidations != 1 {
+		t.Errorf("expected 1 invalidation, got %+v", stats)
+	}
+}
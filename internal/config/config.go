@@ -14,12 +14,14 @@ import (
 
 // Config representa la configuración completa del sistema IoT
 type Config struct {
-	Environment string         `mapstructure:"environment"`
-	NATS        NATSConfig     `mapstructure:"nats"`
-	Database    DatabaseConfig `mapstructure:"database"`
-	HTTP        HTTPConfig     `mapstructure:"http"`
-	Sensors     []SensorDef    `mapstructure:"sensors"`
-	Logging     LoggingConfig  `mapstructure:"logging"`
+	Environment string            `mapstructure:"environment"`
+	NATS        NATSConfig        `mapstructure:"nats"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	HTTP        HTTPConfig        `mapstructure:"http"`
+	SensorTypes []sensor.TypeSpec `mapstructure:"sensor_types"` // Tipos adicionales o que redefinen los incorporados
+	Sensors     []SensorDef       `mapstructure:"sensors"`
+	Devices     []DeviceDef       `mapstructure:"devices"` // Dispositivos multicanal (ej: BME280)
+	Logging     LoggingConfig     `mapstructure:"logging"`
 }
 
 // NATSConfig contiene la configuración del servidor NATS
@@ -31,14 +33,165 @@ type NATSConfig struct {
 
 // DatabaseConfig contiene la configuración de la base de datos
 type DatabaseConfig struct {
-	Type string `mapstructure:"type"` // "sqlite", "influxdb"
-	Path string `mapstructure:"path"` // Para SQLite
+	Type string `mapstructure:"type"` // "sqlite", "influxdb", "memory", "tsfile"
+	Path string `mapstructure:"path"` // Fichero de SQLite o directorio de tsfile
 
-	// Para InfluxDB (feat-8)
+	SQLite SQLiteConfig `mapstructure:"sqlite"`
+	TSFile TSFileConfig `mapstructure:"tsfile"`
+
+	// Para InfluxDB v2 (token opcional si el servidor no exige autenticación)
 	URL    string `mapstructure:"url"`
 	Token  string `mapstructure:"token"`
 	Org    string `mapstructure:"org"`
 	Bucket string `mapstructure:"bucket"`
+
+	// Para memory (gateways edge sin disco y tests)
+	Capacity int    `mapstructure:"capacity"` // Lecturas por sensor en el ring buffer (0 = 1000)
+	Snapshot string `mapstructure:"snapshot"` // Fichero donde volcar el estado al parar (opcional)
+
+	Retention RetentionConfig `mapstructure:"retention"`
+	Backup    BackupConfig    `mapstructure:"backup"` // Solo SQLite
+	Cache     CacheConfig     `mapstructure:"cache"`
+
+	Instrumentation InstrumentationConfig `mapstructure:"instrumentation"`
+
+	// Write-behind de lecturas (0 = valores por defecto)
+	BatchSize     int           `mapstructure:"batch_size"`     // Lecturas por transacción
+	FlushInterval time.Duration `mapstructure:"flush_interval"` // Latencia máxima antes de persistir
+	MaxPending    int           `mapstructure:"max_pending"`    // Lecturas en memoria sin persistir (0 = 10000)
+	FlushRetries  int           `mapstructure:"flush_retries"`  // Reintentos de un lote fallido (0 = 3)
+}
+
+// SQLiteConfig ajusta los pragmas y el pool de lectura de SQLite (0 / vacío = valores por defecto)
+type SQLiteConfig struct {
+	BusyTimeout time.Duration `mapstructure:"busy_timeout"` // Espera ante bloqueos (0 = 5s)
+	Synchronous string        `mapstructure:"synchronous"`  // OFF, NORMAL, FULL, EXTRA ("" = NORMAL)
+	CacheSize   int           `mapstructure:"cache_size"`   // >0 páginas, <0 KiB
+	ReadConns   int           `mapstructure:"read_conns"`   // Conexiones de solo lectura (0 = 4)
+}
+
+// Validate valida los ajustes de SQLite
+func (s *SQLiteConfig) Validate() error {
+	if s.BusyTimeout < 0 {
+		return fmt.Errorf("busy_timeout must not be negative")
+	}
+	if s.ReadConns < 0 {
+		return fmt.Errorf("read_conns must not be negative")
+	}
+	switch strings.ToUpper(s.Synchronous) {
+	case "", "OFF", "NORMAL", "FULL", "EXTRA":
+	default:
+		return fmt.Errorf("synchronous must be OFF, NORMAL, FULL or EXTRA")
+	}
+	return nil
+}
+
+// TSFileConfig ajusta el motor de series temporales tsfile (0 = valores por defecto)
+type TSFileConfig struct {
+	BlockSize   int   `mapstructure:"block_size"`   // Lecturas por bloque comprimido (0 = 1024)
+	SegmentSize int64 `mapstructure:"segment_size"` // Bytes a partir de los que se sella un segmento (0 = 4 MiB)
+	WALSize     int64 `mapstructure:"wal_size"`     // Bytes de WAL que fuerzan un checkpoint (0 = 16 MiB)
+}
+
+// Validate valida los ajustes de tsfile
+func (t *TSFileConfig) Validate() error {
+	if t.BlockSize < 0 {
+		return fmt.Errorf("block_size must not be negative")
+	}
+	if t.SegmentSize < 0 {
+		return fmt.Errorf("segment_size must not be negative")
+	}
+	if t.WALSize < 0 {
+		return fmt.Errorf("wal_size must not be negative")
+	}
+	return nil
+}
+
+// RetentionConfig define cuánto tiempo se conservan las lecturas crudas.
+// Las lecturas que expiran pueden consolidarse antes en resúmenes horarios y diarios.
+type RetentionConfig struct {
+	Enabled  bool                     `mapstructure:"enabled"`
+	MaxAge   time.Duration            `mapstructure:"max_age"`  // Retención global (ej: 720h); 0 = sin límite
+	ByType   map[string]time.Duration `mapstructure:"by_type"`  // Retención por tipo de sensor (prevalece sobre max_age)
+	Rollup   bool                     `mapstructure:"rollup"`   // Consolidar en resúmenes antes de purgar
+	Interval time.Duration            `mapstructure:"interval"` // Frecuencia del job de retención
+}
+
+// Validate valida la política de retención
+func (r *RetentionConfig) Validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	if r.Interval <= 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+	if r.MaxAge < 0 {
+		return fmt.Errorf("max_age must not be negative")
+	}
+	if r.MaxAge == 0 && len(r.ByType) == 0 {
+		return fmt.Errorf("max_age or by_type is required when retention is enabled")
+	}
+	for sensorType, maxAge := range r.ByType {
+		if maxAge <= 0 {
+			return fmt.Errorf("by_type.%s must be greater than 0", sensorType)
+		}
+	}
+	return nil
+}
+
+// CacheConfig activa la caché en proceso de configuraciones y últimas lecturas
+type CacheConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Size    int           `mapstructure:"size"` // Elementos por caché (0 = 1000)
+	TTL     time.Duration `mapstructure:"ttl"`  // Vida máxima de un elemento (0 = 30s)
+}
+
+// Validate valida la configuración de la caché
+func (c *CacheConfig) Validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("size must not be negative")
+	}
+	if c.TTL < 0 {
+		return fmt.Errorf("ttl must not be negative")
+	}
+	return nil
+}
+
+// InstrumentationConfig activa las métricas por método del repositorio (latencias, errores
+// y filas) y el log de consultas lentas
+type InstrumentationConfig struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"` // Latencia de consulta lenta (0 = 200ms)
+}
+
+// Validate valida la configuración de la instrumentación
+func (i *InstrumentationConfig) Validate() error {
+	if i.SlowQueryThreshold < 0 {
+		return fmt.Errorf("slow_query_threshold must not be negative")
+	}
+	return nil
+}
+
+// BackupConfig define dónde se guardan los snapshots de la base de datos y cuántos se conservan.
+// Con dir configurado los backups pueden pedirse por NATS (sensor.admin.backup) o programarse con interval.
+type BackupConfig struct {
+	Dir      string        `mapstructure:"dir"`      // Directorio de snapshots ("" = backups deshabilitados)
+	Keep     int           `mapstructure:"keep"`     // Snapshots a conservar (0 = 7)
+	Interval time.Duration `mapstructure:"interval"` // Frecuencia de backups programados (0 = solo bajo demanda)
+}
+
+// Validate valida la configuración de backups
+func (b *BackupConfig) Validate() error {
+	if b.Keep < 0 {
+		return fmt.Errorf("keep must not be negative")
+	}
+	if b.Interval < 0 {
+		return fmt.Errorf("interval must not be negative")
+	}
+	if b.Interval > 0 && b.Dir == "" {
+		return fmt.Errorf("dir is required for scheduled backups")
+	}
+	return nil
 }
 
 // HTTPConfig contiene la configuración del servidor HTTP (feat-6)
@@ -54,6 +207,7 @@ type SensorDef struct {
 	Type     sensor.SensorType   `mapstructure:"type"`
 	Name     string              `mapstructure:"name"`
 	Location string              `mapstructure:"location"`
+	Tags     []string            `mapstructure:"tags"`
 	Config   sensor.SensorConfig `mapstructure:"config"`
 }
 
@@ -84,34 +238,211 @@ func (c *Config) Validate() error {
 	if c.Database.Type == "sqlite" && c.Database.Path == "" {
 		return fmt.Errorf("database.path is required for sqlite")
 	}
+	if c.Database.Type == "tsfile" && c.Database.Path == "" {
+		return fmt.Errorf("database.path is required for tsfile")
+	}
+	if c.Database.Type == "influxdb" && (c.Database.URL == "" || c.Database.Org == "" || c.Database.Bucket == "") {
+		return fmt.Errorf("database.url, database.org and database.bucket are required for influxdb")
+	}
+	if err := c.Database.SQLite.Validate(); err != nil {
+		return fmt.Errorf("database.sqlite: %w", err)
+	}
+	if err := c.Database.TSFile.Validate(); err != nil {
+		return fmt.Errorf("database.tsfile: %w", err)
+	}
+	if c.Database.Capacity < 0 {
+		return fmt.Errorf("database.capacity must not be negative")
+	}
+	if c.Database.BatchSize < 0 {
+		return fmt.Errorf("database.batch_size must not be negative")
+	}
+	if c.Database.FlushInterval < 0 {
+		return fmt.Errorf("database.flush_interval must not be negative")
+	}
+	if c.Database.MaxPending < 0 {
+		return fmt.Errorf("database.max_pending must not be negative")
+	}
+	if c.Database.FlushRetries < 0 {
+		return fmt.Errorf("database.flush_retries must not be negative")
+	}
+	if err := c.Database.Retention.Validate(); err != nil {
+		return fmt.Errorf("database.retention: %w", err)
+	}
+	if err := c.Database.Backup.Validate(); err != nil {
+		return fmt.Errorf("database.backup: %w", err)
+	}
+	if c.Database.Backup.Dir != "" && c.Database.Type != "sqlite" {
+		return fmt.Errorf("database.backup is only supported for sqlite")
+	}
+	if err := c.Database.Cache.Validate(); err != nil {
+		return fmt.Errorf("database.cache: %w", err)
+	}
+	if err := c.Database.Instrumentation.Validate(); err != nil {
+		return fmt.Errorf("database.instrumentation: %w", err)
+	}
+
+	// Validar tipos declarados
+	declared := make(map[sensor.SensorType]bool, len(c.SensorTypes))
+	for i, spec := range c.SensorTypes {
+		if err := spec.Validate(); err != nil {
+			return fmt.Errorf("sensor_types[%d]: %w", i, err)
+		}
+		if declared[spec.Type] {
+			return fmt.Errorf("sensor_types[%d]: duplicate type %q", i, spec.Type)
+		}
+		declared[spec.Type] = true
+	}
 
-	// Validar Sensors
-	if len(c.Sensors) == 0 {
-		return fmt.Errorf("at least one sensor must be configured")
+	// Validar Sensors y Devices
+	if len(c.Sensors) == 0 && len(c.Devices) == 0 {
+		return fmt.Errorf("at least one sensor or device must be configured")
 	}
 	for i, s := range c.Sensors {
-		if err := s.Validate(); err != nil {
+		if err := s.validate(declared); err != nil {
 			return fmt.Errorf("sensor[%d]: %w", i, err)
 		}
 	}
+	for i, d := range c.Devices {
+		if err := d.validate(declared); err != nil {
+			return fmt.Errorf("device[%d]: %w", i, err)
+		}
+	}
 
 	return nil
 }
 
-// Validate valida la definición de un sensor
+// Validate valida la definición de un sensor. El tipo debe estar en el catálogo de tipos.
 func (s *SensorDef) Validate() error {
+	return s.validate(nil)
+}
+
+// validate valida la definición aceptando además los tipos declarados en la configuración,
+// que aún no están en el catálogo hasta que el servidor los registra
+func (s *SensorDef) validate(declared map[sensor.SensorType]bool) error {
 	if s.ID == "" {
 		return fmt.Errorf("sensor id is required")
 	}
 	if s.Type == "" {
 		return fmt.Errorf("sensor type is required")
 	}
+	if _, ok := sensor.LookupType(s.Type); !ok && !declared[s.Type] {
+		return fmt.Errorf("unknown sensor type %q (must be: %s)", s.Type, strings.Join(sensor.TypeNames(), ", "))
+	}
 	if s.Name == "" {
 		return fmt.Errorf("sensor name is required")
 	}
 	return s.Config.Validate()
 }
 
+// DeviceDef define un dispositivo que mide varias magnitudes en cada muestra (ej: un BME280
+// con temperatura, humedad y presión). Cada canal es un sensor lógico con su propio ID,
+// configuración y alertas; el dispositivo marca el intervalo y el estado de todos ellos.
+type DeviceDef struct {
+	ID       string       `mapstructure:"id"`
+	Name     string       `mapstructure:"name"`
+	Location string       `mapstructure:"location"`
+	Tags     []string     `mapstructure:"tags"`
+	Interval int          `mapstructure:"interval"` // Intervalo de muestreo en ms
+	Enabled  bool         `mapstructure:"enabled"`
+	Channels []ChannelDef `mapstructure:"channels"`
+}
+
+// ChannelDef define un canal de un dispositivo
+type ChannelDef struct {
+	SensorID  string            `mapstructure:"sensor_id"` // ID del sensor lógico del canal
+	Type      sensor.SensorType `mapstructure:"type"`
+	Name      string            `mapstructure:"name"`      // "" = nombre (o ID) del dispositivo y tipo
+	Threshold *float64          `mapstructure:"threshold"` // nil = umbral por defecto del tipo
+
+	// Límites de alerta y calibración opcionales, como en sensor.SensorConfig
+	LowThreshold *float64            `mapstructure:"low_threshold"`
+	Hysteresis   float64             `mapstructure:"hysteresis"`
+	MaxRate      float64             `mapstructure:"max_rate"`
+	Calibration  *sensor.Calibration `mapstructure:"calibration"`
+}
+
+// Validate valida la definición de un dispositivo. Los tipos deben estar en el catálogo.
+func (d *DeviceDef) Validate() error {
+	return d.validate(nil)
+}
+
+// validate valida el dispositivo aceptando además los tipos declarados en la configuración
+func (d *DeviceDef) validate(declared map[sensor.SensorType]bool) error {
+	if d.ID == "" {
+		return fmt.Errorf("device id is required")
+	}
+	if d.Interval <= 0 {
+		return fmt.Errorf("interval must be greater than 0")
+	}
+	if len(d.Channels) == 0 {
+		return fmt.Errorf("at least one channel must be configured")
+	}
+	seen := make(map[string]bool, len(d.Channels))
+	for i, ch := range d.Channels {
+		if ch.SensorID == "" {
+			return fmt.Errorf("channel[%d]: sensor_id is required", i)
+		}
+		if seen[ch.SensorID] {
+			return fmt.Errorf("channel[%d]: duplicate sensor_id %q", i, ch.SensorID)
+		}
+		seen[ch.SensorID] = true
+		if ch.Type == "" {
+			return fmt.Errorf("channel[%d]: sensor type is required", i)
+		}
+		if _, ok := sensor.LookupType(ch.Type); !ok && !declared[ch.Type] {
+			return fmt.Errorf("channel[%d]: unknown sensor type %q (must be: %s)", i, ch.Type, strings.Join(sensor.TypeNames(), ", "))
+		}
+		if ch.Threshold != nil && ch.LowThreshold != nil && *ch.LowThreshold >= *ch.Threshold {
+			return fmt.Errorf("channel[%d]: low_threshold must be less than threshold", i)
+		}
+		if ch.Hysteresis < 0 || ch.MaxRate < 0 {
+			return fmt.Errorf("channel[%d]: hysteresis and max_rate must not be negative", i)
+		}
+		if err := ch.Calibration.Validate(); err != nil {
+			return fmt.Errorf("channel[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// SensorDefs retorna la definición del sensor lógico de cada canal. Los canales sin
+// umbral toman el del tipo, por lo que los tipos deben estar ya en el catálogo.
+func (d *DeviceDef) SensorDefs() []SensorDef {
+	defs := make([]SensorDef, 0, len(d.Channels))
+	for _, ch := range d.Channels {
+		name := ch.Name
+		if name == "" && d.Name != "" {
+			name = fmt.Sprintf("%s (%s)", d.Name, ch.Type)
+		} else if name == "" {
+			name = fmt.Sprintf("%s (%s)", d.ID, ch.Type)
+		}
+		threshold := 0.0
+		if ch.Threshold != nil {
+			threshold = *ch.Threshold
+		} else if spec, ok := sensor.LookupType(ch.Type); ok {
+			threshold = spec.DefaultThreshold
+		}
+		defs = append(defs, SensorDef{
+			ID:       ch.SensorID,
+			Type:     ch.Type,
+			Name:     name,
+			Location: d.Location,
+			Tags:     d.Tags,
+			Config: sensor.SensorConfig{
+				SensorID:     ch.SensorID,
+				Interval:     d.Interval,
+				Threshold:    threshold,
+				LowThreshold: ch.LowThreshold,
+				Hysteresis:   ch.Hysteresis,
+				MaxRate:      ch.MaxRate,
+				Calibration:  ch.Calibration,
+				Enabled:      d.Enabled,
+			},
+		})
+	}
+	return defs
+}
+
 // Load carga la configuración desde un archivo usando Viper
 func Load(filepath string) (*Config, error) {
 	v := viper.New()
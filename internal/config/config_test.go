@@ -34,6 +34,7 @@ sensors:
     type: temperature
     name: Test Sensor
     location: lab
+    tags: [critico, norte]
     config:
       sensor_id: temp-001
       interval: 5000
@@ -88,6 +89,10 @@ logging:
 		t.Errorf("Expected sensor type 'temperature', got '%s'", sensor.Type)
 	}
 
+	if len(sensor.Tags) != 2 || sensor.Tags[0] != "critico" || sensor.Tags[1] != "norte" {
+		t.Errorf("Expected tags [critico norte], got %v", sensor.Tags)
+	}
+
 	if sensor.Config.Interval != 5000 {
 		t.Errorf("Expected interval 5000, got %d", sensor.Config.Interval)
 	}
@@ -97,6 +102,202 @@ logging:
 	}
 }
 
+func TestLoad_RetentionConfig(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "config-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	configYAML := `
+environment: test
+nats:
+  url: nats://localhost:4222
+  timeout: 10s
+database:
+  type: sqlite
+  path: ./test.db
+  retention:
+    enabled: true
+    max_age: 720h
+    by_type:
+      pressure: 168h
+    rollup: true
+    interval: 1h
+sensors:
+  - id: temp-001
+    type: temperature
+    name: Test Sensor
+    config:
+      sensor_id: temp-001
+      interval: 1000
+      threshold: 30.0
+      enabled: true
+`
+	if _, err := tmpfile.Write([]byte(configYAML)); err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+
+	cfg, err := Load(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	retention := cfg.Database.Retention
+	if !retention.Enabled || !retention.Rollup {
+		t.Errorf("expected retention and rollup enabled, got %+v", retention)
+	}
+	if retention.MaxAge != 720*time.Hour {
+		t.Errorf("expected max_age 720h, got %v", retention.MaxAge)
+	}
+	if retention.ByType["pressure"] != 168*time.Hour {
+		t.Errorf("expected by_type.pressure 168h, got %v", retention.ByType["pressure"])
+	}
+	if retention.Interval != time.Hour {
+		t.Errorf("expected interval 1h, got %v", retention.Interval)
+	}
+}
+
+func TestLoad_SensorTypes(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "config-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	configYAML := `
+environment: test
+nats:
+  url: nats://localhost:4222
+  timeout: 10s
+database:
+  type: sqlite
+  path: ./test.db
+sensor_types:
+  - type: co2
+    unit: ppm
+    min: 0
+    max: 5000
+    generator:
+      base: 600
+      variation: 400
+    default_threshold: 1000
+sensors:
+  - id: co2-001
+    type: co2
+    name: CO2 Sala 1
+    config:
+      sensor_id: co2-001
+      interval: 1000
+      threshold: 1200
+      low_threshold: 400
+      hysteresis: 50
+      max_rate: 200
+      calibration:
+        offset: -35
+        gain: 1.05
+      enabled: true
+devices:
+  - id: air-01
+    interval: 5000
+    enabled: true
+    channels:
+      - sensor_id: air-01-co2
+        type: co2
+        threshold: 1500
+      - sensor_id: air-01-temp
+        type: temperature
+        low_threshold: 0
+        max_rate: 2
+        calibration:
+          polynomial: [0.2, 1.01]
+`
+	if _, err := tmpfile.Write([]byte(configYAML)); err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+
+	cfg, err := Load(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	want := sensor.TypeSpec{
+		Type:             "co2",
+		Unit:             "ppm",
+		Max:              5000,
+		Generator:        sensor.Generator{Base: 600, Variation: 400},
+		DefaultThreshold: 1000,
+	}
+	if len(cfg.SensorTypes) != 1 || cfg.SensorTypes[0] != want {
+		t.Errorf("expected sensor_types [%+v], got %+v", want, cfg.SensorTypes)
+	}
+
+	// Los canales de los dispositivos pueden usar tipos declarados
+	if len(cfg.Devices) != 1 || len(cfg.Devices[0].Channels) != 2 {
+		t.Fatalf("expected 1 device with 2 channels, got %+v", cfg.Devices)
+	}
+	channels := cfg.Devices[0].Channels
+	if channels[0].Threshold == nil || *channels[0].Threshold != 1500 || channels[1].Threshold != nil {
+		t.Errorf("expected threshold 1500 on air-01-co2 and none on air-01-temp, got %v and %v", channels[0].Threshold, channels[1].Threshold)
+	}
+
+	// Límites de alerta adicionales (un umbral inferior de 0 es un límite, no su ausencia)
+	co2 := cfg.Sensors[0].Config
+	if co2.LowThreshold == nil || *co2.LowThreshold != 400 || co2.Hysteresis != 50 || co2.MaxRate != 200 {
+		t.Errorf("expected low_threshold 400, hysteresis 50 and max_rate 200, got %+v", co2)
+	}
+	temp := cfg.Devices[0].SensorDefs()[1].Config
+	if temp.LowThreshold == nil || *temp.LowThreshold != 0 || temp.MaxRate != 2 {
+		t.Errorf("expected air-01-temp with low_threshold 0 and max_rate 2, got %+v", temp)
+	}
+
+	// Calibración de sensores y canales
+	if co2.Calibration == nil || co2.Calibration.Offset != -35 || co2.Calibration.Gain != 1.05 {
+		t.Errorf("expected co2-001 calibration offset -35 and gain 1.05, got %+v", co2.Calibration)
+	}
+	if !temp.Calibration.Equal(&sensor.Calibration{Polynomial: []float64{0.2, 1.01}}) {
+		t.Errorf("expected air-01-temp polynomial calibration, got %+v", temp.Calibration)
+	}
+}
+
+func TestDeviceDef_SensorDefs(t *testing.T) {
+	threshold := 25.0
+	device := DeviceDef{
+		ID:       "bme-01",
+		Name:     "BME280",
+		Location: "sala",
+		Tags:     []string{"planta-1"},
+		Interval: 2000,
+		Enabled:  true,
+		Channels: []ChannelDef{
+			{SensorID: "bme-01-temp", Type: sensor.SensorTypeTemperature, Threshold: &threshold},
+			{SensorID: "bme-01-hum", Type: sensor.SensorTypeHumidity, Name: "Humedad sala"},
+		},
+	}
+
+	defs := device.SensorDefs()
+	if len(defs) != 2 {
+		t.Fatalf("expected 2 sensor definitions, got %d", len(defs))
+	}
+	if defs[0].Name != "BME280 (temperature)" || defs[0].Config.Threshold != 25 {
+		t.Errorf("unexpected temperature channel %+v", defs[0])
+	}
+	humidity, _ := sensor.LookupType(sensor.SensorTypeHumidity)
+	if defs[1].Name != "Humedad sala" || defs[1].Config.Threshold != humidity.DefaultThreshold {
+		t.Errorf("unexpected humidity channel %+v", defs[1])
+	}
+	for _, def := range defs {
+		if def.Location != "sala" || def.Config.Interval != 2000 || !def.Config.Enabled || def.Config.SensorID != def.ID {
+			t.Errorf("channel %s does not inherit the device settings: %+v", def.ID, def)
+		}
+		if err := def.Validate(); err != nil {
+			t.Errorf("channel %s is invalid: %v", def.ID, err)
+		}
+	}
+}
+
 func TestLoad_FileNotFound(t *testing.T) {
 	_, err := Load("nonexistent.yaml")
 	if err == nil {
@@ -208,6 +409,377 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid influxdb",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:   "influxdb",
+					URL:    "http://localhost:8086",
+					Token:  "token",
+					Org:    "iot",
+					Bucket: "sensors",
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: false,
+		},
+		{
+			name: "influxdb missing bucket",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "influxdb",
+					URL:  "http://localhost:8086",
+					Org:  "iot",
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sqlite pragmas",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "sqlite",
+					Path: "./test.db",
+					SQLite: SQLiteConfig{
+						BusyTimeout: 2 * time.Second,
+						Synchronous: "full",
+						CacheSize:   -16000,
+						ReadConns:   8,
+					},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: false,
+		},
+		{
+			name: "sqlite invalid synchronous",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:   "sqlite",
+					Path:   "./test.db",
+					SQLite: SQLiteConfig{Synchronous: "SOMETIMES"},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sqlite negative read_conns",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:   "sqlite",
+					Path:   "./test.db",
+					SQLite: SQLiteConfig{ReadConns: -1},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "scheduled backups",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:   "sqlite",
+					Path:   "./test.db",
+					Backup: BackupConfig{Dir: "./backups", Keep: 3, Interval: 24 * time.Hour},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: false,
+		},
+		{
+			name: "scheduled backups without dir",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:   "sqlite",
+					Path:   "./test.db",
+					Backup: BackupConfig{Interval: time.Hour},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "backups on memory backend",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:   "memory",
+					Backup: BackupConfig{Dir: "./backups"},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cache with negative ttl",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:  "sqlite",
+					Path:  "./test.db",
+					Cache: CacheConfig{Enabled: true, TTL: -time.Second},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "instrumentation with negative slow query threshold",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:            "sqlite",
+					Path:            "./test.db",
+					Instrumentation: InstrumentationConfig{Enabled: true, SlowQueryThreshold: -time.Millisecond},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tsfile missing path",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:   "tsfile",
+					TSFile: TSFileConfig{BlockSize: 512},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "tsfile with negative segment size",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:   "tsfile",
+					Path:   "./data/tsfile",
+					TSFile: TSFileConfig{SegmentSize: -1},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "sensor with declared type",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "sqlite",
+					Path: "./test.db",
+				},
+				SensorTypes: []sensor.TypeSpec{{Type: "light", Unit: "lx", Min: 0, Max: 100000}},
+				Sensors: []SensorDef{{
+					ID:     "light-001",
+					Type:   "light",
+					Name:   "Luz",
+					Config: sensor.SensorConfig{SensorID: "light-001", Interval: 1000},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "sensor type with invalid range",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "sqlite",
+					Path: "./test.db",
+				},
+				SensorTypes: []sensor.TypeSpec{{Type: "light", Unit: "lx", Min: 10, Max: 10}},
+				Sensors:     []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "only devices configured",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "sqlite",
+					Path: "./test.db",
+				},
+				Devices: []DeviceDef{{
+					ID:       "bme-01",
+					Interval: 1000,
+					Channels: []ChannelDef{
+						{SensorID: "bme-01-temp", Type: sensor.SensorTypeTemperature},
+						{SensorID: "bme-01-hum", Type: sensor.SensorTypeHumidity},
+					},
+				}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "device with duplicate channel",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "sqlite",
+					Path: "./test.db",
+				},
+				Devices: []DeviceDef{{
+					ID:       "bme-01",
+					Interval: 1000,
+					Channels: []ChannelDef{
+						{SensorID: "bme-01-temp", Type: sensor.SensorTypeTemperature},
+						{SensorID: "bme-01-temp", Type: sensor.SensorTypeHumidity},
+					},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device channel with unknown type",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "sqlite",
+					Path: "./test.db",
+				},
+				Devices: []DeviceDef{{
+					ID:       "air-01",
+					Interval: 1000,
+					Channels: []ChannelDef{{SensorID: "air-01-co2", Type: "co2"}},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "memory with negative capacity",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type:     "memory",
+					Capacity: -1,
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retention enabled without interval",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "sqlite",
+					Path: "./test.db",
+					Retention: RetentionConfig{
+						Enabled: true,
+						MaxAge:  720 * time.Hour,
+					},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: true,
+		},
+		{
+			name: "retention by type",
+			config: &Config{
+				Environment: "test",
+				NATS: NATSConfig{
+					URL:     "nats://localhost:4222",
+					Timeout: 10 * time.Second,
+				},
+				Database: DatabaseConfig{
+					Type: "sqlite",
+					Path: "./test.db",
+					Retention: RetentionConfig{
+						Enabled:  true,
+						ByType:   map[string]time.Duration{"pressure": 24 * time.Hour},
+						Interval: time.Hour,
+					},
+				},
+				Sensors: []SensorDef{validSensor},
+			},
+			wantErr: false,
+		},
 		{
 			name: "no sensors configured",
 			config: &Config{
@@ -299,6 +871,19 @@ func TestSensorDef_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "unknown type",
+			sensorDef: SensorDef{
+				ID:   "co2-001",
+				Type: "co2",
+				Name: "CO2 Sensor",
+				Config: sensor.SensorConfig{
+					SensorID: "co2-001",
+					Interval: 5000,
+				},
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid config (interval <= 0)",
 			sensorDef: SensorDef{
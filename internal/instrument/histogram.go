@@ -0,0 +1,87 @@
+package instrument
+
+import "time"
+
+// bucketBounds son los límites superiores (inclusivos) de los buckets de latencia.
+// Las llamadas más lentas que el último van al bucket "+Inf".
+var bucketBounds = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+}
+
+// Bucket es un tramo del histograma: llamadas con latencia <= Le (y mayor que el anterior)
+type Bucket struct {
+	Le    string `json:"le"`
+	Count int64  `json:"count"`
+}
+
+// histogram acumula latencias en los buckets fijos de bucketBounds. No es seguro para
+// uso concurrente: lo protege el mutex del método al que pertenece.
+type histogram struct {
+	counts [len(bucketBounds) + 1]int64 // El último es "+Inf"
+	total  time.Duration
+	max    time.Duration
+	n      int64
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := 0
+	for i < len(bucketBounds) && d > bucketBounds[i] {
+		i++
+	}
+	h.counts[i]++
+	h.total += d
+	h.n++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+// buckets retorna los tramos del histograma con su etiqueta
+func (h *histogram) buckets() []Bucket {
+	buckets := make([]Bucket, len(h.counts))
+	for i, count := range h.counts {
+		le := "+Inf"
+		if i < len(bucketBounds) {
+			le = bucketBounds[i].String()
+		}
+		buckets[i] = Bucket{Le: le, Count: count}
+	}
+	return buckets
+}
+
+// quantile estima el percentil q (0-1) como el límite superior del bucket que lo contiene;
+// en el bucket "+Inf" se usa la latencia máxima observada
+func (h *histogram) quantile(q float64) time.Duration {
+	if h.n == 0 {
+		return 0
+	}
+	rank := int64(q*float64(h.n) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			if i < len(bucketBounds) {
+				return bucketBounds[i]
+			}
+			break
+		}
+	}
+	return h.max
+}
+
+// ms convierte una duración a milisegundos con decimales
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,325 @@
+package instrument
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/logger"
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultSlowThreshold = 200 * time.Millisecond
+
+// Options configura el decorador de instrumentación
+type Options struct {
+	// SlowThreshold es la latencia a partir de la cual se registra la consulta como lenta
+	// (<= 0 = 200ms)
+	SlowThreshold time.Duration
+}
+
+// MethodStats contiene las métricas acumuladas de un método del repositorio
+type MethodStats struct {
+	Calls     int64    `json:"calls"`
+	Errors    int64    `json:"errors"`
+	Rows      int64    `json:"rows"`
+	SlowCalls int64    `json:"slow_calls"`
+	AvgMs     float64  `json:"avg_ms"`
+	MaxMs     float64  `json:"max_ms"`
+	P50Ms     float64  `json:"p50_ms"`
+	P95Ms     float64  `json:"p95_ms"`
+	P99Ms     float64  `json:"p99_ms"`
+	Histogram []Bucket `json:"histogram"`
+}
+
+// Stats agrupa las métricas de todos los métodos invocados al menos una vez
+type Stats struct {
+	SlowThresholdMs float64                `json:"slow_threshold_ms"`
+	Methods         map[string]MethodStats `json:"methods"`
+}
+
+// method acumula las métricas de un método
+type method struct {
+	hist   histogram
+	errors int64
+	rows   int64
+	slow   int64
+}
+
+// Repository decora un repository.Repository midiendo cada llamada: histograma de
+// latencias, errores y filas leídas o escritas por método. Las llamadas más lentas que
+// Options.SlowThreshold se registran con logger como consultas lentas.
+type Repository struct {
+	repo          repository.Repository
+	slowThreshold time.Duration
+
+	mu      sync.Mutex
+	methods map[string]*method
+}
+
+// Asegurar que Repository implementa repository.Repository
+var _ repository.Repository = (*Repository)(nil)
+
+// New crea el decorador sobre repo
+func New(repo repository.Repository, opts Options) *Repository {
+	if opts.SlowThreshold <= 0 {
+		opts.SlowThreshold = defaultSlowThreshold
+	}
+
+	return &Repository{
+		repo:          repo,
+		slowThreshold: opts.SlowThreshold,
+		methods:       make(map[string]*method),
+	}
+}
+
+// Unwrap retorna el repositorio decorado
+func (r *Repository) Unwrap() repository.Repository {
+	return r.repo
+}
+
+// Stats retorna una copia de las métricas por método
+func (r *Repository) Stats() Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := Stats{
+		SlowThresholdMs: ms(r.slowThreshold),
+		Methods:         make(map[string]MethodStats, len(r.methods)),
+	}
+	for name, m := range r.methods {
+		s := MethodStats{
+			Calls:     m.hist.n,
+			Errors:    m.errors,
+			Rows:      m.rows,
+			SlowCalls: m.slow,
+			MaxMs:     ms(m.hist.max),
+			P50Ms:     ms(m.hist.quantile(0.50)),
+			P95Ms:     ms(m.hist.quantile(0.95)),
+			P99Ms:     ms(m.hist.quantile(0.99)),
+			Histogram: m.hist.buckets(),
+		}
+		if m.hist.n > 0 {
+			s.AvgMs = ms(m.hist.total) / float64(m.hist.n)
+		}
+		stats.Methods[name] = s
+	}
+	return stats
+}
+
+// observe registra una llamada a name que empezó en start
+func (r *Repository) observe(name string, start time.Time, rows int, err error) {
+	elapsed := time.Since(start)
+	slow := elapsed >= r.slowThreshold
+
+	r.mu.Lock()
+	m, ok := r.methods[name]
+	if !ok {
+		m = &method{}
+		r.methods[name] = m
+	}
+	m.hist.observe(elapsed)
+	m.rows += int64(rows)
+	if err != nil {
+		m.errors++
+	}
+	if slow {
+		m.slow++
+	}
+	r.mu.Unlock()
+
+	if slow {
+		fields := logrus.Fields{
+			"method":      name,
+			"duration_ms": ms(elapsed),
+			"rows":        rows,
+		}
+		if err != nil {
+			fields["error"] = err
+		}
+		logger.WithFields(fields).Warn("[Repository] Slow query")
+	}
+}
+
+// SaveReading mide la escritura de una lectura
+func (r *Repository) SaveReading(ctx context.Context, reading *sensor.SensorReading) error {
+	start := time.Now()
+	err := r.repo.SaveReading(ctx, reading)
+	r.observe("SaveReading", start, rowsIf(err, 1), err)
+	return err
+}
+
+// SaveReadings mide la escritura de un lote
+func (r *Repository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	start := time.Now()
+	err := r.repo.SaveReadings(ctx, readings)
+	r.observe("SaveReadings", start, rowsIf(err, len(readings)), err)
+	return err
+}
+
+// ImportReadings mide la importación de un lote; las filas son las lecturas no omitidas
+func (r *Repository) ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]repository.SkippedReading, error) {
+	start := time.Now()
+	skipped, err := r.repo.ImportReadings(ctx, readings)
+	r.observe("ImportReadings", start, rowsIf(err, len(readings)-len(skipped)), err)
+	return skipped, err
+}
+
+// GetLatestReadings mide la consulta de las últimas lecturas
+func (r *Repository) GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error) {
+	start := time.Now()
+	readings, err := r.repo.GetLatestReadings(ctx, sensorID, limit)
+	r.observe("GetLatestReadings", start, len(readings), err)
+	return readings, err
+}
+
+// GetReadingsPage mide la consulta de una página de lecturas
+func (r *Repository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	start := time.Now()
+	readings, next, err := r.repo.GetReadingsPage(ctx, sensorID, cursor, limit)
+	r.observe("GetReadingsPage", start, len(readings), err)
+	return readings, next, err
+}
+
+// GetReadingsByTimeRange mide la consulta por rango temporal
+func (r *Repository) GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error) {
+	begin := time.Now()
+	readings, err := r.repo.GetReadingsByTimeRange(ctx, sensorID, start, end)
+	r.observe("GetReadingsByTimeRange", begin, len(readings), err)
+	return readings, err
+}
+
+// GetReadingsRangePage mide la consulta de una página ascendente de un rango temporal
+func (r *Repository) GetReadingsRangePage(ctx context.Context, sensorID string, start, end time.Time, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	begin := time.Now()
+	readings, next, err := r.repo.GetReadingsRangePage(ctx, sensorID, start, end, cursor, limit)
+	r.observe("GetReadingsRangePage", begin, len(readings), err)
+	return readings, next, err
+}
+
+// SearchReadings mide la búsqueda por metadatos de sensor
+func (r *Repository) SearchReadings(ctx context.Context, query repository.ReadingSearch) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	start := time.Now()
+	readings, next, err := r.repo.SearchReadings(ctx, query)
+	r.observe("SearchReadings", start, len(readings), err)
+	return readings, next, err
+}
+
+// GetAggregatedReadings mide la consulta de agregados; las filas son los buckets
+func (r *Repository) GetAggregatedReadings(ctx context.Context, sensorID string, start, end time.Time, bucket time.Duration) ([]*sensor.ReadingAggregate, error) {
+	begin := time.Now()
+	aggregates, err := r.repo.GetAggregatedReadings(ctx, sensorID, start, end, bucket)
+	r.observe("GetAggregatedReadings", begin, len(aggregates), err)
+	return aggregates, err
+}
+
+// ApplyRetention mide una pasada de retención; las filas son las lecturas eliminadas
+func (r *Repository) ApplyRetention(ctx context.Context, policy repository.RetentionPolicy) (int64, error) {
+	start := time.Now()
+	deleted, err := r.repo.ApplyRetention(ctx, policy)
+	r.observe("ApplyRetention", start, int(deleted), err)
+	return deleted, err
+}
+
+// SaveConfig mide la escritura de una configuración
+func (r *Repository) SaveConfig(ctx context.Context, config *sensor.SensorConfig) error {
+	start := time.Now()
+	err := r.repo.SaveConfig(ctx, config)
+	r.observe("SaveConfig", start, rowsIf(err, 1), err)
+	return err
+}
+
+// GetConfig mide la lectura de una configuración
+func (r *Repository) GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
+	start := time.Now()
+	config, err := r.repo.GetConfig(ctx, sensorID)
+	r.observe("GetConfig", start, rowsIf(err, 1), err)
+	return config, err
+}
+
+// DeleteConfig mide el borrado de una configuración
+func (r *Repository) DeleteConfig(ctx context.Context, sensorID string) error {
+	start := time.Now()
+	err := r.repo.DeleteConfig(ctx, sensorID)
+	r.observe("DeleteConfig", start, 0, err)
+	return err
+}
+
+// GetConfigHistory mide la consulta del historial de configuración
+func (r *Repository) GetConfigHistory(ctx context.Context, sensorID string, limit int) ([]*sensor.ConfigRevision, error) {
+	start := time.Now()
+	revisions, err := r.repo.GetConfigHistory(ctx, sensorID, limit)
+	r.observe("GetConfigHistory", start, len(revisions), err)
+	return revisions, err
+}
+
+// SaveAlert mide la escritura de una alerta
+func (r *Repository) SaveAlert(ctx context.Context, alert *sensor.Alert) error {
+	start := time.Now()
+	err := r.repo.SaveAlert(ctx, alert)
+	r.observe("SaveAlert", start, rowsIf(err, 1), err)
+	return err
+}
+
+// GetAlerts mide la consulta de alertas
+func (r *Repository) GetAlerts(ctx context.Context, filter repository.AlertFilter) ([]*sensor.Alert, error) {
+	start := time.Now()
+	alerts, err := r.repo.GetAlerts(ctx, filter)
+	r.observe("GetAlerts", start, len(alerts), err)
+	return alerts, err
+}
+
+// SaveSensor mide la escritura de los metadatos de un sensor
+func (r *Repository) SaveSensor(ctx context.Context, s *sensor.Sensor) error {
+	start := time.Now()
+	err := r.repo.SaveSensor(ctx, s)
+	r.observe("SaveSensor", start, rowsIf(err, 1), err)
+	return err
+}
+
+// GetSensor mide la lectura de los metadatos de un sensor
+func (r *Repository) GetSensor(ctx context.Context, sensorID string) (*sensor.Sensor, error) {
+	start := time.Now()
+	s, err := r.repo.GetSensor(ctx, sensorID)
+	r.observe("GetSensor", start, rowsIf(err, 1), err)
+	return s, err
+}
+
+// ListSensors mide el listado de sensores
+func (r *Repository) ListSensors(ctx context.Context) ([]*sensor.Sensor, error) {
+	start := time.Now()
+	sensors, err := r.repo.ListSensors(ctx)
+	r.observe("ListSensors", start, len(sensors), err)
+	return sensors, err
+}
+
+// DeleteSensor mide el borrado de los metadatos de un sensor
+func (r *Repository) DeleteSensor(ctx context.Context, sensorID string) error {
+	start := time.Now()
+	err := r.repo.DeleteSensor(ctx, sensorID)
+	r.observe("DeleteSensor", start, 0, err)
+	return err
+}
+
+// PurgeSensorData mide el borrado de los datos de un sensor; las filas son las lecturas eliminadas
+func (r *Repository) PurgeSensorData(ctx context.Context, sensorID string) (int64, error) {
+	start := time.Now()
+	deleted, err := r.repo.PurgeSensorData(ctx, sensorID)
+	r.observe("PurgeSensorData", start, int(deleted), err)
+	return deleted, err
+}
+
+// Close cierra el repositorio decorado
+func (r *Repository) Close() error {
+	return r.repo.Close()
+}
+
+// rowsIf retorna n si la llamada tuvo éxito y 0 si falló
+func rowsIf(err error, n int) int {
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,148 @@
+package instrument
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/logger"
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/alejandro/technical_test_uvigo/internal/storage"
+)
+
+func newTestRepository(t *testing.T, opts Options) *Repository {
+	t.Helper()
+
+	repo, err := storage.NewMemoryRepository(100, "")
+	if err != nil {
+		t.Fatalf("failed to create memory repository: %v", err)
+	}
+	instrumented := New(repo, opts)
+	t.Cleanup(func() { instrumented.Close() })
+
+	return instrumented
+}
+
+func TestRepository_RecordsCallsRowsAndErrors(t *testing.T) {
+	repo := newTestRepository(t, Options{SlowThreshold: time.Hour})
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	readings := []*sensor.SensorReading{
+		{ID: "r1", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 20, Timestamp: base},
+		{ID: "r2", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 21, Timestamp: base.Add(time.Second)},
+		{ID: "r3", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 22, Timestamp: base.Add(2 * time.Second)},
+	}
+	if err := repo.SaveReadings(ctx, readings); err != nil {
+		t.Fatalf("SaveReadings failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, _, err := repo.GetReadingsPage(ctx, "temp-001", nil, 2); err != nil {
+			t.Fatalf("GetReadingsPage failed: %v", err)
+		}
+	}
+	if _, err := repo.GetConfig(ctx, "missing"); err == nil {
+		t.Fatal("expected error for missing config")
+	}
+
+	stats := repo.Stats()
+	if got := stats.Methods["SaveReadings"]; got.Calls != 1 || got.Rows != 3 || got.Errors != 0 {
+		t.Errorf("unexpected SaveReadings stats: %+v", got)
+	}
+	if got := stats.Methods["GetReadingsPage"]; got.Calls != 2 || got.Rows != 4 {
+		t.Errorf("unexpected GetReadingsPage stats: %+v", got)
+	}
+	if got := stats.Methods["GetConfig"]; got.Calls != 1 || got.Rows != 0 || got.Errors != 1 {
+		t.Errorf("unexpected GetConfig stats: %+v", got)
+	}
+	if _, ok := stats.Methods["SaveReading"]; ok {
+		t.Error("expected methods never called to be absent")
+	}
+
+	page := stats.Methods["GetReadingsPage"]
+	var total int64
+	for _, bucket := range page.Histogram {
+		total += bucket.Count
+	}
+	if total != 2 || len(page.Histogram) != len(bucketBounds)+1 || page.Histogram[len(page.Histogram)-1].Le != "+Inf" {
+		t.Errorf("unexpected histogram: %+v", page.Histogram)
+	}
+	if stats.SlowThresholdMs != float64(time.Hour/time.Millisecond) {
+		t.Errorf("expected slow threshold of 1h, got %vms", stats.SlowThresholdMs)
+	}
+}
+
+func TestRepository_SlowQueryLog(t *testing.T) {
+	var buf bytes.Buffer
+	log := logger.GetLogger()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stdout) })
+
+	repo := newTestRepository(t, Options{SlowThreshold: time.Nanosecond})
+	repo.ListSensors(context.Background())
+
+	if !strings.Contains(buf.String(), "[Repository] Slow query") || !strings.Contains(buf.String(), "method=ListSensors") {
+		t.Errorf("expected a slow query log for ListSensors, got %q", buf.String())
+	}
+	if got := repo.Stats().Methods["ListSensors"]; got.SlowCalls != 1 {
+		t.Errorf("expected 1 slow call, got %+v", got)
+	}
+}
+
+func TestRepository_Unwrap(t *testing.T) {
+	base, err := storage.NewMemoryRepository(10, "")
+	if err != nil {
+		t.Fatalf("failed to create memory repository: %v", err)
+	}
+	repo := New(base, Options{})
+	defer repo.Close()
+
+	if repository.Unwrap(repo) != repository.Repository(base) {
+		t.Error("Unwrap() did not return the decorated repository")
+	}
+	if repo.Stats().SlowThresholdMs != float64(defaultSlowThreshold/time.Millisecond) {
+		t.Errorf("expected the default slow threshold, got %vms", repo.Stats().SlowThresholdMs)
+	}
+}
+
+func TestHistogram_Quantiles(t *testing.T) {
+	var h histogram
+	for i := 0; i < 90; i++ {
+		h.observe(3 * time.Millisecond) // bucket 5ms
+	}
+	for i := 0; i < 9; i++ {
+		h.observe(80 * time.Millisecond) // bucket 100ms
+	}
+	h.observe(4 * time.Second) // bucket +Inf
+
+	tests := []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.50, 5 * time.Millisecond},
+		{0.95, 100 * time.Millisecond},
+		{0.99, 100 * time.Millisecond},
+		{1.00, 4 * time.Second}, // En +Inf se usa el máximo observado
+	}
+	for _, tt := range tests {
+		if got := h.quantile(tt.q); got != tt.want {
+			t.Errorf("quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+
+	if got := h.buckets()[0]; got.Le != "1ms" || got.Count != 0 {
+		t.Errorf("unexpected first bucket: %+v", got)
+	}
+	if h.max != 4*time.Second || h.n != 100 {
+		t.Errorf("unexpected max/count: %v/%d", h.max, h.n)
+	}
+
+	var empty histogram
+	if empty.quantile(0.99) != 0 {
+		t.Error("expected 0 for an empty histogram")
+	}
+}
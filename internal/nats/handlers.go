@@ -4,8 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/alejandro/technical_test_uvigo/internal/backup"
+	"github.com/alejandro/technical_test_uvigo/internal/cache"
 	"github.com/alejandro/technical_test_uvigo/internal/config"
 	"github.com/alejandro/technical_test_uvigo/internal/logger"
 	"github.com/alejandro/technical_test_uvigo/internal/repository"
@@ -17,9 +21,23 @@ import (
 type Handler struct {
 	client       *Client
 	repo         repository.Repository
-	addSensor    func(config.SensorDef) error            // Callback para añadir sensores dinámicamente
-	listSensors  func() []config.SensorDef               // Callback para listar todos los sensores
-	updateConfig func(string, sensor.SensorConfig) error // Callback para actualizar config de sensores
+	addSensor    func(config.SensorDef) error                    // Callback para añadir sensores dinámicamente
+	removeSensor func(string) error                              // Callback para dar de baja sensores
+	listSensors  func() []config.SensorDef                       // Callback para listar todos los sensores
+	updateConfig func(string, sensor.SensorConfig) error         // Callback para actualizar config de sensores
+	metrics      func() map[string]interface{}                   // Callback para obtener métricas internas
+	backup       func(context.Context) (*backup.Snapshot, error) // Callback para generar un backup
+	cacheStats   func() cache.RepositoryStats                    // Callback para las métricas de la caché
+	configEvent  func(ConfigChangedEvent)                        // Callback para cambios de config de otros procesos
+}
+
+// ConfigChangedEvent se publica en sensor.config.changed.<id> cada vez que un handler
+// guarda o elimina la configuración de un sensor
+type ConfigChangedEvent struct {
+	SensorID  string    `json:"sensor_id"`
+	Reason    string    `json:"reason"`
+	ChangedBy string    `json:"changed_by"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // NewHandler crea un nuevo handler con cliente NATS y repositorio
@@ -35,6 +53,11 @@ func (h *Handler) SetAddSensorCallback(callback func(config.SensorDef) error) {
 	h.addSensor = callback
 }
 
+// SetRemoveSensorCallback configura el callback para dar de baja sensores del simulador
+func (h *Handler) SetRemoveSensorCallback(callback func(string) error) {
+	h.removeSensor = callback
+}
+
 // SetListSensorsCallback configura el callback para listar sensores
 func (h *Handler) SetListSensorsCallback(callback func() []config.SensorDef) {
 	h.listSensors = callback
@@ -45,6 +68,27 @@ func (h *Handler) SetUpdateConfigCallback(callback func(string, sensor.SensorCon
 	h.updateConfig = callback
 }
 
+// SetMetricsCallback configura el callback que expone las métricas del servidor
+func (h *Handler) SetMetricsCallback(callback func() map[string]interface{}) {
+	h.metrics = callback
+}
+
+// SetBackupCallback configura el callback que genera un backup de la base de datos
+func (h *Handler) SetBackupCallback(callback func(context.Context) (*backup.Snapshot, error)) {
+	h.backup = callback
+}
+
+// SetCacheStatsCallback configura el callback que expone las métricas de la caché
+func (h *Handler) SetCacheStatsCallback(callback func() cache.RepositoryStats) {
+	h.cacheStats = callback
+}
+
+// SetConfigChangedCallback configura el callback que recibe los eventos de
+// sensor.config.changed.<id> (incluidos los que publica este mismo handler)
+func (h *Handler) SetConfigChangedCallback(callback func(ConfigChangedEvent)) {
+	h.configEvent = callback
+}
+
 // HandleConfigRequests inicia los handlers para peticiones de configuración (GET y SET)
 func (h *Handler) HandleConfigRequests() error {
 	// Handler para obtener configuración (GET)
@@ -63,6 +107,30 @@ func (h *Handler) HandleConfigRequests() error {
 		return fmt.Errorf("failed to subscribe to config.set: %w", err)
 	}
 
+	// Handler para consultar el historial de configuración
+	_, err = h.client.Subscribe("sensor.config.history.*", func(msg *natslib.Msg) {
+		h.handleConfigHistory(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to config.history: %w", err)
+	}
+
+	// Handler para restaurar una revisión anterior de configuración
+	_, err = h.client.Subscribe("sensor.config.rollback.*", func(msg *natslib.Msg) {
+		h.handleConfigRollback(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to config.rollback: %w", err)
+	}
+
+	// Handler para calibrar sensores frente a medidas de referencia
+	_, err = h.client.Subscribe("sensor.calibrate.*", func(msg *natslib.Msg) {
+		h.handleCalibrate(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to sensor.calibrate: %w", err)
+	}
+
 	// Handler para consultar últimas lecturas
 	_, err = h.client.Subscribe("sensor.readings.query.*", func(msg *natslib.Msg) {
 		h.handleReadingsQuery(msg)
@@ -71,6 +139,46 @@ func (h *Handler) HandleConfigRequests() error {
 		return fmt.Errorf("failed to subscribe to readings.query: %w", err)
 	}
 
+	// Handler para consultar agregados por bucket temporal
+	_, err = h.client.Subscribe("sensor.readings.stats.*", func(msg *natslib.Msg) {
+		h.handleReadingsStats(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to readings.stats: %w", err)
+	}
+
+	// Handler para exportar lecturas por trozos
+	_, err = h.client.Subscribe("sensor.readings.export.*", func(msg *natslib.Msg) {
+		h.handleReadingsExport(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to readings.export: %w", err)
+	}
+
+	// Handler para importar lecturas históricas
+	_, err = h.client.Subscribe("sensor.readings.import", func(msg *natslib.Msg) {
+		h.handleReadingsImport(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to readings.import: %w", err)
+	}
+
+	// Handler para buscar lecturas por metadatos de sensor
+	_, err = h.client.Subscribe("sensor.readings.search", func(msg *natslib.Msg) {
+		h.handleReadingsSearch(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to readings.search: %w", err)
+	}
+
+	// Handler para consultar alertas persistidas
+	_, err = h.client.Subscribe("sensor.alerts.query", func(msg *natslib.Msg) {
+		h.handleAlertsQuery(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to alerts.query: %w", err)
+	}
+
 	// Handler para registrar nuevos sensores
 	_, err = h.client.Subscribe("sensor.register", func(msg *natslib.Msg) {
 		h.handleRegister(msg)
@@ -79,6 +187,14 @@ func (h *Handler) HandleConfigRequests() error {
 		return fmt.Errorf("failed to subscribe to sensor.register: %w", err)
 	}
 
+	// Handler para dar de baja sensores
+	_, err = h.client.Subscribe("sensor.remove.*", func(msg *natslib.Msg) {
+		h.handleRemove(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to sensor.remove: %w", err)
+	}
+
 	// Handler para listar todos los sensores
 	_, err = h.client.Subscribe("sensor.list", func(msg *natslib.Msg) {
 		h.handleList(msg)
@@ -87,6 +203,40 @@ func (h *Handler) HandleConfigRequests() error {
 		return fmt.Errorf("failed to subscribe to sensor.list: %w", err)
 	}
 
+	// Handler para consultar métricas internas
+	_, err = h.client.Subscribe("sensor.metrics", func(msg *natslib.Msg) {
+		h.handleMetrics(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to sensor.metrics: %w", err)
+	}
+
+	// Handler para solicitar un backup online de la base de datos
+	_, err = h.client.Subscribe("sensor.admin.backup", func(msg *natslib.Msg) {
+		h.handleBackup(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to admin.backup: %w", err)
+	}
+
+	// Handler para consultar las métricas de la caché
+	_, err = h.client.Subscribe("sensor.cache.stats", func(msg *natslib.Msg) {
+		h.handleCacheStats(msg)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to cache.stats: %w", err)
+	}
+
+	// Eventos de cambio de configuración (solo si hay quien los consuma)
+	if h.configEvent != nil {
+		_, err = h.client.Subscribe("sensor.config.changed.*", func(msg *natslib.Msg) {
+			h.handleConfigChanged(msg)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to config.changed: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -99,15 +249,55 @@ func (h *Handler) handleConfigGet(msg *natslib.Msg) {
 		return
 	}
 
+	// Body opcional: {"unit": "°F"} para recibir los límites de alerta en otra unidad
+	var req UnitRequest
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.replyError(msg, fmt.Sprintf("invalid config request: %v", err))
+			return
+		}
+	}
+	if err := req.normalize(); err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
 	// Obtener configuración del repositorio
-	config, err := h.repo.GetConfig(context.Background(), sensorID)
+	ctx := context.Background()
+	config, err := h.repo.GetConfig(ctx, sensorID)
 	if err != nil || config == nil {
 		h.replyError(msg, fmt.Sprintf("config not found for sensor %s", sensorID))
 		return
 	}
 
+	// Los límites se guardan en la unidad del sensor
+	response := struct {
+		sensor.SensorConfig
+		Unit string `json:"unit,omitempty"`
+	}{SensorConfig: *config}
+	if unit, err := h.sensorUnit(ctx, sensorID); err == nil {
+		response.Unit = unit
+	}
+	if req.Unit != "" || len(req.Units) > 0 {
+		if response.Unit == "" {
+			h.replyError(msg, fmt.Sprintf("unknown unit for sensor %s", sensorID))
+			return
+		}
+		target, err := req.target(response.Unit, true)
+		if err == nil && target != "" {
+			var converted *sensor.SensorConfig
+			if converted, err = config.InUnit(response.Unit, target); err == nil {
+				response.SensorConfig, response.Unit = *converted, target
+			}
+		}
+		if err != nil {
+			h.replyError(msg, err.Error())
+			return
+		}
+	}
+
 	// Responder con la configuración
-	data, err := json.Marshal(config)
+	data, err := json.Marshal(response)
 	if err != nil {
 		h.replyError(msg, "failed to marshal config")
 		return
@@ -125,12 +315,31 @@ func (h *Handler) handleConfigSet(msg *natslib.Msg) {
 		return
 	}
 
-	// Parsear configuración del mensaje
-	var config sensor.SensorConfig
-	if err := json.Unmarshal(msg.Data, &config); err != nil {
+	// Parsear configuración del mensaje (changed_by opcional para el historial y unit
+	// opcional si los límites vienen en otra unidad que la del sensor)
+	var req struct {
+		sensor.SensorConfig
+		ChangedBy string `json:"changed_by"`
+		Unit      string `json:"unit"`
+	}
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
 		h.replyError(msg, "invalid config format")
 		return
 	}
+	config := req.SensorConfig
+
+	if req.Unit != "" {
+		unit, err := h.sensorUnit(context.Background(), sensorID)
+		var converted *sensor.SensorConfig
+		if err == nil {
+			converted, err = config.InUnit(req.Unit, unit)
+		}
+		if err != nil {
+			h.replyError(msg, fmt.Sprintf("invalid threshold unit: %v", err))
+			return
+		}
+		config = *converted
+	}
 
 	// Validar configuración
 	if err := config.Validate(); err != nil {
@@ -139,10 +348,12 @@ func (h *Handler) handleConfigSet(msg *natslib.Msg) {
 	}
 
 	// Guardar en repositorio
-	if err := h.repo.SaveConfig(context.Background(), &config); err != nil {
+	ctx := repository.WithConfigChange(context.Background(), changedBy(req.ChangedBy), "set")
+	if err := h.repo.SaveConfig(ctx, &config); err != nil {
 		h.replyError(msg, fmt.Sprintf("failed to save config: %v", err))
 		return
 	}
+	h.publishConfigChanged(ctx, config.SensorID)
 
 	// Actualizar la configuración en el simulador (si el callback está configurado)
 	if h.updateConfig != nil {
@@ -163,23 +374,16 @@ func (h *Handler) handleConfigSet(msg *natslib.Msg) {
 	msg.Respond(data)
 }
 
-// replyError envía una respuesta de error en formato JSON
-func (h *Handler) replyError(msg *natslib.Msg, errorMsg string) {
-	response := map[string]string{"error": errorMsg}
-	data, _ := json.Marshal(response)
-	msg.Respond(data)
-}
-
-// handleReadingsQuery procesa peticiones para obtener últimas lecturas de un sensor
-func (h *Handler) handleReadingsQuery(msg *natslib.Msg) {
-	// Extraer sensor ID del subject (sensor.readings.query.<id>)
+// handleConfigHistory procesa peticiones del historial de configuración de un sensor.
+// Body opcional: {"limit": 10}. Retorna las revisiones de la más reciente a la más antigua.
+func (h *Handler) handleConfigHistory(msg *natslib.Msg) {
+	// Extraer sensor ID del subject (sensor.config.history.<id>)
 	sensorID := extractSensorID(msg.Subject)
 	if sensorID == "" {
 		h.replyError(msg, "invalid subject format")
 		return
 	}
 
-	// Parsear límite opcional del body
 	limit := 10 // Default
 	if len(msg.Data) > 0 {
 		var req struct {
@@ -190,92 +394,1215 @@ func (h *Handler) handleReadingsQuery(msg *natslib.Msg) {
 		}
 	}
 
-	// Obtener lecturas del repositorio
-	readings, err := h.repo.GetLatestReadings(context.Background(), sensorID, limit)
+	history, err := h.repo.GetConfigHistory(context.Background(), sensorID, limit)
 	if err != nil {
-		h.replyError(msg, fmt.Sprintf("failed to get readings: %v", err))
+		h.replyError(msg, fmt.Sprintf("failed to get config history: %v", err))
 		return
 	}
 
-	// Responder con las lecturas
-	data, err := json.Marshal(readings)
+	data, err := json.Marshal(history)
 	if err != nil {
-		h.replyError(msg, "failed to marshal readings")
+		h.replyError(msg, "failed to marshal config history")
 		return
 	}
 
 	msg.Respond(data)
 }
 
-// handleRegister procesa peticiones para registrar nuevos sensores dinámicamente
-func (h *Handler) handleRegister(msg *natslib.Msg) {
-	// Verificar que el callback esté configurado
-	if h.addSensor == nil {
-		h.replyError(msg, "sensor registration not configured")
+// handleConfigRollback restaura una revisión anterior de la configuración de un sensor.
+// Body: {"revision": 3, "changed_by": "alice"}; sin revision se restaura la penúltima.
+// La restauración queda registrada como una nueva revisión del historial.
+func (h *Handler) handleConfigRollback(msg *natslib.Msg) {
+	// Extraer sensor ID del subject (sensor.config.rollback.<id>)
+	sensorID := extractSensorID(msg.Subject)
+	if sensorID == "" {
+		h.replyError(msg, "invalid subject format")
 		return
 	}
 
-	// Parsear definición del sensor
-	var sensorDef config.SensorDef
-	if err := json.Unmarshal(msg.Data, &sensorDef); err != nil {
-		h.replyError(msg, fmt.Sprintf("invalid sensor definition: %v", err))
-		return
+	var req struct {
+		Revision  int    `json:"revision"`
+		ChangedBy string `json:"changed_by"`
+	}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.replyError(msg, fmt.Sprintf("invalid rollback request: %v", err))
+			return
+		}
 	}
 
-	// Validar definición
-	if sensorDef.ID == "" {
-		h.replyError(msg, "sensor ID is required")
+	history, err := h.repo.GetConfigHistory(context.Background(), sensorID, 0)
+	if err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to get config history: %v", err))
 		return
 	}
-	if sensorDef.Type == "" {
-		h.replyError(msg, "sensor type is required")
+	if len(history) == 0 {
+		h.replyError(msg, fmt.Sprintf("no config history for sensor %s", sensorID))
+		return
 	}
 
-	// Validar configuración
-	if err := sensorDef.Config.Validate(); err != nil {
-		h.replyError(msg, fmt.Sprintf("invalid config: %v", err))
+	// history[0] es la revisión actual
+	var target *sensor.ConfigRevision
+	if req.Revision == 0 {
+		if len(history) < 2 {
+			h.replyError(msg, "no previous revision to roll back to")
+			return
+		}
+		target = history[1]
+	} else {
+		for _, rev := range history {
+			if rev.Revision == req.Revision {
+				target = rev
+				break
+			}
+		}
+	}
+	if target == nil {
+		h.replyError(msg, fmt.Sprintf("revision %d not found for sensor %s", req.Revision, sensorID))
+		return
+	}
+	if target.Revision == history[0].Revision {
+		h.replyError(msg, fmt.Sprintf("revision %d is already the current config", target.Revision))
 		return
 	}
 
-	// Asegurar que el sensor_id en config coincide
-	sensorDef.Config.SensorID = sensorDef.ID
+	config := target.Config
+	config.SensorID = sensorID
 
-	// Guardar configuración en el repositorio primero
-	if err := h.repo.SaveConfig(context.Background(), &sensorDef.Config); err != nil {
+	ctx := repository.WithConfigChange(context.Background(), changedBy(req.ChangedBy),
+		fmt.Sprintf("rollback to revision %d", target.Revision))
+	if err := h.repo.SaveConfig(ctx, &config); err != nil {
 		h.replyError(msg, fmt.Sprintf("failed to save config: %v", err))
 		return
 	}
+	h.publishConfigChanged(ctx, sensorID)
 
-	// Añadir sensor al simulador
-	if err := h.addSensor(sensorDef); err != nil {
-		h.replyError(msg, fmt.Sprintf("failed to add sensor: %v", err))
-		return
+	// Aplicar la configuración restaurada en el simulador
+	if h.updateConfig != nil {
+		if err := h.updateConfig(sensorID, config); err != nil {
+			logger.Errorf("[NATS Handler] ERROR applying rollback: %v", err)
+			h.replyError(msg, fmt.Sprintf("failed to update simulator: %v", err))
+			return
+		}
+	} else {
+		logger.Warn("[NATS Handler] WARNING: updateConfig callback is nil!")
 	}
 
-	// Responder con éxito
+	logger.Infof("[NATS Handler] Config of sensor %s rolled back to revision %d", sensorID, target.Revision)
+
 	response := map[string]interface{}{
-		"status":    "ok",
-		"sensor_id": sensorDef.ID,
-		"message":   fmt.Sprintf("sensor %s registered successfully", sensorDef.ID),
+		"status":         "ok",
+		"sensor_id":      sensorID,
+		"rolled_back_to": target.Revision,
+		"config":         config,
 	}
 	data, _ := json.Marshal(response)
 	msg.Respond(data)
 }
 
-// handleList procesa peticiones para listar todos los sensores
-func (h *Handler) handleList(msg *natslib.Msg) {
-	if h.listSensors == nil {
-		h.replyError(msg, "sensor listing not configured")
+// maxCalibrationSamples limita las lecturas que se promedian en una calibración de un punto
+const maxCalibrationSamples = 100
+
+// CalibrationPoint es una medida de referencia y el valor bruto que midió el sensor a la vez
+type CalibrationPoint struct {
+	Raw       float64 `json:"raw"`
+	Reference float64 `json:"reference"`
+}
+
+// CalibrateRequest es el cuerpo de sensor.calibrate.<id>. Con Reference se ajusta el
+// término constante (calibración de un punto) para el valor bruto Raw o, si no se indica,
+// para la media de los valores brutos de las últimas Samples lecturas válidas (1 por
+// defecto). Con Points (dos puntos) se calculan la ganancia y el offset. Unit es la unidad
+// de las medidas ("" = la del sensor).
+type CalibrateRequest struct {
+	Reference *float64           `json:"reference,omitempty"`
+	Raw       *float64           `json:"raw,omitempty"`
+	Samples   int                `json:"samples,omitempty"`
+	Points    []CalibrationPoint `json:"points,omitempty"`
+	Unit      string             `json:"unit,omitempty"`
+	DryRun    bool               `json:"dry_run,omitempty"` // Calcular sin guardar
+	ChangedBy string             `json:"changed_by,omitempty"`
+}
+
+// CalibrateResponse es la respuesta de sensor.calibrate.<id>. La calibración está en la
+// unidad del sensor.
+type CalibrateResponse struct {
+	Status      string              `json:"status"`
+	SensorID    string              `json:"sensor_id"`
+	Unit        string              `json:"unit,omitempty"`
+	Raw         *float64            `json:"raw,omitempty"`     // Valor bruto de la calibración de un punto
+	Samples     int                 `json:"samples,omitempty"` // Lecturas promediadas para obtener Raw
+	Previous    *sensor.Calibration `json:"previous,omitempty"`
+	Calibration *sensor.Calibration `json:"calibration"`
+	Applied     bool                `json:"applied"` // false con dry_run
+	Error       string              `json:"error,omitempty"`
+}
+
+// handleCalibrate calcula la calibración de un sensor a partir de medidas de referencia,
+// la guarda como una nueva revisión de su configuración y la aplica al simulador
+func (h *Handler) handleCalibrate(msg *natslib.Msg) {
+	// Extraer sensor ID del subject (sensor.calibrate.<id>)
+	sensorID := strings.TrimPrefix(msg.Subject, SubjectCalibrate+".")
+	if sensorID == "" || sensorID == msg.Subject {
+		h.replyError(msg, "invalid subject format")
 		return
 	}
 
-	sensors := h.listSensors()
+	var req CalibrateRequest
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.replyError(msg, fmt.Sprintf("invalid calibrate request: %v", err))
+		return
+	}
+	switch {
+	case req.Reference == nil && len(req.Points) == 0:
+		h.replyError(msg, "reference or points are required")
+		return
+	case req.Reference != nil && len(req.Points) > 0:
+		h.replyError(msg, "reference and points are mutually exclusive")
+		return
+	case len(req.Points) > 0 && len(req.Points) != 2:
+		h.replyError(msg, "two-point calibration requires exactly 2 points")
+		return
+	case req.Samples < 0 || req.Samples > maxCalibrationSamples:
+		h.replyError(msg, fmt.Sprintf("samples must be between 0 and %d (0 = 1)", maxCalibrationSamples))
+		return
+	}
 
-	data, err := json.Marshal(sensors)
+	ctx := context.Background()
+	current, err := h.repo.GetConfig(ctx, sensorID)
+	if err != nil || current == nil {
+		h.replyError(msg, fmt.Sprintf("config not found for sensor %s", sensorID))
+		return
+	}
+	response := CalibrateResponse{Status: "ok", SensorID: sensorID, Previous: current.Calibration}
+
+	// Las medidas se pasan a la unidad del sensor, que es la de la calibración
+	toSensorUnit := func(v float64) (float64, error) { return v, nil }
+	if unit, err := h.sensorUnit(ctx, sensorID); err == nil {
+		response.Unit = unit
+		if req.Unit != "" {
+			toSensorUnit = func(v float64) (float64, error) { return sensor.ConvertValue(v, req.Unit, unit) }
+		}
+	} else if req.Unit != "" {
+		h.replyError(msg, err.Error())
+		return
+	}
+	values := []*float64{req.Reference, req.Raw}
+	for i := range req.Points {
+		values = append(values, &req.Points[i].Raw, &req.Points[i].Reference)
+	}
+	for _, v := range values {
+		if v == nil {
+			continue
+		}
+		if *v, err = toSensorUnit(*v); err != nil {
+			h.replyError(msg, fmt.Sprintf("invalid calibration unit: %v", err))
+			return
+		}
+	}
+
+	var calibration *sensor.Calibration
+	if req.Reference != nil {
+		raw := req.Raw
+		if raw == nil {
+			samples := max(req.Samples, 1)
+			if raw, response.Samples, err = h.averageRaw(ctx, sensorID, samples); err != nil {
+				h.replyError(msg, err.Error())
+				return
+			}
+		}
+		response.Raw = raw
+		calibration = current.Calibration.WithReference(*raw, *req.Reference)
+	} else {
+		p1, p2 := req.Points[0], req.Points[1]
+		if calibration, err = sensor.TwoPointCalibration(p1.Raw, p1.Reference, p2.Raw, p2.Reference); err != nil {
+			h.replyError(msg, err.Error())
+			return
+		}
+	}
+
+	config := *current
+	config.Calibration = calibration
+	if err := config.Validate(); err != nil {
+		h.replyError(msg, fmt.Sprintf("invalid calibration: %v", err))
+		return
+	}
+	response.Calibration = calibration
+
+	if !req.DryRun {
+		ctx := repository.WithConfigChange(ctx, changedBy(req.ChangedBy), "calibrate")
+		if err := h.repo.SaveConfig(ctx, &config); err != nil {
+			h.replyError(msg, fmt.Sprintf("failed to save config: %v", err))
+			return
+		}
+		h.publishConfigChanged(ctx, sensorID)
+
+		if h.updateConfig != nil {
+			if err := h.updateConfig(sensorID, config); err != nil {
+				logger.Errorf("[NATS Handler] ERROR applying calibration: %v", err)
+				h.replyError(msg, fmt.Sprintf("failed to update simulator: %v", err))
+				return
+			}
+		} else {
+			logger.Warn("[NATS Handler] WARNING: updateConfig callback is nil!")
+		}
+		response.Applied = true
+		logger.Infof("[NATS Handler] Sensor %s calibrated", sensorID)
+	}
+
+	data, _ := json.Marshal(response)
+	msg.Respond(data)
+}
+
+// averageRaw retorna la media de los valores brutos de las últimas lecturas válidas de un
+// sensor (como mucho samples) y cuántas se han promediado
+func (h *Handler) averageRaw(ctx context.Context, sensorID string, samples int) (*float64, int, error) {
+	readings, err := h.repo.GetLatestReadings(ctx, sensorID, samples)
 	if err != nil {
-		h.replyError(msg, "failed to marshal sensors")
+		return nil, 0, fmt.Errorf("failed to get readings: %w", err)
+	}
+	var sum float64
+	var n int
+	for _, reading := range readings {
+		if !reading.IsError() {
+			sum += reading.Raw()
+			n++
+		}
+	}
+	if n == 0 {
+		return nil, 0, fmt.Errorf("no valid readings to calibrate sensor %s", sensorID)
+	}
+	avg := sum / float64(n)
+	return &avg, n, nil
+}
+
+// changedBy retorna el autor de un cambio recibido por NATS ("nats" si no se indica)
+func changedBy(author string) string {
+	if author == "" {
+		return "nats"
+	}
+	return author
+}
+
+// replyError envía una respuesta de error en formato JSON
+func (h *Handler) replyError(msg *natslib.Msg, errorMsg string) {
+	response := map[string]string{"error": errorMsg}
+	data, _ := json.Marshal(response)
+	msg.Respond(data)
+}
+
+// ReadingsPage es la respuesta de sensor.readings.query.<id> y sensor.readings.search.
+// NextCursor se envía como "cursor" en la siguiente petición para obtener la página
+// anterior en el tiempo; vacío si no hay más lecturas.
+type ReadingsPage struct {
+	Readings   []*sensor.SensorReading `json:"readings"`
+	NextCursor string                  `json:"next_cursor,omitempty"`
+}
+
+// UnitRequest son los campos opcionales de conversión de unidades de las consultas. Unit
+// convierte todos los valores a esa unidad; Units elige la unidad por magnitud
+// (ej: {"temperature": "°F", "pressure": "psi"}) y deja el resto como están. Si se
+// indican ambos prevalece Unit.
+type UnitRequest struct {
+	Unit  string            `json:"unit,omitempty"`
+	Units map[string]string `json:"units,omitempty"`
+}
+
+// normalize valida las unidades pedidas y las normaliza (ej: "F" -> "°F")
+func (u *UnitRequest) normalize() error {
+	if u.Unit != "" {
+		unit, err := sensor.ParseUnit(u.Unit)
+		if err != nil {
+			return err
+		}
+		u.Unit = unit
+	}
+	for dimension, unit := range u.Units {
+		parsed, err := sensor.ParseUnit(unit)
+		if err != nil {
+			return err
+		}
+		if sensor.UnitDimension(parsed) != dimension {
+			return fmt.Errorf("unit %s is not a %s unit", unit, dimension)
+		}
+		u.Units[dimension] = parsed
+	}
+	return nil
+}
+
+// target retorna la unidad a la que convertir un valor en from ("" = sin conversión).
+// Con strict, Unit debe ser compatible con from; si no, lo incompatible se deja igual.
+func (u UnitRequest) target(from string, strict bool) (string, error) {
+	dimension := sensor.UnitDimension(from)
+	switch {
+	case u.Unit != "" && (strict || dimension == sensor.UnitDimension(u.Unit)):
+		if dimension == "" && from != u.Unit {
+			return "", fmt.Errorf("cannot convert %q to %s", from, u.Unit)
+		}
+		return u.Unit, nil
+	case u.Unit == "" && dimension != "":
+		return u.Units[dimension], nil
+	default:
+		return "", nil
+	}
+}
+
+// convertReadings retorna copias de las lecturas en las unidades pedidas (las lecturas
+// del repositorio pueden estar compartidas con la caché y no se modifican)
+func (u UnitRequest) convertReadings(readings []*sensor.SensorReading, strict bool) ([]*sensor.SensorReading, error) {
+	if u.Unit == "" && len(u.Units) == 0 {
+		return readings, nil
+	}
+	converted := make([]*sensor.SensorReading, len(readings))
+	for i, reading := range readings {
+		unit, err := u.target(reading.Unit, strict)
+		if err != nil {
+			return nil, err
+		}
+		if unit == "" {
+			converted[i] = reading
+			continue
+		}
+		if converted[i], err = reading.InUnit(unit); err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
+
+// sensorUnit retorna la unidad en la que se guardan las lecturas de un sensor: la de su
+// tipo en el catálogo o, si no está registrado, la de su última lectura
+func (h *Handler) sensorUnit(ctx context.Context, sensorID string) (string, error) {
+	if meta, err := h.repo.GetSensor(ctx, sensorID); err == nil && meta != nil {
+		if spec, ok := sensor.LookupType(meta.Type); ok {
+			return spec.Unit, nil
+		}
+	}
+	latest, err := h.repo.GetLatestReadings(ctx, sensorID, 1)
+	if err != nil {
+		return "", fmt.Errorf("failed to get unit of sensor %s: %w", sensorID, err)
+	}
+	if len(latest) == 0 {
+		return "", fmt.Errorf("unknown unit for sensor %s", sensorID)
+	}
+	return latest[0].Unit, nil
+}
+
+// handleReadingsQuery procesa peticiones paginadas de lecturas de un sensor, de la más
+// reciente a la más antigua. Body opcional: {"limit": 10, "cursor": "<next_cursor>",
+// "unit": "°F"} (o "units" por magnitud, ver UnitRequest).
+func (h *Handler) handleReadingsQuery(msg *natslib.Msg) {
+	// Extraer sensor ID del subject (sensor.readings.query.<id>)
+	sensorID := extractSensorID(msg.Subject)
+	if sensorID == "" {
+		h.replyError(msg, "invalid subject format")
 		return
 	}
+
+	// Parsear límite, cursor y unidades opcionales del body
+	limit := 10 // Default
+	var cursor *repository.ReadingCursor
+	var units UnitRequest
+	if len(msg.Data) > 0 {
+		var req struct {
+			Limit  int    `json:"limit"`
+			Cursor string `json:"cursor"`
+			UnitRequest
+		}
+		if err := json.Unmarshal(msg.Data, &req); err == nil {
+			if req.Limit > 0 {
+				limit = req.Limit
+			}
+			if req.Cursor != "" {
+				if cursor, err = repository.DecodeReadingCursor(req.Cursor); err != nil {
+					h.replyError(msg, err.Error())
+					return
+				}
+			}
+			if err := req.UnitRequest.normalize(); err != nil {
+				h.replyError(msg, err.Error())
+				return
+			}
+			units = req.UnitRequest
+		}
+	}
+
+	// Obtener página de lecturas del repositorio
+	readings, next, err := h.repo.GetReadingsPage(context.Background(), sensorID, cursor, limit)
+	if err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to get readings: %v", err))
+		return
+	}
+	if readings, err = units.convertReadings(readings, true); err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
+	page := ReadingsPage{Readings: readings}
+	if page.Readings == nil {
+		page.Readings = []*sensor.SensorReading{}
+	}
+	if next != nil {
+		page.NextCursor = next.Encode()
+	}
+
+	// Responder con la página
+	data, err := json.Marshal(page)
+	if err != nil {
+		h.replyError(msg, "failed to marshal readings")
+		return
+	}
+
+	msg.Respond(data)
+}
+
+// handleReadingsStats procesa peticiones de estadísticas agregadas por bucket temporal.
+// Body opcional: {"bucket": "5m", "start": "<RFC3339>", "end": "<RFC3339>", "unit": "°F"}.
+// Por defecto: bucket de 1h sobre las últimas 24h, en la unidad del sensor.
+func (h *Handler) handleReadingsStats(msg *natslib.Msg) {
+	// Extraer sensor ID del subject (sensor.readings.stats.<id>)
+	sensorID := extractSensorID(msg.Subject)
+	if sensorID == "" {
+		h.replyError(msg, "invalid subject format")
+		return
+	}
+
+	var req struct {
+		Bucket string    `json:"bucket"`
+		Start  time.Time `json:"start"`
+		End    time.Time `json:"end"`
+		UnitRequest
+	}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.replyError(msg, fmt.Sprintf("invalid stats request: %v", err))
+			return
+		}
+	}
+	if err := req.UnitRequest.normalize(); err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
+	if req.Bucket == "" {
+		req.Bucket = "1h"
+	}
+	bucket, err := repository.ParseBucket(req.Bucket)
+	if err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
+	if req.End.IsZero() {
+		req.End = time.Now().UTC()
+	}
+	if req.Start.IsZero() {
+		req.Start = req.End.Add(-24 * time.Hour)
+	}
+	if req.Start.After(req.End) {
+		h.replyError(msg, "start must be before end")
+		return
+	}
+
+	ctx := context.Background()
+	aggregates, err := h.repo.GetAggregatedReadings(ctx, sensorID, req.Start, req.End, bucket)
+	if err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to aggregate readings: %v", err))
+		return
+	}
+
+	// Los agregados no guardan la unidad: se toma la del sensor
+	if len(aggregates) > 0 {
+		unit, err := h.sensorUnit(ctx, sensorID)
+		if err != nil {
+			h.replyError(msg, err.Error())
+			return
+		}
+		target, err := req.UnitRequest.target(unit, true)
+		if err != nil {
+			h.replyError(msg, err.Error())
+			return
+		}
+		converted := make([]*sensor.ReadingAggregate, len(aggregates))
+		for i, aggregate := range aggregates {
+			withUnit := *aggregate
+			withUnit.Unit = unit
+			converted[i] = &withUnit
+			if target != "" {
+				if converted[i], err = withUnit.InUnit(target); err != nil {
+					h.replyError(msg, err.Error())
+					return
+				}
+			}
+		}
+		aggregates = converted
+	}
+
+	data, err := json.Marshal(aggregates)
+	if err != nil {
+		h.replyError(msg, "failed to marshal aggregates")
+		return
+	}
+
+	msg.Respond(data)
+}
+
+// Límites de cada trozo de una exportación: el cliente pide trozos hasta que next_cursor
+// llega vacío, así que ni el servidor ni el cliente cargan el rango completo en memoria
+const (
+	defaultExportLimit = 1000
+	maxExportLimit     = 2000 // ~400KB con lecturas típicas (~200 bytes en JSON)
+
+	// maxExportBytes acota el JSON de las lecturas de cada trozo para no superar el
+	// max_payload por defecto de NATS (1MB) con ids o errores largos: el trozo se corta
+	// antes y next_cursor continúa desde la última lectura incluida
+	maxExportBytes = 768 << 10
+)
+
+// handleReadingsExport procesa peticiones de exportación de lecturas de un sensor en el rango
+// [from, to], de la más antigua a la más reciente, en trozos de como mucho limit lecturas.
+// Body: {"from": "<RFC3339>", "to": "<RFC3339>", "limit": 1000, "cursor": "<next_cursor>",
+// "unit": "°F"}.
+func (h *Handler) handleReadingsExport(msg *natslib.Msg) {
+	// Extraer sensor ID del subject (sensor.readings.export.<id>)
+	sensorID := extractSensorID(msg.Subject)
+	if sensorID == "" {
+		h.replyError(msg, "invalid subject format")
+		return
+	}
+
+	var req struct {
+		From   time.Time `json:"from"`
+		To     time.Time `json:"to"`
+		Limit  int       `json:"limit"`
+		Cursor string    `json:"cursor"`
+		UnitRequest
+	}
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.replyError(msg, fmt.Sprintf("invalid export request: %v", err))
+		return
+	}
+	if err := req.UnitRequest.normalize(); err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
+	if req.From.IsZero() {
+		h.replyError(msg, "from is required")
+		return
+	}
+	if req.To.IsZero() {
+		req.To = time.Now().UTC()
+	}
+	if req.From.After(req.To) {
+		h.replyError(msg, "from must be before to")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultExportLimit
+	}
+	req.Limit = min(req.Limit, maxExportLimit)
+
+	// El cursor marca la última lectura enviada
+	var cursor *repository.ReadingCursor
+	if req.Cursor != "" {
+		var err error
+		if cursor, err = repository.DecodeReadingCursor(req.Cursor); err != nil {
+			h.replyError(msg, err.Error())
+			return
+		}
+	}
+
+	readings, next, err := h.repo.GetReadingsRangePage(context.Background(), sensorID, req.From, req.To, cursor, req.Limit)
+	if err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to export readings: %v", err))
+		return
+	}
+	if readings, err = req.UnitRequest.convertReadings(readings, true); err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
+	page := ReadingsPage{Readings: readings}
+	if n := fitPayload(readings, maxExportBytes); n < len(readings) {
+		page.Readings = readings[:n]
+		last := page.Readings[n-1]
+		next = &repository.ReadingCursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+	if next != nil {
+		page.NextCursor = next.Encode()
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		h.replyError(msg, "failed to marshal readings")
+		return
+	}
+	msg.Respond(data)
+}
+
+// fitPayload retorna cuántas de las primeras lecturas caben en budget bytes de JSON
+// (al menos una, para que la exportación siempre avance)
+func fitPayload(readings []*sensor.SensorReading, budget int) int {
+	size := 0
+	for i, reading := range readings {
+		data, err := json.Marshal(reading)
+		if err != nil {
+			return max(i, 1)
+		}
+		size += len(data) + 1 // Separador del array
+		if size > budget {
+			return max(i, 1)
+		}
+	}
+	return len(readings)
+}
+
+// Límites de cada petición de importación: el cliente envía el fichero en lotes y cada
+// lote se escribe en una transacción. Con ~215 bytes por registro típico, MaxImportRecords
+// deja el lote muy por debajo del max_payload por defecto de NATS (1MB); MaxImportBytes es
+// el presupuesto de JSON de los registros con el que el cliente corta antes los lotes de
+// registros grandes (ids o errores largos).
+const (
+	MaxImportRecords = 2000
+	MaxImportBytes   = 768 << 10
+)
+
+// ImportRecord es una lectura a importar junto con la línea del fichero de origen
+type ImportRecord struct {
+	Line int `json:"line"`
+	sensor.SensorReading
+}
+
+// ImportRejection describe un registro no importado y el motivo
+type ImportRejection struct {
+	Line   int    `json:"line"`
+	ID     string `json:"id,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult es la respuesta de sensor.readings.import
+type ImportResult struct {
+	Accepted int               `json:"accepted"`
+	Rejected []ImportRejection `json:"rejected"`
+}
+
+// handleReadingsImport procesa un lote de lecturas históricas: valida cada registro con
+// SensorReading.Validate, descarta los ids repetidos en el lote o ya guardados y escribe
+// el resto con Repository.ImportReadings. Body: {"records": [{"line": 2, "id": ...}]}.
+func (h *Handler) handleReadingsImport(msg *natslib.Msg) {
+	var req struct {
+		Records []ImportRecord `json:"records"`
+	}
+	if err := json.Unmarshal(msg.Data, &req); err != nil {
+		h.replyError(msg, fmt.Sprintf("invalid import request: %v", err))
+		return
+	}
+	if len(req.Records) == 0 {
+		h.replyError(msg, "records are required")
+		return
+	}
+	if len(req.Records) > MaxImportRecords {
+		h.replyError(msg, fmt.Sprintf("too many records: %d (max %d)", len(req.Records), MaxImportRecords))
+		return
+	}
+
+	result := ImportResult{Rejected: []ImportRejection{}}
+	readings := make([]*sensor.SensorReading, 0, len(req.Records))
+	lines := make(map[string]int, len(req.Records)) // id -> línea del registro enviado al repositorio
+	for i := range req.Records {
+		record := &req.Records[i]
+		if err := record.Validate(); err != nil {
+			result.Rejected = append(result.Rejected, ImportRejection{Line: record.Line, ID: record.ID, Reason: err.Error()})
+			continue
+		}
+		if line, ok := lines[record.ID]; ok {
+			result.Rejected = append(result.Rejected, ImportRejection{
+				Line:   record.Line,
+				ID:     record.ID,
+				Reason: fmt.Sprintf("duplicate id (first seen at line %d)", line),
+			})
+			continue
+		}
+		lines[record.ID] = record.Line
+		readings = append(readings, &record.SensorReading)
+	}
+
+	skipped, err := h.repo.ImportReadings(context.Background(), readings)
+	if err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to import readings: %v", err))
+		return
+	}
+	for _, skip := range skipped {
+		result.Rejected = append(result.Rejected, ImportRejection{Line: lines[skip.ID], ID: skip.ID, Reason: skip.Reason})
+	}
+	sort.SliceStable(result.Rejected, func(i, j int) bool { return result.Rejected[i].Line < result.Rejected[j].Line })
+	result.Accepted = len(readings) - len(skipped)
+
+	logger.Infof("[NATS Handler] Imported %d readings (%d rejected)", result.Accepted, len(result.Rejected))
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		h.replyError(msg, "failed to marshal import result")
+		return
+	}
+	msg.Respond(data)
+}
+
+// maxSearchLimit limita el tamaño de página de sensor.readings.search
+const maxSearchLimit = 1000
+
+// handleReadingsSearch procesa búsquedas paginadas de lecturas de varios sensores filtrando
+// por sus metadatos. Body opcional: {"type": "...", "location": "...", "tags": ["..."],
+// "start": "<RFC3339>", "end": "<RFC3339>", "limit": 50, "cursor": "<next_cursor>",
+// "unit": "°F"}. Con varios tipos, unit solo convierte las lecturas compatibles.
+func (h *Handler) handleReadingsSearch(msg *natslib.Msg) {
+	var req struct {
+		Type     sensor.SensorType `json:"type"`
+		Location string            `json:"location"`
+		Tags     []string          `json:"tags"`
+		Start    time.Time         `json:"start"`
+		End      time.Time         `json:"end"`
+		Limit    int               `json:"limit"`
+		Cursor   string            `json:"cursor"`
+		UnitRequest
+	}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.replyError(msg, fmt.Sprintf("invalid search request: %v", err))
+			return
+		}
+	}
+	if err := req.UnitRequest.normalize(); err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
+	if !req.Start.IsZero() && !req.End.IsZero() && req.Start.After(req.End) {
+		h.replyError(msg, "start must be before end")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Limit > maxSearchLimit {
+		h.replyError(msg, fmt.Sprintf("limit too large: %d (max %d)", req.Limit, maxSearchLimit))
+		return
+	}
+
+	query := repository.ReadingSearch{
+		Type:     req.Type,
+		Location: req.Location,
+		Tags:     req.Tags,
+		Start:    req.Start,
+		End:      req.End,
+		Limit:    req.Limit,
+	}
+	if req.Cursor != "" {
+		cursor, err := repository.DecodeReadingCursor(req.Cursor)
+		if err != nil {
+			h.replyError(msg, err.Error())
+			return
+		}
+		query.Cursor = cursor
+	}
+
+	readings, next, err := h.repo.SearchReadings(context.Background(), query)
+	if err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to search readings: %v", err))
+		return
+	}
+	if readings, err = req.UnitRequest.convertReadings(readings, false); err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
+	page := ReadingsPage{Readings: readings}
+	if page.Readings == nil {
+		page.Readings = []*sensor.SensorReading{}
+	}
+	if next != nil {
+		page.NextCursor = next.Encode()
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		h.replyError(msg, "failed to marshal readings")
+		return
+	}
+
+	msg.Respond(data)
+}
+
+// handleAlertsQuery procesa peticiones de consulta de alertas persistidas.
+// Body opcional: {"sensor_id": "...", "severity": "warning|critical",
+// "start": "<RFC3339>", "end": "<RFC3339>", "limit": 50, "unit": "°F"}. Por defecto: las
+// últimas 50. unit convierte valor y umbral de las alertas en unidades compatibles.
+func (h *Handler) handleAlertsQuery(msg *natslib.Msg) {
+	var req struct {
+		SensorID string    `json:"sensor_id"`
+		Severity string    `json:"severity"`
+		Start    time.Time `json:"start"`
+		End      time.Time `json:"end"`
+		Limit    int       `json:"limit"`
+		UnitRequest
+	}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.replyError(msg, fmt.Sprintf("invalid alerts request: %v", err))
+			return
+		}
+	}
+	if err := req.UnitRequest.normalize(); err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+
+	severity, err := sensor.ParseAlertSeverity(req.Severity)
+	if err != nil {
+		h.replyError(msg, err.Error())
+		return
+	}
+	if !req.Start.IsZero() && !req.End.IsZero() && req.Start.After(req.End) {
+		h.replyError(msg, "start must be before end")
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+
+	alerts, err := h.repo.GetAlerts(context.Background(), repository.AlertFilter{
+		SensorID: req.SensorID,
+		Severity: severity,
+		Start:    req.Start,
+		End:      req.End,
+		Limit:    req.Limit,
+	})
+	if err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to get alerts: %v", err))
+		return
+	}
+	// Copias en la unidad pedida (las del repositorio no se modifican)
+	converted := make([]*sensor.Alert, len(alerts))
+	for i, alert := range alerts {
+		converted[i] = alert
+		if unit, _ := req.UnitRequest.target(alert.Unit, false); unit != "" {
+			if converted[i], err = alert.InUnit(unit); err != nil {
+				h.replyError(msg, err.Error())
+				return
+			}
+		}
+	}
+	alerts = converted
+
+	data, err := json.Marshal(alerts)
+	if err != nil {
+		h.replyError(msg, "failed to marshal alerts")
+		return
+	}
+
+	msg.Respond(data)
+}
+
+// handleRegister procesa peticiones para registrar nuevos sensores dinámicamente
+func (h *Handler) handleRegister(msg *natslib.Msg) {
+	// Verificar que el callback esté configurado
+	if h.addSensor == nil {
+		h.replyError(msg, "sensor registration not configured")
+		return
+	}
+
+	// Parsear definición del sensor
+	var sensorDef config.SensorDef
+	if err := json.Unmarshal(msg.Data, &sensorDef); err != nil {
+		h.replyError(msg, fmt.Sprintf("invalid sensor definition: %v", err))
+		return
+	}
+	var given struct {
+		Config struct {
+			Threshold *float64 `json:"threshold"`
+		} `json:"config"`
+	}
+	json.Unmarshal(msg.Data, &given)
+
+	// Validar definición
+	if sensorDef.ID == "" {
+		h.replyError(msg, "sensor ID is required")
+		return
+	}
+	if sensorDef.Type == "" {
+		h.replyError(msg, "sensor type is required")
+		return
+	}
+	spec, ok := sensor.LookupType(sensorDef.Type)
+	if !ok {
+		h.replyError(msg, fmt.Sprintf("unknown sensor type %q (must be: %s)", sensorDef.Type, strings.Join(sensor.TypeNames(), ", ")))
+		return
+	}
+
+	// Sin umbral en la petición se usa el umbral por defecto del tipo
+	if given.Config.Threshold == nil {
+		sensorDef.Config.Threshold = spec.DefaultThreshold
+	}
+
+	// Validar configuración
+	if err := sensorDef.Config.Validate(); err != nil {
+		h.replyError(msg, fmt.Sprintf("invalid config: %v", err))
+		return
+	}
+
+	// Asegurar que el sensor_id en config coincide
+	sensorDef.Config.SensorID = sensorDef.ID
+
+	// Un ID repetido se rechaza antes de tocar su configuración
+	ctx := repository.WithConfigChange(context.Background(), changedBy(""), "register")
+	if existing, err := h.repo.GetSensor(ctx, sensorDef.ID); (err == nil && existing != nil) || (h.listSensors != nil && h.sensorRunning(sensorDef.ID)) {
+		h.replyError(msg, fmt.Sprintf("sensor %s already exists", sensorDef.ID))
+		return
+	}
+
+	// Persistir metadatos y configuración antes de arrancarlo: así se rehidrata tras un
+	// reinicio del servidor, y si algo falla el alta se deshace sin tocar el simulador
+	registered := &sensor.Sensor{
+		ID:       sensorDef.ID,
+		Type:     sensorDef.Type,
+		Name:     sensorDef.Name,
+		Location: sensorDef.Location,
+		Tags:     sensorDef.Tags,
+		Source:   sensor.SensorSourceRegister,
+	}
+	if err := h.repo.SaveSensor(ctx, registered); err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to persist sensor: %v", err))
+		return
+	}
+	if err := h.repo.SaveConfig(ctx, &sensorDef.Config); err != nil {
+		h.undoRegister(ctx, sensorDef.ID, false)
+		h.replyError(msg, fmt.Sprintf("failed to save config: %v", err))
+		return
+	}
+
+	// Añadir sensor al simulador
+	if err := h.addSensor(sensorDef); err != nil {
+		h.undoRegister(ctx, sensorDef.ID, true)
+		h.replyError(msg, fmt.Sprintf("failed to add sensor: %v", err))
+		return
+	}
+	h.publishConfigChanged(ctx, sensorDef.ID)
+
+	// Responder con éxito
+	response := map[string]interface{}{
+		"status":    "ok",
+		"sensor_id": sensorDef.ID,
+		"threshold": sensorDef.Config.Threshold,
+		"message":   fmt.Sprintf("sensor %s registered successfully", sensorDef.ID),
+	}
+	data, _ := json.Marshal(response)
+	msg.Respond(data)
+}
+
+// undoRegister borra los metadatos (y la configuración si ya se guardó) de un alta fallida
+func (h *Handler) undoRegister(ctx context.Context, sensorID string, withConfig bool) {
+	if err := h.repo.DeleteSensor(ctx, sensorID); err != nil {
+		logger.Errorf("[NATS Handler] ERROR undoing registration of %s: %v", sensorID, err)
+	}
+	if !withConfig {
+		return
+	}
+	if err := h.repo.DeleteConfig(ctx, sensorID); err != nil {
+		logger.Errorf("[NATS Handler] ERROR undoing registration of %s: %v", sensorID, err)
+	}
+}
+
+// handleRemove procesa peticiones para dar de baja un sensor: lo detiene en el simulador
+// y elimina sus metadatos y su configuración actual (el historial se conserva).
+// Body opcional: {"purge": true, "changed_by": "..."} para borrar además todos sus datos.
+func (h *Handler) handleRemove(msg *natslib.Msg) {
+	if h.removeSensor == nil || h.listSensors == nil {
+		h.replyError(msg, "sensor removal not configured")
+		return
+	}
+
+	// Extraer sensor ID del subject (sensor.remove.<id>)
+	sensorID := strings.TrimPrefix(msg.Subject, SubjectRemove+".")
+	if sensorID == "" || sensorID == msg.Subject {
+		h.replyError(msg, "invalid subject format")
+		return
+	}
+
+	var req struct {
+		Purge     bool   `json:"purge"`
+		ChangedBy string `json:"changed_by"`
+	}
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			h.replyError(msg, fmt.Sprintf("invalid remove request: %v", err))
+			return
+		}
+	}
+
+	ctx := repository.WithConfigChange(context.Background(), changedBy(req.ChangedBy), "remove")
+	if h.sensorRunning(sensorID) {
+		// Los sensores del YAML se vuelven a añadir al reiniciar: se quitan del fichero
+		if meta, err := h.repo.GetSensor(ctx, sensorID); err == nil && meta != nil && meta.Source == sensor.SensorSourceConfig {
+			h.replyError(msg, fmt.Sprintf("sensor %s is defined in the configuration file, remove it there", sensorID))
+			return
+		}
+
+		// Detener el sensor primero para que no genere más lecturas
+		if err := h.removeSensor(sensorID); err != nil {
+			h.replyError(msg, fmt.Sprintf("failed to remove sensor: %v", err))
+			return
+		}
+	} else if cfg, err := h.repo.GetConfig(ctx, sensorID); err != nil || cfg == nil {
+		// Si no está en el simulador pero conserva su configuración, es el reintento de una
+		// baja que falló después de detenerlo
+		h.replyError(msg, fmt.Sprintf("failed to remove sensor: sensor %s not found", sensorID))
+		return
+	}
+
+	// Purgar antes de borrar metadatos y configuración: si falla, la baja se puede reintentar
+	response := map[string]interface{}{
+		"status":    "ok",
+		"sensor_id": sensorID,
+		"purged":    req.Purge,
+	}
+	if req.Purge {
+		deleted, err := h.repo.PurgeSensorData(ctx, sensorID)
+		if err != nil {
+			h.replyError(msg, fmt.Sprintf("sensor stopped but failed to purge its data: %v", err))
+			return
+		}
+		response["readings_deleted"] = deleted
+	}
+
+	if err := h.repo.DeleteSensor(ctx, sensorID); err != nil {
+		h.replyError(msg, fmt.Sprintf("sensor stopped but failed to delete it: %v", err))
+		return
+	}
+	if err := h.repo.DeleteConfig(ctx, sensorID); err != nil {
+		h.replyError(msg, fmt.Sprintf("sensor stopped but failed to delete its config: %v", err))
+		return
+	}
+	h.publishConfigChanged(ctx, sensorID)
+
+	data, _ := json.Marshal(response)
+	msg.Respond(data)
+}
+
+// sensorRunning indica si el sensor está en el simulador
+func (h *Handler) sensorRunning(sensorID string) bool {
+	for _, def := range h.listSensors() {
+		if def.ID == sensorID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleList procesa peticiones para listar todos los sensores
+func (h *Handler) handleList(msg *natslib.Msg) {
+	if h.listSensors == nil {
+		h.replyError(msg, "sensor listing not configured")
+		return
+	}
+
+	sensors := h.listSensors()
+
+	data, err := json.Marshal(sensors)
+	if err != nil {
+		h.replyError(msg, "failed to marshal sensors")
+		return
+	}
+	msg.Respond(data)
+}
+
+// handleMetrics procesa peticiones de métricas internas del servidor
+func (h *Handler) handleMetrics(msg *natslib.Msg) {
+	if h.metrics == nil {
+		h.replyError(msg, "metrics not configured")
+		return
+	}
+
+	data, err := json.Marshal(h.metrics())
+	if err != nil {
+		h.replyError(msg, "failed to marshal metrics")
+		return
+	}
+	msg.Respond(data)
+}
+
+// handleCacheStats responde con las métricas de la caché de repositorio
+func (h *Handler) handleCacheStats(msg *natslib.Msg) {
+	if h.cacheStats == nil {
+		h.replyError(msg, "cache not enabled")
+		return
+	}
+
+	data, err := json.Marshal(h.cacheStats())
+	if err != nil {
+		h.replyError(msg, "failed to marshal cache stats")
+		return
+	}
+	msg.Respond(data)
+}
+
+// publishConfigChanged notifica el cambio de configuración de un sensor a otros procesos
+// (p. ej. para invalidar sus cachés). Un fallo al publicar solo se registra.
+func (h *Handler) publishConfigChanged(ctx context.Context, sensorID string) {
+	change := repository.ConfigChangeFromContext(ctx)
+	data, err := json.Marshal(ConfigChangedEvent{
+		SensorID:  sensorID,
+		Reason:    change.Reason,
+		ChangedBy: change.ChangedBy,
+		Timestamp: time.Now().UTC(),
+	})
+	if err != nil {
+		logger.Errorf("[NATS Handler] ERROR marshaling config change of %s: %v", sensorID, err)
+		return
+	}
+	if err := h.client.Publish(ConfigChangedSubject(sensorID), data); err != nil {
+		logger.Errorf("[NATS Handler] ERROR publishing config change of %s: %v", sensorID, err)
+	}
+}
+
+// handleConfigChanged entrega al callback los eventos de sensor.config.changed.<id>
+func (h *Handler) handleConfigChanged(msg *natslib.Msg) {
+	var event ConfigChangedEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil || event.SensorID == "" {
+		// Evento sin payload válido: basta con el ID del subject
+		event = ConfigChangedEvent{SensorID: extractSensorID(msg.Subject)}
+	}
+	if event.SensorID == "" {
+		return
+	}
+	h.configEvent(event)
+}
+
+// backupTimeout limita la duración de un backup bajo demanda
+const backupTimeout = 5 * time.Minute
+
+// handleBackup procesa peticiones de backup de la base de datos
+func (h *Handler) handleBackup(msg *natslib.Msg) {
+	if h.backup == nil {
+		h.replyError(msg, "backups not configured")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), backupTimeout)
+	defer cancel()
+
+	snapshot, err := h.backup(ctx)
+	if err != nil {
+		h.replyError(msg, fmt.Sprintf("failed to create backup: %v", err))
+		return
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"status":     "ok",
+		"path":       snapshot.Path,
+		"size_bytes": snapshot.SizeBytes,
+		"created_at": snapshot.CreatedAt,
+	})
 	msg.Respond(data)
 }
 
@@ -4,18 +4,31 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alejandro/technical_test_uvigo/internal/backup"
+	"github.com/alejandro/technical_test_uvigo/internal/cache"
 	"github.com/alejandro/technical_test_uvigo/internal/config"
 	"github.com/alejandro/technical_test_uvigo/internal/repository"
 	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	natsserver "github.com/nats-io/nats-server/v2/server"
 )
 
 // MockRepository para testing de handlers
 type MockRepository struct {
 	configs  map[string]*sensor.SensorConfig
 	readings map[string][]*sensor.SensorReading
+	sensors  map[string]*sensor.Sensor
+	history  map[string][]*sensor.ConfigRevision
+	alerts   []*sensor.Alert // Más reciente primero
+
+	lastAlertFilter repository.AlertFilter
+	lastSearch      repository.ReadingSearch
+	purgeErr        error // Si no es nil, PurgeSensorData falla una vez con este error
 }
 
 // Asegurar que MockRepository implementa repository.Repository
@@ -25,6 +38,8 @@ func NewMockRepository() *MockRepository {
 	return &MockRepository{
 		configs:  make(map[string]*sensor.SensorConfig),
 		readings: make(map[string][]*sensor.SensorReading),
+		sensors:  make(map[string]*sensor.Sensor),
+		history:  make(map[string][]*sensor.ConfigRevision),
 	}
 }
 
@@ -33,6 +48,32 @@ func (m *MockRepository) SaveReading(ctx context.Context, reading *sensor.Sensor
 	return nil
 }
 
+func (m *MockRepository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	for _, reading := range readings {
+		m.SaveReading(ctx, reading)
+	}
+	return nil
+}
+
+func (m *MockRepository) ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]repository.SkippedReading, error) {
+	seen := make(map[string]bool)
+	for _, stored := range m.readings {
+		for _, reading := range stored {
+			seen[reading.ID] = true
+		}
+	}
+	var skipped []repository.SkippedReading
+	for _, reading := range readings {
+		if seen[reading.ID] {
+			skipped = append(skipped, repository.SkippedReading{ID: reading.ID, Reason: repository.SkipDuplicateID})
+			continue
+		}
+		seen[reading.ID] = true
+		m.SaveReading(ctx, reading)
+	}
+	return skipped, nil
+}
+
 func (m *MockRepository) GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error) {
 	readings := m.readings[sensorID]
 	if len(readings) > limit {
@@ -41,15 +82,102 @@ func (m *MockRepository) GetLatestReadings(ctx context.Context, sensorID string,
 	return readings, nil
 }
 
+// GetReadingsPage asume que las lecturas se guardaron en orden cronológico
+func (m *MockRepository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	stored := m.readings[sensorID]
+	var page []*sensor.SensorReading
+	for i := len(stored) - 1; i >= 0; i-- {
+		if cursor != nil && !cursor.Before(stored[i]) {
+			continue
+		}
+		if len(page) == limit {
+			last := page[limit-1]
+			return page, &repository.ReadingCursor{Timestamp: last.Timestamp, ID: last.ID}, nil
+		}
+		page = append(page, stored[i])
+	}
+	return page, nil, nil
+}
+
 func (m *MockRepository) GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error) {
-	return m.readings[sensorID], nil
+	var readings []*sensor.SensorReading
+	for _, r := range m.readings[sensorID] {
+		if !r.Timestamp.Before(start) && !r.Timestamp.After(end) {
+			readings = append(readings, r)
+		}
+	}
+	return readings, nil
+}
+
+// GetReadingsRangePage ordena las lecturas del rango por (timestamp, id) como los backends reales
+func (m *MockRepository) GetReadingsRangePage(ctx context.Context, sensorID string, start, end time.Time, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	var page []*sensor.SensorReading
+	for _, r := range m.readings[sensorID] {
+		if !r.Timestamp.Before(start) && !r.Timestamp.After(end) && (cursor == nil || cursor.After(r)) {
+			page = append(page, r)
+		}
+	}
+	sort.Slice(page, func(i, j int) bool {
+		if page[i].Timestamp.Equal(page[j].Timestamp) {
+			return page[i].ID < page[j].ID
+		}
+		return page[i].Timestamp.Before(page[j].Timestamp)
+	})
+	if len(page) > limit {
+		last := page[limit-1]
+		return page[:limit], &repository.ReadingCursor{Timestamp: last.Timestamp, ID: last.ID}, nil
+	}
+	return page, nil, nil
+}
+
+func (m *MockRepository) GetAggregatedReadings(ctx context.Context, sensorID string, start, end time.Time, bucket time.Duration) ([]*sensor.ReadingAggregate, error) {
+	readings := m.readings[sensorID]
+	if len(readings) == 0 {
+		return nil, nil
+	}
+	agg := &sensor.ReadingAggregate{SensorID: sensorID, BucketStart: start.Truncate(bucket), Min: readings[0].Value, Max: readings[0].Value}
+	var sum float64
+	for _, r := range readings {
+		agg.Count++
+		sum += r.Value
+		agg.Min = min(agg.Min, r.Value)
+		agg.Max = max(agg.Max, r.Value)
+	}
+	agg.Avg = sum / float64(agg.Count)
+	return []*sensor.ReadingAggregate{agg}, nil
+}
+
+func (m *MockRepository) ApplyRetention(ctx context.Context, policy repository.RetentionPolicy) (int64, error) {
+	return 0, nil
 }
 
 func (m *MockRepository) SaveConfig(ctx context.Context, config *sensor.SensorConfig) error {
 	m.configs[config.SensorID] = config
+
+	history := m.history[config.SensorID]
+	if n := len(history); n > 0 && history[n-1].Config.Equal(*config) {
+		return nil
+	}
+	change := repository.ConfigChangeFromContext(ctx)
+	m.history[config.SensorID] = append(history, &sensor.ConfigRevision{
+		Revision:  len(history) + 1,
+		Config:    *config,
+		ChangedBy: change.ChangedBy,
+		Reason:    change.Reason,
+		ChangedAt: time.Now().UTC(),
+	})
 	return nil
 }
 
+func (m *MockRepository) GetConfigHistory(ctx context.Context, sensorID string, limit int) ([]*sensor.ConfigRevision, error) {
+	history := m.history[sensorID]
+	revisions := make([]*sensor.ConfigRevision, 0, len(history))
+	for i := len(history) - 1; i >= 0 && (limit <= 0 || len(revisions) < limit); i-- {
+		revisions = append(revisions, history[i])
+	}
+	return revisions, nil
+}
+
 func (m *MockRepository) GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
 	config, exists := m.configs[sensorID]
 	if !exists {
@@ -58,6 +186,79 @@ func (m *MockRepository) GetConfig(ctx context.Context, sensorID string) (*senso
 	return config, nil
 }
 
+func (m *MockRepository) DeleteConfig(ctx context.Context, sensorID string) error {
+	delete(m.configs, sensorID)
+	return nil
+}
+
+func (m *MockRepository) PurgeSensorData(ctx context.Context, sensorID string) (int64, error) {
+	if err := m.purgeErr; err != nil {
+		m.purgeErr = nil
+		return 0, err
+	}
+	deleted := int64(len(m.readings[sensorID]))
+	delete(m.readings, sensorID)
+	delete(m.history, sensorID)
+	return deleted, nil
+}
+
+func (m *MockRepository) SaveAlert(ctx context.Context, alert *sensor.Alert) error {
+	m.alerts = append([]*sensor.Alert{alert}, m.alerts...)
+	return nil
+}
+
+func (m *MockRepository) GetAlerts(ctx context.Context, filter repository.AlertFilter) ([]*sensor.Alert, error) {
+	m.lastAlertFilter = filter
+	var alerts []*sensor.Alert
+	for _, alert := range m.alerts {
+		if filter.SensorID != "" && alert.SensorID != filter.SensorID {
+			continue
+		}
+		if filter.Severity != "" && alert.Severity != filter.Severity {
+			continue
+		}
+		if filter.Limit > 0 && len(alerts) == filter.Limit {
+			break
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// SearchReadings devuelve las lecturas de los sensores que cumplen la búsqueda, sin paginar
+func (m *MockRepository) SearchReadings(ctx context.Context, query repository.ReadingSearch) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	m.lastSearch = query
+	var readings []*sensor.SensorReading
+	for id, s := range m.sensors {
+		if query.Matches(s) {
+			readings = append(readings, m.readings[id]...)
+		}
+	}
+	return readings, nil, nil
+}
+
+func (m *MockRepository) SaveSensor(ctx context.Context, s *sensor.Sensor) error {
+	m.sensors[s.ID] = s
+	return nil
+}
+
+func (m *MockRepository) GetSensor(ctx context.Context, sensorID string) (*sensor.Sensor, error) {
+	return m.sensors[sensorID], nil
+}
+
+func (m *MockRepository) ListSensors(ctx context.Context) ([]*sensor.Sensor, error) {
+	sensors := make([]*sensor.Sensor, 0, len(m.sensors))
+	for _, s := range m.sensors {
+		sensors = append(sensors, s)
+	}
+	return sensors, nil
+}
+
+func (m *MockRepository) DeleteSensor(ctx context.Context, sensorID string) error {
+	delete(m.sensors, sensorID)
+	return nil
+}
+
 func (m *MockRepository) Close() error {
 	return nil
 }
@@ -277,14 +478,116 @@ func TestHandler_ReadingsQuery(t *testing.T) {
 	}
 
 	// Parsear respuesta
-	var readings []*sensor.SensorReading
-	if err := json.Unmarshal(response.Data, &readings); err != nil {
+	var page ReadingsPage
+	if err := json.Unmarshal(response.Data, &page); err != nil {
 		t.Fatalf("failed to unmarshal response: %v", err)
 	}
 
 	// Verificar
-	if len(readings) > 5 {
-		t.Errorf("expected at most 5 readings, got %d", len(readings))
+	if len(page.Readings) > 5 {
+		t.Errorf("expected at most 5 readings, got %d", len(page.Readings))
+	}
+}
+
+func TestHandler_UnitConversion(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	// Sensor de temperatura en °C con umbral de 30°C
+	repo := NewMockRepository()
+	ctx := context.Background()
+	sensorID := "temp-001"
+	repo.SaveSensor(ctx, &sensor.Sensor{ID: sensorID, Type: sensor.SensorTypeTemperature})
+	repo.SaveConfig(ctx, &sensor.SensorConfig{SensorID: sensorID, Interval: 1000, Threshold: 30, Enabled: true})
+	repo.SaveReading(ctx, &sensor.SensorReading{
+		ID:        "reading-1",
+		SensorID:  sensorID,
+		Type:      sensor.SensorTypeTemperature,
+		Value:     25,
+		Unit:      "°C",
+		Timestamp: time.Now(),
+	})
+
+	handler := NewHandler(client, repo)
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	request := func(subject string, body any) []byte {
+		t.Helper()
+		reqCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		data, _ := json.Marshal(body)
+		response, err := client.Request(reqCtx, subject, data)
+		if err != nil {
+			t.Fatalf("Request(%s) failed: %v", subject, err)
+		}
+		return response.Data
+	}
+
+	// Lecturas en °F (alias "F")
+	var page ReadingsPage
+	if err := json.Unmarshal(request(ReadingsQuerySubject(sensorID), map[string]string{"unit": "F"}), &page); err != nil {
+		t.Fatalf("failed to unmarshal readings: %v", err)
+	}
+	if len(page.Readings) != 1 || math.Abs(page.Readings[0].Value-77) > 1e-9 || page.Readings[0].Unit != "°F" {
+		t.Errorf("expected 77°F, got %+v", page.Readings)
+	}
+	if stored, _ := repo.GetLatestReadings(ctx, sensorID, 1); stored[0].Value != 25 {
+		t.Errorf("stored reading was modified: %v", stored[0].Value)
+	}
+
+	// Unidad incompatible con el sensor
+	var errResp map[string]string
+	json.Unmarshal(request(ReadingsQuerySubject(sensorID), map[string]string{"unit": "psi"}), &errResp)
+	if errResp["error"] == "" {
+		t.Error("expected error converting °C to psi")
+	}
+
+	// Estadísticas con la unidad del sensor y convertidas
+	var aggregates []*sensor.ReadingAggregate
+	json.Unmarshal(request(ReadingsStatsSubject(sensorID), map[string]string{}), &aggregates)
+	if len(aggregates) != 1 || aggregates[0].Unit != "°C" {
+		t.Errorf("expected stats in °C, got %+v", aggregates)
+	}
+	json.Unmarshal(request(ReadingsStatsSubject(sensorID), map[string]string{"unit": "K"}), &aggregates)
+	if len(aggregates) != 1 || aggregates[0].Unit != "K" || math.Abs(aggregates[0].Avg-298.15) > 1e-9 {
+		t.Errorf("expected stats in K, got %+v", aggregates)
+	}
+
+	// Umbral en °F
+	var config struct {
+		Threshold float64 `json:"threshold"`
+		Unit      string  `json:"unit"`
+	}
+	json.Unmarshal(request(ConfigGetSubject(sensorID), map[string]any{"units": map[string]string{"temperature": "°F"}}), &config)
+	if math.Abs(config.Threshold-86) > 1e-9 || config.Unit != "°F" {
+		t.Errorf("expected threshold 86°F, got %+v", config)
+	}
+
+	// Límites enviados en °F: se guardan en °C (histéresis y variación como diferencias)
+	request(ConfigSetSubject(sensorID), map[string]any{
+		"sensor_id": sensorID, "interval": 1000, "threshold": 95, "low_threshold": 32,
+		"hysteresis": 1.8, "max_rate": 9, "enabled": true, "unit": "°F",
+	})
+	stored, _ := repo.GetConfig(ctx, sensorID)
+	if math.Abs(stored.Threshold-35) > 1e-9 || stored.LowThreshold == nil || math.Abs(*stored.LowThreshold) > 1e-9 ||
+		math.Abs(stored.Hysteresis-1) > 1e-9 || math.Abs(stored.MaxRate-5) > 1e-9 {
+		t.Errorf("expected stored limits 0..35°C, hysteresis 1 and max rate 5, got %+v", stored)
+	}
+
+	// Umbral inferior por encima del superior
+	errResp = nil
+	json.Unmarshal(request(ConfigSetSubject(sensorID), map[string]any{"sensor_id": sensorID, "interval": 1000, "threshold": 30, "low_threshold": 40, "enabled": true}), &errResp)
+	if !strings.Contains(errResp["error"], "low_threshold") {
+		t.Errorf("expected low_threshold validation error, got %v", errResp)
 	}
 }
 
@@ -303,6 +606,9 @@ func TestHandler_Register(t *testing.T) {
 	// Configurar callback de registro
 	registered := false
 	handler.SetAddSensorCallback(func(sensorDef config.SensorDef) error {
+		if sensorDef.ID == "broken-001" {
+			return fmt.Errorf("simulator unavailable")
+		}
 		registered = true
 		return nil
 	})
@@ -319,6 +625,7 @@ func TestHandler_Register(t *testing.T) {
 		ID:   "new-sensor-001",
 		Type: sensor.SensorTypeTemperature,
 		Name: "New Temperature Sensor",
+		Tags: []string{"critico"},
 		Config: sensor.SensorConfig{
 			SensorID:  "new-sensor-001",
 			Interval:  2000,
@@ -359,4 +666,1098 @@ func TestHandler_Register(t *testing.T) {
 	if !registered {
 		t.Error("sensor registration callback was not called")
 	}
+
+	// Verificar que los metadatos del sensor se persistieron
+	saved, _ := repo.GetSensor(context.Background(), "new-sensor-001")
+	if saved == nil {
+		t.Fatal("sensor metadata was not persisted")
+	}
+	if saved.Name != "New Temperature Sensor" {
+		t.Errorf("expected persisted name 'New Temperature Sensor', got %q", saved.Name)
+	}
+	if len(saved.Tags) != 1 || saved.Tags[0] != "critico" || saved.Source != sensor.SensorSourceRegister {
+		t.Errorf("expected tags [critico] and source register, got %v and %q", saved.Tags, saved.Source)
+	}
+
+	register := func(def config.SensorDef) map[string]interface{} {
+		t.Helper()
+		data, _ := json.Marshal(def)
+		response, err := client.Request(ctx, subject, data)
+		if err != nil {
+			t.Fatalf("Request() failed: %v", err)
+		}
+		var result map[string]interface{}
+		json.Unmarshal(response.Data, &result)
+		return result
+	}
+
+	// ID repetido: se rechaza sin sobrescribir su configuración
+	duplicate := newSensor
+	duplicate.Config.Threshold = 99
+	if result := register(duplicate); result["error"] == nil {
+		t.Errorf("expected error for duplicate sensor, got %v", result)
+	}
+	if cfg := repo.configs["new-sensor-001"]; cfg == nil || cfg.Threshold != 28.0 {
+		t.Errorf("duplicate registration overwrote the config: %+v", cfg)
+	}
+
+	// Si el simulador no lo acepta, se deshacen metadatos y configuración
+	broken := newSensor
+	broken.ID = "broken-001"
+	if result := register(broken); result["error"] == nil {
+		t.Errorf("expected error when the simulator rejects the sensor, got %v", result)
+	}
+	if repo.sensors["broken-001"] != nil || repo.configs["broken-001"] != nil {
+		t.Error("failed registration should not leave the sensor persisted")
+	}
+}
+
+func TestHandler_RegisterUsesTypeRegistry(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	handler := NewHandler(client, repo)
+	var added config.SensorDef
+	handler.SetAddSensorCallback(func(sensorDef config.SensorDef) error {
+		added = sensorDef
+		return nil
+	})
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	request := func(payload string) map[string]interface{} {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		response, err := client.Request(ctx, RegisterSubject(), []byte(payload))
+		if err != nil {
+			t.Fatalf("Request() failed: %v", err)
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(response.Data, &result); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return result
+	}
+
+	// Sin umbral se aplica el del tipo
+	result := request(`{"ID": "hum-009", "Type": "humidity", "Config": {"sensor_id": "hum-009", "interval": 1000, "enabled": true}}`)
+	if result["status"] != "ok" {
+		t.Fatalf("expected status ok, got %v", result)
+	}
+	spec, _ := sensor.LookupType(sensor.SensorTypeHumidity)
+	if added.Config.Threshold != spec.DefaultThreshold || result["threshold"] != spec.DefaultThreshold {
+		t.Errorf("expected default threshold %v, got %v (response %v)", spec.DefaultThreshold, added.Config.Threshold, result["threshold"])
+	}
+
+	// Un umbral explícito, aunque sea 0, prevalece
+	request(`{"ID": "hum-010", "Type": "humidity", "Config": {"sensor_id": "hum-010", "interval": 1000, "threshold": 0}}`)
+	if added.ID != "hum-010" || added.Config.Threshold != 0 {
+		t.Errorf("expected explicit threshold 0 for hum-010, got %+v", added)
+	}
+
+	// Tipo no registrado
+	result = request(`{"ID": "co2-001", "Type": "co2", "Config": {"sensor_id": "co2-001", "interval": 1000}}`)
+	if errMsg, _ := result["error"].(string); !strings.Contains(errMsg, "unknown sensor type") {
+		t.Errorf("expected unknown sensor type error, got %v", result)
+	}
+}
+
+func TestHandler_ReadingsStats(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	for i := 0; i < 4; i++ {
+		repo.SaveReading(context.Background(), &sensor.SensorReading{
+			ID:        fmt.Sprintf("reading-%d", i),
+			SensorID:  "temp-001",
+			Type:      sensor.SensorTypeTemperature,
+			Value:     float64(20 + i),
+			Unit:      "°C",
+			Timestamp: time.Now(),
+		})
+	}
+
+	handler := NewHandler(client, repo)
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Bucket válido
+	requestBody, _ := json.Marshal(map[string]string{"bucket": "5m"})
+	response, err := client.Request(ctx, ReadingsStatsSubject("temp-001"), requestBody)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+
+	var aggregates []*sensor.ReadingAggregate
+	if err := json.Unmarshal(response.Data, &aggregates); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if len(aggregates) != 1 {
+		t.Fatalf("expected 1 aggregate, got %d", len(aggregates))
+	}
+	if aggregates[0].Count != 4 || aggregates[0].Min != 20 || aggregates[0].Max != 23 {
+		t.Errorf("unexpected aggregate: %+v", aggregates[0])
+	}
+
+	// Bucket inválido
+	requestBody, _ = json.Marshal(map[string]string{"bucket": "7m"})
+	response, err = client.Request(ctx, ReadingsStatsSubject("temp-001"), requestBody)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+
+	var result map[string]string
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if result["error"] == "" {
+		t.Error("expected error for unsupported bucket")
+	}
+}
+
+func TestHandler_Metrics(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	handler := NewHandler(client, NewMockRepository())
+	handler.SetMetricsCallback(func() map[string]interface{} {
+		return map[string]interface{}{"writer": map[string]int{"flushes": 3}}
+	})
+
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	response, err := client.Request(ctx, MetricsSubject(), nil)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+
+	var result map[string]map[string]int
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if result["writer"]["flushes"] != 3 {
+		t.Errorf("expected writer.flushes 3, got %v", result)
+	}
+}
+
+func TestHandler_CacheStatsAndConfigEvents(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	repo.SaveConfig(context.Background(), &sensor.SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30, Enabled: true})
+
+	handler := NewHandler(client, repo)
+	handler.SetCacheStatsCallback(func() cache.RepositoryStats {
+		return cache.RepositoryStats{Configs: cache.Stats{Hits: 8, Misses: 2, HitRatio: 0.8}}
+	})
+	events := make(chan ConfigChangedEvent, 1)
+	handler.SetConfigChangedCallback(func(event ConfigChangedEvent) {
+		events <- event
+	})
+
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	response, err := client.Request(ctx, CacheStatsSubject(), nil)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	var stats cache.RepositoryStats
+	if err := json.Unmarshal(response.Data, &stats); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if stats.Configs.Hits != 8 || stats.Configs.HitRatio != 0.8 {
+		t.Errorf("unexpected cache stats: %+v", stats)
+	}
+
+	// Un cambio de configuración publica el evento
+	configData, _ := json.Marshal(map[string]interface{}{"sensor_id": "temp-001", "interval": 2000, "threshold": 25, "enabled": true, "changed_by": "alice"})
+	if _, err := client.Request(ctx, ConfigSetSubject("temp-001"), configData); err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.SensorID != "temp-001" || event.Reason != "set" || event.ChangedBy != "alice" {
+			t.Errorf("unexpected event: %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("config change event not received")
+	}
+
+	// Sin caché configurada el subject responde con error
+	otherClient, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer otherClient.Close()
+	client.Close()
+	if err := NewHandler(otherClient, repo).HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	response, err = otherClient.Request(ctx, CacheStatsSubject(), nil)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	var errResp map[string]string
+	json.Unmarshal(response.Data, &errResp)
+	if errResp["error"] != "cache not enabled" {
+		t.Errorf("expected cache not enabled error, got %s", response.Data)
+	}
+}
+
+func TestHandler_AdminBackup(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	// Primer backup correcto, el segundo falla
+	createdAt := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	calls := 0
+	handler := NewHandler(client, NewMockRepository())
+	handler.SetBackupCallback(func(ctx context.Context) (*backup.Snapshot, error) {
+		calls++
+		if calls > 1 {
+			return nil, fmt.Errorf("disk full")
+		}
+		return &backup.Snapshot{Path: "/backups/sensors-20251016T120000.000Z.db", SizeBytes: 4096, CreatedAt: createdAt}, nil
+	})
+
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	request := func() map[string]interface{} {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+
+		response, err := client.Request(ctx, AdminBackupSubject(), nil)
+		if err != nil {
+			t.Fatalf("Request() failed: %v", err)
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(response.Data, &result); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return result
+	}
+
+	result := request()
+	if result["status"] != "ok" || result["path"] != "/backups/sensors-20251016T120000.000Z.db" || result["size_bytes"] != 4096.0 {
+		t.Errorf("unexpected backup response: %v", result)
+	}
+
+	if result := request(); result["error"] != "failed to create backup: disk full" {
+		t.Errorf("unexpected error response: %v", result)
+	}
+}
+
+func TestHandler_ConfigHistoryAndRollback(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	handler := NewHandler(client, repo)
+
+	var applied []sensor.SensorConfig
+	handler.SetUpdateConfigCallback(func(sensorID string, cfg sensor.SensorConfig) error {
+		applied = append(applied, cfg)
+		return nil
+	})
+
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	request := func(subject string, body interface{}) []byte {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		response, err := client.Request(ctx, subject, data)
+		if err != nil {
+			t.Fatalf("Request(%s) failed: %v", subject, err)
+		}
+		return response.Data
+	}
+
+	// Revisión 1 (registro inicial) y 2 (set con autor)
+	repo.SaveConfig(context.Background(), &sensor.SensorConfig{SensorID: "temp-001", Interval: 5000, Threshold: 30, Enabled: true})
+	request(ConfigSetSubject("temp-001"), map[string]interface{}{
+		"sensor_id": "temp-001", "interval": 1000, "threshold": 20.0, "enabled": true, "changed_by": "alice",
+	})
+
+	var history []sensor.ConfigRevision
+	if err := json.Unmarshal(request(ConfigHistorySubject("temp-001"), nil), &history); err != nil {
+		t.Fatalf("failed to unmarshal history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(history))
+	}
+	if history[0].Revision != 2 || history[0].ChangedBy != "alice" || history[0].Reason != "set" || history[0].Config.Threshold != 20 {
+		t.Errorf("unexpected latest revision: %+v", history[0])
+	}
+
+	// Rollback sin revisión: vuelve a la penúltima (1)
+	var result map[string]interface{}
+	json.Unmarshal(request(ConfigRollbackSubject("temp-001"), map[string]string{"changed_by": "bob"}), &result)
+	if result["status"] != "ok" || result["rolled_back_to"] != float64(1) {
+		t.Fatalf("unexpected rollback response: %v", result)
+	}
+
+	current, _ := repo.GetConfig(context.Background(), "temp-001")
+	if current.Interval != 5000 || current.Threshold != 30 {
+		t.Errorf("config was not restored: %+v", current)
+	}
+	if len(applied) != 2 || applied[1].Interval != 5000 {
+		t.Errorf("expected simulator to receive the restored config, got %+v", applied)
+	}
+
+	latest, _ := repo.GetConfigHistory(context.Background(), "temp-001", 1)
+	if latest[0].Revision != 3 || latest[0].ChangedBy != "bob" || latest[0].Reason != "rollback to revision 1" {
+		t.Errorf("rollback was not recorded as a new revision: %+v", latest[0])
+	}
+
+	// Errores: revisión actual e inexistente
+	json.Unmarshal(request(ConfigRollbackSubject("temp-001"), map[string]int{"revision": 3}), &result)
+	if result["error"] == nil {
+		t.Errorf("expected error when rolling back to the current revision, got %v", result)
+	}
+	result = nil
+	json.Unmarshal(request(ConfigRollbackSubject("temp-001"), map[string]int{"revision": 42}), &result)
+	if result["error"] == nil {
+		t.Errorf("expected error for unknown revision, got %v", result)
+	}
+	result = nil
+	json.Unmarshal(request(ConfigRollbackSubject("unknown"), nil), &result)
+	if result["error"] == nil {
+		t.Errorf("expected error for sensor without history, got %v", result)
+	}
+}
+
+func TestHandler_Calibrate(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	handler := NewHandler(client, repo)
+
+	var applied []sensor.SensorConfig
+	handler.SetUpdateConfigCallback(func(sensorID string, cfg sensor.SensorConfig) error {
+		applied = append(applied, cfg)
+		return nil
+	})
+
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx := context.Background()
+	repo.SaveSensor(ctx, &sensor.Sensor{ID: "temp-001", Type: sensor.SensorTypeTemperature, Name: "Sala"})
+	repo.SaveConfig(ctx, &sensor.SensorConfig{SensorID: "temp-001", Interval: 5000, Threshold: 30, Enabled: true})
+	raw := 21.5
+	errMsg := "sensor timeout"
+	repo.SaveReading(ctx, &sensor.SensorReading{ID: "r1", SensorID: "temp-001", Value: 20.5, Unit: "°C", Timestamp: time.Now()})
+	repo.SaveReading(ctx, &sensor.SensorReading{ID: "r2", SensorID: "temp-001", Value: 99, RawValue: &raw, Unit: "°C", Timestamp: time.Now()})
+	repo.SaveReading(ctx, &sensor.SensorReading{ID: "r3", SensorID: "temp-001", Unit: "°C", Error: &errMsg, Timestamp: time.Now()})
+
+	calibrate := func(sensorID string, body interface{}) CalibrateResponse {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		msg, err := client.Request(ctx, CalibrateSubject(sensorID), data)
+		if err != nil {
+			t.Fatalf("Request() failed: %v", err)
+		}
+		var response CalibrateResponse
+		if err := json.Unmarshal(msg.Data, &response); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		return response
+	}
+
+	// Un punto frente a la media de los valores brutos de las lecturas válidas (20.5 y 21.5)
+	response := calibrate("temp-001", map[string]interface{}{"reference": 21.4, "samples": 3, "changed_by": "alice"})
+	if response.Error != "" || !response.Applied || response.Samples != 2 || response.Raw == nil || *response.Raw != 21 {
+		t.Fatalf("unexpected one-point response: %+v", response)
+	}
+	current, _ := repo.GetConfig(ctx, "temp-001")
+	if current.Calibration == nil || math.Abs(current.Calibration.Offset-0.4) > 1e-9 || current.Threshold != 30 {
+		t.Errorf("expected offset 0.4 saved with the rest of the config, got %+v", current)
+	}
+	if len(applied) != 1 || applied[0].Calibration == nil {
+		t.Errorf("expected simulator to receive the calibration, got %+v", applied)
+	}
+	if latest, _ := repo.GetConfigHistory(ctx, "temp-001", 1); latest[0].ChangedBy != "alice" || latest[0].Reason != "calibrate" {
+		t.Errorf("calibration was not recorded as a revision: %+v", latest[0])
+	}
+
+	// Dos puntos en °F sin guardar: 0°C -> 1°C y 100°C -> 99°C
+	response = calibrate("temp-001", map[string]interface{}{
+		"points":  []CalibrationPoint{{Raw: 32, Reference: 33.8}, {Raw: 212, Reference: 210.2}},
+		"unit":    "°F",
+		"dry_run": true,
+	})
+	if response.Error != "" || response.Applied || response.Unit != "°C" || response.Calibration == nil ||
+		math.Abs(response.Calibration.Gain-0.98) > 1e-9 || math.Abs(response.Calibration.Offset-1) > 1e-9 {
+		t.Fatalf("unexpected two-point response: %+v", response)
+	}
+	if response.Previous == nil || math.Abs(response.Previous.Offset-0.4) > 1e-9 {
+		t.Errorf("expected the previous calibration in the response, got %+v", response.Previous)
+	}
+	if current, _ := repo.GetConfig(ctx, "temp-001"); math.Abs(current.Calibration.Offset-0.4) > 1e-9 || len(applied) != 1 {
+		t.Errorf("dry run modified the config: %+v", current.Calibration)
+	}
+
+	// Errores
+	for name, body := range map[string]interface{}{
+		"no reference":   map[string]interface{}{},
+		"both modes":     map[string]interface{}{"reference": 20, "points": []CalibrationPoint{{1, 1}, {2, 2}}},
+		"three points":   map[string]interface{}{"points": []CalibrationPoint{{1, 1}, {2, 2}, {3, 3}}},
+		"same raw twice": map[string]interface{}{"points": []CalibrationPoint{{1, 1}, {1, 2}}},
+		"bad unit":       map[string]interface{}{"reference": 20, "unit": "psi"},
+		"bad samples":    map[string]interface{}{"reference": 20, "samples": -1},
+	} {
+		if response := calibrate("temp-001", body); response.Error == "" {
+			t.Errorf("%s: expected error, got %+v", name, response)
+		}
+	}
+	if response := calibrate("unknown", map[string]float64{"reference": 20}); response.Error == "" {
+		t.Errorf("expected error for unknown sensor, got %+v", response)
+	}
+}
+
+func TestHandler_AlertsQuery(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	base := time.Now().UTC()
+	for i, severity := range []sensor.AlertSeverity{sensor.AlertSeverityWarning, sensor.AlertSeverityCritical, sensor.AlertSeverityWarning} {
+		repo.SaveAlert(context.Background(), &sensor.Alert{
+			ID:        fmt.Sprintf("alert-%d", i),
+			SensorID:  "temp-001",
+			Type:      sensor.SensorTypeTemperature,
+			Severity:  severity,
+			Value:     float64(31 + i),
+			Threshold: 30,
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	handler := NewHandler(client, repo)
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Sin body: últimas 50 alertas
+	response, err := client.Request(ctx, AlertsQuerySubject(), nil)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	var alerts []*sensor.Alert
+	if err := json.Unmarshal(response.Data, &alerts); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(alerts) != 3 || alerts[0].ID != "alert-2" {
+		t.Errorf("expected 3 alerts newest first, got %v", alerts)
+	}
+	if repo.lastAlertFilter.Limit != 50 {
+		t.Errorf("expected default limit 50, got %d", repo.lastAlertFilter.Limit)
+	}
+
+	// Filtro por severidad y sensor
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"sensor_id": "temp-001",
+		"severity":  "critical",
+		"start":     base.Add(-time.Hour),
+	})
+	response, err = client.Request(ctx, AlertsQuerySubject(), requestBody)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	alerts = nil
+	json.Unmarshal(response.Data, &alerts)
+	if len(alerts) != 1 || alerts[0].ID != "alert-1" {
+		t.Errorf("expected only the critical alert, got %v", alerts)
+	}
+	if repo.lastAlertFilter.SensorID != "temp-001" || !repo.lastAlertFilter.Start.Equal(base.Add(-time.Hour)) {
+		t.Errorf("filter not passed to repository: %+v", repo.lastAlertFilter)
+	}
+
+	// Severidad inválida
+	requestBody, _ = json.Marshal(map[string]string{"severity": "info"})
+	response, err = client.Request(ctx, AlertsQuerySubject(), requestBody)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	var errResp map[string]string
+	json.Unmarshal(response.Data, &errResp)
+	if errResp["error"] == "" {
+		t.Errorf("expected error for invalid severity, got %s", response.Data)
+	}
+}
+
+func TestHandler_ReadingsSearch(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	repo.SaveSensor(context.Background(), &sensor.Sensor{ID: "temp-001", Type: sensor.SensorTypeTemperature, Location: "almacen", Tags: []string{"critico"}})
+	repo.SaveSensor(context.Background(), &sensor.Sensor{ID: "temp-002", Type: sensor.SensorTypeTemperature, Location: "oficina"})
+	base := time.Now().UTC()
+	for _, id := range []string{"temp-001", "temp-002"} {
+		repo.SaveReading(context.Background(), &sensor.SensorReading{ID: id + "-r", SensorID: id, Type: sensor.SensorTypeTemperature, Value: 21, Timestamp: base})
+	}
+
+	handler := NewHandler(client, repo)
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"type":     "temperature",
+		"location": "almacen",
+		"tags":     []string{"critico"},
+		"start":    base.Add(-time.Hour),
+	})
+	response, err := client.Request(ctx, ReadingsSearchSubject(), requestBody)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	var page ReadingsPage
+	if err := json.Unmarshal(response.Data, &page); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if len(page.Readings) != 1 || page.Readings[0].SensorID != "temp-001" {
+		t.Errorf("expected only the reading of temp-001, got %v", page.Readings)
+	}
+	if repo.lastSearch.Limit != 50 || repo.lastSearch.Location != "almacen" || !repo.lastSearch.Start.Equal(base.Add(-time.Hour)) {
+		t.Errorf("search not passed to repository: %+v", repo.lastSearch)
+	}
+
+	// Errores de validación
+	for _, body := range []string{
+		`{"limit": 5000}`,
+		`{"cursor": "%%%"}`,
+		fmt.Sprintf(`{"start": %q, "end": %q}`, base.Format(time.RFC3339), base.Add(-time.Hour).Format(time.RFC3339)),
+	} {
+		response, err = client.Request(ctx, ReadingsSearchSubject(), []byte(body))
+		if err != nil {
+			t.Fatalf("Request() failed: %v", err)
+		}
+		var errResp map[string]string
+		json.Unmarshal(response.Data, &errResp)
+		if errResp["error"] == "" {
+			t.Errorf("expected error for %s, got %s", body, response.Data)
+		}
+	}
+}
+
+func TestHandler_ReadingsExport(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	// Lecturas con huecos, dos con el mismo timestamp y una fuera de rango
+	repo := NewMockRepository()
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{-time.Hour, 0, 30 * time.Minute, 30 * time.Minute, 2*time.Hour + time.Second, 3 * time.Hour}
+	for i, offset := range offsets {
+		repo.SaveReading(context.Background(), &sensor.SensorReading{
+			ID:        fmt.Sprintf("reading-%d", len(offsets)-i), // ids no ordenados como el tiempo
+			SensorID:  "temp-001",
+			Type:      sensor.SensorTypeTemperature,
+			Value:     float64(i),
+			Timestamp: base.Add(offset),
+		})
+	}
+
+	handler := NewHandler(client, repo)
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var ids []string
+	cursor := ""
+	for chunks := 1; ; chunks++ {
+		requestBody, _ := json.Marshal(map[string]interface{}{
+			"from":   base,
+			"to":     base.Add(3 * time.Hour),
+			"limit":  2,
+			"cursor": cursor,
+		})
+		response, err := client.Request(ctx, ReadingsExportSubject("temp-001"), requestBody)
+		if err != nil {
+			t.Fatalf("Request() failed: %v", err)
+		}
+
+		var page ReadingsPage
+		if err := json.Unmarshal(response.Data, &page); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		for _, reading := range page.Readings {
+			ids = append(ids, reading.ID)
+		}
+
+		if page.NextCursor == "" {
+			if chunks != 3 {
+				t.Errorf("expected 3 chunks, got %d", chunks)
+			}
+			break
+		}
+		if chunks > 5 {
+			t.Fatal("export did not terminate")
+		}
+		cursor = page.NextCursor
+	}
+
+	// Orden ascendente (timestamp, id) e inclusivo en ambos extremos
+	want := "reading-5,reading-3,reading-4,reading-2,reading-1"
+	if got := strings.Join(ids, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	response, err := client.Request(ctx, ReadingsExportSubject("temp-001"), []byte(`{"limit": 10}`))
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	var result map[string]interface{}
+	json.Unmarshal(response.Data, &result)
+	if result["error"] != "from is required" {
+		t.Errorf("expected 'from is required', got %v", result)
+	}
+}
+
+// maxSizeReadings genera n lecturas con campos de tamaño realista (id ULID, raw_value,
+// timestamp con nanosegundos) para comprobar el tamaño de los mensajes
+func maxSizeReadings(n int, sensorID string) []*sensor.SensorReading {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 123456789, time.UTC)
+	raw := -12345.678901234
+	readings := make([]*sensor.SensorReading, n)
+	for i := range readings {
+		readings[i] = &sensor.SensorReading{
+			ID:        fmt.Sprintf("01JA%022d", i),
+			SensorID:  sensorID,
+			Type:      sensor.SensorTypeTemperature,
+			Value:     -12345.678901234,
+			RawValue:  &raw,
+			Unit:      "°C",
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		}
+	}
+	return readings
+}
+
+func TestReadingsExport_MaxChunkFitsPayload(t *testing.T) {
+	readings := maxSizeReadings(maxExportLimit, "warehouse-north-temperature-001")
+	cursor := repository.ReadingCursor{Timestamp: readings[len(readings)-1].Timestamp, ID: readings[len(readings)-1].ID}
+	if n := fitPayload(readings, maxExportBytes); n != len(readings) {
+		t.Fatalf("expected a full chunk of typical readings, got %d of %d", n, len(readings))
+	}
+
+	data, err := json.Marshal(ReadingsPage{Readings: readings, NextCursor: cursor.Encode()})
+	if err != nil {
+		t.Fatalf("failed to marshal chunk: %v", err)
+	}
+	if len(data) > natsserver.MAX_PAYLOAD_SIZE {
+		t.Errorf("max-size chunk is %d bytes, over the default max_payload (%d)", len(data), natsserver.MAX_PAYLOAD_SIZE)
+	}
+
+	// Con ids de sensor largos el trozo se corta por bytes
+	long := maxSizeReadings(maxExportLimit, strings.Repeat("s", 512))
+	n := fitPayload(long, maxExportBytes)
+	if n == 0 || n >= len(long) {
+		t.Fatalf("expected the chunk to be cut by size, got %d of %d", n, len(long))
+	}
+	data, _ = json.Marshal(ReadingsPage{Readings: long[:n], NextCursor: cursor.Encode()})
+	if len(data) > natsserver.MAX_PAYLOAD_SIZE {
+		t.Errorf("size-cut chunk is %d bytes, over the default max_payload (%d)", len(data), natsserver.MAX_PAYLOAD_SIZE)
+	}
+}
+
+func TestReadingsImport_MaxBatchFitsPayload(t *testing.T) {
+	readings := maxSizeReadings(MaxImportRecords, "warehouse-north-temperature-001")
+	records := make([]ImportRecord, len(readings))
+	size := 0
+	for i, reading := range readings {
+		records[i] = ImportRecord{Line: 1_000_000 + i, SensorReading: *reading}
+		encoded, _ := json.Marshal(records[i])
+		size += len(encoded) + 1
+	}
+	if size > MaxImportBytes {
+		t.Errorf("a full batch of typical records is %d bytes, over MaxImportBytes (%d)", size, MaxImportBytes)
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		t.Fatalf("failed to marshal batch: %v", err)
+	}
+	if len(data) > natsserver.MAX_PAYLOAD_SIZE {
+		t.Errorf("max-size batch is %d bytes, over the default max_payload (%d)", len(data), natsserver.MAX_PAYLOAD_SIZE)
+	}
+	if MaxImportBytes+len(`{"records":[]}`) > natsserver.MAX_PAYLOAD_SIZE {
+		t.Errorf("MaxImportBytes (%d) does not fit in the default max_payload (%d)", MaxImportBytes, natsserver.MAX_PAYLOAD_SIZE)
+	}
+}
+
+func TestHandler_ReadingsImport(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	ts := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+	repo.SaveReading(context.Background(), &sensor.SensorReading{ID: "old-1", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 20, Unit: "°C", Timestamp: ts})
+
+	handler := NewHandler(client, repo)
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	record := func(line int, id string, unit string) ImportRecord {
+		return ImportRecord{Line: line, SensorReading: sensor.SensorReading{
+			ID: id, SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 21, Unit: unit, Timestamp: ts.Add(time.Duration(line) * time.Minute),
+		}}
+	}
+	requestBody, _ := json.Marshal(map[string]interface{}{
+		"records": []ImportRecord{
+			record(2, "new-1", "°C"),
+			record(3, "old-1", "°C"), // ya guardada
+			record(4, "new-2", ""),   // no pasa Validate
+			record(5, "new-1", "°C"), // repetida en el lote
+			record(6, "new-3", "°C"),
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	response, err := client.Request(ctx, ReadingsImportSubject(), requestBody)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+
+	var result ImportResult
+	if err := json.Unmarshal(response.Data, &result); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+
+	if result.Accepted != 2 {
+		t.Errorf("expected 2 accepted readings, got %d", result.Accepted)
+	}
+	want := []ImportRejection{
+		{Line: 3, ID: "old-1", Reason: "duplicate id (already stored)"},
+		{Line: 4, ID: "new-2", Reason: "unit is required"},
+		{Line: 5, ID: "new-1", Reason: "duplicate id (first seen at line 2)"},
+	}
+	if fmt.Sprint(result.Rejected) != fmt.Sprint(want) {
+		t.Errorf("expected rejections %v, got %v", want, result.Rejected)
+	}
+	if got := len(repo.readings["temp-001"]); got != 3 {
+		t.Errorf("expected 3 stored readings, got %d", got)
+	}
+
+	response, err = client.Request(ctx, ReadingsImportSubject(), []byte(`{"records": []}`))
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	var errResult map[string]interface{}
+	json.Unmarshal(response.Data, &errResult)
+	if errResult["error"] != "records are required" {
+		t.Errorf("expected 'records are required', got %v", errResult)
+	}
+}
+
+func TestHandler_ReadingsQueryPagination(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		repo.SaveReading(context.Background(), &sensor.SensorReading{
+			ID:        fmt.Sprintf("reading-%d", i),
+			SensorID:  "temp-001",
+			Type:      sensor.SensorTypeTemperature,
+			Value:     float64(20 + i),
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	handler := NewHandler(client, repo)
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Recorrer todas las páginas de 2 lecturas
+	var ids []string
+	cursor := ""
+	for pages := 1; ; pages++ {
+		requestBody, _ := json.Marshal(map[string]interface{}{"limit": 2, "cursor": cursor})
+		response, err := client.Request(ctx, ReadingsQuerySubject("temp-001"), requestBody)
+		if err != nil {
+			t.Fatalf("Request() failed: %v", err)
+		}
+
+		var page ReadingsPage
+		if err := json.Unmarshal(response.Data, &page); err != nil {
+			t.Fatalf("failed to unmarshal response: %v", err)
+		}
+		for _, reading := range page.Readings {
+			ids = append(ids, reading.ID)
+		}
+
+		if page.NextCursor == "" {
+			if pages != 3 {
+				t.Errorf("expected 3 pages, got %d", pages)
+			}
+			break
+		}
+		if pages > 3 {
+			t.Fatal("pagination did not terminate")
+		}
+		cursor = page.NextCursor
+	}
+
+	want := "reading-4,reading-3,reading-2,reading-1,reading-0"
+	if got := strings.Join(ids, ","); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+
+	// Cursor inválido
+	requestBody, _ := json.Marshal(map[string]string{"cursor": "%%%"})
+	response, err := client.Request(ctx, ReadingsQuerySubject("temp-001"), requestBody)
+	if err != nil {
+		t.Fatalf("Request() failed: %v", err)
+	}
+	var errResp map[string]string
+	json.Unmarshal(response.Data, &errResp)
+	if !strings.Contains(errResp["error"], "invalid cursor") {
+		t.Errorf("expected invalid cursor error, got %s", response.Data)
+	}
+}
+
+func TestHandler_Remove(t *testing.T) {
+	_, url := setupTestNATS(t)
+
+	client, err := NewClient(url)
+	if err != nil {
+		t.Fatalf("NewClient() failed: %v", err)
+	}
+	defer client.Close()
+
+	repo := NewMockRepository()
+	ctx := context.Background()
+	for _, id := range []string{"temp-001", "temp-002", "temp-003"} {
+		repo.SaveConfig(ctx, &sensor.SensorConfig{SensorID: id, Interval: 1000, Enabled: true})
+		repo.SaveSensor(ctx, &sensor.Sensor{ID: id, Type: sensor.SensorTypeTemperature})
+		repo.SaveReading(ctx, &sensor.SensorReading{ID: id + "-r1", SensorID: id, Timestamp: time.Now()})
+	}
+	repo.SaveSensor(ctx, &sensor.Sensor{ID: "yaml-001", Type: sensor.SensorTypeTemperature, Source: sensor.SensorSourceConfig})
+
+	handler := NewHandler(client, repo)
+
+	running := map[string]bool{"temp-001": true, "temp-002": true, "temp-003": true, "yaml-001": true}
+	handler.SetRemoveSensorCallback(func(sensorID string) error {
+		if !running[sensorID] {
+			return fmt.Errorf("sensor %s not found", sensorID)
+		}
+		delete(running, sensorID)
+		return nil
+	})
+	handler.SetListSensorsCallback(func() []config.SensorDef {
+		var defs []config.SensorDef
+		for id := range running {
+			defs = append(defs, config.SensorDef{ID: id})
+		}
+		return defs
+	})
+
+	if err := handler.HandleConfigRequests(); err != nil {
+		t.Fatalf("HandleConfigRequests() failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	request := func(sensorID string, body interface{}) map[string]interface{} {
+		t.Helper()
+		data, _ := json.Marshal(body)
+		reqCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		defer cancel()
+		response, err := client.Request(reqCtx, RemoveSubject(sensorID), data)
+		if err != nil {
+			t.Fatalf("Request() failed: %v", err)
+		}
+		var result map[string]interface{}
+		json.Unmarshal(response.Data, &result)
+		return result
+	}
+
+	// Sin purge: se conservan lecturas e historial
+	result := request("temp-001", nil)
+	if result["status"] != "ok" || result["purged"] != false {
+		t.Fatalf("unexpected response: %v", result)
+	}
+	if running["temp-001"] {
+		t.Error("sensor was not stopped in the simulator")
+	}
+	if repo.sensors["temp-001"] != nil || repo.configs["temp-001"] != nil {
+		t.Error("sensor metadata and config should be deleted")
+	}
+	if len(repo.readings["temp-001"]) != 1 || len(repo.history["temp-001"]) != 1 {
+		t.Error("readings and config history should be kept without purge")
+	}
+
+	// Con purge
+	result = request("temp-002", map[string]bool{"purge": true})
+	if result["status"] != "ok" || result["purged"] != true || result["readings_deleted"] != float64(1) {
+		t.Fatalf("unexpected response: %v", result)
+	}
+	if len(repo.readings["temp-002"]) != 0 || len(repo.history["temp-002"]) != 0 {
+		t.Error("readings and config history should be purged")
+	}
+
+	// Purge fallido: el sensor queda detenido con sus metadatos y la baja se reintenta
+	repo.purgeErr = fmt.Errorf("disk full")
+	result = request("temp-003", map[string]bool{"purge": true})
+	if result["error"] == nil || running["temp-003"] {
+		t.Fatalf("expected purge error with the sensor stopped, got %v", result)
+	}
+	if repo.sensors["temp-003"] == nil || repo.configs["temp-003"] == nil {
+		t.Fatal("sensor metadata and config should be kept when the purge fails")
+	}
+	result = request("temp-003", map[string]bool{"purge": true})
+	if result["status"] != "ok" || result["readings_deleted"] != float64(1) {
+		t.Fatalf("unexpected retry response: %v", result)
+	}
+	if repo.sensors["temp-003"] != nil || len(repo.readings["temp-003"]) != 0 {
+		t.Error("retry should purge and delete the sensor")
+	}
+
+	// Sensor del YAML: se rechaza sin detenerlo
+	result = request("yaml-001", nil)
+	if result["error"] == nil || !running["yaml-001"] {
+		t.Errorf("expected error for a YAML-defined sensor, got %v", result)
+	}
+
+	// Sensor desconocido: el repositorio no se toca
+	result = request("unknown", map[string]bool{"purge": true})
+	if result["error"] == nil {
+		t.Errorf("expected error for unknown sensor, got %v", result)
+	}
 }
@@ -4,12 +4,24 @@ import "fmt"
 
 // Subjects NATS organizados jerárquicamente
 const (
-	SubjectReadings      = "sensor.readings"       // sensor.readings.<type>.<id>
-	SubjectReadingsQuery = "sensor.readings.query" // sensor.readings.query.<id>
-	SubjectConfig        = "sensor.config"         // sensor.config.<get|set>.<id>
-	SubjectAlerts        = "sensor.alerts"         // sensor.alerts.<type>.<id>
-	SubjectRegister      = "sensor.register"       // sensor.register
-	SubjectList          = "sensor.list"           // sensor.list
+	SubjectReadings       = "sensor.readings"         // sensor.readings.<type>.<id>
+	SubjectReadingsQuery  = "sensor.readings.query"   // sensor.readings.query.<id>
+	SubjectReadingsStats  = "sensor.readings.stats"   // sensor.readings.stats.<id>
+	SubjectReadingsExport = "sensor.readings.export"  // sensor.readings.export.<id>
+	SubjectReadingsImport = "sensor.readings.import"  // sensor.readings.import
+	SubjectReadingsSearch = "sensor.readings.search"  // sensor.readings.search
+	SubjectDeviceReadings = "sensor.devices.readings" // sensor.devices.readings.<device-id>
+	SubjectConfig         = "sensor.config"           // sensor.config.<get|set|history|rollback>.<id>
+	SubjectConfigChanged  = "sensor.config.changed"   // sensor.config.changed.<id> (eventos)
+	SubjectAlerts         = "sensor.alerts"           // sensor.alerts.<type>.<id>
+	SubjectAlertsQuery    = "sensor.alerts.query"     // sensor.alerts.query
+	SubjectRegister       = "sensor.register"         // sensor.register
+	SubjectRemove         = "sensor.remove"           // sensor.remove.<id>
+	SubjectCalibrate      = "sensor.calibrate"        // sensor.calibrate.<id>
+	SubjectList           = "sensor.list"             // sensor.list
+	SubjectMetrics        = "sensor.metrics"          // sensor.metrics
+	SubjectAdminBackup    = "sensor.admin.backup"     // sensor.admin.backup
+	SubjectCacheStats     = "sensor.cache.stats"      // sensor.cache.stats
 )
 
 // ReadingSubject construye el subject para publicar una lectura
@@ -18,6 +30,12 @@ func ReadingSubject(sensorType, sensorID string) string {
 	return fmt.Sprintf("%s.%s.%s", SubjectReadings, sensorType, sensorID)
 }
 
+// DeviceReadingSubject construye el subject para publicar una muestra de un dispositivo
+// Ejemplo: "sensor.devices.readings.bme280-01"
+func DeviceReadingSubject(deviceID string) string {
+	return fmt.Sprintf("%s.%s", SubjectDeviceReadings, deviceID)
+}
+
 // ConfigGetSubject construye el subject para obtener configuración
 // Ejemplo: "sensor.config.get.temp-001"
 func ConfigGetSubject(sensorID string) string {
@@ -30,24 +48,96 @@ func ConfigSetSubject(sensorID string) string {
 	return fmt.Sprintf("%s.set.%s", SubjectConfig, sensorID)
 }
 
+// ConfigHistorySubject construye el subject para consultar el historial de configuración
+// Ejemplo: "sensor.config.history.temp-001"
+func ConfigHistorySubject(sensorID string) string {
+	return fmt.Sprintf("%s.history.%s", SubjectConfig, sensorID)
+}
+
+// ConfigRollbackSubject construye el subject para restaurar una revisión de configuración
+// Ejemplo: "sensor.config.rollback.temp-001"
+func ConfigRollbackSubject(sensorID string) string {
+	return fmt.Sprintf("%s.rollback.%s", SubjectConfig, sensorID)
+}
+
+// ConfigChangedSubject construye el subject donde se publican los cambios de configuración
+// Ejemplo: "sensor.config.changed.temp-001"
+func ConfigChangedSubject(sensorID string) string {
+	return fmt.Sprintf("%s.%s", SubjectConfigChanged, sensorID)
+}
+
 // AlertSubject construye el subject para publicar alertas
 // Ejemplo: "sensor.alerts.temperature.temp-001"
 func AlertSubject(sensorType, sensorID string) string {
 	return fmt.Sprintf("%s.%s.%s", SubjectAlerts, sensorType, sensorID)
 }
 
+// AlertsQuerySubject retorna el subject para consultar alertas persistidas
+func AlertsQuerySubject() string {
+	return SubjectAlertsQuery
+}
+
 // ReadingsQuerySubject construye el subject para consultar lecturas
 // Ejemplo: "sensor.readings.query.temp-001"
 func ReadingsQuerySubject(sensorID string) string {
 	return fmt.Sprintf("%s.%s", SubjectReadingsQuery, sensorID)
 }
 
+// ReadingsStatsSubject construye el subject para consultar agregados por bucket
+// Ejemplo: "sensor.readings.stats.temp-001"
+func ReadingsStatsSubject(sensorID string) string {
+	return fmt.Sprintf("%s.%s", SubjectReadingsStats, sensorID)
+}
+
+// ReadingsExportSubject construye el subject para exportar lecturas por trozos
+// Ejemplo: "sensor.readings.export.temp-001"
+func ReadingsExportSubject(sensorID string) string {
+	return fmt.Sprintf("%s.%s", SubjectReadingsExport, sensorID)
+}
+
+// ReadingsImportSubject retorna el subject para importar lecturas históricas
+func ReadingsImportSubject() string {
+	return SubjectReadingsImport
+}
+
+// ReadingsSearchSubject retorna el subject para buscar lecturas por metadatos de sensor
+func ReadingsSearchSubject() string {
+	return SubjectReadingsSearch
+}
+
 // RegisterSubject retorna el subject para registrar nuevos sensores
 func RegisterSubject() string {
 	return SubjectRegister
 }
 
+// RemoveSubject construye el subject para dar de baja un sensor
+// Ejemplo: "sensor.remove.temp-001"
+func RemoveSubject(sensorID string) string {
+	return fmt.Sprintf("%s.%s", SubjectRemove, sensorID)
+}
+
+// CalibrateSubject construye el subject para calibrar un sensor
+// Ejemplo: "sensor.calibrate.temp-001"
+func CalibrateSubject(sensorID string) string {
+	return fmt.Sprintf("%s.%s", SubjectCalibrate, sensorID)
+}
+
 // ListSubject retorna el subject para listar todos los sensores
 func ListSubject() string {
 	return SubjectList
 }
+
+// MetricsSubject retorna el subject para consultar métricas internas del servidor
+func MetricsSubject() string {
+	return SubjectMetrics
+}
+
+// AdminBackupSubject retorna el subject para solicitar un backup de la base de datos
+func AdminBackupSubject() string {
+	return SubjectAdminBackup
+}
+
+// CacheStatsSubject retorna el subject para consultar las métricas de la caché
+func CacheStatsSubject() string {
+	return SubjectCacheStats
+}
@@ -33,6 +33,14 @@ func TestReadingSubject(t *testing.T) {
 	}
 }
 
+func TestDeviceReadingSubject(t *testing.T) {
+	got := DeviceReadingSubject("bme280-01")
+	want := "sensor.devices.readings.bme280-01"
+	if got != want {
+		t.Errorf("DeviceReadingSubject() = %v, want %v", got, want)
+	}
+}
+
 func TestConfigGetSubject(t *testing.T) {
 	got := ConfigGetSubject("temp-001")
 	want := "sensor.config.get.temp-001"
@@ -56,3 +64,99 @@ func TestAlertSubject(t *testing.T) {
 		t.Errorf("AlertSubject() = %v, want %v", got, want)
 	}
 }
+
+func TestReadingsStatsSubject(t *testing.T) {
+	got := ReadingsStatsSubject("temp-001")
+	want := "sensor.readings.stats.temp-001"
+	if got != want {
+		t.Errorf("ReadingsStatsSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigHistorySubject(t *testing.T) {
+	got := ConfigHistorySubject("temp-001")
+	want := "sensor.config.history.temp-001"
+	if got != want {
+		t.Errorf("ConfigHistorySubject() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigRollbackSubject(t *testing.T) {
+	got := ConfigRollbackSubject("temp-001")
+	want := "sensor.config.rollback.temp-001"
+	if got != want {
+		t.Errorf("ConfigRollbackSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestAlertsQuerySubject(t *testing.T) {
+	got := AlertsQuerySubject()
+	want := "sensor.alerts.query"
+	if got != want {
+		t.Errorf("AlertsQuerySubject() = %v, want %v", got, want)
+	}
+}
+
+func TestRemoveSubject(t *testing.T) {
+	got := RemoveSubject("temp-001")
+	want := "sensor.remove.temp-001"
+	if got != want {
+		t.Errorf("RemoveSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestCalibrateSubject(t *testing.T) {
+	got := CalibrateSubject("temp-001")
+	want := "sensor.calibrate.temp-001"
+	if got != want {
+		t.Errorf("CalibrateSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestAdminBackupSubject(t *testing.T) {
+	got := AdminBackupSubject()
+	want := "sensor.admin.backup"
+	if got != want {
+		t.Errorf("AdminBackupSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestReadingsExportSubject(t *testing.T) {
+	got := ReadingsExportSubject("temp-001")
+	want := "sensor.readings.export.temp-001"
+	if got != want {
+		t.Errorf("ReadingsExportSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestReadingsImportSubject(t *testing.T) {
+	got := ReadingsImportSubject()
+	want := "sensor.readings.import"
+	if got != want {
+		t.Errorf("ReadingsImportSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestReadingsSearchSubject(t *testing.T) {
+	got := ReadingsSearchSubject()
+	want := "sensor.readings.search"
+	if got != want {
+		t.Errorf("ReadingsSearchSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestConfigChangedSubject(t *testing.T) {
+	got := ConfigChangedSubject("temp-001")
+	want := "sensor.config.changed.temp-001"
+	if got != want {
+		t.Errorf("ConfigChangedSubject() = %v, want %v", got, want)
+	}
+}
+
+func TestCacheStatsSubject(t *testing.T) {
+	got := CacheStatsSubject()
+	want := "sensor.cache.stats"
+	if got != want {
+		t.Errorf("CacheStatsSubject() = %v, want %v", got, want)
+	}
+}
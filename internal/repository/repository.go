@@ -2,30 +2,263 @@ package repository
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alejandro/technical_test_uvigo/internal/sensor"
 )
 
+// Buckets soportados para las consultas de agregación
+var Buckets = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+// ParseBucket convierte un bucket textual ("1m", "5m", "1h", "1d") en su duración
+func ParseBucket(bucket string) (time.Duration, error) {
+	d, ok := Buckets[bucket]
+	if !ok {
+		return 0, fmt.Errorf("unsupported bucket %q (must be: 1m, 5m, 1h, 1d)", bucket)
+	}
+	return d, nil
+}
+
+// RetentionPolicy describe qué lecturas crudas expiran en una pasada de retención
+type RetentionPolicy struct {
+	SensorType   sensor.SensorType   // Si no está vacío, solo afecta a este tipo
+	ExcludeTypes []sensor.SensorType // Tipos excluidos (tienen su propia política)
+	Before       time.Time           // Expiran las lecturas con timestamp anterior
+	Rollup       bool                // Consolidar en resúmenes horario/diario antes de borrar
+}
+
+// ReadingCursor marca la última lectura de una página. Las páginas recorren las lecturas
+// de un sensor en orden (timestamp, id) descendente; el id desempata timestamps iguales.
+type ReadingCursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+// Encode serializa el cursor como un token opaco para los clientes
+func (c ReadingCursor) Encode() string {
+	raw := strconv.FormatInt(c.Timestamp.UnixNano(), 10) + "|" + c.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Before indica si la lectura va después del cursor en el orden de paginación
+func (c ReadingCursor) Before(reading *sensor.SensorReading) bool {
+	if reading.Timestamp.Equal(c.Timestamp) {
+		return reading.ID < c.ID
+	}
+	return reading.Timestamp.Before(c.Timestamp)
+}
+
+// After indica si la lectura va después del cursor en orden (timestamp, id) ascendente,
+// el que usan las exportaciones
+func (c ReadingCursor) After(reading *sensor.SensorReading) bool {
+	if reading.Timestamp.Equal(c.Timestamp) {
+		return reading.ID > c.ID
+	}
+	return reading.Timestamp.After(c.Timestamp)
+}
+
+// DecodeReadingCursor parsea un token generado por ReadingCursor.Encode
+func DecodeReadingCursor(token string) (*ReadingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor: %w", err)
+	}
+	nanos, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, fmt.Errorf("invalid cursor: missing separator")
+	}
+	ns, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor timestamp: %w", err)
+	}
+	return &ReadingCursor{Timestamp: time.Unix(0, ns).UTC(), ID: id}, nil
+}
+
+// AlertFilter filtra la consulta de alertas; los campos vacíos no filtran
+type AlertFilter struct {
+	SensorID string
+	Severity sensor.AlertSeverity
+	Start    time.Time // Cero = sin límite inferior
+	End      time.Time // Cero = sin límite superior
+	Limit    int       // <= 0 = sin límite
+}
+
+// ReadingSearch filtra lecturas de varios sensores por sus metadatos (tabla sensors).
+// Los campos vacíos no filtran; solo se consideran sensores con metadatos guardados.
+type ReadingSearch struct {
+	Type     sensor.SensorType
+	Location string
+	Tags     []string       // El sensor debe tener todas las etiquetas
+	Start    time.Time      // Cero = sin límite inferior
+	End      time.Time      // Cero = sin límite superior
+	Cursor   *ReadingCursor // Continúa después de esta lectura (nil = desde la más reciente)
+	Limit    int
+}
+
+// Matches indica si los metadatos del sensor cumplen los filtros de tipo, ubicación y etiquetas
+func (q ReadingSearch) Matches(s *sensor.Sensor) bool {
+	if q.Type != "" && s.Type != q.Type {
+		return false
+	}
+	if q.Location != "" && s.Location != q.Location {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !slices.Contains(s.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// Motivos por los que ImportReadings omite una lectura
+const (
+	SkipDuplicateID        = "duplicate id (already stored)"
+	SkipDuplicateTimestamp = "duplicate timestamp (the backend keeps one reading per sensor and instant)"
+)
+
+// SkippedReading es una lectura que ImportReadings no ha guardado y el motivo
+type SkippedReading struct {
+	ID     string
+	Reason string
+}
+
+// ConfigChange describe quién y por qué cambia una configuración. Viaja en el context
+// de SaveConfig para no alterar su firma; se registra en el historial de configuración.
+type ConfigChange struct {
+	ChangedBy string
+	Reason    string
+}
+
+type configChangeKey struct{}
+
+// WithConfigChange adjunta al context el autor y el motivo del cambio de configuración
+func WithConfigChange(ctx context.Context, changedBy, reason string) context.Context {
+	return context.WithValue(ctx, configChangeKey{}, ConfigChange{ChangedBy: changedBy, Reason: reason})
+}
+
+// ConfigChangeFromContext retorna el cambio adjunto al context (autor "system" si no hay)
+func ConfigChangeFromContext(ctx context.Context) ConfigChange {
+	change, _ := ctx.Value(configChangeKey{}).(ConfigChange)
+	if change.ChangedBy == "" {
+		change.ChangedBy = "system"
+	}
+	return change
+}
+
+// DuplicateCounter lo implementan los backends que detectan lecturas con un id ya
+// guardado: SaveReading y SaveReadings las ignoran en lugar de fallar.
+type DuplicateCounter interface {
+	// DuplicateReadings retorna el número de lecturas ignoradas desde el arranque
+	DuplicateReadings() int64
+}
+
+// Unwrap retorna el repositorio base bajo los decoradores (los que implementan
+// Unwrap() Repository, como la caché) para consultar sus interfaces opcionales
+func Unwrap(repo Repository) Repository {
+	for {
+		wrapper, ok := repo.(interface{ Unwrap() Repository })
+		if !ok {
+			return repo
+		}
+		repo = wrapper.Unwrap()
+	}
+}
+
 // Repository define el contrato de persistencia para sensores.
 // Esta interfaz es agnóstica de la implementación (SQLite, PostgreSQL, TimescaleDB, etc.)
 // permitiendo cambiar la base de datos sin modificar la lógica de negocio.
 type Repository interface {
-	// SaveReading persiste una lectura de sensor
+	// SaveReading persiste una lectura de sensor. Es idempotente: guardar de nuevo una
+	// lectura con el mismo id no la duplica ni retorna error.
 	SaveReading(ctx context.Context, reading *sensor.SensorReading) error
 
+	// SaveReadings persiste un lote de lecturas en una única transacción (todo o nada)
+	SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error
+
+	// ImportReadings persiste un lote de lecturas históricas en una única transacción,
+	// omitiendo las que tienen un id ya guardado o repetido en el lote (y, en los backends
+	// que no distinguen lecturas del mismo sensor e instante, las que colisionan). Retorna
+	// las lecturas omitidas, en el orden del lote.
+	ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]SkippedReading, error)
+
 	// GetLatestReadings obtiene las últimas N lecturas de un sensor
 	GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error)
 
+	// GetReadingsPage obtiene hasta limit lecturas de un sensor en orden (timestamp, id)
+	// descendente, empezando después de cursor (nil = desde la más reciente). Retorna el
+	// cursor de la siguiente página, o nil si no hay más lecturas.
+	GetReadingsPage(ctx context.Context, sensorID string, cursor *ReadingCursor, limit int) ([]*sensor.SensorReading, *ReadingCursor, error)
+
 	// GetReadingsByTimeRange obtiene lecturas en un rango temporal
 	GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error)
 
-	// SaveConfig guarda o actualiza la configuración de un sensor
+	// GetReadingsRangePage obtiene hasta limit lecturas de un sensor en el rango [start, end]
+	// en orden (timestamp, id) ascendente, empezando después de cursor (nil = desde start).
+	// Retorna el cursor de la siguiente página, o nil si no hay más lecturas en el rango.
+	GetReadingsRangePage(ctx context.Context, sensorID string, start, end time.Time, cursor *ReadingCursor, limit int) ([]*sensor.SensorReading, *ReadingCursor, error)
+
+	// SearchReadings obtiene hasta query.Limit lecturas de los sensores cuyos metadatos
+	// cumplen el filtro, en orden (timestamp, id) descendente. Retorna el cursor de la
+	// siguiente página, o nil si no hay más lecturas.
+	SearchReadings(ctx context.Context, query ReadingSearch) ([]*sensor.SensorReading, *ReadingCursor, error)
+
+	// GetAggregatedReadings obtiene min/max/avg/count/stddev por bucket temporal
+	// en el rango [start, end], excluyendo lecturas con error. Ordenado por bucket ascendente.
+	GetAggregatedReadings(ctx context.Context, sensorID string, start, end time.Time, bucket time.Duration) ([]*sensor.ReadingAggregate, error)
+
+	// ApplyRetention elimina las lecturas crudas que cumplen la política (consolidándolas
+	// antes si policy.Rollup) de forma atómica. Retorna el número de lecturas eliminadas.
+	ApplyRetention(ctx context.Context, policy RetentionPolicy) (int64, error)
+
+	// SaveConfig guarda o actualiza la configuración de un sensor. Si cambia respecto a la
+	// última revisión, añade una entrada al historial con el ConfigChange del context.
 	SaveConfig(ctx context.Context, config *sensor.SensorConfig) error
 
 	// GetConfig obtiene la configuración de un sensor
 	GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error)
 
+	// DeleteConfig elimina la configuración actual de un sensor (idempotente). El historial
+	// de configuración se conserva como archivo del sensor dado de baja.
+	DeleteConfig(ctx context.Context, sensorID string) error
+
+	// GetConfigHistory obtiene las últimas N revisiones de configuración de un sensor,
+	// de la más reciente a la más antigua. limit <= 0 retorna todas.
+	GetConfigHistory(ctx context.Context, sensorID string, limit int) ([]*sensor.ConfigRevision, error)
+
+	// SaveAlert persiste una alerta generada por el simulador
+	SaveAlert(ctx context.Context, alert *sensor.Alert) error
+
+	// GetAlerts obtiene las alertas que cumplen el filtro, la más reciente primero
+	GetAlerts(ctx context.Context, filter AlertFilter) ([]*sensor.Alert, error)
+
+	// SaveSensor guarda o actualiza los metadatos de un sensor (tipo, nombre, ubicación,
+	// etiquetas y origen). Las etiquetas sustituyen a las anteriores.
+	SaveSensor(ctx context.Context, s *sensor.Sensor) error
+
+	// GetSensor obtiene los metadatos de un sensor registrado
+	GetSensor(ctx context.Context, sensorID string) (*sensor.Sensor, error)
+
+	// ListSensors obtiene todos los sensores registrados
+	ListSensors(ctx context.Context) ([]*sensor.Sensor, error)
+
+	// DeleteSensor elimina los metadatos de un sensor registrado
+	DeleteSensor(ctx context.Context, sensorID string) error
+
+	// PurgeSensorData elimina todos los datos de un sensor: lecturas, resúmenes de retención,
+	// alertas e historial de configuración. Retorna el número de lecturas eliminadas.
+	PurgeSensorData(ctx context.Context, sensorID string) (int64, error)
+
 	// Close cierra la conexión a la base de datos
 	Close() error
 }
@@ -1,21 +1,30 @@
-package repository
+package repository_test
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alejandro/technical_test_uvigo/internal/cache"
+	"github.com/alejandro/technical_test_uvigo/internal/instrument"
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
 	"github.com/alejandro/technical_test_uvigo/internal/sensor"
 	"github.com/alejandro/technical_test_uvigo/internal/storage"
 )
 
 // TestRepositoryInterface verifica que todas las implementaciones cumplen la interfaz
 func TestRepositoryInterface(t *testing.T) {
-	var _ Repository = (*storage.SQLiteRepository)(nil)
+	var _ repository.Repository = (*storage.SQLiteRepository)(nil)
+	var _ repository.Repository = (*storage.InfluxDBRepository)(nil)
+	var _ repository.Repository = (*storage.MemoryRepository)(nil)
+	var _ repository.Repository = (*storage.TSFileRepository)(nil)
 }
 
 // RepositoryContractTests son tests de contrato que cualquier implementación debe pasar
-func RepositoryContractTests(t *testing.T, repo Repository) {
+func RepositoryContractTests(t *testing.T, repo repository.Repository) {
 	ctx := context.Background()
 
 	t.Run("SaveAndGetConfig", func(t *testing.T) {
@@ -96,6 +105,102 @@ func RepositoryContractTests(t *testing.T, repo Repository) {
 		}
 	})
 
+	t.Run("ConfigAlertLimits", func(t *testing.T) {
+		low := -5.0
+		config := &sensor.SensorConfig{
+			SensorID:     "test-limits",
+			Interval:     5000,
+			Threshold:    30.0,
+			LowThreshold: &low,
+			Hysteresis:   0.5,
+			MaxRate:      2,
+			Enabled:      true,
+		}
+		if err := repo.SaveConfig(ctx, config); err != nil {
+			t.Fatalf("SaveConfig() failed: %v", err)
+		}
+
+		retrieved, err := repo.GetConfig(ctx, "test-limits")
+		if err != nil {
+			t.Fatalf("GetConfig() failed: %v", err)
+		}
+		if !retrieved.Equal(*config) {
+			t.Errorf("Expected %+v, got %+v", config, retrieved)
+		}
+
+		// Quitar el umbral inferior
+		updated := *config
+		updated.LowThreshold = nil
+		if err := repo.SaveConfig(ctx, &updated); err != nil {
+			t.Fatalf("SaveConfig() update failed: %v", err)
+		}
+		if retrieved, err = repo.GetConfig(ctx, "test-limits"); err != nil {
+			t.Fatalf("GetConfig() failed: %v", err)
+		}
+		if retrieved.LowThreshold != nil || retrieved.MaxRate != 2 {
+			t.Errorf("Expected no low threshold and max rate 2, got %+v", retrieved)
+		}
+
+		history, err := repo.GetConfigHistory(ctx, "test-limits", 0)
+		if err != nil {
+			t.Fatalf("GetConfigHistory() failed: %v", err)
+		}
+		if len(history) != 2 || !history[1].Config.Equal(*config) || !history[0].Config.Equal(updated) {
+			t.Errorf("Expected 2 revisions with the limits, got %+v", history)
+		}
+	})
+
+	t.Run("Calibration", func(t *testing.T) {
+		config := &sensor.SensorConfig{
+			SensorID:    "test-calibration",
+			Interval:    5000,
+			Threshold:   30.0,
+			Calibration: &sensor.Calibration{Polynomial: []float64{0.1, 1.02, -0.001}},
+			Enabled:     true,
+		}
+		if err := repo.SaveConfig(ctx, config); err != nil {
+			t.Fatalf("SaveConfig() failed: %v", err)
+		}
+		retrieved, err := repo.GetConfig(ctx, "test-calibration")
+		if err != nil {
+			t.Fatalf("GetConfig() failed: %v", err)
+		}
+		if !retrieved.Equal(*config) {
+			t.Errorf("Expected calibration %+v, got %+v", config.Calibration, retrieved.Calibration)
+		}
+
+		updated := *config
+		updated.Calibration = &sensor.Calibration{Offset: -0.4}
+		if err := repo.SaveConfig(ctx, &updated); err != nil {
+			t.Fatalf("SaveConfig() update failed: %v", err)
+		}
+		history, err := repo.GetConfigHistory(ctx, "test-calibration", 0)
+		if err != nil {
+			t.Fatalf("GetConfigHistory() failed: %v", err)
+		}
+		if len(history) != 2 || !history[1].Config.Equal(*config) || !history[0].Config.Equal(updated) {
+			t.Errorf("Expected 2 revisions with the calibrations, got %+v", history)
+		}
+
+		// Las lecturas calibradas conservan el valor bruto
+		raw := 25.9
+		now := time.Now().UTC()
+		readings := []*sensor.SensorReading{
+			{ID: "calibrated-1", SensorID: "test-calibration", Type: sensor.SensorTypeTemperature, Value: 25.5, RawValue: &raw, Unit: "°C", Timestamp: now},
+			{ID: "calibrated-2", SensorID: "test-calibration", Type: sensor.SensorTypeTemperature, Value: 25.7, Unit: "°C", Timestamp: now.Add(time.Second)},
+		}
+		if err := repo.SaveReadings(ctx, readings); err != nil {
+			t.Fatalf("SaveReadings() failed: %v", err)
+		}
+		latest, err := repo.GetLatestReadings(ctx, "test-calibration", 10)
+		if err != nil {
+			t.Fatalf("GetLatestReadings() failed: %v", err)
+		}
+		if len(latest) != 2 || latest[0].RawValue != nil || latest[1].RawValue == nil || *latest[1].RawValue != raw || latest[1].Value != 25.5 {
+			t.Errorf("Expected the raw value only on calibrated-1, got %+v", latest)
+		}
+	})
+
 	t.Run("SaveAndGetReading", func(t *testing.T) {
 		reading := &sensor.SensorReading{
 			ID:        "reading-001",
@@ -216,6 +321,175 @@ func RepositoryContractTests(t *testing.T, repo Repository) {
 		}
 	})
 
+	t.Run("GetReadingsPage", func(t *testing.T) {
+		sensorID := "test-008"
+		base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+		// Dos lecturas comparten timestamp: el id desempata entre páginas
+		timestamps := []time.Time{base, base.Add(time.Second), base.Add(time.Second), base.Add(2 * time.Second), base.Add(3 * time.Second)}
+		for i, ts := range timestamps {
+			repo.SaveReading(ctx, &sensor.SensorReading{
+				ID:        fmt.Sprintf("page-%d", i),
+				SensorID:  sensorID,
+				Type:      sensor.SensorTypeTemperature,
+				Value:     float64(i),
+				Unit:      "°C",
+				Timestamp: ts,
+			})
+		}
+
+		var ids []string
+		var cursor *repository.ReadingCursor
+		for pages := 0; pages < 5; pages++ {
+			readings, next, err := repo.GetReadingsPage(ctx, sensorID, cursor, 2)
+			if err != nil {
+				t.Fatalf("GetReadingsPage() failed: %v", err)
+			}
+			for _, r := range readings {
+				ids = append(ids, r.ID)
+			}
+			if next == nil {
+				break
+			}
+			// El cursor viaja como token opaco entre peticiones
+			if cursor, err = repository.DecodeReadingCursor(next.Encode()); err != nil {
+				t.Fatalf("DecodeReadingCursor() failed: %v", err)
+			}
+		}
+
+		want := "page-4,page-3,page-2,page-1,page-0"
+		if got := strings.Join(ids, ","); got != want {
+			t.Errorf("Expected pages %s, got %s", want, got)
+		}
+
+		if _, _, err := repo.GetReadingsPage(ctx, sensorID, nil, 0); err == nil {
+			t.Error("Expected error for page size 0, got nil")
+		}
+	})
+
+	t.Run("GetReadingsRangePage", func(t *testing.T) {
+		sensorID := "test-011"
+		base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+		// Huecos largos entre lecturas, dos con el mismo timestamp y una fuera del rango
+		timestamps := []time.Time{base.Add(-time.Hour), base, base.Add(24 * time.Hour), base.Add(24 * time.Hour), base.Add(90 * 24 * time.Hour), base.Add(365 * 24 * time.Hour)}
+		for i, ts := range timestamps {
+			repo.SaveReading(ctx, &sensor.SensorReading{
+				ID:        fmt.Sprintf("range-%d", len(timestamps)-i), // ids no ordenados como el tiempo
+				SensorID:  sensorID,
+				Type:      sensor.SensorTypeTemperature,
+				Value:     float64(i),
+				Unit:      "°C",
+				Timestamp: ts,
+			})
+		}
+
+		var ids []string
+		var cursor *repository.ReadingCursor
+		for pages := 0; pages < 5; pages++ {
+			readings, next, err := repo.GetReadingsRangePage(ctx, sensorID, base, base.Add(90*24*time.Hour), cursor, 2)
+			if err != nil {
+				t.Fatalf("GetReadingsRangePage() failed: %v", err)
+			}
+			for _, r := range readings {
+				ids = append(ids, r.ID)
+			}
+			if next == nil {
+				break
+			}
+			if cursor, err = repository.DecodeReadingCursor(next.Encode()); err != nil {
+				t.Fatalf("DecodeReadingCursor() failed: %v", err)
+			}
+		}
+
+		// Ascendente por (timestamp, id) e inclusivo en ambos extremos
+		want := "range-5,range-3,range-4,range-2"
+		if got := strings.Join(ids, ","); got != want {
+			t.Errorf("Expected pages %s, got %s", want, got)
+		}
+
+		if _, _, err := repo.GetReadingsRangePage(ctx, sensorID, base, base, nil, 0); err == nil {
+			t.Error("Expected error for page size 0, got nil")
+		}
+	})
+
+	t.Run("SaveReading_Idempotent", func(t *testing.T) {
+		sensorID := "test-010"
+		reading := &sensor.SensorReading{ID: "retry-1", SensorID: sensorID, Type: sensor.SensorTypeTemperature, Value: 21, Unit: "°C", Timestamp: time.Now().UTC()}
+
+		var before int64
+		counter, counts := repo.(repository.DuplicateCounter)
+		if counts {
+			before = counter.DuplicateReadings()
+		}
+
+		// Un reintento (individual o dentro de un lote) no duplica ni falla
+		if err := repo.SaveReading(ctx, reading); err != nil {
+			t.Fatalf("SaveReading() failed: %v", err)
+		}
+		if err := repo.SaveReading(ctx, reading); err != nil {
+			t.Fatalf("SaveReading() retry failed: %v", err)
+		}
+		retry := *reading
+		retry.Value = 99
+		if err := repo.SaveReadings(ctx, []*sensor.SensorReading{&retry}); err != nil {
+			t.Fatalf("SaveReadings() retry failed: %v", err)
+		}
+
+		readings, err := repo.GetLatestReadings(ctx, sensorID, 10)
+		if err != nil {
+			t.Fatalf("GetLatestReadings() failed: %v", err)
+		}
+		if len(readings) != 1 || readings[0].Value != 21 {
+			t.Errorf("Expected the first reading only, got %v", readings)
+		}
+		if counts {
+			if got := counter.DuplicateReadings() - before; got != 2 {
+				t.Errorf("Expected 2 duplicates, got %d", got)
+			}
+		}
+	})
+
+	t.Run("ImportReadings", func(t *testing.T) {
+		sensorID := "test-009"
+		base := time.Date(2024, 6, 1, 8, 0, 0, 0, time.UTC)
+		reading := func(id string, offset time.Duration) *sensor.SensorReading {
+			return &sensor.SensorReading{ID: id, SensorID: sensorID, Type: sensor.SensorTypeTemperature, Value: 18, Unit: "°C", Timestamp: base.Add(offset)}
+		}
+
+		if err := repo.SaveReading(ctx, reading("import-1", 0)); err != nil {
+			t.Fatalf("SaveReading() failed: %v", err)
+		}
+
+		skipped, err := repo.ImportReadings(ctx, []*sensor.SensorReading{
+			reading("import-1", time.Minute), // ya guardada
+			reading("import-2", 2*time.Minute),
+			reading("import-2", 3*time.Minute), // repetida en el lote
+			reading("import-3", 4*time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("ImportReadings() failed: %v", err)
+		}
+		var ids []string
+		for _, skip := range skipped {
+			ids = append(ids, skip.ID)
+			if skip.Reason != repository.SkipDuplicateID {
+				t.Errorf("Expected reason %q for %s, got %q", repository.SkipDuplicateID, skip.ID, skip.Reason)
+			}
+		}
+		if got := strings.Join(ids, ","); got != "import-1,import-2" {
+			t.Errorf("Expected skipped import-1,import-2, got %s", got)
+		}
+
+		readings, err := repo.GetLatestReadings(ctx, sensorID, 10)
+		if err != nil {
+			t.Fatalf("GetLatestReadings() failed: %v", err)
+		}
+		if len(readings) != 3 {
+			t.Errorf("Expected 3 readings after import, got %d", len(readings))
+		}
+	})
+
 	t.Run("SaveReadingWithError", func(t *testing.T) {
 		errorMsg := "sensor timeout"
 		reading := &sensor.SensorReading{
@@ -263,6 +537,193 @@ func RepositoryContractTests(t *testing.T, repo Repository) {
 			t.Error("Expected error for nonexistent sensor config, got nil")
 		}
 	})
+
+	t.Run("SearchReadings", func(t *testing.T) {
+		base := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+		sensors := []*sensor.Sensor{
+			{ID: "search-temp-1", Type: sensor.SensorTypeTemperature, Location: "almacen", Tags: []string{"critico", "norte"}},
+			{ID: "search-temp-2", Type: sensor.SensorTypeTemperature, Location: "almacen", Tags: []string{"norte"}},
+			{ID: "search-temp-3", Type: sensor.SensorTypeTemperature, Location: "oficina", Tags: []string{"critico", "norte"}},
+			{ID: "search-hum-1", Type: sensor.SensorTypeHumidity, Location: "almacen", Tags: []string{"critico", "norte"}},
+		}
+		for i, s := range sensors {
+			if err := repo.SaveSensor(ctx, s); err != nil {
+				t.Fatalf("SaveSensor() failed: %v", err)
+			}
+			for j := 0; j < 3; j++ {
+				repo.SaveReading(ctx, &sensor.SensorReading{
+					ID:        fmt.Sprintf("%s-%d", s.ID, j),
+					SensorID:  s.ID,
+					Type:      s.Type,
+					Value:     float64(j),
+					Timestamp: base.Add(time.Duration(j*len(sensors)+i) * time.Minute),
+				})
+			}
+		}
+
+		query := repository.ReadingSearch{Type: sensor.SensorTypeTemperature, Location: "almacen", Limit: 4}
+		page, next, err := repo.SearchReadings(ctx, query)
+		if err != nil {
+			t.Fatalf("SearchReadings() failed: %v", err)
+		}
+		want := []string{"search-temp-2-2", "search-temp-1-2", "search-temp-2-1", "search-temp-1-1"}
+		if len(page) != len(want) || next == nil {
+			t.Fatalf("Expected a full page with next cursor, got %d readings (next %v)", len(page), next)
+		}
+		for i, reading := range page {
+			if reading.ID != want[i] {
+				t.Errorf("page[%d] = %s, want %s", i, reading.ID, want[i])
+			}
+		}
+
+		query.Cursor = next
+		page, next, err = repo.SearchReadings(ctx, query)
+		if err != nil {
+			t.Fatalf("SearchReadings() second page failed: %v", err)
+		}
+		if len(page) != 2 || page[0].ID != "search-temp-2-0" || next != nil {
+			t.Errorf("Unexpected last page: %v (next %v)", page, next)
+		}
+
+		// Etiquetas (todas requeridas) y rango temporal
+		query = repository.ReadingSearch{Tags: []string{"norte", "critico"}, Start: base.Add(4 * time.Minute), End: base.Add(8 * time.Minute), Limit: 10}
+		page, _, err = repo.SearchReadings(ctx, query)
+		if err != nil {
+			t.Fatalf("SearchReadings() by tags failed: %v", err)
+		}
+		want = []string{"search-temp-1-2", "search-hum-1-1", "search-temp-3-1", "search-temp-1-1"}
+		if len(page) != len(want) {
+			t.Fatalf("Expected %d readings, got %v", len(want), page)
+		}
+		for i, reading := range page {
+			if reading.ID != want[i] {
+				t.Errorf("tags page[%d] = %s, want %s", i, reading.ID, want[i])
+			}
+		}
+
+		if _, _, err := repo.SearchReadings(ctx, repository.ReadingSearch{}); err == nil {
+			t.Error("Expected error for zero limit, got nil")
+		}
+	})
+
+	t.Run("SaveListAndDeleteSensor", func(t *testing.T) {
+		s := &sensor.Sensor{
+			ID:       "test-007",
+			Type:     sensor.SensorTypeHumidity,
+			Name:     "Humidity Lab",
+			Location: "lab",
+			Tags:     []string{"critico", "interior"},
+		}
+
+		if err := repo.SaveSensor(ctx, s); err != nil {
+			t.Fatalf("SaveSensor() failed: %v", err)
+		}
+
+		retrieved, err := repo.GetSensor(ctx, "test-007")
+		if err != nil {
+			t.Fatalf("GetSensor() failed: %v", err)
+		}
+		if retrieved.Type != s.Type || retrieved.Name != s.Name || retrieved.Location != s.Location {
+			t.Errorf("Expected %+v, got %+v", s, retrieved)
+		}
+		if !slices.Equal(retrieved.Tags, s.Tags) || retrieved.Source != sensor.SensorSourceRegister {
+			t.Errorf("Expected tags %v and source %q, got %v and %q", s.Tags, sensor.SensorSourceRegister, retrieved.Tags, retrieved.Source)
+		}
+
+		// Un nuevo guardado sustituye las etiquetas
+		s.Tags = []string{"exterior"}
+		if err := repo.SaveSensor(ctx, s); err != nil {
+			t.Fatalf("SaveSensor() failed: %v", err)
+		}
+		if retrieved, _ := repo.GetSensor(ctx, "test-007"); !slices.Equal(retrieved.Tags, s.Tags) {
+			t.Errorf("Expected tags %v after update, got %v", s.Tags, retrieved.Tags)
+		}
+
+		sensors, err := repo.ListSensors(ctx)
+		if err != nil {
+			t.Fatalf("ListSensors() failed: %v", err)
+		}
+		found := false
+		for _, listed := range sensors {
+			if listed.ID == "test-007" {
+				found = true
+			}
+		}
+		if !found {
+			t.Error("Saved sensor not found in ListSensors")
+		}
+
+		if err := repo.DeleteSensor(ctx, "test-007"); err != nil {
+			t.Fatalf("DeleteSensor() failed: %v", err)
+		}
+		if _, err := repo.GetSensor(ctx, "test-007"); err == nil {
+			t.Error("Expected error for deleted sensor, got nil")
+		}
+	})
+}
+
+// TestParseBucket verifica los buckets soportados para agregación
+func TestParseBucket(t *testing.T) {
+	tests := []struct {
+		bucket  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"1m", time.Minute, false},
+		{"5m", 5 * time.Minute, false},
+		{"1h", time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"2h", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			got, err := repository.ParseBucket(tt.bucket)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBucket(%q) error = %v, wantErr %v", tt.bucket, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseBucket(%q) = %v, want %v", tt.bucket, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestReadingCursor verifica la serialización del cursor de paginación
+func TestReadingCursor(t *testing.T) {
+	cursor := repository.ReadingCursor{Timestamp: time.Date(2025, 1, 1, 10, 0, 0, 123456789, time.UTC), ID: "read|1"}
+
+	decoded, err := repository.DecodeReadingCursor(cursor.Encode())
+	if err != nil {
+		t.Fatalf("DecodeReadingCursor() failed: %v", err)
+	}
+	if !decoded.Timestamp.Equal(cursor.Timestamp) || decoded.ID != cursor.ID {
+		t.Errorf("DecodeReadingCursor() = %+v, want %+v", decoded, cursor)
+	}
+
+	for _, token := range []string{"%%%", "bm9zZXBhcmF0b3I", "eHh8aWQ"} { // inválido, sin separador, timestamp no numérico
+		if _, err := repository.DecodeReadingCursor(token); err == nil {
+			t.Errorf("DecodeReadingCursor(%q) expected error, got nil", token)
+		}
+	}
+
+	// Orden ascendente (exportaciones): el id desempata timestamps iguales
+	tests := []struct {
+		reading *sensor.SensorReading
+		after   bool
+	}{
+		{&sensor.SensorReading{ID: "read|0", Timestamp: cursor.Timestamp}, false},
+		{&sensor.SensorReading{ID: "read|1", Timestamp: cursor.Timestamp}, false},
+		{&sensor.SensorReading{ID: "read|2", Timestamp: cursor.Timestamp}, true},
+		{&sensor.SensorReading{ID: "a", Timestamp: cursor.Timestamp.Add(time.Nanosecond)}, true},
+		{&sensor.SensorReading{ID: "z", Timestamp: cursor.Timestamp.Add(-time.Nanosecond)}, false},
+	}
+	for _, tt := range tests {
+		if got := cursor.After(tt.reading); got != tt.after {
+			t.Errorf("After(%s @ %s) = %v, want %v", tt.reading.ID, tt.reading.Timestamp, got, tt.after)
+		}
+	}
 }
 
 // TestSQLiteRepository ejecuta los tests de contrato con SQLite
@@ -278,6 +739,67 @@ func TestSQLiteRepository(t *testing.T) {
 	RepositoryContractTests(t, repo)
 }
 
+// TestMemoryRepository ejecuta los tests de contrato con el repositorio en memoria
+func TestMemoryRepository(t *testing.T) {
+	repo, err := storage.NewMemoryRepository(100, "")
+	if err != nil {
+		t.Fatalf("Failed to create memory repository: %v", err)
+	}
+	defer repo.Close()
+
+	RepositoryContractTests(t, repo)
+}
+
+// TestTSFileRepository ejecuta los tests de contrato con el motor tsfile. Bloques y
+// segmentos pequeños para que las consultas crucen bloques comprimidos y segmentos sellados.
+func TestTSFileRepository(t *testing.T) {
+	repo, err := storage.NewTSFileRepository(t.TempDir(), storage.TSFileOptions{BlockSize: 2, SegmentSize: 256})
+	if err != nil {
+		t.Fatalf("Failed to create tsfile repository: %v", err)
+	}
+	defer repo.Close()
+
+	RepositoryContractTests(t, repo)
+}
+
+// TestCachedRepository ejecuta los tests de contrato con la caché sobre SQLite: las
+// escrituras deben invalidar lo cacheado para que las lecturas posteriores las vean
+func TestCachedRepository(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create SQLite repository: %v", err)
+	}
+	cached := cache.New(repo, cache.Options{Size: 10, TTL: time.Minute})
+	defer cached.Close()
+
+	RepositoryContractTests(t, cached)
+
+	if repository.Unwrap(cached) != repository.Repository(repo) {
+		t.Error("Unwrap() did not return the decorated repository")
+	}
+}
+
+// TestInstrumentedRepository ejecuta los tests de contrato con la caché sobre la
+// instrumentación sobre SQLite, el mismo orden de decoradores que usa el servidor
+func TestInstrumentedRepository(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create SQLite repository: %v", err)
+	}
+	instrumented := instrument.New(repo, instrument.Options{})
+	cached := cache.New(instrumented, cache.Options{Size: 10, TTL: time.Minute})
+	defer cached.Close()
+
+	RepositoryContractTests(t, cached)
+
+	if repository.Unwrap(cached) != repository.Repository(repo) {
+		t.Error("Unwrap() did not reach the base repository")
+	}
+	if stats := instrumented.Stats(); stats.Methods["SaveReading"].Calls == 0 {
+		t.Errorf("expected SaveReading calls to be recorded, got %+v", stats.Methods["SaveReading"])
+	}
+}
+
 // TestRepositoryClose verifica que Close() funciona correctamente
 func TestRepositoryClose(t *testing.T) {
 	repo, err := storage.NewSQLiteRepository(":memory:")
@@ -0,0 +1,131 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/config"
+	"github.com/alejandro/technical_test_uvigo/internal/logger"
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/sirupsen/logrus"
+)
+
+// Job aplica periódicamente la política de retención sobre las lecturas crudas
+type Job struct {
+	repo   repository.Repository
+	cfg    config.RetentionConfig
+	now    func() time.Time // Inyectable para tests
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New crea un job de retención con la política indicada
+func New(repo repository.Repository, cfg config.RetentionConfig) *Job {
+	return &Job{
+		repo: repo,
+		cfg:  cfg,
+		now:  time.Now,
+	}
+}
+
+// Start lanza el job en background: una pasada inmediata y después cada cfg.Interval
+func (j *Job) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+
+	j.wg.Add(1)
+	go j.loop(ctx)
+
+	logger.Infof("[Retention] Job started (interval=%s, rollup=%v)", j.cfg.Interval, j.cfg.Rollup)
+}
+
+// Stop detiene el job y espera a que termine la pasada en curso
+func (j *Job) Stop() {
+	if j.cancel == nil {
+		return
+	}
+	j.cancel()
+	j.wg.Wait()
+	logger.Info("[Retention] Job stopped")
+}
+
+// loop ejecuta RunOnce en cada tick hasta que se cancela el context
+func (j *Job) loop(ctx context.Context) {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			logger.WithField("error", err).Error("[Retention] Error applying retention")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce aplica la política una vez y retorna el número total de lecturas eliminadas.
+// Primero se aplican las retenciones por tipo y después la global sobre el resto de tipos.
+func (j *Job) RunOnce(ctx context.Context) (int64, error) {
+	now := j.now().UTC()
+	var total int64
+
+	// Orden determinista para logs y tests
+	types := make([]string, 0, len(j.cfg.ByType))
+	for sensorType := range j.cfg.ByType {
+		types = append(types, sensorType)
+	}
+	sort.Strings(types)
+
+	excluded := make([]sensor.SensorType, 0, len(types))
+	for _, sensorType := range types {
+		excluded = append(excluded, sensor.SensorType(sensorType))
+
+		deleted, err := j.repo.ApplyRetention(ctx, repository.RetentionPolicy{
+			SensorType: sensor.SensorType(sensorType),
+			Before:     now.Add(-j.cfg.ByType[sensorType]),
+			Rollup:     j.cfg.Rollup,
+		})
+		if err != nil {
+			return total, fmt.Errorf("retention for type %s: %w", sensorType, err)
+		}
+		total += deleted
+		j.logPurge(sensorType, deleted)
+	}
+
+	if j.cfg.MaxAge > 0 {
+		deleted, err := j.repo.ApplyRetention(ctx, repository.RetentionPolicy{
+			ExcludeTypes: excluded,
+			Before:       now.Add(-j.cfg.MaxAge),
+			Rollup:       j.cfg.Rollup,
+		})
+		if err != nil {
+			return total, fmt.Errorf("global retention: %w", err)
+		}
+		total += deleted
+		j.logPurge("*", deleted)
+	}
+
+	return total, nil
+}
+
+// logPurge registra cuántas lecturas se eliminaron para un tipo
+func (j *Job) logPurge(sensorType string, deleted int64) {
+	if deleted == 0 {
+		return
+	}
+	logger.WithFields(logrus.Fields{
+		"type":    sensorType,
+		"deleted": deleted,
+		"rollup":  j.cfg.Rollup,
+	}).Info("[Retention] Expired readings purged")
+}
@@ -0,0 +1,92 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/config"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/alejandro/technical_test_uvigo/internal/storage"
+)
+
+func TestJob_RunOnce(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	now := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	// temp: 2 días y 10 días de antigüedad; press: 2 días de antigüedad
+	readings := []*sensor.SensorReading{
+		{ID: "read-001", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Timestamp: now.Add(-48 * time.Hour)},
+		{ID: "read-002", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Timestamp: now.Add(-240 * time.Hour)},
+		{ID: "read-003", SensorID: "press-001", Type: sensor.SensorTypePressure, Timestamp: now.Add(-48 * time.Hour)},
+	}
+	for _, r := range readings {
+		r.Unit = "u"
+		if err := repo.SaveReading(ctx, r); err != nil {
+			t.Fatalf("SaveReading failed: %v", err)
+		}
+	}
+
+	job := New(repo, config.RetentionConfig{
+		Enabled:  true,
+		MaxAge:   168 * time.Hour,                                      // 7 días global
+		ByType:   map[string]time.Duration{"pressure": 24 * time.Hour}, // 1 día presión
+		Interval: time.Hour,
+	})
+	job.now = func() time.Time { return now }
+
+	deleted, err := job.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("RunOnce failed: %v", err)
+	}
+
+	// Expiran read-002 (global) y read-003 (por tipo)
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted readings, got %d", deleted)
+	}
+
+	temp, _ := repo.GetLatestReadings(ctx, "temp-001", 10)
+	if len(temp) != 1 || temp[0].ID != "read-001" {
+		t.Errorf("expected only read-001 to survive, got %d readings", len(temp))
+	}
+
+	press, _ := repo.GetLatestReadings(ctx, "press-001", 10)
+	if len(press) != 0 {
+		t.Errorf("expected pressure readings to be purged, got %d", len(press))
+	}
+}
+
+func TestJob_StartStop(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	job := New(repo, config.RetentionConfig{
+		Enabled:  true,
+		MaxAge:   time.Hour,
+		Interval: 10 * time.Millisecond,
+	})
+
+	job.Start()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		job.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Stop() did not return")
+	}
+}
@@ -0,0 +1,115 @@
+package sensor
+
+import (
+	"errors"
+	"math"
+	"slices"
+)
+
+// maxPolynomialDegree limita el grado del polinomio de calibración
+const maxPolynomialDegree = 5
+
+// Calibration corrige el valor bruto que mide un sensor: gain·raw + offset o, si se indica
+// Polynomial, c0 + c1·raw + c2·raw² + ... Los parámetros están en la unidad del tipo del
+// sensor y no se convierten al consultar la configuración en otra unidad.
+type Calibration struct {
+	Offset     float64   `json:"offset,omitempty" yaml:"offset,omitempty" mapstructure:"offset"`
+	Gain       float64   `json:"gain,omitempty" yaml:"gain,omitempty" mapstructure:"gain"`                   // 0 = 1 (sin corrección de escala)
+	Polynomial []float64 `json:"polynomial,omitempty" yaml:"polynomial,omitempty" mapstructure:"polynomial"` // Coeficientes de menor a mayor grado
+}
+
+// Validate valida los parámetros de calibración
+func (c *Calibration) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if len(c.Polynomial) > 0 && (c.Offset != 0 || c.Gain != 0) {
+		return errors.New("calibration polynomial cannot be combined with offset or gain")
+	}
+	if len(c.Polynomial) > maxPolynomialDegree+1 {
+		return errors.New("calibration polynomial must have at most 6 coefficients")
+	}
+	for _, v := range append([]float64{c.Offset, c.Gain}, c.Polynomial...) {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("calibration parameters must be finite numbers")
+		}
+	}
+	return nil
+}
+
+// IsIdentity indica si la calibración deja los valores sin cambios (nil incluido)
+func (c *Calibration) IsIdentity() bool {
+	if c == nil {
+		return true
+	}
+	if len(c.Polynomial) > 0 {
+		for i, coef := range c.Polynomial {
+			if (i == 1 && coef != 1) || (i != 1 && coef != 0) {
+				return false
+			}
+		}
+		return len(c.Polynomial) > 1
+	}
+	return c.Offset == 0 && (c.Gain == 0 || c.Gain == 1)
+}
+
+// Apply retorna el valor calibrado de un valor bruto (nil = sin calibración)
+func (c *Calibration) Apply(raw float64) float64 {
+	if c == nil {
+		return raw
+	}
+	if len(c.Polynomial) > 0 {
+		value := 0.0
+		for i := len(c.Polynomial) - 1; i >= 0; i-- {
+			value = value*raw + c.Polynomial[i]
+		}
+		return value
+	}
+	gain := c.Gain
+	if gain == 0 {
+		gain = 1
+	}
+	return gain*raw + c.Offset
+}
+
+// Equal indica si dos calibraciones tienen los mismos parámetros (nil = sin calibración)
+func (c *Calibration) Equal(other *Calibration) bool {
+	if c.IsIdentity() && other.IsIdentity() {
+		return true
+	}
+	if c == nil || other == nil {
+		return false
+	}
+	return c.Offset == other.Offset && c.Gain == other.Gain && slices.Equal(c.Polynomial, other.Polynomial)
+}
+
+// WithReference retorna una copia de la calibración con el término constante ajustado para
+// que el valor bruto raw dé la medida de referencia (calibración de un punto: conserva la
+// ganancia o los demás coeficientes del polinomio)
+func (c *Calibration) WithReference(raw, reference float64) *Calibration {
+	adjusted := &Calibration{}
+	if c != nil {
+		adjusted.Offset, adjusted.Gain = c.Offset, c.Gain
+		adjusted.Polynomial = slices.Clone(c.Polynomial)
+	}
+	delta := reference - adjusted.Apply(raw)
+	if len(adjusted.Polynomial) > 0 {
+		adjusted.Polynomial[0] += delta
+	} else {
+		adjusted.Offset += delta
+	}
+	return adjusted
+}
+
+// TwoPointCalibration calcula la ganancia y el offset que llevan dos valores brutos a sus
+// medidas de referencia
+func TwoPointCalibration(raw1, reference1, raw2, reference2 float64) (*Calibration, error) {
+	if raw1 == raw2 {
+		return nil, errors.New("two-point calibration requires two different raw values")
+	}
+	gain := (reference2 - reference1) / (raw2 - raw1)
+	if gain == 0 {
+		return nil, errors.New("two-point calibration requires two different reference values")
+	}
+	return &Calibration{Gain: gain, Offset: reference1 - gain*raw1}, nil
+}
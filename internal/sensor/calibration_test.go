@@ -0,0 +1,79 @@
+package sensor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalibration_Apply(t *testing.T) {
+	tests := []struct {
+		name        string
+		calibration *Calibration
+		raw         float64
+		want        float64
+	}{
+		{"nil", nil, 21.5, 21.5},
+		{"offset", &Calibration{Offset: -0.4}, 21.5, 21.1},
+		{"gain 0 means 1", &Calibration{Offset: 1}, 10, 11},
+		{"gain and offset", &Calibration{Gain: 1.02, Offset: -0.5}, 50, 50.5},
+		{"polynomial", &Calibration{Polynomial: []float64{1, 2, 0.5}}, 4, 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.calibration.Apply(tt.raw); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Apply(%v) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalibration_IsIdentity(t *testing.T) {
+	identities := []*Calibration{nil, {}, {Gain: 1}, {Polynomial: []float64{0, 1}}}
+	for _, c := range identities {
+		if !c.IsIdentity() {
+			t.Errorf("expected %+v to be an identity calibration", c)
+		}
+	}
+	others := []*Calibration{{Offset: 0.1}, {Gain: 2}, {Polynomial: []float64{0}}, {Polynomial: []float64{0, 1, 0.1}}}
+	for _, c := range others {
+		if c.IsIdentity() {
+			t.Errorf("expected %+v not to be an identity calibration", c)
+		}
+	}
+}
+
+func TestCalibration_WithReference(t *testing.T) {
+	// Sin calibración previa: solo offset
+	if c := (*Calibration)(nil).WithReference(20.6, 21); math.Abs(c.Offset-0.4) > 1e-9 || c.Gain != 0 {
+		t.Errorf("WithReference on nil = %+v", c)
+	}
+
+	// Conserva la ganancia
+	linear := &Calibration{Gain: 2, Offset: 1}
+	if c := linear.WithReference(10, 20); c.Gain != 2 || c.Offset != 0 || linear.Offset != 1 {
+		t.Errorf("WithReference on linear = %+v (original %+v)", c, linear)
+	}
+
+	// Ajusta el término constante del polinomio sin modificar el original
+	poly := &Calibration{Polynomial: []float64{1, 2, 0.5}}
+	c := poly.WithReference(4, 16)
+	if math.Abs(c.Apply(4)-16) > 1e-9 || c.Polynomial[2] != 0.5 || poly.Polynomial[0] != 1 {
+		t.Errorf("WithReference on polynomial = %+v (original %+v)", c, poly)
+	}
+}
+
+func TestTwoPointCalibration(t *testing.T) {
+	c, err := TwoPointCalibration(0.5, 0, 99, 100)
+	if err != nil {
+		t.Fatalf("TwoPointCalibration failed: %v", err)
+	}
+	if math.Abs(c.Apply(0.5)) > 1e-9 || math.Abs(c.Apply(99)-100) > 1e-9 {
+		t.Errorf("TwoPointCalibration = %+v, maps 0.5 -> %v and 99 -> %v", c, c.Apply(0.5), c.Apply(99))
+	}
+	if _, err := TwoPointCalibration(10, 0, 10, 100); err == nil {
+		t.Error("expected error with equal raw values")
+	}
+	if _, err := TwoPointCalibration(10, 5, 20, 5); err == nil {
+		t.Error("expected error with equal reference values")
+	}
+}
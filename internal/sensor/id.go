@@ -0,0 +1,96 @@
+package sensor
+
+import (
+	"crypto/rand"
+	"io"
+	"sync"
+	"time"
+)
+
+// crockford es el alfabeto Base32 de Crockford que usan los ULID (sin I, L, O ni U)
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// IDGenerator genera IDs estilo ULID: 26 caracteres con 48 bits de timestamp en ms y 80
+// bits aleatorios. Dentro del mismo ms (o si el reloj retrocede) incrementa la parte
+// aleatoria del último ID, así que los IDs son únicos y crecientes en orden lexicográfico
+// aunque los generen varias goroutines a la vez. Es seguro para uso concurrente.
+type IDGenerator struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entropy io.Reader
+	lastMs  uint64
+	last    [10]byte // Parte aleatoria del último ID
+}
+
+// NewIDGenerator crea un generador con el reloj del sistema y entropía de crypto/rand
+func NewIDGenerator() *IDGenerator {
+	return &IDGenerator{now: time.Now, entropy: rand.Reader}
+}
+
+// defaultIDs es el generador compartido por todos los productores del proceso
+var defaultIDs = NewIDGenerator()
+
+// NewID genera un ID con el generador compartido del proceso
+func NewID() string {
+	return defaultIDs.New()
+}
+
+// New genera el siguiente ID
+func (g *IDGenerator) New() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(g.now().UnixMilli())
+	if ms <= g.lastMs && g.increment() {
+		ms = g.lastMs
+	} else {
+		if ms <= g.lastMs {
+			// Desbordamiento de los 80 bits en el mismo ms: se avanza el timestamp
+			ms = g.lastMs + 1
+		}
+		if _, err := io.ReadFull(g.entropy, g.last[:]); err != nil {
+			panic("sensor: failed to read entropy: " + err.Error())
+		}
+		g.lastMs = ms
+	}
+
+	return encodeID(ms, g.last)
+}
+
+// increment suma 1 a la parte aleatoria; false si desborda
+func (g *IDGenerator) increment() bool {
+	for i := len(g.last) - 1; i >= 0; i-- {
+		g.last[i]++
+		if g.last[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeID codifica timestamp y entropía (128 bits) en 26 caracteres Base32
+func encodeID(ms uint64, entropy [10]byte) string {
+	var id [26]byte
+
+	// 48 bits de timestamp en 10 caracteres (el primero solo usa 3 bits)
+	for i := 9; i >= 0; i-- {
+		id[i] = crockford[ms&0x1f]
+		ms >>= 5
+	}
+
+	// 80 bits aleatorios en 16 caracteres, de 5 en 5 bits
+	var bits uint64
+	var n uint
+	pos := 10
+	for _, b := range entropy {
+		bits = bits<<8 | uint64(b)
+		n += 8
+		for n >= 5 {
+			n -= 5
+			id[pos] = crockford[(bits>>n)&0x1f]
+			pos++
+		}
+	}
+
+	return string(id[:])
+}
@@ -0,0 +1,77 @@
+package sensor
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestIDGenerator_Monotonic(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	g := &IDGenerator{now: func() time.Time { return now }, entropy: bytes.NewReader(append(bytes.Repeat([]byte{0xff}, 10), bytes.Repeat([]byte{0x01}, 10)...))}
+
+	first := g.New()
+	if len(first) != 26 {
+		t.Fatalf("expected 26 characters, got %q", first)
+	}
+	if first[:10] != "01JGGMXK80" {
+		t.Errorf("unexpected timestamp part %q", first[:10])
+	}
+
+	// Mismo ms con la parte aleatoria al máximo: se avanza el timestamp
+	second := g.New()
+	if second <= first || second[:10] != "01JGGMXK81" {
+		t.Errorf("expected overflow to advance the timestamp, got %q after %q", second, first)
+	}
+
+	// Mismo ms: incrementa la parte aleatoria
+	third := g.New()
+	if third <= second || third[:10] != second[:10] {
+		t.Errorf("expected %q > %q with the same timestamp", third, second)
+	}
+
+	// El reloj retrocede: sigue siendo creciente
+	now = now.Add(-time.Second)
+	if fourth := g.New(); fourth <= third {
+		t.Errorf("expected %q > %q after the clock went backwards", fourth, third)
+	}
+}
+
+func TestNewID_Concurrent(t *testing.T) {
+	const workers, perWorker = 5, 2000
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[string]bool, workers*perWorker)
+	batches := make([][]string, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ids := make([]string, perWorker)
+			for i := range ids {
+				ids[i] = NewID()
+			}
+			batches[w] = ids
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, id := range ids {
+				if seen[id] {
+					t.Errorf("duplicate id %s", id)
+				}
+				seen[id] = true
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Cada productor ve sus IDs en orden creciente
+	for w, ids := range batches {
+		if !sort.StringsAreSorted(ids) {
+			t.Errorf("worker %d: ids are not sorted", w)
+		}
+	}
+}
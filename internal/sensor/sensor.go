@@ -2,6 +2,8 @@ package sensor
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"time"
 )
 
@@ -14,20 +16,33 @@ const (
 	SensorTypePressure    SensorType = "pressure"
 )
 
+// Origen de los metadatos de un sensor
+const (
+	SensorSourceRegister = "register" // Alta dinámica con sensor.register
+	SensorSourceConfig   = "config"   // Definido en el YAML de configuración
+)
+
 // Sensor representa un sensor físico del dispositivo IoT
 type Sensor struct {
 	ID       string     `json:"id"`
 	Type     SensorType `json:"type"`
 	Name     string     `json:"name"`
 	Location string     `json:"location,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`   // Etiquetas libres para las búsquedas (ej: "planta-2")
+	Source   string     `json:"source,omitempty"` // SensorSourceRegister o SensorSourceConfig ("" = register)
 }
 
-// SensorConfig contiene la configuración de un sensor
+// SensorConfig contiene la configuración de un sensor. Los límites de alerta están en la
+// unidad del tipo del sensor.
 type SensorConfig struct {
-	SensorID  string  `json:"sensor_id" yaml:"sensor_id" mapstructure:"sensor_id"`
-	Interval  int     `json:"interval" yaml:"interval" mapstructure:"interval"`    // Intervalo de muestreo en ms
-	Threshold float64 `json:"threshold" yaml:"threshold" mapstructure:"threshold"` // Umbral de alerta
-	Enabled   bool    `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
+	SensorID     string       `json:"sensor_id" yaml:"sensor_id" mapstructure:"sensor_id"`
+	Interval     int          `json:"interval" yaml:"interval" mapstructure:"interval"`                                    // Intervalo de muestreo en ms
+	Threshold    float64      `json:"threshold" yaml:"threshold" mapstructure:"threshold"`                                 // Umbral de alerta superior
+	LowThreshold *float64     `json:"low_threshold,omitempty" yaml:"low_threshold,omitempty" mapstructure:"low_threshold"` // Umbral inferior (nil = sin límite)
+	Hysteresis   float64      `json:"hysteresis,omitempty" yaml:"hysteresis,omitempty" mapstructure:"hysteresis"`          // Banda muerta para rearmar las alertas de umbral (0 = alerta en cada lectura)
+	MaxRate      float64      `json:"max_rate,omitempty" yaml:"max_rate,omitempty" mapstructure:"max_rate"`                // Variación máxima por minuto entre lecturas (0 = sin límite)
+	Calibration  *Calibration `json:"calibration,omitempty" yaml:"calibration,omitempty" mapstructure:"calibration"`       // Corrección de los valores brutos (nil = sin calibración)
+	Enabled      bool         `json:"enabled" yaml:"enabled" mapstructure:"enabled"`
 }
 
 // Validate valida la configuración del sensor
@@ -38,15 +53,55 @@ func (c *SensorConfig) Validate() error {
 	if c.Interval <= 0 {
 		return errors.New("interval must be greater than 0")
 	}
+	if c.LowThreshold != nil && *c.LowThreshold >= c.Threshold {
+		return errors.New("low_threshold must be less than threshold")
+	}
+	if c.Hysteresis < 0 {
+		return errors.New("hysteresis must not be negative")
+	}
+	if c.LowThreshold != nil && c.Hysteresis >= c.Threshold-*c.LowThreshold {
+		return errors.New("hysteresis must be less than the distance between low_threshold and threshold")
+	}
+	if c.MaxRate < 0 {
+		return errors.New("max_rate must not be negative")
+	}
+	if err := c.Calibration.Validate(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// Equal indica si dos configuraciones tienen los mismos valores (LowThreshold y
+// Calibration se comparan por valor, no por puntero)
+func (c SensorConfig) Equal(other SensorConfig) bool {
+	if (c.LowThreshold == nil) != (other.LowThreshold == nil) ||
+		(c.LowThreshold != nil && *c.LowThreshold != *other.LowThreshold) {
+		return false
+	}
+	if !c.Calibration.Equal(other.Calibration) {
+		return false
+	}
+	c.LowThreshold, other.LowThreshold = nil, nil
+	c.Calibration, other.Calibration = nil, nil
+	return c == other
+}
+
+// ConfigRevision es una versión histórica de la configuración de un sensor
+type ConfigRevision struct {
+	Revision  int          `json:"revision"` // Correlativo por sensor, empezando en 1
+	Config    SensorConfig `json:"config"`
+	ChangedBy string       `json:"changed_by,omitempty"`
+	Reason    string       `json:"reason,omitempty"` // Origen del cambio: set, register, rollback...
+	ChangedAt time.Time    `json:"changed_at"`
+}
+
 // SensorReading representa una lectura de un sensor
 type SensorReading struct {
 	ID        string     `json:"id"`
 	SensorID  string     `json:"sensor_id"`
 	Type      SensorType `json:"type"`
-	Value     float64    `json:"value"`
+	Value     float64    `json:"value"`               // Valor calibrado
+	RawValue  *float64   `json:"raw_value,omitempty"` // Valor medido antes de calibrar (nil = sin calibración)
 	Unit      string     `json:"unit"`
 	Error     *string    `json:"error,omitempty"` // Error de lectura si existe
 	Timestamp time.Time  `json:"timestamp"`
@@ -72,7 +127,90 @@ func (r *SensorReading) Validate() error {
 	return nil
 }
 
+// DeviceReading es una muestra de un dispositivo multicanal (ej: un BME280): una lectura
+// por canal, todas con el mismo timestamp, que se guardan y publican juntas
+type DeviceReading struct {
+	ID        string           `json:"id"`
+	DeviceID  string           `json:"device_id"`
+	Timestamp time.Time        `json:"timestamp"`
+	Readings  []*SensorReading `json:"readings"`
+}
+
+// ReadingAggregate resume las lecturas válidas (sin error) de un sensor en un bucket temporal
+type ReadingAggregate struct {
+	SensorID    string    `json:"sensor_id"`
+	BucketStart time.Time `json:"bucket_start"` // Inicio del bucket (UTC, alineado a epoch)
+	Count       int       `json:"count"`
+	Min         float64   `json:"min"`
+	Max         float64   `json:"max"`
+	Avg         float64   `json:"avg"`
+	StdDev      float64   `json:"stddev"`         // Desviación típica poblacional
+	Unit        string    `json:"unit,omitempty"` // Unidad de los valores (la rellena sensor.readings.stats)
+}
+
+// Raw retorna el valor medido antes de calibrar (Value si la lectura no está calibrada)
+func (r *SensorReading) Raw() float64 {
+	if r.RawValue != nil {
+		return *r.RawValue
+	}
+	return r.Value
+}
+
 // IsError indica si la lectura contiene un error
 func (r *SensorReading) IsError() bool {
 	return r.Error != nil && *r.Error != ""
 }
+
+// AlertSeverity indica la gravedad de una alerta
+type AlertSeverity string
+
+const (
+	AlertSeverityWarning  AlertSeverity = "warning"
+	AlertSeverityCritical AlertSeverity = "critical"
+)
+
+// AlertKind indica qué límite de la configuración ha superado una lectura
+type AlertKind string
+
+const (
+	AlertKindHigh AlertKind = "high" // Valor por encima de Threshold
+	AlertKindLow  AlertKind = "low"  // Valor por debajo de LowThreshold
+	AlertKindRate AlertKind = "rate" // Variación por minuto mayor que MaxRate
+)
+
+// criticalMargin es el exceso relativo sobre el umbral a partir del cual una alerta es crítica
+const criticalMargin = 0.10
+
+// SeverityFor calcula la gravedad de una alerta: crítica si el valor supera el umbral
+// en más de un 10%, aviso en otro caso
+func SeverityFor(value, threshold float64) AlertSeverity {
+	if value-threshold > math.Abs(threshold)*criticalMargin {
+		return AlertSeverityCritical
+	}
+	return AlertSeverityWarning
+}
+
+// ParseAlertSeverity valida una gravedad textual ("" se acepta como sin filtro)
+func ParseAlertSeverity(s string) (AlertSeverity, error) {
+	switch severity := AlertSeverity(s); severity {
+	case "", AlertSeverityWarning, AlertSeverityCritical:
+		return severity, nil
+	default:
+		return "", fmt.Errorf("unsupported severity %q (must be: warning, critical)", s)
+	}
+}
+
+// Alert representa una alerta generada al superar un límite de la configuración de un
+// sensor. En las alertas de variación (AlertKindRate) Value y Threshold son por minuto.
+type Alert struct {
+	ID        string        `json:"id"`
+	SensorID  string        `json:"sensor_id"`
+	Type      SensorType    `json:"type"`
+	Kind      AlertKind     `json:"kind,omitempty"` // "" en alertas anteriores a los límites inferiores (= high)
+	Severity  AlertSeverity `json:"severity"`
+	Value     float64       `json:"value"`
+	Threshold float64       `json:"threshold"`
+	Unit      string        `json:"unit"`
+	Message   string        `json:"message"`
+	Timestamp time.Time     `json:"timestamp"`
+}
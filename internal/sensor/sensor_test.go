@@ -159,6 +159,36 @@ func TestSensorConfig_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name:    "valid alert limits",
+			config:  SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30.0, LowThreshold: floatPtr(0), Hysteresis: 1, MaxRate: 2},
+			wantErr: false,
+		},
+		{
+			name:    "low threshold not below threshold",
+			config:  SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30.0, LowThreshold: floatPtr(30)},
+			wantErr: true,
+		},
+		{
+			name:    "negative hysteresis",
+			config:  SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30.0, Hysteresis: -1},
+			wantErr: true,
+		},
+		{
+			name:    "hysteresis wider than the allowed range",
+			config:  SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30.0, LowThreshold: floatPtr(25), Hysteresis: 5},
+			wantErr: true,
+		},
+		{
+			name:    "negative max rate",
+			config:  SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30.0, MaxRate: -0.5},
+			wantErr: true,
+		},
+		{
+			name:    "polynomial combined with gain",
+			config:  SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30.0, Calibration: &Calibration{Gain: 2, Polynomial: []float64{0, 1}}},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -171,3 +201,67 @@ func TestSensorConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestSensorConfig_Equal(t *testing.T) {
+	a := SensorConfig{SensorID: "temp-001", Interval: 1000, Threshold: 30, LowThreshold: floatPtr(0)}
+	b := a
+	b.LowThreshold = floatPtr(0)
+	if !a.Equal(b) {
+		t.Error("expected configs with equal low thresholds in different pointers to be equal")
+	}
+	b.LowThreshold = nil
+	if a.Equal(b) {
+		t.Error("expected config without low threshold to differ")
+	}
+	b.LowThreshold = floatPtr(0)
+	b.MaxRate = 1
+	if a.Equal(b) {
+		t.Error("expected configs with different max rate to differ")
+	}
+	b.MaxRate = 0
+	b.Calibration = &Calibration{Offset: 0.5}
+	if a.Equal(b) {
+		t.Error("expected configs with different calibration to differ")
+	}
+	b.Calibration = &Calibration{Gain: 1}
+	if !a.Equal(b) {
+		t.Error("expected an identity calibration to equal no calibration")
+	}
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestSeverityFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     float64
+		threshold float64
+		want      AlertSeverity
+	}{
+		{"just above threshold", 31.0, 30.0, AlertSeverityWarning},
+		{"exactly 10% above", 33.0, 30.0, AlertSeverityWarning},
+		{"more than 10% above", 33.5, 30.0, AlertSeverityCritical},
+		{"negative threshold", -9.5, -10.0, AlertSeverityWarning},
+		{"negative threshold critical", -8.0, -10.0, AlertSeverityCritical},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SeverityFor(tt.value, tt.threshold); got != tt.want {
+				t.Errorf("SeverityFor(%v, %v) = %v, want %v", tt.value, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAlertSeverity(t *testing.T) {
+	for _, valid := range []string{"", "warning", "critical"} {
+		if _, err := ParseAlertSeverity(valid); err != nil {
+			t.Errorf("ParseAlertSeverity(%q) unexpected error: %v", valid, err)
+		}
+	}
+	if _, err := ParseAlertSeverity("info"); err == nil {
+		t.Error("ParseAlertSeverity(\"info\") expected error, got nil")
+	}
+}
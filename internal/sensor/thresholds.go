@@ -0,0 +1,73 @@
+package sensor
+
+import (
+	"math"
+	"time"
+)
+
+// ThresholdBreach es un límite de la configuración superado por una lectura
+type ThresholdBreach struct {
+	Kind  AlertKind
+	Value float64 // Valor de la lectura o, en AlertKindRate, variación por minuto (con signo)
+	Limit float64 // Límite superado (MaxRate en AlertKindRate)
+}
+
+// Severity calcula la gravedad de la alerta según lo que se aleja la lectura del límite
+func (b ThresholdBreach) Severity() AlertSeverity {
+	switch b.Kind {
+	case AlertKindLow:
+		return SeverityFor(-b.Value, -b.Limit)
+	case AlertKindRate:
+		return SeverityFor(math.Abs(b.Value), b.Limit)
+	default:
+		return SeverityFor(b.Value, b.Limit)
+	}
+}
+
+// AlarmState es el estado de las alarmas de un sensor entre lecturas: qué umbrales están
+// disparados (para la histéresis) y la última lectura válida (para la variación por minuto).
+// No es seguro para uso concurrente.
+type AlarmState struct {
+	high, low bool
+	last      float64
+	lastAt    time.Time
+}
+
+// Check evalúa una lectura válida con los límites de config y actualiza el estado. Con
+// Hysteresis > 0 un umbral disparado no vuelve a alertar hasta que el valor regresa al
+// rango permitido con un margen de Hysteresis; con 0 alerta cada lectura fuera del rango.
+func (s *AlarmState) Check(config *SensorConfig, value float64, at time.Time) []ThresholdBreach {
+	var breaches []ThresholdBreach
+
+	switch {
+	case value > config.Threshold:
+		if !s.high || config.Hysteresis == 0 {
+			breaches = append(breaches, ThresholdBreach{Kind: AlertKindHigh, Value: value, Limit: config.Threshold})
+		}
+		s.high = true
+	case value <= config.Threshold-config.Hysteresis:
+		s.high = false
+	}
+
+	switch {
+	case config.LowThreshold == nil:
+		s.low = false
+	case value < *config.LowThreshold:
+		if !s.low || config.Hysteresis == 0 {
+			breaches = append(breaches, ThresholdBreach{Kind: AlertKindLow, Value: value, Limit: *config.LowThreshold})
+		}
+		s.low = true
+	case value >= *config.LowThreshold+config.Hysteresis:
+		s.low = false
+	}
+
+	if config.MaxRate > 0 && !s.lastAt.IsZero() && at.After(s.lastAt) {
+		rate := (value - s.last) / at.Sub(s.lastAt).Minutes()
+		if math.Abs(rate) > config.MaxRate {
+			breaches = append(breaches, ThresholdBreach{Kind: AlertKindRate, Value: rate, Limit: config.MaxRate})
+		}
+	}
+	s.last, s.lastAt = value, at
+
+	return breaches
+}
@@ -0,0 +1,105 @@
+package sensor
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAlarmState_Check(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		config SensorConfig
+		values []float64
+		want   [][]AlertKind // Alertas esperadas tras cada lectura
+	}{
+		{
+			name:   "threshold without hysteresis alerts every reading",
+			config: SensorConfig{Threshold: 30},
+			values: []float64{25, 31, 32, 29, 31},
+			want:   [][]AlertKind{nil, {AlertKindHigh}, {AlertKindHigh}, nil, {AlertKindHigh}},
+		},
+		{
+			name:   "hysteresis rearms below threshold minus hysteresis",
+			config: SensorConfig{Threshold: 30, Hysteresis: 2},
+			values: []float64{31, 32, 29, 31, 27.5, 30.5},
+			want:   [][]AlertKind{{AlertKindHigh}, nil, nil, nil, nil, {AlertKindHigh}},
+		},
+		{
+			name:   "low threshold with hysteresis",
+			config: SensorConfig{Threshold: 30, LowThreshold: floatPtr(0), Hysteresis: 1},
+			values: []float64{5, -1, -3, 0.5, -0.5, 1.5, -2},
+			want:   [][]AlertKind{nil, {AlertKindLow}, nil, nil, nil, nil, {AlertKindLow}},
+		},
+		{
+			name:   "rate of change per minute",
+			config: SensorConfig{Threshold: 1100, MaxRate: 5},
+			values: []float64{1010, 1012, 1005, 1010},
+			want:   [][]AlertKind{nil, nil, {AlertKindRate}, nil},
+		},
+		{
+			name:   "threshold and rate on the same reading",
+			config: SensorConfig{Threshold: 30, MaxRate: 5},
+			values: []float64{20, 31},
+			want:   [][]AlertKind{nil, {AlertKindHigh, AlertKindRate}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var state AlarmState
+			for i, value := range tt.values {
+				// Una lectura por minuto: la variación por minuto es la diferencia
+				var kinds []AlertKind
+				for _, breach := range state.Check(&tt.config, value, base.Add(time.Duration(i)*time.Minute)) {
+					kinds = append(kinds, breach.Kind)
+				}
+				if !reflect.DeepEqual(kinds, tt.want[i]) {
+					t.Errorf("reading %d (%.1f): expected %v, got %v", i, value, tt.want[i], kinds)
+				}
+			}
+		})
+	}
+}
+
+func TestAlarmState_CheckRate(t *testing.T) {
+	var state AlarmState
+	config := SensorConfig{Threshold: 1100, MaxRate: 2}
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	state.Check(&config, 1010, base)
+	breaches := state.Check(&config, 1007, base.Add(30*time.Second))
+	if len(breaches) != 1 {
+		t.Fatalf("expected 1 rate breach, got %v", breaches)
+	}
+	// -3 hPa en 30s = -6 hPa/min
+	if math.Abs(breaches[0].Value+6) > 1e-9 || breaches[0].Limit != 2 {
+		t.Errorf("expected -6/min with limit 2, got %+v", breaches[0])
+	}
+	if breaches[0].Severity() != AlertSeverityCritical {
+		t.Errorf("expected critical severity, got %s", breaches[0].Severity())
+	}
+}
+
+func TestThresholdBreach_Severity(t *testing.T) {
+	tests := []struct {
+		breach ThresholdBreach
+		want   AlertSeverity
+	}{
+		{ThresholdBreach{Kind: AlertKindHigh, Value: 31, Limit: 30}, AlertSeverityWarning},
+		{ThresholdBreach{Kind: AlertKindHigh, Value: 40, Limit: 30}, AlertSeverityCritical},
+		{ThresholdBreach{Kind: AlertKindLow, Value: 9.5, Limit: 10}, AlertSeverityWarning},
+		{ThresholdBreach{Kind: AlertKindLow, Value: 5, Limit: 10}, AlertSeverityCritical},
+		{ThresholdBreach{Kind: AlertKindRate, Value: -5.2, Limit: 5}, AlertSeverityWarning},
+		{ThresholdBreach{Kind: AlertKindRate, Value: -8, Limit: 5}, AlertSeverityCritical},
+	}
+
+	for _, tt := range tests {
+		if got := tt.breach.Severity(); got != tt.want {
+			t.Errorf("%+v: expected %s, got %s", tt.breach, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package sensor
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Generator describe los valores que simula el simulador para un tipo: base ± variation/2.
+// El generador vacío reparte los valores de forma uniforme en el rango físico del tipo.
+type Generator struct {
+	Base      float64 `json:"base" mapstructure:"base"`
+	Variation float64 `json:"variation" mapstructure:"variation"` // Amplitud total alrededor de base
+}
+
+// TypeSpec declara un tipo de sensor: unidad, rango físico, generador por defecto del
+// simulador y umbral de alerta por defecto para los sensores que no indican el suyo
+type TypeSpec struct {
+	Type             SensorType `json:"type" mapstructure:"type"`
+	Unit             string     `json:"unit" mapstructure:"unit"`
+	Min              float64    `json:"min" mapstructure:"min"` // Rango físico que puede medir el sensor
+	Max              float64    `json:"max" mapstructure:"max"`
+	Generator        Generator  `json:"generator" mapstructure:"generator"`
+	DefaultThreshold float64    `json:"default_threshold" mapstructure:"default_threshold"`
+}
+
+// reservedTypeNames son las acciones de sensor.readings.<accion>: un tipo con ese nombre
+// publicaría sus lecturas (sensor.readings.<type>.<id>) en el subject de la acción
+var reservedTypeNames = []string{"query", "stats", "export", "import", "search"}
+
+// Validate valida la declaración de un tipo
+func (s *TypeSpec) Validate() error {
+	if s.Type == "" {
+		return errors.New("type is required")
+	}
+	// El tipo es un token de subject NATS
+	if strings.ContainsAny(string(s.Type), ".*>") || strings.IndexFunc(string(s.Type), unicode.IsSpace) >= 0 {
+		return fmt.Errorf("type %q must not contain '.', '*', '>' or whitespace", s.Type)
+	}
+	if slices.Contains(reservedTypeNames, string(s.Type)) {
+		return fmt.Errorf("type %q is reserved (must not be: %s)", s.Type, strings.Join(reservedTypeNames, ", "))
+	}
+	if s.Unit == "" {
+		return errors.New("unit is required")
+	}
+	if s.Min >= s.Max {
+		return errors.New("min must be less than max")
+	}
+	if s.Generator.Variation < 0 {
+		return errors.New("generator.variation must not be negative")
+	}
+	if s.Generator != (Generator{}) && (s.Generator.Base < s.Min || s.Generator.Base > s.Max) {
+		return errors.New("generator.base must be within min and max")
+	}
+	return nil
+}
+
+// Generate genera un valor simulado dentro del rango físico del tipo
+func (s *TypeSpec) Generate(r *rand.Rand) float64 {
+	var value float64
+	if s.Generator == (Generator{}) {
+		value = s.Min + r.Float64()*(s.Max-s.Min)
+	} else {
+		value = s.Generator.Base + (r.Float64()-0.5)*s.Generator.Variation
+	}
+	return min(max(value, s.Min), s.Max)
+}
+
+// TypeRegistry es el catálogo de tipos de sensor conocidos. Es seguro para uso concurrente.
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	types map[SensorType]TypeSpec
+}
+
+// NewTypeRegistry crea un catálogo con los tipos incorporados
+func NewTypeRegistry() *TypeRegistry {
+	r := &TypeRegistry{types: make(map[SensorType]TypeSpec)}
+	for _, spec := range builtinTypes {
+		r.types[spec.Type] = spec
+	}
+	return r
+}
+
+// builtinTypes son los tipos disponibles sin declararlos en la configuración
+var builtinTypes = []TypeSpec{
+	{Type: SensorTypeTemperature, Unit: "°C", Min: -40, Max: 85, Generator: Generator{Base: 25, Variation: 20}, DefaultThreshold: 30},
+	{Type: SensorTypeHumidity, Unit: "%", Min: 0, Max: 100, Generator: Generator{Base: 55, Variation: 50}, DefaultThreshold: 70},
+	{Type: SensorTypePressure, Unit: "hPa", Min: 300, Max: 1100, Generator: Generator{Base: 1010, Variation: 60}, DefaultThreshold: 1030},
+}
+
+// Register añade un tipo o reemplaza la declaración de uno existente
+func (r *TypeRegistry) Register(spec TypeSpec) error {
+	if err := spec.Validate(); err != nil {
+		return fmt.Errorf("invalid sensor type %q: %w", spec.Type, err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.types[spec.Type] = spec
+	return nil
+}
+
+// Lookup retorna la declaración de un tipo
+func (r *TypeRegistry) Lookup(t SensorType) (TypeSpec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	spec, ok := r.types[t]
+	return spec, ok
+}
+
+// Types retorna los tipos registrados ordenados por nombre
+func (r *TypeRegistry) Types() []TypeSpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	specs := make([]TypeSpec, 0, len(r.types))
+	for _, spec := range r.types {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Type < specs[j].Type })
+	return specs
+}
+
+// Names retorna los nombres de los tipos registrados ordenados
+func (r *TypeRegistry) Names() []string {
+	specs := r.Types()
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		names[i] = string(spec.Type)
+	}
+	return names
+}
+
+// defaultTypes es el catálogo compartido por todo el proceso
+var defaultTypes = NewTypeRegistry()
+
+// RegisterType añade o reemplaza un tipo en el catálogo del proceso
+func RegisterType(spec TypeSpec) error {
+	return defaultTypes.Register(spec)
+}
+
+// LookupType retorna la declaración de un tipo del catálogo del proceso
+func LookupType(t SensorType) (TypeSpec, bool) {
+	return defaultTypes.Lookup(t)
+}
+
+// Types retorna los tipos del catálogo del proceso ordenados por nombre
+func Types() []TypeSpec {
+	return defaultTypes.Types()
+}
+
+// TypeNames retorna los nombres de los tipos del catálogo del proceso
+func TypeNames() []string {
+	return defaultTypes.Names()
+}
@@ -0,0 +1,96 @@
+package sensor
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTypeRegistry_Builtins(t *testing.T) {
+	r := NewTypeRegistry()
+
+	if names := r.Names(); !reflect.DeepEqual(names, []string{"humidity", "pressure", "temperature"}) {
+		t.Errorf("unexpected builtin types %v", names)
+	}
+	for _, spec := range r.Types() {
+		if err := spec.Validate(); err != nil {
+			t.Errorf("builtin type %s is invalid: %v", spec.Type, err)
+		}
+	}
+	if spec, ok := r.Lookup(SensorTypePressure); !ok || spec.Unit != "hPa" {
+		t.Errorf("expected pressure in hPa, got %+v (%v)", spec, ok)
+	}
+	if _, ok := r.Lookup("co2"); ok {
+		t.Error("expected co2 not to be registered")
+	}
+}
+
+func TestTypeRegistry_Register(t *testing.T) {
+	r := NewTypeRegistry()
+
+	co2 := TypeSpec{Type: "co2", Unit: "ppm", Min: 0, Max: 5000, DefaultThreshold: 1000}
+	if err := r.Register(co2); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if spec, ok := r.Lookup("co2"); !ok || spec != co2 {
+		t.Errorf("Lookup(co2) = %+v, %v", spec, ok)
+	}
+
+	// Redefinir un tipo incorporado
+	if err := r.Register(TypeSpec{Type: SensorTypeTemperature, Unit: "°F", Min: -40, Max: 185}); err != nil {
+		t.Fatalf("Register() failed: %v", err)
+	}
+	if spec, _ := r.Lookup(SensorTypeTemperature); spec.Unit != "°F" {
+		t.Errorf("expected temperature to be redefined, got %+v", spec)
+	}
+
+	invalid := []TypeSpec{
+		{Unit: "ppm", Max: 1},
+		{Type: "co2", Max: 1},
+		{Type: "co2", Unit: "ppm", Min: 1, Max: 1},
+		{Type: "co2", Unit: "ppm", Max: 10, Generator: Generator{Base: 5, Variation: -1}},
+		{Type: "co2", Unit: "ppm", Max: 10, Generator: Generator{Base: 50}},
+	}
+	for _, spec := range invalid {
+		if err := r.Register(spec); err == nil {
+			t.Errorf("expected Register(%+v) to fail", spec)
+		}
+	}
+
+	// El catálogo del proceso no se ve afectado
+	if _, ok := LookupType("co2"); ok {
+		t.Error("expected the process registry not to contain co2")
+	}
+}
+
+func TestTypeSpec_ValidateName(t *testing.T) {
+	// Nombres que no son un token de subject válido o que chocan con sensor.readings.<accion>
+	for _, name := range []SensorType{"co2.room", "co2*", "co2>", "co 2", "co2\t", "query", "stats", "export", "import", "search"} {
+		spec := TypeSpec{Type: name, Unit: "ppm", Max: 1}
+		if err := spec.Validate(); err == nil {
+			t.Errorf("expected type %q to be rejected", name)
+		}
+	}
+
+	for _, name := range []SensorType{"co2", "air_quality", "light-lux", "queries"} {
+		spec := TypeSpec{Type: name, Unit: "ppm", Max: 1}
+		if err := spec.Validate(); err != nil {
+			t.Errorf("expected type %q to be valid, got %v", name, err)
+		}
+	}
+}
+
+func TestTypeSpec_Generate(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	specs := []TypeSpec{
+		{Type: "humidity", Unit: "%", Min: 0, Max: 100, Generator: Generator{Base: 95, Variation: 40}}, // Recorta en max
+		{Type: "light", Unit: "lx", Min: 10, Max: 20},                                                  // Uniforme en el rango
+	}
+	for _, spec := range specs {
+		for i := 0; i < 200; i++ {
+			if v := spec.Generate(rng); v < spec.Min || v > spec.Max {
+				t.Fatalf("%s: value %v out of range [%v, %v]", spec.Type, v, spec.Min, spec.Max)
+			}
+		}
+	}
+}
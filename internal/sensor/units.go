@@ -0,0 +1,226 @@
+package sensor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Magnitudes físicas con unidades convertibles entre sí
+const (
+	DimensionTemperature = "temperature"
+	DimensionPressure    = "pressure"
+	DimensionHumidity    = "humidity"
+)
+
+// unitDef describe una unidad como conversión afín a la unidad base de su magnitud:
+// base = valor*scale + offset (°C para temperatura, Pa para presión)
+type unitDef struct {
+	dimension string
+	scale     float64
+	offset    float64
+}
+
+var units = map[string]unitDef{
+	"°C": {DimensionTemperature, 1, 0},
+	"°F": {DimensionTemperature, 5.0 / 9, -32 * 5.0 / 9},
+	"K":  {DimensionTemperature, 1, -273.15},
+
+	"Pa":   {DimensionPressure, 1, 0},
+	"hPa":  {DimensionPressure, 100, 0},
+	"kPa":  {DimensionPressure, 1000, 0},
+	"mbar": {DimensionPressure, 100, 0},
+	"bar":  {DimensionPressure, 100000, 0},
+	"atm":  {DimensionPressure, 101325, 0},
+	"psi":  {DimensionPressure, 6894.757293168, 0},
+	"inHg": {DimensionPressure, 3386.389, 0},
+	"mmHg": {DimensionPressure, 133.322387415, 0},
+
+	"%": {DimensionHumidity, 1, 0},
+}
+
+// unitAliases son otras formas de escribir las unidades (en minúsculas)
+var unitAliases = map[string]string{
+	"c": "°C", "degc": "°C", "celsius": "°C", "ºc": "°C",
+	"f": "°F", "degf": "°F", "fahrenheit": "°F", "ºf": "°F",
+	"kelvin": "K",
+	"rh":     "%", "%rh": "%",
+}
+
+// ParseUnit normaliza una unidad conocida o uno de sus alias (ej: "F" o "fahrenheit" -> "°F")
+func ParseUnit(s string) (string, error) {
+	if _, ok := units[s]; ok {
+		return s, nil
+	}
+	lower := strings.ToLower(strings.TrimSpace(s))
+	if unit, ok := unitAliases[lower]; ok {
+		return unit, nil
+	}
+	for unit := range units {
+		if strings.ToLower(unit) == lower {
+			return unit, nil
+		}
+	}
+	return "", fmt.Errorf("unknown unit %q (known units: %s)", s, strings.Join(knownUnits(""), ", "))
+}
+
+// UnitDimension retorna la magnitud de una unidad ("" si no es convertible)
+func UnitDimension(unit string) string {
+	if parsed, err := ParseUnit(unit); err == nil {
+		return units[parsed].dimension
+	}
+	return ""
+}
+
+// knownUnits retorna las unidades de una magnitud ("" = todas) ordenadas
+func knownUnits(dimension string) []string {
+	names := make([]string, 0, len(units))
+	for unit, def := range units {
+		if dimension == "" || def.dimension == dimension {
+			names = append(names, unit)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveUnits valida que from y to son convertibles y retorna sus definiciones
+func resolveUnits(from, to string) (unitDef, unitDef, error) {
+	fromUnit, err := ParseUnit(from)
+	if err != nil {
+		return unitDef{}, unitDef{}, err
+	}
+	toUnit, err := ParseUnit(to)
+	if err != nil {
+		return unitDef{}, unitDef{}, err
+	}
+	fromDef, toDef := units[fromUnit], units[toUnit]
+	if fromDef.dimension != toDef.dimension {
+		return unitDef{}, unitDef{}, fmt.Errorf("cannot convert %s to %s (compatible units: %s)", from, to, strings.Join(knownUnits(fromDef.dimension), ", "))
+	}
+	return fromDef, toDef, nil
+}
+
+// ConvertValue convierte un valor absoluto (una lectura, un umbral) entre unidades compatibles
+func ConvertValue(value float64, from, to string) (float64, error) {
+	if from == to {
+		return value, nil
+	}
+	fromDef, toDef, err := resolveUnits(from, to)
+	if err != nil {
+		return 0, err
+	}
+	base := value*fromDef.scale + fromDef.offset
+	return (base - toDef.offset) / toDef.scale, nil
+}
+
+// ConvertDelta convierte una diferencia entre valores (una desviación típica, una
+// variación por minuto), que solo depende de la escala de las unidades
+func ConvertDelta(delta float64, from, to string) (float64, error) {
+	if from == to {
+		return delta, nil
+	}
+	fromDef, toDef, err := resolveUnits(from, to)
+	if err != nil {
+		return 0, err
+	}
+	return delta * fromDef.scale / toDef.scale, nil
+}
+
+// InUnit retorna una copia de la lectura en otra unidad. Las lecturas con error solo
+// cambian la unidad: su valor no es una medida.
+func (r *SensorReading) InUnit(unit string) (*SensorReading, error) {
+	converted := *r
+	if unit == r.Unit {
+		return &converted, nil
+	}
+	if !r.IsError() {
+		value, err := ConvertValue(r.Value, r.Unit, unit)
+		if err != nil {
+			return nil, err
+		}
+		converted.Value = value
+		if r.RawValue != nil {
+			raw, err := ConvertValue(*r.RawValue, r.Unit, unit)
+			if err != nil {
+				return nil, err
+			}
+			converted.RawValue = &raw
+		}
+	} else if _, _, err := resolveUnits(r.Unit, unit); err != nil {
+		return nil, err
+	}
+	converted.Unit = unit
+	return &converted, nil
+}
+
+// InUnit retorna una copia del agregado en otra unidad
+func (a *ReadingAggregate) InUnit(unit string) (*ReadingAggregate, error) {
+	converted := *a
+	if unit == a.Unit {
+		return &converted, nil
+	}
+	var err error
+	for _, v := range []*float64{&converted.Min, &converted.Max, &converted.Avg} {
+		if *v, err = ConvertValue(*v, a.Unit, unit); err != nil {
+			return nil, err
+		}
+	}
+	if converted.StdDev, err = ConvertDelta(a.StdDev, a.Unit, unit); err != nil {
+		return nil, err
+	}
+	converted.StdDev = math.Abs(converted.StdDev)
+	converted.Unit = unit
+	return &converted, nil
+}
+
+// InUnit retorna una copia de la alerta con el valor y el umbral en otra unidad (en las
+// de variación son diferencias por minuto)
+func (a *Alert) InUnit(unit string) (*Alert, error) {
+	converted := *a
+	if unit == a.Unit {
+		return &converted, nil
+	}
+	convert := ConvertValue
+	if a.Kind == AlertKindRate {
+		convert = ConvertDelta
+	}
+	var err error
+	if converted.Value, err = convert(a.Value, a.Unit, unit); err != nil {
+		return nil, err
+	}
+	if converted.Threshold, err = convert(a.Threshold, a.Unit, unit); err != nil {
+		return nil, err
+	}
+	converted.Unit = unit
+	return &converted, nil
+}
+
+// InUnit retorna una copia de la configuración con los límites de alerta, guardados en
+// from, en la unidad to: los umbrales son valores y la histéresis y la variación
+// máxima son diferencias. La calibración se queda en la unidad del sensor.
+func (c *SensorConfig) InUnit(from, to string) (*SensorConfig, error) {
+	converted := *c
+	if from == to {
+		return &converted, nil
+	}
+	var err error
+	if converted.Threshold, err = ConvertValue(c.Threshold, from, to); err != nil {
+		return nil, err
+	}
+	if c.LowThreshold != nil {
+		low, err := ConvertValue(*c.LowThreshold, from, to)
+		if err != nil {
+			return nil, err
+		}
+		converted.LowThreshold = &low
+	}
+	for _, v := range []*float64{&converted.Hysteresis, &converted.MaxRate} {
+		if *v, err = ConvertDelta(*v, from, to); err != nil {
+			return nil, err
+		}
+		*v = math.Abs(*v)
+	}
+	return &converted, nil
+}
@@ -0,0 +1,113 @@
+package sensor
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		value    float64
+		from, to string
+		want     float64
+	}{
+		{100, "°C", "°F", 212},
+		{-40, "°F", "°C", -40},
+		{0, "°C", "K", 273.15},
+		{68, "F", "celsius", 20},
+		{1013.25, "hPa", "atm", 1},
+		{14.6959, "psi", "hPa", 1013.25},
+		{1, "bar", "mbar", 1000},
+		{55, "%", "%", 55},
+		{400, "ppm", "ppm", 400}, // Misma unidad aunque no sea convertible
+	}
+	for _, tt := range tests {
+		got, err := ConvertValue(tt.value, tt.from, tt.to)
+		if err != nil {
+			t.Errorf("ConvertValue(%v, %s, %s) failed: %v", tt.value, tt.from, tt.to, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("ConvertValue(%v, %s, %s) = %v, want %v", tt.value, tt.from, tt.to, got, tt.want)
+		}
+	}
+
+	for _, pair := range [][2]string{{"°C", "psi"}, {"%", "°F"}, {"ppm", "°C"}, {"°C", "furlong"}} {
+		if _, err := ConvertValue(1, pair[0], pair[1]); err == nil {
+			t.Errorf("expected error converting %s to %s", pair[0], pair[1])
+		}
+	}
+}
+
+func TestConvertDelta(t *testing.T) {
+	// Una variación de 10 °C son 18 °F: el offset no interviene
+	if got, _ := ConvertDelta(10, "°C", "°F"); math.Abs(got-18) > 1e-9 {
+		t.Errorf("ConvertDelta(10, °C, °F) = %v, want 18", got)
+	}
+	if got, _ := ConvertDelta(5, "K", "°C"); got != 5 {
+		t.Errorf("ConvertDelta(5, K, °C) = %v, want 5", got)
+	}
+}
+
+func TestParseUnit(t *testing.T) {
+	for input, want := range map[string]string{"F": "°F", "degC": "°C", "kelvin": "K", "HPA": "hPa", "psi": "psi", "°F": "°F"} {
+		if got, err := ParseUnit(input); err != nil || got != want {
+			t.Errorf("ParseUnit(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseUnit("lightyear"); err == nil {
+		t.Error("expected error for an unknown unit")
+	}
+	if UnitDimension("psi") != DimensionPressure || UnitDimension("ppm") != "" {
+		t.Error("unexpected unit dimensions")
+	}
+}
+
+func TestInUnit(t *testing.T) {
+	msg := "sensor timeout"
+	reading := &SensorReading{ID: "r1", SensorID: "temp-001", Value: 25, Unit: "°C"}
+	converted, err := reading.InUnit("°F")
+	if err != nil || converted.Value != 77 || converted.Unit != "°F" {
+		t.Errorf("InUnit(°F) = %+v, %v", converted, err)
+	}
+	if reading.Value != 25 || reading.Unit != "°C" {
+		t.Errorf("InUnit modified the original reading: %+v", reading)
+	}
+
+	raw := 20.0
+	calibrated := &SensorReading{ID: "r3", Value: 25, RawValue: &raw, Unit: "°C"}
+	if converted, err := calibrated.InUnit("°F"); err != nil || converted.Value != 77 || *converted.RawValue != 68 || raw != 20 {
+		t.Errorf("InUnit(°F) on a calibrated reading = %+v, %v", converted, err)
+	}
+
+	// Las lecturas con error solo cambian de unidad
+	failed := &SensorReading{ID: "r2", Value: 0, Unit: "°C", Error: &msg}
+	if converted, err := failed.InUnit("°F"); err != nil || converted.Value != 0 || converted.Unit != "°F" {
+		t.Errorf("InUnit(°F) on an error reading = %+v, %v", converted, err)
+	}
+
+	aggregate := &ReadingAggregate{Min: 10, Max: 30, Avg: 20, StdDev: 5, Unit: "°C"}
+	if converted, err := aggregate.InUnit("°F"); err != nil || converted.Min != 50 || converted.Max != 86 || converted.Avg != 68 || converted.StdDev != 9 {
+		t.Errorf("aggregate InUnit(°F) = %+v, %v", converted, err)
+	}
+
+	alert := &Alert{Value: 1040, Threshold: 1030, Unit: "hPa"}
+	if converted, err := alert.InUnit("kPa"); err != nil || converted.Value != 104 || converted.Threshold != 103 {
+		t.Errorf("alert InUnit(kPa) = %+v, %v", converted, err)
+	}
+
+	// Las alertas de variación son diferencias por minuto
+	rateAlert := &Alert{Kind: AlertKindRate, Value: -10, Threshold: 5, Unit: "°C"}
+	if converted, err := rateAlert.InUnit("°F"); err != nil || converted.Value != -18 || converted.Threshold != 9 {
+		t.Errorf("rate alert InUnit(°F) = %+v, %v", converted, err)
+	}
+
+	low := 0.0
+	config := &SensorConfig{SensorID: "temp-001", Threshold: 30, LowThreshold: &low, Hysteresis: 1, MaxRate: 5}
+	if converted, err := config.InUnit("°C", "°F"); err != nil || converted.Threshold != 86 || *converted.LowThreshold != 32 || math.Abs(converted.Hysteresis-1.8) > 1e-9 || converted.MaxRate != 9 {
+		t.Errorf("config InUnit(°F) = %+v, %v", converted, err)
+	}
+	if low != 0 {
+		t.Errorf("InUnit modified the original low threshold: %v", low)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	natsclient "github.com/alejandro/technical_test_uvigo/internal/nats"
 	"github.com/alejandro/technical_test_uvigo/internal/repository"
 	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/alejandro/technical_test_uvigo/internal/writer"
 	"github.com/sirupsen/logrus"
 )
 
@@ -27,18 +28,42 @@ type sensorState struct {
 	ticker   *time.Ticker
 	lastRead time.Time
 	rand     *rand.Rand
+	removed  chan struct{}  // Se cierra en RemoveSensor para detener su ticker goroutine
+	inflight sync.WaitGroup // Tareas del sensor en proceso; RemoveSensor las espera antes del flush
+	device   string         // Dispositivo al que pertenece el canal ("" = sensor independiente)
+
+	alarmMu sync.Mutex        // Protege alarm: dos lecturas del sensor pueden procesarse a la vez
+	alarm   sensor.AlarmState // Histéresis y última lectura válida para las alertas
 }
 
-// readingTask representa una tarea de lectura de sensor
+// deviceState mantiene el estado de un dispositivo multicanal. Sus canales están también
+// en Simulator.sensors (sin ticker propio) para la configuración, el listado y las alertas.
+type deviceState struct {
+	def      config.DeviceDef
+	ticker   *time.Ticker
+	channels []*sensorState
+	removed  chan struct{}
+}
+
+// readingTask representa una tarea de lectura de sensor o de muestra de un dispositivo
 type readingTask struct {
 	sensorID string
 	state    *sensorState
+	device   *deviceState // Si no es nil, la tarea es una muestra de todos sus canales
+}
+
+// Options configura el simulador
+type Options struct {
+	Workers int            // Tamaño del worker pool (0 = defaultWorkers)
+	Batch   writer.Options // Write-behind de lecturas hacia el repositorio
 }
 
 // Simulator gestiona múltiples sensores con worker pool
 type Simulator struct {
 	sensors    map[string]*sensorState // key: sensor ID
+	devices    map[string]*deviceState // key: device ID
 	repo       repository.Repository
+	writer     *writer.BatchWriter // Persistencia de lecturas por lotes
 	natsClient natsclient.Publisher
 	mu         sync.RWMutex
 	ctx        context.Context
@@ -55,16 +80,27 @@ func New(repo repository.Repository, natsClient natsclient.Publisher) *Simulator
 
 // NewWithWorkers crea un simulador con número específico de workers
 func NewWithWorkers(repo repository.Repository, natsClient natsclient.Publisher, workers int) *Simulator {
+	return NewWithOptions(repo, natsClient, Options{Workers: workers})
+}
+
+// NewWithOptions crea un simulador con worker pool y write-behind configurables
+func NewWithOptions(repo repository.Repository, natsClient natsclient.Publisher, opts Options) *Simulator {
 	ctx, cancel := context.WithCancel(context.Background())
 
+	if opts.Workers <= 0 {
+		opts.Workers = defaultWorkers
+	}
+
 	s := &Simulator{
 		sensors:    make(map[string]*sensorState),
+		devices:    make(map[string]*deviceState),
 		repo:       repo,
+		writer:     writer.NewBatchWriter(repo, opts.Batch),
 		natsClient: natsClient,
 		ctx:        ctx,
 		cancel:     cancel,
 		taskQueue:  make(chan readingTask, taskQueueSize),
-		workers:    workers,
+		workers:    opts.Workers,
 	}
 
 	// Iniciar worker pool
@@ -103,6 +139,7 @@ func (s *Simulator) AddSensor(sensorDef config.SensorDef) error {
 		ticker:   time.NewTicker(time.Duration(sensorDef.Config.Interval) * time.Millisecond),
 		lastRead: time.Now(),
 		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		removed:  make(chan struct{}),
 	}
 
 	s.sensors[sensorDef.ID] = state
@@ -122,21 +159,87 @@ func (s *Simulator) AddSensor(sensorDef config.SensorDef) error {
 	return nil
 }
 
-// RemoveSensor elimina un sensor del simulador
-func (s *Simulator) RemoveSensor(sensorID string) error {
+// AddDevice añade un dispositivo multicanal: registra un sensor lógico por canal y un
+// único ticker que muestrea todos los canales a la vez
+func (s *Simulator) AddDevice(deviceDef config.DeviceDef) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if _, exists := s.devices[deviceDef.ID]; exists {
+		return fmt.Errorf("device %s already exists", deviceDef.ID)
+	}
+	sensorDefs := deviceDef.SensorDefs()
+	for _, sensorDef := range sensorDefs {
+		if _, exists := s.sensors[sensorDef.ID]; exists {
+			return fmt.Errorf("sensor %s already exists", sensorDef.ID)
+		}
+	}
+
+	// Guardar configuración de los canales en BD
+	for _, sensorDef := range sensorDefs {
+		if err := s.repo.SaveConfig(s.ctx, &sensorDef.Config); err != nil {
+			return fmt.Errorf("failed to save config for sensor %s: %w", sensorDef.ID, err)
+		}
+	}
+
+	device := &deviceState{
+		def:     deviceDef,
+		ticker:  time.NewTicker(time.Duration(deviceDef.Interval) * time.Millisecond),
+		removed: make(chan struct{}),
+	}
+	for _, sensorDef := range sensorDefs {
+		state := &sensorState{
+			def:      sensorDef,
+			lastRead: time.Now(),
+			rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+			removed:  device.removed,
+			device:   deviceDef.ID,
+		}
+		s.sensors[sensorDef.ID] = state
+		device.channels = append(device.channels, state)
+	}
+	s.devices[deviceDef.ID] = device
+
+	if deviceDef.Enabled {
+		s.wg.Add(1)
+		go s.deviceTicker(device)
+	}
+
+	logger.WithFields(logrus.Fields{
+		"device_id": deviceDef.ID,
+		"channels":  len(sensorDefs),
+		"interval":  deviceDef.Interval,
+	}).Info("[Simulator] Device added")
+
+	return nil
+}
+
+// RemoveSensor elimina un sensor del simulador: detiene su ticker goroutine, descarta
+// sus tareas pendientes, espera a las que ya estaban en proceso y persiste las lecturas
+// que quedaban en el write-behind
+func (s *Simulator) RemoveSensor(sensorID string) error {
+	s.mu.Lock()
 	state, exists := s.sensors[sensorID]
 	if !exists {
+		s.mu.Unlock()
 		return fmt.Errorf("sensor %s not found", sensorID)
 	}
+	if state.device != "" {
+		s.mu.Unlock()
+		return fmt.Errorf("sensor %s is a channel of device %s", sensorID, state.device)
+	}
 
-	// Detener ticker
+	// Detener ticker y su goroutine
 	state.ticker.Stop()
+	close(state.removed)
 
 	// Eliminar del mapa
 	delete(s.sensors, sensorID)
+	s.mu.Unlock()
+
+	// Fuera del lock: las tareas en proceso encolan su lectura y el flush la escribe
+	state.inflight.Wait()
+	s.writer.Flush()
 
 	logger.WithField("sensor_id", sensorID).Info("[Simulator] Sensor removed")
 
@@ -198,8 +301,12 @@ func (s *Simulator) worker(id int) {
 				logger.WithField("worker_id", id).Debug("[Simulator] Task queue closed, worker stopping")
 				return
 			}
-			// Procesar la lectura del sensor
-			s.processReading(task.sensorID, task.state)
+			// Procesar la lectura del sensor o la muestra del dispositivo
+			if task.device != nil {
+				s.processDeviceReading(task.device)
+			} else {
+				s.processReading(task.sensorID, task.state)
+			}
 		}
 	}
 }
@@ -216,6 +323,10 @@ func (s *Simulator) sensorTicker(sensorID string, state *sensorState) {
 			logger.WithField("sensor_id", sensorID).Debug("[Simulator] Sensor ticker stopped")
 			return
 
+		case <-state.removed:
+			logger.WithField("sensor_id", sensorID).Debug("[Simulator] Sensor ticker stopped (sensor removed)")
+			return
+
 		case <-state.ticker.C:
 			// Verificar si el sensor sigue habilitado
 			s.mu.RLock()
@@ -238,45 +349,140 @@ func (s *Simulator) sensorTicker(sensorID string, state *sensorState) {
 	}
 }
 
+// deviceTicker envía una tarea por muestra del dispositivo según su intervalo
+func (s *Simulator) deviceTicker(device *deviceState) {
+	defer s.wg.Done()
+
+	logger.WithField("device_id", device.def.ID).Debug("[Simulator] Device ticker started")
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			logger.WithField("device_id", device.def.ID).Debug("[Simulator] Device ticker stopped")
+			return
+
+		case <-device.removed:
+			return
+
+		case <-device.ticker.C:
+			select {
+			case s.taskQueue <- readingTask{sensorID: device.def.ID, device: device}:
+			default:
+				logger.WithField("device_id", device.def.ID).Warn("[Simulator] Task queue full, skipping sample")
+			}
+		}
+	}
+}
+
+// processDeviceReading genera una lectura por cada canal habilitado con el mismo timestamp,
+// las encola juntas para que se guarden en la misma transacción, publica la muestra
+// completa y después cada canal en su subject de sensor para los suscriptores existentes
+func (s *Simulator) processDeviceReading(device *deviceState) {
+	select {
+	case <-device.removed:
+		return
+	default:
+	}
+
+	sample := &sensor.DeviceReading{
+		ID:        sensor.NewID(),
+		DeviceID:  device.def.ID,
+		Timestamp: time.Now().UTC(),
+	}
+	var channels []*sensorState
+	for _, state := range device.channels {
+		s.mu.RLock()
+		enabled := state.def.Config.Enabled
+		s.mu.RUnlock()
+		if !enabled {
+			continue
+		}
+		reading := s.generateReading(state.def.ID, state)
+		reading.Timestamp = sample.Timestamp
+		sample.Readings = append(sample.Readings, reading)
+		channels = append(channels, state)
+	}
+	if len(sample.Readings) == 0 {
+		return
+	}
+
+	// 1. Encolar todos los canales en el mismo lote (write-behind)
+	s.writer.Write(sample.Readings...)
+
+	// 2. Publicar la muestra completa
+	subject := natsclient.DeviceReadingSubject(device.def.ID)
+	if data, err := json.Marshal(sample); err != nil {
+		logger.WithFields(logrus.Fields{
+			"device_id": device.def.ID,
+			"error":     err,
+		}).Error("[Simulator] Error marshaling device reading")
+	} else if err := s.natsClient.Publish(subject, data); err != nil {
+		logger.WithFields(logrus.Fields{
+			"device_id": device.def.ID,
+			"subject":   subject,
+			"error":     err,
+		}).Error("[Simulator] Error publishing device reading")
+	}
+
+	// 3. Publicar cada canal y verificar sus alertas
+	for i, reading := range sample.Readings {
+		s.publishReading(reading, channels[i])
+		s.checkAndPublishAlert(reading, channels[i])
+	}
+}
+
 // processReading genera y procesa una lectura de un sensor
 func (s *Simulator) processReading(sensorID string, state *sensorState) {
+	// Descartar tareas encoladas antes de eliminar el sensor. El alta en inflight se hace
+	// bajo el lock con el que RemoveSensor cierra removed, para que no se cuele tras su Wait
+	s.mu.RLock()
+	select {
+	case <-state.removed:
+		s.mu.RUnlock()
+		return
+	default:
+	}
+	state.inflight.Add(1)
+	s.mu.RUnlock()
+	defer state.inflight.Done()
+
 	// Generar lectura
 	reading := s.generateReading(sensorID, state)
 
-	// 1. Guardar en BD
-	if err := s.repo.SaveReading(s.ctx, reading); err != nil {
-		logger.WithFields(logrus.Fields{
-			"sensor_id": sensorID,
-			"error":     err,
-		}).Error("[Simulator] Error saving reading")
-	}
+	// 1. Encolar para persistencia por lotes (write-behind)
+	s.writer.Write(reading)
 
 	// 2. Publicar en NATS
-	subject := natsclient.ReadingSubject(string(state.def.Type), sensorID)
+	s.publishReading(reading, state)
+
+	// 3. Verificar alertas
+	s.checkAndPublishAlert(reading, state)
+}
+
+// publishReading publica una lectura en sensor.readings.<type>.<id>
+func (s *Simulator) publishReading(reading *sensor.SensorReading, state *sensorState) {
+	subject := natsclient.ReadingSubject(string(state.def.Type), reading.SensorID)
 	data, err := json.Marshal(reading)
 	if err != nil {
 		logger.WithFields(logrus.Fields{
-			"sensor_id": sensorID,
+			"sensor_id": reading.SensorID,
 			"error":     err,
 		}).Error("[Simulator] Error marshaling reading")
-	} else {
-		if err := s.natsClient.Publish(subject, data); err != nil {
-			logger.WithFields(logrus.Fields{
-				"sensor_id": sensorID,
-				"subject":   subject,
-				"error":     err,
-			}).Error("[Simulator] Error publishing reading")
-		}
+		return
+	}
+	if err := s.natsClient.Publish(subject, data); err != nil {
+		logger.WithFields(logrus.Fields{
+			"sensor_id": reading.SensorID,
+			"subject":   subject,
+			"error":     err,
+		}).Error("[Simulator] Error publishing reading")
 	}
-
-	// 3. Verificar alertas
-	s.checkAndPublishAlert(reading, state)
 }
 
-// generateReading genera una lectura simulada
+// generateReading genera una lectura simulada con el valor calibrado y el valor bruto
 func (s *Simulator) generateReading(sensorID string, state *sensorState) *sensor.SensorReading {
 	reading := &sensor.SensorReading{
-		ID:        fmt.Sprintf("read-%d", time.Now().UnixNano()),
+		ID:        sensor.NewID(),
 		SensorID:  sensorID,
 		Type:      state.def.Type,
 		Timestamp: time.Now().UTC(),
@@ -291,59 +497,36 @@ func (s *Simulator) generateReading(sensorID string, state *sensorState) *sensor
 		return reading
 	}
 
-	// Generar valor según el tipo de sensor
-	reading.Value = s.generateValue(state)
+	// Generar valor según el tipo de sensor y aplicar la calibración antes de guardarlo
+	// y de verificar las alertas
+	raw := s.generateValue(state)
+	reading.Value = raw
 	reading.Unit = s.getUnit(state.def.Type)
 
+	s.mu.RLock()
+	calibration := state.def.Config.Calibration
+	s.mu.RUnlock()
+	if !calibration.IsIdentity() {
+		reading.Value = calibration.Apply(raw)
+		reading.RawValue = &raw
+	}
+
 	return reading
 }
 
-// generateValue genera un valor aleatorio según el tipo de sensor
+// generateValue genera un valor aleatorio con el generador del tipo de sensor (0 si el tipo no está registrado)
 func (s *Simulator) generateValue(state *sensorState) float64 {
-	switch state.def.Type {
-	case sensor.SensorTypeTemperature:
-		// Temperatura entre 15°C y 35°C con variación
-		base := 25.0
-		variation := (state.rand.Float64() - 0.5) * 20.0 // ±10°C
-		return base + variation
-
-	case sensor.SensorTypeHumidity:
-		// Humedad entre 30% y 80%
-		base := 55.0
-		variation := (state.rand.Float64() - 0.5) * 50.0 // ±25%
-		value := base + variation
-		// Clamp entre 0 y 100
-		if value < 0 {
-			return 0
-		}
-		if value > 100 {
-			return 100
-		}
-		return value
-
-	case sensor.SensorTypePressure:
-		// Presión entre 980 hPa y 1040 hPa
-		base := 1010.0
-		variation := (state.rand.Float64() - 0.5) * 60.0 // ±30 hPa
-		return base + variation
-
-	default:
+	spec, ok := sensor.LookupType(state.def.Type)
+	if !ok {
 		return 0
 	}
+	return spec.Generate(state.rand)
 }
 
-// getUnit retorna la unidad según el tipo de sensor
+// getUnit retorna la unidad del tipo de sensor ("" si el tipo no está registrado)
 func (s *Simulator) getUnit(sensorType sensor.SensorType) string {
-	switch sensorType {
-	case sensor.SensorTypeTemperature:
-		return "°C"
-	case sensor.SensorTypeHumidity:
-		return "%"
-	case sensor.SensorTypePressure:
-		return "hPa"
-	default:
-		return ""
-	}
+	spec, _ := sensor.LookupType(sensorType)
+	return spec.Unit
 }
 
 // generateErrorMessage genera un mensaje de error aleatorio
@@ -358,54 +541,97 @@ func (s *Simulator) generateErrorMessage(state *sensorState) string {
 	return errors[state.rand.Intn(len(errors))]
 }
 
-// checkAndPublishAlert verifica si el valor excede el umbral y publica alerta
+// checkAndPublishAlert verifica la lectura con los límites de alerta del sensor (umbral
+// superior e inferior con histéresis y variación máxima por minuto) y persiste y publica
+// en NATS una alerta por cada límite superado. Se persiste primero para no perderla si no
+// hay suscriptores.
 func (s *Simulator) checkAndPublishAlert(reading *sensor.SensorReading, state *sensorState) {
-	// Si la lectura tiene error, no verificamos threshold
+	// Si la lectura tiene error, no verificamos los límites
 	if reading.IsError() {
 		return
 	}
 
-	// Verificar si se excede el umbral
-	if reading.Value > state.def.Config.Threshold {
-		alert := map[string]interface{}{
-			"sensor_id": reading.SensorID,
-			"type":      state.def.Type,
-			"value":     reading.Value,
-			"threshold": state.def.Config.Threshold,
-			"unit":      reading.Unit,
-			"timestamp": reading.Timestamp,
-			"message":   fmt.Sprintf("Sensor %s exceeded threshold: %.2f %s > %.2f %s", reading.SensorID, reading.Value, reading.Unit, state.def.Config.Threshold, reading.Unit),
-		}
+	s.mu.RLock()
+	config := state.def.Config
+	s.mu.RUnlock()
+
+	state.alarmMu.Lock()
+	breaches := state.alarm.Check(&config, reading.Value, reading.Timestamp)
+	state.alarmMu.Unlock()
+
+	for _, breach := range breaches {
+		s.publishAlert(&sensor.Alert{
+			ID:        sensor.NewID(),
+			SensorID:  reading.SensorID,
+			Type:      state.def.Type,
+			Kind:      breach.Kind,
+			Severity:  breach.Severity(),
+			Value:     breach.Value,
+			Threshold: breach.Limit,
+			Unit:      reading.Unit,
+			Timestamp: reading.Timestamp,
+			Message:   alertMessage(reading.SensorID, breach, reading.Unit),
+		}, state)
+	}
+}
 
-		// Publicar alerta en NATS
-		subject := natsclient.AlertSubject(string(state.def.Type), reading.SensorID)
-		data, err := json.Marshal(alert)
-		if err != nil {
-			logger.WithFields(logrus.Fields{
-				"sensor_id": reading.SensorID,
-				"error":     err,
-			}).Error("[Simulator] Error marshaling alert")
-			return
-		}
+// alertMessage describe el límite superado por una lectura
+func alertMessage(sensorID string, breach sensor.ThresholdBreach, unit string) string {
+	switch breach.Kind {
+	case sensor.AlertKindLow:
+		return fmt.Sprintf("Sensor %s below low threshold: %.2f %s < %.2f %s", sensorID, breach.Value, unit, breach.Limit, unit)
+	case sensor.AlertKindRate:
+		return fmt.Sprintf("Sensor %s changed too fast: %.2f %s/min (max %.2f %s/min)", sensorID, breach.Value, unit, breach.Limit, unit)
+	default:
+		return fmt.Sprintf("Sensor %s exceeded threshold: %.2f %s > %.2f %s", sensorID, breach.Value, unit, breach.Limit, unit)
+	}
+}
 
-		if err := s.natsClient.Publish(subject, data); err != nil {
-			logger.WithFields(logrus.Fields{
-				"sensor_id": reading.SensorID,
-				"subject":   subject,
-				"error":     err,
-			}).Error("[Simulator] Error publishing alert")
-		} else {
-			logger.WithFields(logrus.Fields{
-				"sensor_id": reading.SensorID,
-				"type":      state.def.Type,
-				"value":     reading.Value,
-				"threshold": state.def.Config.Threshold,
-				"unit":      reading.Unit,
-			}).Warn("[Simulator] ALERT: Sensor exceeded threshold")
-		}
+// publishAlert persiste una alerta y la publica en sensor.alerts.<type>.<id>
+func (s *Simulator) publishAlert(alert *sensor.Alert, state *sensorState) {
+	// Persistir alerta
+	if err := s.repo.SaveAlert(s.ctx, alert); err != nil {
+		logger.WithFields(logrus.Fields{
+			"sensor_id": alert.SensorID,
+			"error":     err,
+		}).Error("[Simulator] Error saving alert")
+	}
+
+	// Publicar alerta en NATS
+	subject := natsclient.AlertSubject(string(state.def.Type), alert.SensorID)
+	data, err := json.Marshal(alert)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"sensor_id": alert.SensorID,
+			"error":     err,
+		}).Error("[Simulator] Error marshaling alert")
+		return
+	}
+
+	if err := s.natsClient.Publish(subject, data); err != nil {
+		logger.WithFields(logrus.Fields{
+			"sensor_id": alert.SensorID,
+			"subject":   subject,
+			"error":     err,
+		}).Error("[Simulator] Error publishing alert")
+	} else {
+		logger.WithFields(logrus.Fields{
+			"sensor_id": alert.SensorID,
+			"type":      state.def.Type,
+			"kind":      alert.Kind,
+			"severity":  alert.Severity,
+			"value":     alert.Value,
+			"threshold": alert.Threshold,
+			"unit":      alert.Unit,
+		}).Warn("[Simulator] ALERT: Sensor exceeded alert limit")
 	}
 }
 
+// WriterStats retorna las métricas del write-behind (latencia de flush y tamaño de lote)
+func (s *Simulator) WriterStats() writer.Stats {
+	return s.writer.Stats()
+}
+
 // GetSensorCount retorna el número de sensores activos
 func (s *Simulator) GetSensorCount() int {
 	s.mu.RLock()
@@ -413,6 +639,13 @@ func (s *Simulator) GetSensorCount() int {
 	return len(s.sensors)
 }
 
+// GetDeviceCount retorna el número de dispositivos multicanal
+func (s *Simulator) GetDeviceCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.devices)
+}
+
 // ListSensors retorna la lista de IDs de sensores
 func (s *Simulator) ListSensors() []string {
 	s.mu.RLock()
@@ -494,6 +727,9 @@ func (s *Simulator) Stop() {
 			state.ticker.Stop()
 		}
 	}
+	for _, device := range s.devices {
+		device.ticker.Stop()
+	}
 	s.mu.Unlock()
 
 	// 4. Cerrar el task queue (ya no se aceptan más tareas)
@@ -502,5 +738,8 @@ func (s *Simulator) Stop() {
 	// 5. Esperar a que terminen todas las goroutines (workers + tickers)
 	s.wg.Wait()
 
+	// 6. Persistir las lecturas pendientes del write-behind
+	s.writer.Close()
+
 	logger.Info("[Simulator] Stopped successfully")
 }
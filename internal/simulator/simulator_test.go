@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -18,6 +20,7 @@ import (
 type mockRepository struct {
 	configs  map[string]*sensor.SensorConfig
 	readings []*sensor.SensorReading
+	alerts   []*sensor.Alert
 	mu       sync.Mutex
 }
 
@@ -37,18 +40,49 @@ func (m *mockRepository) SaveReading(ctx context.Context, reading *sensor.Sensor
 	return nil
 }
 
+func (m *mockRepository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readings = append(m.readings, readings...)
+	return nil
+}
+
+func (m *mockRepository) ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]repository.SkippedReading, error) {
+	return nil, m.SaveReadings(ctx, readings)
+}
+
 func (m *mockRepository) GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	return m.readings, nil
 }
 
+func (m *mockRepository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	return nil, nil, nil
+}
+
+func (m *mockRepository) GetReadingsRangePage(ctx context.Context, sensorID string, start, end time.Time, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	return nil, nil, nil
+}
+
+func (m *mockRepository) SearchReadings(ctx context.Context, query repository.ReadingSearch) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	return nil, nil, nil
+}
+
 func (m *mockRepository) GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	return m.readings, nil
 }
 
+func (m *mockRepository) GetAggregatedReadings(ctx context.Context, sensorID string, start, end time.Time, bucket time.Duration) ([]*sensor.ReadingAggregate, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ApplyRetention(ctx context.Context, policy repository.RetentionPolicy) (int64, error) {
+	return 0, nil
+}
+
 func (m *mockRepository) SaveConfig(ctx context.Context, config *sensor.SensorConfig) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -65,6 +99,47 @@ func (m *mockRepository) GetConfig(ctx context.Context, sensorID string) (*senso
 	return nil, nil
 }
 
+func (m *mockRepository) GetConfigHistory(ctx context.Context, sensorID string, limit int) ([]*sensor.ConfigRevision, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) DeleteConfig(ctx context.Context, sensorID string) error {
+	return nil
+}
+
+func (m *mockRepository) PurgeSensorData(ctx context.Context, sensorID string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockRepository) SaveAlert(ctx context.Context, alert *sensor.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alerts = append(m.alerts, alert)
+	return nil
+}
+
+func (m *mockRepository) GetAlerts(ctx context.Context, filter repository.AlertFilter) ([]*sensor.Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.alerts, nil
+}
+
+func (m *mockRepository) SaveSensor(ctx context.Context, s *sensor.Sensor) error {
+	return nil
+}
+
+func (m *mockRepository) GetSensor(ctx context.Context, sensorID string) (*sensor.Sensor, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ListSensors(ctx context.Context) ([]*sensor.Sensor, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) DeleteSensor(ctx context.Context, sensorID string) error {
+	return nil
+}
+
 func (m *mockRepository) Close() error {
 	return nil
 }
@@ -210,6 +285,133 @@ func TestRemoveSensor(t *testing.T) {
 	}
 }
 
+func TestRemoveSensor_StopsTicker(t *testing.T) {
+	repo := newMockRepository()
+	natsClient := &mockNATSClient{}
+	sim := New(repo, natsClient)
+	defer sim.Stop()
+
+	sensorDef := config.SensorDef{
+		ID:   "test-001",
+		Type: sensor.SensorTypeTemperature,
+		Config: sensor.SensorConfig{
+			SensorID:  "test-001",
+			Interval:  10,
+			Threshold: 100.0,
+			Enabled:   true,
+		},
+	}
+	sim.AddSensor(sensorDef)
+	time.Sleep(50 * time.Millisecond)
+
+	if err := sim.RemoveSensor("test-001"); err != nil {
+		t.Fatalf("RemoveSensor() failed: %v", err)
+	}
+
+	// RemoveSensor persiste las lecturas pendientes y no se generan más
+	natsClient.mu.Lock()
+	published := len(natsClient.published)
+	natsClient.mu.Unlock()
+	if published == 0 {
+		t.Fatal("Expected readings before removal")
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	natsClient.mu.Lock()
+	after := len(natsClient.published)
+	natsClient.mu.Unlock()
+	if after != published {
+		t.Errorf("Expected no readings after removal, got %d more", after-published)
+	}
+
+	repo.mu.Lock()
+	saved := len(repo.readings)
+	repo.mu.Unlock()
+	if saved != published {
+		t.Errorf("Expected %d readings flushed on removal, got %d", published, saved)
+	}
+
+	// El ID queda libre para volver a registrarlo
+	if err := sim.AddSensor(sensorDef); err != nil {
+		t.Errorf("AddSensor() after removal failed: %v", err)
+	}
+}
+
+func TestAddDevice(t *testing.T) {
+	repo := newMockRepository()
+	natsClient := &mockNATSClient{}
+	sim := New(repo, natsClient)
+	defer sim.Stop()
+
+	pressureThreshold := 1050.0
+	deviceDef := config.DeviceDef{
+		ID:       "bme-01",
+		Name:     "BME280",
+		Interval: 3600000, // Sin ticks durante el test: la muestra se genera a mano
+		Enabled:  true,
+		Channels: []config.ChannelDef{
+			{SensorID: "bme-01-temp", Type: sensor.SensorTypeTemperature},
+			{SensorID: "bme-01-hum", Type: sensor.SensorTypeHumidity},
+			{SensorID: "bme-01-press", Type: sensor.SensorTypePressure, Threshold: &pressureThreshold},
+		},
+	}
+	if err := sim.AddDevice(deviceDef); err != nil {
+		t.Fatalf("AddDevice() failed: %v", err)
+	}
+	if sim.GetDeviceCount() != 1 || sim.GetSensorCount() != 3 {
+		t.Fatalf("expected 1 device with 3 channel sensors, got %d devices and %d sensors", sim.GetDeviceCount(), sim.GetSensorCount())
+	}
+	if err := sim.AddDevice(deviceDef); err == nil {
+		t.Error("expected error adding a duplicate device")
+	}
+
+	// Umbral por defecto del tipo salvo en el canal que indica el suyo
+	temp, _ := sensor.LookupType(sensor.SensorTypeTemperature)
+	if cfg, _ := repo.GetConfig(context.Background(), "bme-01-temp"); cfg == nil || cfg.Threshold != temp.DefaultThreshold {
+		t.Errorf("expected default threshold %v for bme-01-temp, got %+v", temp.DefaultThreshold, cfg)
+	}
+	if cfg, _ := repo.GetConfig(context.Background(), "bme-01-press"); cfg == nil || cfg.Threshold != pressureThreshold {
+		t.Errorf("expected threshold %v for bme-01-press, got %+v", pressureThreshold, cfg)
+	}
+
+	// Los canales solo se eliminan con su dispositivo
+	if err := sim.RemoveSensor("bme-01-hum"); err == nil {
+		t.Error("expected error removing a device channel")
+	}
+
+	sim.processDeviceReading(sim.devices["bme-01"])
+	sim.writer.Flush()
+
+	natsClient.mu.Lock()
+	published := append([]string(nil), natsClient.published...)
+	natsClient.mu.Unlock()
+	if len(published) == 0 || published[0] != "sensor.devices.readings.bme-01" {
+		t.Fatalf("expected the device sample to be published first, got %v", published)
+	}
+	var channels []string // Sin las alertas que puedan intercalarse
+	for _, subject := range published[1:] {
+		if strings.HasPrefix(subject, "sensor.readings.") {
+			channels = append(channels, subject)
+		}
+	}
+	want := []string{"sensor.readings.temperature.bme-01-temp", "sensor.readings.humidity.bme-01-hum", "sensor.readings.pressure.bme-01-press"}
+	if !reflect.DeepEqual(channels, want) {
+		t.Errorf("expected one reading per channel %v, got %v", want, channels)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.readings) != 3 {
+		t.Fatalf("expected 3 persisted channel readings, got %d", len(repo.readings))
+	}
+	for _, reading := range repo.readings[1:] {
+		if !reading.Timestamp.Equal(repo.readings[0].Timestamp) {
+			t.Errorf("expected all channels to share the sample timestamp, got %v and %v", reading.Timestamp, repo.readings[0].Timestamp)
+		}
+	}
+}
+
 func TestRemoveSensor_NotFound(t *testing.T) {
 	repo := newMockRepository()
 	natsClient := &mockNATSClient{}
@@ -338,6 +540,126 @@ func TestGetUnit(t *testing.T) {
 	}
 }
 
+func TestGenerateReading_Calibration(t *testing.T) {
+	repo := newMockRepository()
+	natsClient := &mockNATSClient{}
+	sim := New(repo, natsClient)
+
+	state := &sensorState{
+		def: config.SensorDef{
+			ID:     "test-001",
+			Type:   sensor.SensorTypeTemperature,
+			Config: sensor.SensorConfig{SensorID: "test-001", Threshold: 30.0, Calibration: &sensor.Calibration{Gain: 2, Offset: -1}},
+		},
+		rand: rand.New(rand.NewSource(1)),
+	}
+
+	for i := 0; i < 50; i++ {
+		reading := sim.generateReading("test-001", state)
+		if reading.IsError() {
+			if reading.RawValue != nil {
+				t.Errorf("Expected no raw value on an error reading: %+v", reading)
+			}
+			continue
+		}
+		if reading.RawValue == nil || reading.Value != 2**reading.RawValue-1 {
+			t.Fatalf("Expected the calibrated value and the raw value, got %+v", reading)
+		}
+	}
+
+	// Sin calibración no se guarda el valor bruto
+	state.def.Config.Calibration = nil
+	if reading := sim.generateReading("test-001", state); reading.RawValue != nil {
+		t.Errorf("Expected no raw value without calibration, got %+v", reading)
+	}
+}
+
+func TestCheckAndPublishAlert(t *testing.T) {
+	repo := newMockRepository()
+	natsClient := &mockNATSClient{}
+	sim := New(repo, natsClient)
+	defer sim.Stop()
+
+	state := &sensorState{
+		def: config.SensorDef{
+			ID:     "test-001",
+			Type:   sensor.SensorTypeTemperature,
+			Config: sensor.SensorConfig{SensorID: "test-001", Threshold: 30.0},
+		},
+	}
+
+	errMsg := "sensor timeout"
+	readings := []*sensor.SensorReading{
+		{SensorID: "test-001", Value: 25.0, Unit: "°C", Timestamp: time.Now()},                 // Bajo umbral
+		{SensorID: "test-001", Value: 99.0, Unit: "°C", Error: &errMsg, Timestamp: time.Now()}, // Lectura con error
+		{SensorID: "test-001", Value: 31.0, Unit: "°C", Timestamp: time.Now()},                 // Aviso
+		{SensorID: "test-001", Value: 40.0, Unit: "°C", Timestamp: time.Now()},                 // Crítica (> 10%)
+	}
+	for _, reading := range readings {
+		sim.checkAndPublishAlert(reading, state)
+	}
+
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.alerts) != 2 {
+		t.Fatalf("Expected 2 persisted alerts, got %d", len(repo.alerts))
+	}
+	if repo.alerts[0].Severity != sensor.AlertSeverityWarning || repo.alerts[0].Value != 31.0 {
+		t.Errorf("Unexpected first alert: %+v", repo.alerts[0])
+	}
+	if repo.alerts[1].Severity != sensor.AlertSeverityCritical || repo.alerts[1].Threshold != 30.0 {
+		t.Errorf("Unexpected second alert: %+v", repo.alerts[1])
+	}
+
+	natsClient.mu.Lock()
+	defer natsClient.mu.Unlock()
+	if len(natsClient.published) != 2 || natsClient.published[0] != "sensor.alerts.temperature.test-001" {
+		t.Errorf("Expected 2 alerts published, got %v", natsClient.published)
+	}
+}
+
+func TestCheckAndPublishAlert_Limits(t *testing.T) {
+	repo := newMockRepository()
+	natsClient := &mockNATSClient{}
+	sim := New(repo, natsClient)
+	defer sim.Stop()
+
+	low := 0.0
+	state := &sensorState{
+		def: config.SensorDef{
+			ID:     "test-001",
+			Type:   sensor.SensorTypeTemperature,
+			Config: sensor.SensorConfig{SensorID: "test-001", Threshold: 30.0, LowThreshold: &low, Hysteresis: 1, MaxRate: 10},
+		},
+	}
+
+	base := time.Now().UTC()
+	values := []float64{31, 32, 5, -1, -2, 2, -1}
+	for i, value := range values {
+		sim.checkAndPublishAlert(&sensor.SensorReading{SensorID: "test-001", Value: value, Unit: "°C", Timestamp: base.Add(time.Duration(i) * time.Minute)}, state)
+	}
+
+	// 31 (high), 32 (ya disparada), 5 (variación -27/min), -1 (low), -2 (ya disparada),
+	// 2 (rearma), -1 (low)
+	want := []sensor.AlertKind{sensor.AlertKindHigh, sensor.AlertKindRate, sensor.AlertKindLow, sensor.AlertKindLow}
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+	if len(repo.alerts) != len(want) {
+		t.Fatalf("Expected %d persisted alerts, got %d", len(want), len(repo.alerts))
+	}
+	for i, kind := range want {
+		if repo.alerts[i].Kind != kind {
+			t.Errorf("Alert %d: expected kind %s, got %+v", i, kind, repo.alerts[i])
+		}
+	}
+	if rate := repo.alerts[1]; rate.Value != -27 || rate.Threshold != 10 || rate.Severity != sensor.AlertSeverityCritical {
+		t.Errorf("Unexpected rate alert: %+v", rate)
+	}
+	if lowAlert := repo.alerts[2]; lowAlert.Threshold != 0 || !strings.Contains(lowAlert.Message, "below low threshold") {
+		t.Errorf("Unexpected low alert: %+v", lowAlert)
+	}
+}
+
 func TestListSensors(t *testing.T) {
 	repo := newMockRepository()
 	natsClient := &mockNATSClient{}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+// Acumuladores de estadísticas por bucket compartidos por los backends que
+// agregan en Go (InfluxDB, memoria) en lugar de en la propia base de datos.
+
+// rollupKey identifica un bucket de un sensor (inicio en segundos epoch)
+type rollupKey struct {
+	sensorID string
+	start    int64
+}
+
+// bucketStats acumula las sumas necesarias para min/max/avg/stddev de un bucket
+type bucketStats struct {
+	count      int
+	min, max   float64
+	sum        float64
+	sumSquares float64
+}
+
+// valueStats crea el acumulador de una única lectura
+func valueStats(v float64) *bucketStats {
+	return &bucketStats{count: 1, min: v, max: v, sum: v, sumSquares: v * v}
+}
+
+// merge fusiona otro acumulador en s
+func (s *bucketStats) merge(o *bucketStats) {
+	if s.count == 0 {
+		*s = *o
+		return
+	}
+	s.count += o.count
+	s.min = math.Min(s.min, o.min)
+	s.max = math.Max(s.max, o.max)
+	s.sum += o.sum
+	s.sumSquares += o.sumSquares
+}
+
+// aggregate calcula el resumen final del bucket
+func (s *bucketStats) aggregate(sensorID string, start int64) *sensor.ReadingAggregate {
+	n := float64(s.count)
+	avg := s.sum / n
+	return &sensor.ReadingAggregate{
+		SensorID:    sensorID,
+		BucketStart: time.Unix(start, 0).UTC(),
+		Count:       s.count,
+		Min:         s.min,
+		Max:         s.max,
+		Avg:         avg,
+		// Var = E[x²] - E[x]², acotada a 0 por errores de redondeo
+		StdDev: math.Sqrt(math.Max(0, s.sumSquares/n-avg*avg)),
+	}
+}
+
+// bucketMap agrupa acumuladores por sensor y bucket
+type bucketMap map[rollupKey]*bucketStats
+
+// merge fusiona stats en el bucket key
+func (m bucketMap) merge(key rollupKey, stats *bucketStats) {
+	if current, ok := m[key]; ok {
+		current.merge(stats)
+		return
+	}
+	copied := *stats
+	m[key] = &copied
+}
+
+// aggregates retorna los resúmenes de un sensor ordenados por bucket ascendente
+func (m bucketMap) aggregates(sensorID string) []*sensor.ReadingAggregate {
+	keys := make([]rollupKey, 0, len(m))
+	for key := range m {
+		if key.sensorID == sensorID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].start < keys[j].start })
+
+	aggregates := make([]*sensor.ReadingAggregate, 0, len(keys))
+	for _, key := range keys {
+		aggregates = append(aggregates, m[key].aggregate(sensorID, key.start))
+	}
+	return aggregates
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Tablas que debe contener cualquier snapshot para poder restaurarse
+var requiredTables = []string{"schema_migrations", "sensor_readings", "sensor_configs"}
+
+// Backup genera en destPath una copia consistente de la base de datos con VACUUM INTO.
+// Se ejecuta online en una conexión propia: con WAL la copia es una transacción de
+// lectura y no bloquea al escritor único, así que el simulador sigue escribiendo.
+// En ":memory:" solo existe la conexión de escritura y las escrituras esperan a la copia.
+// destPath no debe existir.
+func (r *SQLiteRepository) Backup(ctx context.Context, destPath string) error {
+	if _, err := os.Stat(destPath); err == nil {
+		return fmt.Errorf("backup destination %s already exists", destPath)
+	}
+
+	db := r.db
+	if r.dbPath != "" {
+		// Los lectores usan query_only, que impide VACUUM INTO
+		backupDB, err := sql.Open("sqlite", sqliteDSN(r.dbPath, sqlitePragmas(r.opts)))
+		if err != nil {
+			return fmt.Errorf("failed to open backup connection: %w", err)
+		}
+		defer backupDB.Close()
+		backupDB.SetMaxOpenConns(1)
+		db = backupDB
+	}
+
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, destPath); err != nil {
+		os.Remove(destPath)
+		return fmt.Errorf("failed to back up database to %s: %w", destPath, err)
+	}
+	return nil
+}
+
+// ValidateSnapshot comprueba que path es una base de datos SQLite íntegra con un schema
+// que esta versión sabe migrar: integrity_check, tablas básicas y migraciones aplicadas
+// consecutivas y conocidas. Retorna la versión del schema del snapshot.
+func ValidateSnapshot(ctx context.Context, path string) (int, error) {
+	// sql.Open crearía un fichero vacío si no existe
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(path, []string{"query_only(1)"}))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer db.Close()
+
+	var integrity string
+	if err := db.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&integrity); err != nil {
+		return 0, fmt.Errorf("snapshot is not a valid SQLite database: %w", err)
+	}
+	if integrity != "ok" {
+		return 0, fmt.Errorf("snapshot integrity check failed: %s", integrity)
+	}
+
+	for _, table := range requiredTables {
+		var name string
+		err := db.QueryRowContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&name)
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("snapshot is missing table %s", table)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to inspect snapshot schema: %w", err)
+		}
+	}
+
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query snapshot migrations: %w", err)
+	}
+	defer rows.Close()
+
+	version := 0
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return 0, fmt.Errorf("failed to scan snapshot migration: %w", err)
+		}
+		if v > len(migrations) {
+			return 0, fmt.Errorf("snapshot schema version %d is newer than this build (%d)", v, len(migrations))
+		}
+		if v != version+1 {
+			return 0, fmt.Errorf("snapshot migrations are not consecutive (found %d after %d)", v, version)
+		}
+		version = v
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read snapshot migrations: %w", err)
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("snapshot has no applied migrations")
+	}
+
+	return version, nil
+}
+
+// RestoreSnapshot valida snapshotPath y lo copia sobre dbPath. La base de datos actual
+// (con su WAL consolidado) se conserva como dbPath + ".pre-restore".
+// Debe ejecutarse con iot-server parado; las migraciones pendientes se aplican al arrancar.
+func RestoreSnapshot(ctx context.Context, snapshotPath, dbPath string) error {
+	if _, err := ValidateSnapshot(ctx, snapshotPath); err != nil {
+		return fmt.Errorf("invalid snapshot %s: %w", snapshotPath, err)
+	}
+
+	tmpPath := dbPath + ".restore"
+	if err := copyFile(snapshotPath, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to copy snapshot: %w", err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := checkpointWAL(ctx, dbPath); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+		if err := os.Rename(dbPath, dbPath+".pre-restore"); err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to keep current database: %w", err)
+		}
+	}
+
+	// Sin el WAL/SHM antiguos SQLite no intentará aplicarlos sobre el snapshot
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s%s: %w", dbPath, suffix, err)
+		}
+	}
+
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		return fmt.Errorf("failed to swap in snapshot: %w", err)
+	}
+	return nil
+}
+
+// checkpointWAL vuelca el WAL en el fichero principal para que la copia previa sea completa
+func checkpointWAL(ctx context.Context, dbPath string) error {
+	db, err := openSQLite(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("failed to checkpoint current database: %w", err)
+	}
+	return nil
+}
+
+// copyFile copia src en dst y sincroniza dst a disco
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
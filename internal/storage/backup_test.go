@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+func TestSQLiteRepository_BackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "sensors.db")
+	snapshotPath := filepath.Join(dir, "snapshot.db")
+	ctx := context.Background()
+
+	repo, err := NewSQLiteRepository(dbPath)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	repo.SaveConfig(ctx, &sensor.SensorConfig{SensorID: "temp-001", Interval: 5000, Threshold: 30, Enabled: true})
+	repo.SaveReading(ctx, &sensor.SensorReading{ID: "r1", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 21, Unit: "°C", Timestamp: time.Now()})
+
+	if err := repo.Backup(ctx, snapshotPath); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if err := repo.Backup(ctx, snapshotPath); err == nil {
+		t.Error("expected error when the destination already exists")
+	}
+
+	// Cambios posteriores al backup que el restore debe descartar
+	repo.SaveReading(ctx, &sensor.SensorReading{ID: "r2", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 22, Unit: "°C", Timestamp: time.Now()})
+	repo.Close()
+
+	version, err := ValidateSnapshot(ctx, snapshotPath)
+	if err != nil {
+		t.Fatalf("ValidateSnapshot failed: %v", err)
+	}
+	if migrations, _ := loadMigrations(migrationsFS); version != len(migrations) {
+		t.Errorf("expected schema version %d, got %d", len(migrations), version)
+	}
+
+	if err := RestoreSnapshot(ctx, snapshotPath, dbPath); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if _, err := os.Stat(dbPath + ".pre-restore"); err != nil {
+		t.Errorf("expected previous database to be kept: %v", err)
+	}
+
+	restored, err := NewSQLiteRepository(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open restored database: %v", err)
+	}
+	defer restored.Close()
+
+	readings, _ := restored.GetLatestReadings(ctx, "temp-001", 10)
+	if len(readings) != 1 || readings[0].ID != "r1" {
+		t.Errorf("expected only r1 after restore, got %v", readings)
+	}
+	if config, err := restored.GetConfig(ctx, "temp-001"); err != nil || config.Interval != 5000 {
+		t.Errorf("unexpected restored config: %v, %v", config, err)
+	}
+}
+
+func TestValidateSnapshot_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	notSQLite := filepath.Join(dir, "garbage.db")
+	os.WriteFile(notSQLite, []byte("definitely not a database"), 0o644)
+
+	noMigrations := filepath.Join(dir, "legacy.db")
+	db, _ := openSQLite(noMigrations)
+	db.Exec(`CREATE TABLE sensor_readings (id TEXT); CREATE TABLE sensor_configs (sensor_id TEXT)`)
+	db.Close()
+
+	newer := filepath.Join(dir, "newer.db")
+	repo, _ := NewSQLiteRepository(newer)
+	repo.db.Exec(`INSERT INTO schema_migrations (version, name) VALUES (999, 'future')`)
+	repo.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"missing file", filepath.Join(dir, "missing.db"), "failed to open snapshot"},
+		{"not a database", notSQLite, "not a valid SQLite database"},
+		{"missing schema_migrations", noMigrations, "missing table schema_migrations"},
+		{"newer schema", newer, "newer than this build"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidateSnapshot(ctx, tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// Un snapshot inválido no toca la base de datos actual
+	dbPath := filepath.Join(dir, "sensors.db")
+	os.WriteFile(dbPath, []byte("current"), 0o644)
+	if err := RestoreSnapshot(ctx, notSQLite, dbPath); err == nil {
+		t.Error("expected restore of an invalid snapshot to fail")
+	}
+	if data, _ := os.ReadFile(dbPath); string(data) != "current" {
+		t.Error("current database was modified by a failed restore")
+	}
+}
+
+func TestSQLiteRepository_BackupDoesNotBlockWriter(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+
+	repo, err := NewSQLiteRepository(filepath.Join(dir, "sensors.db"))
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+	repo.SaveReading(ctx, &sensor.SensorReading{ID: "r1", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 21, Unit: "°C", Timestamp: time.Now()})
+
+	// Una transacción abierta en el escritor único: un backup sobre esa conexión esperaría a que termine
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to begin write transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sensor_readings WHERE id = 'r1'`); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+
+	backupCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := repo.Backup(backupCtx, filepath.Join(dir, "snapshot.db")); err != nil {
+		t.Fatalf("Backup blocked on the writer connection: %v", err)
+	}
+}
@@ -0,0 +1,1256 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+// Measurements usados en el bucket de InfluxDB
+const (
+	measurementReadings = "sensor_readings"
+	measurementConfigs  = "sensor_configs"
+	measurementSensors  = "sensors"
+	measurementAlerts   = "alerts"
+)
+
+// configDeletedReason marca en sensor_configs el punto escrito por DeleteConfig: conserva
+// el historial anterior y hace que GetConfig deje de encontrar la configuración
+const configDeletedReason = "deleted"
+
+// influxEpoch es el inicio de rango para consultas y borrados "desde siempre"
+const influxEpoch = "1970-01-01T00:00:00Z"
+
+// influxEnd es el fin de rango para consultas "hasta siempre". A diferencia de now() no
+// depende del reloj del servidor de InfluxDB e incluye las lecturas con timestamp futuro
+// (relojes adelantados o importaciones).
+const influxEnd = "2262-01-01T00:00:00Z"
+
+// InfluxDBRepository implementa repository.Repository sobre la API HTTP de InfluxDB v2.
+// Escribe en line protocol y consulta con Flux. Modelo de datos:
+//   - sensor_readings: tags sensor_id/type, fields id, value, raw_value (opcional), unit y
+//     error (opcional). El id no es tag (la cardinalidad de series crecería sin límite),
+//     así que el punto de una lectura es (sensor_id, type, timestamp): SaveReading y
+//     SaveReadings sobrescriben otra lectura del mismo sensor e instante e ImportReadings
+//     la omite
+//   - sensor_configs: tag sensor_id, fields interval, threshold, low_threshold,
+//     has_low_threshold, hysteresis, max_rate, calibration (JSON, "" = sin calibración),
+//     enabled, changed_by, reason (gana el último
+//     punto; cada punto es una revisión del historial salvo los de reason "deleted", que
+//     marcan la baja del sensor)
+//   - sensors: tag sensor_id, fields type, name, location, source y tags (array JSON;
+//     gana el último punto)
+//   - alerts: tags sensor_id/type/severity, fields id, kind (opcional), value, threshold, unit, message
+//   - sensor_readings_hourly/daily: resúmenes de retención con timestamp = inicio del bucket
+//
+// InfluxDB no tiene transacciones: SaveReadings envía el lote en una única escritura
+// (InfluxDB la acepta o rechaza completa), pero ApplyRetention no es atómica.
+type InfluxDBRepository struct {
+	baseURL string
+	token   string
+	org     string
+	bucket  string
+	client  *http.Client
+}
+
+// NewInfluxDBRepository crea el repositorio y comprueba que el servidor responde en /health
+func NewInfluxDBRepository(baseURL, token, org, bucket string) (*InfluxDBRepository, error) {
+	if baseURL == "" || org == "" || bucket == "" {
+		return nil, fmt.Errorf("influxdb url, org and bucket are required")
+	}
+
+	r := &InfluxDBRepository{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		org:     org,
+		bucket:  bucket,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.ping(ctx); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// ping comprueba el estado del servidor
+func (r *InfluxDBRepository) ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL+"/health", nil)
+	if err != nil {
+		return fmt.Errorf("failed to build health request: %w", err)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to influxdb: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("influxdb health check failed: %w", apiError(resp))
+	}
+	return nil
+}
+
+// SaveReading guarda una lectura como un punto de sensor_readings
+func (r *InfluxDBRepository) SaveReading(ctx context.Context, reading *sensor.SensorReading) error {
+	if err := r.write(ctx, readingLine(reading)); err != nil {
+		return fmt.Errorf("failed to save reading %s: %w", reading.ID, err)
+	}
+	return nil
+}
+
+// SaveReadings guarda un lote de lecturas en una única petición de escritura
+func (r *InfluxDBRepository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	lines := make([]string, 0, len(readings))
+	for _, reading := range readings {
+		lines = append(lines, readingLine(reading))
+	}
+
+	if err := r.write(ctx, lines...); err != nil {
+		return fmt.Errorf("failed to save batch of %d readings: %w", len(readings), err)
+	}
+	return nil
+}
+
+// ImportReadings consulta qué lecturas existen en el rango temporal del lote y escribe el
+// resto en una única petición. Omite los ids ya guardados o repetidos y, como el punto de
+// una lectura es (sensor_id, type, timestamp), las que coinciden en sensor, tipo e instante
+// con otra guardada o anterior en el lote: InfluxDB la sobrescribiría. Un id repetido con
+// otro timestamp fuera del rango del lote no se detecta.
+func (r *InfluxDBRepository) ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]repository.SkippedReading, error) {
+	if len(readings) == 0 {
+		return nil, nil
+	}
+
+	start, stop := readings[0].Timestamp, readings[0].Timestamp
+	for _, reading := range readings[1:] {
+		if reading.Timestamp.Before(start) {
+			start = reading.Timestamp
+		}
+		if reading.Timestamp.After(stop) {
+			stop = reading.Timestamp
+		}
+	}
+
+	flux := r.from(fluxTime(start), fluxTime(stop.Add(time.Nanosecond))) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r._field == "id")
+  |> group()
+  |> keep(columns: ["_time", "_value", "sensor_id", "type"])`, fluxString(measurementReadings))
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query existing reading ids: %w", err)
+	}
+	seen := make(map[string]bool, len(rows))
+	points := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		ts, err := time.Parse(time.RFC3339Nano, row["_time"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse reading timestamp: %w", err)
+		}
+		seen[row["_value"]] = true
+		points[pointKey(row["sensor_id"], row["type"], ts)] = true
+	}
+
+	var skipped []repository.SkippedReading
+	lines := make([]string, 0, len(readings))
+	for _, reading := range readings {
+		key := pointKey(reading.SensorID, string(reading.Type), reading.Timestamp)
+		switch {
+		case seen[reading.ID]:
+			skipped = append(skipped, repository.SkippedReading{ID: reading.ID, Reason: repository.SkipDuplicateID})
+			continue
+		case points[key]:
+			skipped = append(skipped, repository.SkippedReading{ID: reading.ID, Reason: repository.SkipDuplicateTimestamp})
+			continue
+		}
+		seen[reading.ID] = true
+		points[key] = true
+		lines = append(lines, readingLine(reading))
+	}
+
+	if len(lines) > 0 {
+		if err := r.write(ctx, lines...); err != nil {
+			return nil, fmt.Errorf("failed to import batch of %d readings: %w", len(lines), err)
+		}
+	}
+	return skipped, nil
+}
+
+// GetLatestReadings obtiene las últimas N lecturas de un sensor ordenadas por timestamp descendente
+func (r *InfluxDBRepository) GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error) {
+	flux := r.from(influxEpoch, influxEnd) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.sensor_id == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"], desc: true)
+  |> limit(n: %d)`, fluxString(measurementReadings), fluxString(sensorID), limit)
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings for sensor %s: %w", sensorID, err)
+	}
+
+	return parseReadings(rows)
+}
+
+// GetReadingsPage obtiene una página de lecturas en orden (timestamp, id) descendente
+func (r *InfluxDBRepository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", limit)
+	}
+
+	stop, after := influxEnd, ""
+	if cursor != nil {
+		// range() excluye stop: se suma 1ns para incluir las lecturas del mismo instante
+		// y el filtro posterior al pivot descarta las que no van después del cursor
+		stop = fluxTime(cursor.Timestamp.Add(time.Nanosecond))
+		after = fmt.Sprintf(`
+  |> filter(fn: (r) => r._time < %s or (r._time == %s and r.id < %s))`,
+			fluxTime(cursor.Timestamp), fluxTime(cursor.Timestamp), fluxString(cursor.ID))
+	}
+
+	flux := r.from(influxEpoch, stop) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.sensor_id == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()%s
+  |> sort(columns: ["_time", "id"], desc: true)
+  |> limit(n: %d)`, fluxString(measurementReadings), fluxString(sensorID), after, limit+1)
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query readings page for sensor %s: %w", sensorID, err)
+	}
+
+	readings, err := parseReadings(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readings, next := paginate(readings, limit)
+	return readings, next, nil
+}
+
+// SearchReadings resuelve en Go los sensores cuyos metadatos cumplen la búsqueda y consulta
+// sus lecturas en una única query, en orden (timestamp, id) descendente
+func (r *InfluxDBRepository) SearchReadings(ctx context.Context, q repository.ReadingSearch) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if q.Limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", q.Limit)
+	}
+
+	sensors, err := r.ListSensors(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ids []string
+	for _, s := range sensors {
+		if q.Matches(s) {
+			ids = append(ids, fluxString(s.ID))
+		}
+	}
+	if len(ids) == 0 {
+		return []*sensor.SensorReading{}, nil, nil
+	}
+
+	start, stop, after := influxEpoch, influxEnd, ""
+	if !q.Start.IsZero() {
+		start = fluxTime(q.Start)
+	}
+	// range() excluye stop: se suma 1ns para que end y el instante del cursor sean inclusivos
+	if !q.End.IsZero() {
+		stop = fluxTime(q.End.Add(time.Nanosecond))
+	}
+	if q.Cursor != nil {
+		if q.End.IsZero() || q.Cursor.Timestamp.Before(q.End) {
+			stop = fluxTime(q.Cursor.Timestamp.Add(time.Nanosecond))
+		}
+		after = fmt.Sprintf(`
+  |> filter(fn: (r) => r._time < %s or (r._time == %s and r.id < %s))`,
+			fluxTime(q.Cursor.Timestamp), fluxTime(q.Cursor.Timestamp), fluxString(q.Cursor.ID))
+	}
+
+	flux := r.from(start, stop) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and contains(value: r.sensor_id, set: [%s]))
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()%s
+  |> sort(columns: ["_time", "id"], desc: true)
+  |> limit(n: %d)`, fluxString(measurementReadings), strings.Join(ids, ", "), after, q.Limit+1)
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search readings: %w", err)
+	}
+
+	readings, err := parseReadings(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readings, next := paginate(readings, q.Limit)
+	return readings, next, nil
+}
+
+// GetReadingsByTimeRange obtiene lecturas en el rango [start, end]
+func (r *InfluxDBRepository) GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error) {
+	// range() excluye stop, se suma 1ns para que end sea inclusivo como en SQLite
+	flux := r.from(fluxTime(start), fluxTime(end.Add(time.Nanosecond))) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.sensor_id == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"], desc: true)`, fluxString(measurementReadings), fluxString(sensorID))
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings in time range: %w", err)
+	}
+
+	return parseReadings(rows)
+}
+
+// GetReadingsRangePage obtiene una página de lecturas del rango [start, end] en orden
+// (timestamp, id) ascendente
+func (r *InfluxDBRepository) GetReadingsRangePage(ctx context.Context, sensorID string, start, end time.Time, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", limit)
+	}
+
+	after := ""
+	if cursor != nil {
+		// range() incluye start: las lecturas del mismo instante que el cursor las
+		// descarta el filtro posterior al pivot
+		if cursor.Timestamp.After(start) {
+			start = cursor.Timestamp
+		}
+		after = fmt.Sprintf(`
+  |> filter(fn: (r) => r._time > %s or (r._time == %s and r.id > %s))`,
+			fluxTime(cursor.Timestamp), fluxTime(cursor.Timestamp), fluxString(cursor.ID))
+	}
+
+	// range() excluye stop, se suma 1ns para que end sea inclusivo
+	flux := r.from(fluxTime(start), fluxTime(end.Add(time.Nanosecond))) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.sensor_id == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()%s
+  |> sort(columns: ["_time", "id"])
+  |> limit(n: %d)`, fluxString(measurementReadings), fluxString(sensorID), after, limit+1)
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query readings range page for sensor %s: %w", sensorID, err)
+	}
+
+	readings, err := parseReadings(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readings, next := paginate(readings, limit)
+	return readings, next, nil
+}
+
+// GetAggregatedReadings agrupa las lecturas válidas de un sensor en buckets alineados a epoch.
+// Las estadísticas se calculan en Go a partir de las lecturas crudas; para buckets de 1h y 1d
+// se fusionan además los resúmenes generados por la política de retención.
+func (r *InfluxDBRepository) GetAggregatedReadings(ctx context.Context, sensorID string, start, end time.Time, bucket time.Duration) ([]*sensor.ReadingAggregate, error) {
+	if bucket < time.Second {
+		return nil, fmt.Errorf("invalid bucket size: %s", bucket)
+	}
+
+	flux := r.from(fluxTime(start), fluxTime(end.Add(time.Nanosecond))) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.sensor_id == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> filter(fn: (r) => not exists r.error or r.error == "")
+  |> group()
+  |> keep(columns: ["_time", "sensor_id", "type", "value"])`, fluxString(measurementReadings), fluxString(sensorID))
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate readings for sensor %s: %w", sensorID, err)
+	}
+
+	buckets, err := bucketize(rows, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if measurement, ok := rollupTables[bucket]; ok {
+		rollups, err := r.queryRollups(ctx, measurement,
+			fmt.Sprintf(`r.sensor_id == %s`, fluxString(sensorID)),
+			start.UTC().Truncate(bucket), end.Add(time.Nanosecond))
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate readings for sensor %s: %w", sensorID, err)
+		}
+		for key, stats := range rollups {
+			buckets.merge(key, stats)
+		}
+	}
+
+	return buckets.aggregates(sensorID), nil
+}
+
+// ApplyRetention elimina las lecturas crudas que cumplen la política. Si policy.Rollup está
+// activo, antes de borrar consolida las lecturas válidas en sensor_readings_hourly/daily
+// fusionándolas con los resúmenes existentes (el punto reescrito sustituye al anterior).
+// La API de borrado solo admite predicados de igualdad, así que se borra tipo a tipo y
+// solo hasta la lectura más reciente de las leídas: una lectura posterior que llegue
+// entre la consulta y el borrado se queda para la siguiente ejecución. Las que lleguen
+// en ese intervalo con un timestamp anterior se borran sin consolidar.
+func (r *InfluxDBRepository) ApplyRetention(ctx context.Context, policy repository.RetentionPolicy) (int64, error) {
+	types, err := r.retentionTypes(ctx, policy)
+	if err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	for _, sensorType := range types {
+		n, err := r.applyRetentionToType(ctx, sensorType, policy)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
+
+// retentionTypes resuelve los tipos de sensor afectados por la política
+func (r *InfluxDBRepository) retentionTypes(ctx context.Context, policy repository.RetentionPolicy) ([]string, error) {
+	if policy.SensorType != "" {
+		return []string{string(policy.SensorType)}, nil
+	}
+
+	flux := fmt.Sprintf(`import "influxdata/influxdb/schema"
+
+schema.tagValues(bucket: %s, tag: "type", predicate: (r) => r._measurement == %s, start: %s)`,
+		fluxString(r.bucket), fluxString(measurementReadings), influxEpoch)
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reading types: %w", err)
+	}
+
+	excluded := make(map[string]bool, len(policy.ExcludeTypes))
+	for _, t := range policy.ExcludeTypes {
+		excluded[string(t)] = true
+	}
+
+	var types []string
+	for _, row := range rows {
+		if t := row["_value"]; t != "" && !excluded[t] {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+
+	return types, nil
+}
+
+// applyRetentionToType consolida (opcionalmente) y borra las lecturas expiradas de un tipo
+func (r *InfluxDBRepository) applyRetentionToType(ctx context.Context, sensorType string, policy repository.RetentionPolicy) (int64, error) {
+	stop := policy.Before.UTC()
+
+	flux := r.from(influxEpoch, fluxTime(stop)) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.type == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> keep(columns: ["_time", "sensor_id", "type", "value", "error"])`, fluxString(measurementReadings), fluxString(sensorType))
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query expired %s readings: %w", sensorType, err)
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	if policy.Rollup {
+		valid := make([]map[string]string, 0, len(rows))
+		for _, row := range rows {
+			if row["error"] == "" {
+				valid = append(valid, row)
+			}
+		}
+		for bucket, measurement := range rollupTables {
+			if err := r.rollup(ctx, measurement, sensorType, valid, bucket); err != nil {
+				return 0, fmt.Errorf("failed to roll up readings into %s: %w", measurement, err)
+			}
+		}
+	}
+
+	// delete incluye stop: se borra hasta la lectura más reciente de las leídas
+	newest := time.Unix(0, 0).UTC()
+	for _, row := range rows {
+		ts, err := time.Parse(time.RFC3339Nano, row["_time"])
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		if ts.After(newest) {
+			newest = ts
+		}
+	}
+	predicate := fmt.Sprintf(`_measurement=%q AND type=%q`, measurementReadings, sensorType)
+	if err := r.delete(ctx, time.Unix(0, 0).UTC(), newest.UTC(), predicate); err != nil {
+		return 0, fmt.Errorf("failed to purge expired %s readings: %w", sensorType, err)
+	}
+
+	return int64(len(rows)), nil
+}
+
+// rollup fusiona las lecturas válidas en los resúmenes existentes y reescribe los buckets afectados
+func (r *InfluxDBRepository) rollup(ctx context.Context, measurement, sensorType string, rows []map[string]string, bucket time.Duration) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	buckets, err := bucketize(rows, bucket)
+	if err != nil {
+		return err
+	}
+
+	var first, last int64 = math.MaxInt64, math.MinInt64
+	for key := range buckets {
+		first = min(first, key.start)
+		last = max(last, key.start)
+	}
+
+	existing, err := r.queryRollups(ctx, measurement,
+		fmt.Sprintf(`r.type == %s`, fluxString(sensorType)),
+		time.Unix(first, 0), time.Unix(last+1, 0))
+	if err != nil {
+		return err
+	}
+
+	lines := make([]string, 0, len(buckets))
+	for key, stats := range buckets {
+		if prev, ok := existing[key]; ok {
+			stats.merge(prev)
+		}
+		lines = append(lines, fmt.Sprintf("%s,sensor_id=%s,type=%s count=%di,min_value=%s,max_value=%s,sum_value=%s,sum_squares=%s %d",
+			measurement, escapeTag(key.sensorID), escapeTag(sensorType), stats.count,
+			formatFloat(stats.min), formatFloat(stats.max), formatFloat(stats.sum), formatFloat(stats.sumSquares),
+			time.Unix(key.start, 0).UnixNano()))
+	}
+	sort.Strings(lines)
+
+	return r.write(ctx, lines...)
+}
+
+// queryRollups lee los resúmenes de un measurement que cumplen filter en [start, stop)
+func (r *InfluxDBRepository) queryRollups(ctx context.Context, measurement, filter string, start, stop time.Time) (bucketMap, error) {
+	flux := r.from(fluxTime(start), fluxTime(stop)) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()`, fluxString(measurement), filter)
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(bucketMap, len(rows))
+	for _, row := range rows {
+		ts, err := time.Parse(time.RFC3339Nano, row["_time"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rollup time: %w", err)
+		}
+
+		var stats bucketStats
+		if stats.count, err = strconv.Atoi(row["count"]); err != nil {
+			return nil, fmt.Errorf("failed to parse rollup count: %w", err)
+		}
+		for field, dst := range map[string]*float64{
+			"min_value":   &stats.min,
+			"max_value":   &stats.max,
+			"sum_value":   &stats.sum,
+			"sum_squares": &stats.sumSquares,
+		} {
+			if *dst, err = strconv.ParseFloat(row[field], 64); err != nil {
+				return nil, fmt.Errorf("failed to parse rollup %s: %w", field, err)
+			}
+		}
+
+		buckets.merge(rollupKey{sensorID: row["sensor_id"], start: ts.Unix()}, &stats)
+	}
+
+	return buckets, nil
+}
+
+// SaveConfig guarda la configuración como un nuevo punto; GetConfig lee el último.
+// Cada punto es una revisión del historial, así que si la config no cambia no se escribe.
+func (r *InfluxDBRepository) SaveConfig(ctx context.Context, config *sensor.SensorConfig) error {
+	current, err := r.latestConfig(ctx, config.SensorID)
+	if err != nil {
+		return fmt.Errorf("failed to save config for sensor %s: %w", config.SensorID, err)
+	}
+	if current != nil && current.Equal(*config) {
+		return nil
+	}
+
+	fields, err := configFields(config)
+	if err != nil {
+		return fmt.Errorf("failed to save config for sensor %s: %w", config.SensorID, err)
+	}
+	change := repository.ConfigChangeFromContext(ctx)
+	line := fmt.Sprintf("%s,sensor_id=%s %s,changed_by=%s,reason=%s %d",
+		measurementConfigs, escapeTag(config.SensorID), fields,
+		fieldString(change.ChangedBy), fieldString(change.Reason),
+		time.Now().UnixNano())
+
+	if err := r.write(ctx, line); err != nil {
+		return fmt.Errorf("failed to save config for sensor %s: %w", config.SensorID, err)
+	}
+	return nil
+}
+
+// GetConfig obtiene la última configuración guardada de un sensor
+func (r *InfluxDBRepository) GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
+	config, err := r.latestConfig(ctx, sensorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get config for sensor %s: %w", sensorID, err)
+	}
+	if config == nil {
+		return nil, fmt.Errorf("config not found for sensor %s", sensorID)
+	}
+	return config, nil
+}
+
+// latestConfig lee el último punto de configuración de un sensor (nil si no existe)
+func (r *InfluxDBRepository) latestConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
+	flux := r.from(influxEpoch, influxEnd) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.sensor_id == %s)
+  |> last()
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")`, fluxString(measurementConfigs), fluxString(sensorID))
+
+	rows, err := r.query(ctx, flux)
+	if err != nil || len(rows) == 0 || rows[0]["reason"] == configDeletedReason {
+		return nil, err
+	}
+
+	return parseConfig(rows[0])
+}
+
+// DeleteConfig escribe un punto de baja con los valores actuales: GetConfig deja de
+// encontrar la configuración y el historial anterior se conserva
+func (r *InfluxDBRepository) DeleteConfig(ctx context.Context, sensorID string) error {
+	current, err := r.latestConfig(ctx, sensorID)
+	if err != nil {
+		return fmt.Errorf("failed to delete config for sensor %s: %w", sensorID, err)
+	}
+	if current == nil {
+		return nil
+	}
+
+	fields, err := configFields(current)
+	if err != nil {
+		return fmt.Errorf("failed to delete config for sensor %s: %w", sensorID, err)
+	}
+	change := repository.ConfigChangeFromContext(ctx)
+	line := fmt.Sprintf("%s,sensor_id=%s %s,changed_by=%s,reason=%s %d",
+		measurementConfigs, escapeTag(sensorID), fields,
+		fieldString(change.ChangedBy), fieldString(configDeletedReason),
+		time.Now().UnixNano())
+
+	if err := r.write(ctx, line); err != nil {
+		return fmt.Errorf("failed to delete config for sensor %s: %w", sensorID, err)
+	}
+	return nil
+}
+
+// GetConfigHistory obtiene las revisiones de configuración de un sensor, la más reciente primero.
+// La revisión es la posición del punto en orden cronológico.
+func (r *InfluxDBRepository) GetConfigHistory(ctx context.Context, sensorID string, limit int) ([]*sensor.ConfigRevision, error) {
+	flux := r.from(influxEpoch, influxEnd) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.sensor_id == %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"])`, fluxString(measurementConfigs), fluxString(sensorID))
+
+	points, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query config history for sensor %s: %w", sensorID, err)
+	}
+
+	// Los puntos de baja no son revisiones
+	rows := make([]map[string]string, 0, len(points))
+	for _, row := range points {
+		if row["reason"] != configDeletedReason {
+			rows = append(rows, row)
+		}
+	}
+
+	if limit <= 0 || limit > len(rows) {
+		limit = len(rows)
+	}
+
+	history := make([]*sensor.ConfigRevision, 0, limit)
+	for i := len(rows) - 1; i >= len(rows)-limit; i-- {
+		row := rows[i]
+		config, err := parseConfig(row)
+		if err != nil {
+			return nil, err
+		}
+		changedAt, err := time.Parse(time.RFC3339Nano, row["_time"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse config revision time: %w", err)
+		}
+
+		history = append(history, &sensor.ConfigRevision{
+			Revision:  i + 1,
+			Config:    *config,
+			ChangedBy: row["changed_by"],
+			Reason:    row["reason"],
+			ChangedAt: changedAt,
+		})
+	}
+
+	return history, nil
+}
+
+// configFields serializa los valores de una configuración como fields de line protocol.
+// low_threshold se escribe siempre (con has_low_threshold) porque GetConfig lee el último
+// valor de cada field: si se omitiera, last() devolvería el de un punto anterior.
+func configFields(config *sensor.SensorConfig) (string, error) {
+	var low float64
+	if config.LowThreshold != nil {
+		low = *config.LowThreshold
+	}
+	calibration := ""
+	if !config.Calibration.IsIdentity() {
+		data, err := json.Marshal(config.Calibration)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode calibration: %w", err)
+		}
+		calibration = string(data)
+	}
+	return fmt.Sprintf("interval=%di,threshold=%s,low_threshold=%s,has_low_threshold=%t,hysteresis=%s,max_rate=%s,calibration=%s,enabled=%t",
+		config.Interval, formatFloat(config.Threshold), formatFloat(low), config.LowThreshold != nil,
+		formatFloat(config.Hysteresis), formatFloat(config.MaxRate), fieldString(calibration), config.Enabled), nil
+}
+
+// parseConfig convierte una fila pivotada de sensor_configs en configuración. Los puntos
+// anteriores a low_threshold, hysteresis, max_rate y calibration no tienen esos fields.
+func parseConfig(row map[string]string) (*sensor.SensorConfig, error) {
+	var err error
+	config := sensor.SensorConfig{SensorID: row["sensor_id"]}
+	if config.Interval, err = strconv.Atoi(row["interval"]); err != nil {
+		return nil, fmt.Errorf("failed to parse interval for sensor %s: %w", config.SensorID, err)
+	}
+	if config.Threshold, err = strconv.ParseFloat(row["threshold"], 64); err != nil {
+		return nil, fmt.Errorf("failed to parse threshold for sensor %s: %w", config.SensorID, err)
+	}
+	if config.Enabled, err = strconv.ParseBool(row["enabled"]); err != nil {
+		return nil, fmt.Errorf("failed to parse enabled for sensor %s: %w", config.SensorID, err)
+	}
+	if row["has_low_threshold"] == "true" {
+		low, err := strconv.ParseFloat(row["low_threshold"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse low_threshold for sensor %s: %w", config.SensorID, err)
+		}
+		config.LowThreshold = &low
+	}
+	for field, v := range map[string]*float64{"hysteresis": &config.Hysteresis, "max_rate": &config.MaxRate} {
+		if row[field] == "" {
+			continue
+		}
+		if *v, err = strconv.ParseFloat(row[field], 64); err != nil {
+			return nil, fmt.Errorf("failed to parse %s for sensor %s: %w", field, config.SensorID, err)
+		}
+	}
+	if raw := row["calibration"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &config.Calibration); err != nil {
+			return nil, fmt.Errorf("failed to parse calibration for sensor %s: %w", config.SensorID, err)
+		}
+	}
+	return &config, nil
+}
+
+// SaveAlert escribe una alerta como punto de la measurement alerts
+func (r *InfluxDBRepository) SaveAlert(ctx context.Context, alert *sensor.Alert) error {
+	kind := ""
+	if alert.Kind != "" {
+		kind = ",kind=" + fieldString(string(alert.Kind))
+	}
+	line := fmt.Sprintf("%s,sensor_id=%s,type=%s,severity=%s id=%s%s,value=%s,threshold=%s,unit=%s,message=%s %d",
+		measurementAlerts, escapeTag(alert.SensorID), escapeTag(string(alert.Type)), escapeTag(string(alert.Severity)),
+		fieldString(alert.ID), kind, formatFloat(alert.Value), formatFloat(alert.Threshold),
+		fieldString(alert.Unit), fieldString(alert.Message),
+		alert.Timestamp.UnixNano())
+
+	if err := r.write(ctx, line); err != nil {
+		return fmt.Errorf("failed to save alert %s: %w", alert.ID, err)
+	}
+	return nil
+}
+
+// GetAlerts obtiene las alertas que cumplen el filtro ordenadas por timestamp descendente
+func (r *InfluxDBRepository) GetAlerts(ctx context.Context, filter repository.AlertFilter) ([]*sensor.Alert, error) {
+	start, stop := influxEpoch, influxEnd
+	if !filter.Start.IsZero() {
+		start = fluxTime(filter.Start)
+	}
+	if !filter.End.IsZero() {
+		// range() excluye stop, se suma 1ns para que End sea inclusivo
+		stop = fluxTime(filter.End.Add(time.Nanosecond))
+	}
+
+	predicate := "r._measurement == " + fluxString(measurementAlerts)
+	if filter.SensorID != "" {
+		predicate += " and r.sensor_id == " + fluxString(filter.SensorID)
+	}
+	if filter.Severity != "" {
+		predicate += " and r.severity == " + fluxString(string(filter.Severity))
+	}
+
+	flux := r.from(start, stop) + fmt.Sprintf(`
+  |> filter(fn: (r) => %s)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["_time"], desc: true)`, predicate)
+	if filter.Limit > 0 {
+		flux += fmt.Sprintf(`
+  |> limit(n: %d)`, filter.Limit)
+	}
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+
+	alerts := make([]*sensor.Alert, 0, len(rows))
+	for _, row := range rows {
+		alert := sensor.Alert{
+			ID:       row["id"],
+			SensorID: row["sensor_id"],
+			Type:     sensor.SensorType(row["type"]),
+			Kind:     sensor.AlertKind(row["kind"]),
+			Severity: sensor.AlertSeverity(row["severity"]),
+			Unit:     row["unit"],
+			Message:  row["message"],
+		}
+		if alert.Value, err = strconv.ParseFloat(row["value"], 64); err != nil {
+			return nil, fmt.Errorf("failed to parse value of alert %s: %w", alert.ID, err)
+		}
+		if alert.Threshold, err = strconv.ParseFloat(row["threshold"], 64); err != nil {
+			return nil, fmt.Errorf("failed to parse threshold of alert %s: %w", alert.ID, err)
+		}
+		if alert.Timestamp, err = time.Parse(time.RFC3339Nano, row["_time"]); err != nil {
+			return nil, fmt.Errorf("failed to parse alert timestamp: %w", err)
+		}
+		alerts = append(alerts, &alert)
+	}
+
+	return alerts, nil
+}
+
+// SaveSensor guarda los metadatos de un sensor como un nuevo punto
+func (r *InfluxDBRepository) SaveSensor(ctx context.Context, s *sensor.Sensor) error {
+	source := s.Source
+	if source == "" {
+		source = sensor.SensorSourceRegister
+	}
+	tags, err := json.Marshal(s.Tags)
+	if err != nil {
+		return fmt.Errorf("failed to encode tags of sensor %s: %w", s.ID, err)
+	}
+
+	line := fmt.Sprintf("%s,sensor_id=%s type=%s,name=%s,location=%s,source=%s,tags=%s %d",
+		measurementSensors, escapeTag(s.ID),
+		fieldString(string(s.Type)), fieldString(s.Name), fieldString(s.Location),
+		fieldString(source), fieldString(string(tags)),
+		time.Now().UnixNano())
+
+	if err := r.write(ctx, line); err != nil {
+		return fmt.Errorf("failed to save sensor %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// GetSensor obtiene los metadatos de un sensor registrado
+func (r *InfluxDBRepository) GetSensor(ctx context.Context, sensorID string) (*sensor.Sensor, error) {
+	sensors, err := r.querySensors(ctx, fmt.Sprintf(`r.sensor_id == %s`, fluxString(sensorID)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor %s: %w", sensorID, err)
+	}
+	if len(sensors) == 0 {
+		return nil, fmt.Errorf("sensor %s not found", sensorID)
+	}
+	return sensors[0], nil
+}
+
+// ListSensors obtiene todos los sensores registrados ordenados por ID
+func (r *InfluxDBRepository) ListSensors(ctx context.Context) ([]*sensor.Sensor, error) {
+	sensors, err := r.querySensors(ctx, "true")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	return sensors, nil
+}
+
+// querySensors lee el último punto de cada sensor que cumple filter
+func (r *InfluxDBRepository) querySensors(ctx context.Context, filter string) ([]*sensor.Sensor, error) {
+	flux := r.from(influxEpoch, influxEnd) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and %s)
+  |> last()
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> group()
+  |> sort(columns: ["sensor_id"])`, fluxString(measurementSensors), filter)
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return nil, err
+	}
+
+	sensors := make([]*sensor.Sensor, 0, len(rows))
+	for _, row := range rows {
+		s := &sensor.Sensor{
+			ID:       row["sensor_id"],
+			Type:     sensor.SensorType(row["type"]),
+			Name:     row["name"],
+			Location: row["location"],
+			Source:   row["source"],
+		}
+		// Los puntos anteriores a las etiquetas no tienen source ni tags
+		if s.Source == "" {
+			s.Source = sensor.SensorSourceRegister
+		}
+		if raw := row["tags"]; raw != "" {
+			if err := json.Unmarshal([]byte(raw), &s.Tags); err != nil {
+				return nil, fmt.Errorf("failed to parse tags of sensor %s: %w", s.ID, err)
+			}
+		}
+		sensors = append(sensors, s)
+	}
+	return sensors, nil
+}
+
+// DeleteSensor borra todos los puntos de metadatos del sensor (idempotente)
+func (r *InfluxDBRepository) DeleteSensor(ctx context.Context, sensorID string) error {
+	predicate := fmt.Sprintf(`_measurement=%q AND sensor_id=%q`, measurementSensors, sensorID)
+	if err := r.delete(ctx, time.Unix(0, 0).UTC(), time.Now().UTC(), predicate); err != nil {
+		return fmt.Errorf("failed to delete sensor %s: %w", sensorID, err)
+	}
+	return nil
+}
+
+// PurgeSensorData cuenta las lecturas del sensor y borra sus puntos de cada measurement.
+// Como ApplyRetention, no es atómica: cada measurement se borra en una petición.
+func (r *InfluxDBRepository) PurgeSensorData(ctx context.Context, sensorID string) (int64, error) {
+	flux := r.from(influxEpoch, influxEnd) + fmt.Sprintf(`
+  |> filter(fn: (r) => r._measurement == %s and r.sensor_id == %s and r._field == "value")
+  |> group()
+  |> count()`, fluxString(measurementReadings), fluxString(sensorID))
+
+	rows, err := r.query(ctx, flux)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count readings of sensor %s: %w", sensorID, err)
+	}
+	var deleted int64
+	if len(rows) > 0 {
+		if deleted, err = strconv.ParseInt(rows[0]["_value"], 10, 64); err != nil {
+			return 0, fmt.Errorf("failed to parse readings count: %w", err)
+		}
+	}
+
+	measurements := []string{measurementReadings, measurementAlerts, measurementConfigs}
+	for _, table := range rollupTables {
+		measurements = append(measurements, table)
+	}
+	for _, measurement := range measurements {
+		predicate := fmt.Sprintf(`_measurement=%q AND sensor_id=%q`, measurement, sensorID)
+		if err := r.delete(ctx, time.Unix(0, 0).UTC(), time.Now().UTC(), predicate); err != nil {
+			return 0, fmt.Errorf("failed to purge %s of sensor %s: %w", measurement, sensorID, err)
+		}
+	}
+
+	return deleted, nil
+}
+
+// Close libera las conexiones HTTP inactivas
+func (r *InfluxDBRepository) Close() error {
+	r.client.CloseIdleConnections()
+	return nil
+}
+
+// from genera el inicio común de las consultas Flux sobre el bucket configurado
+func (r *InfluxDBRepository) from(start, stop string) string {
+	return fmt.Sprintf(`from(bucket: %s)
+  |> range(start: %s, stop: %s)`, fluxString(r.bucket), start, stop)
+}
+
+// write envía puntos en line protocol con precisión de nanosegundos
+func (r *InfluxDBRepository) write(ctx context.Context, lines ...string) error {
+	params := url.Values{"org": {r.org}, "bucket": {r.bucket}, "precision": {"ns"}}
+	body := strings.NewReader(strings.Join(lines, "\n"))
+
+	resp, err := r.do(ctx, "/api/v2/write?"+params.Encode(), "text/plain; charset=utf-8", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+	return nil
+}
+
+// query ejecuta una consulta Flux y retorna cada fila como un mapa columna -> valor
+func (r *InfluxDBRepository) query(ctx context.Context, flux string) ([]map[string]string, error) {
+	payload, err := json.Marshal(map[string]interface{}{
+		"query": flux,
+		"type":  "flux",
+		"dialect": map[string]interface{}{
+			"header":      true,
+			"annotations": []string{},
+			"delimiter":   ",",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+
+	params := url.Values{"org": {r.org}}
+	resp, err := r.do(ctx, "/api/v2/query?"+params.Encode(), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, apiError(resp)
+	}
+
+	return parseCSV(resp.Body)
+}
+
+// delete borra los puntos en [start, stop] que cumplen predicate
+func (r *InfluxDBRepository) delete(ctx context.Context, start, stop time.Time, predicate string) error {
+	payload, err := json.Marshal(map[string]string{
+		"start":     start.Format(time.RFC3339Nano),
+		"stop":      stop.Format(time.RFC3339Nano),
+		"predicate": predicate,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode delete request: %w", err)
+	}
+
+	params := url.Values{"org": {r.org}, "bucket": {r.bucket}}
+	resp, err := r.do(ctx, "/api/v2/delete?"+params.Encode(), "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return apiError(resp)
+	}
+	return nil
+}
+
+// do envía una petición POST autenticada a la API
+func (r *InfluxDBRepository) do(ctx context.Context, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if r.token != "" {
+		req.Header.Set("Authorization", "Token "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("influxdb request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// apiError construye un error a partir de la respuesta de error de InfluxDB ({"code","message"})
+func apiError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	var apiErr struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil && apiErr.Message != "" {
+		return fmt.Errorf("influxdb returned %d (%s): %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	return fmt.Errorf("influxdb returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// parseCSV lee la respuesta CSV de una consulta Flux (sin anotaciones). Cada tabla
+// empieza con su propia cabecera y puede tener columnas distintas.
+func parseCSV(body io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+
+	var header []string
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse query response: %w", err)
+		}
+
+		// Cabecera de tabla: ",result,table,..." o ",error,reference" si la consulta falla
+		if len(record) > 1 && record[0] == "" && (record[1] == "result" || record[1] == "error") {
+			header = record
+			continue
+		}
+		if header == nil {
+			return nil, fmt.Errorf("failed to parse query response: missing header")
+		}
+		if header[1] == "error" {
+			return nil, fmt.Errorf("flux query failed: %s", record[1])
+		}
+
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			if column != "" && i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// parseReadings convierte las filas pivotadas de sensor_readings en lecturas
+func parseReadings(rows []map[string]string) ([]*sensor.SensorReading, error) {
+	readings := make([]*sensor.SensorReading, 0, len(rows))
+	for _, row := range rows {
+		reading := sensor.SensorReading{
+			ID:       row["id"],
+			SensorID: row["sensor_id"],
+			Type:     sensor.SensorType(row["type"]),
+			Unit:     row["unit"],
+		}
+
+		var err error
+		if reading.Value, err = strconv.ParseFloat(row["value"], 64); err != nil {
+			return nil, fmt.Errorf("failed to parse value of reading %s: %w", reading.ID, err)
+		}
+		if reading.Timestamp, err = time.Parse(time.RFC3339Nano, row["_time"]); err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		if msg := row["error"]; msg != "" {
+			reading.Error = &msg
+		}
+		if raw := row["raw_value"]; raw != "" {
+			rawValue, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse raw_value of reading %s: %w", reading.ID, err)
+			}
+			reading.RawValue = &rawValue
+		}
+
+		readings = append(readings, &reading)
+	}
+	return readings, nil
+}
+
+// bucketize agrupa filas con _time, sensor_id y value en buckets alineados a epoch
+func bucketize(rows []map[string]string, bucket time.Duration) (bucketMap, error) {
+	bucketSecs := int64(bucket / time.Second)
+	buckets := make(bucketMap)
+
+	for _, row := range rows {
+		ts, err := time.Parse(time.RFC3339Nano, row["_time"])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		value, err := strconv.ParseFloat(row["value"], 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse value: %w", err)
+		}
+
+		key := rollupKey{sensorID: row["sensor_id"], start: ts.Unix() / bucketSecs * bucketSecs}
+		buckets.merge(key, valueStats(value))
+	}
+
+	return buckets, nil
+}
+
+// pointKey identifica el punto de sensor_readings de una lectura: series (sensor_id, type)
+// y timestamp. El id es un field, así que dos lecturas con la misma clave son un solo punto.
+func pointKey(sensorID, sensorType string, ts time.Time) string {
+	return sensorID + "|" + sensorType + "|" + strconv.FormatInt(ts.UnixNano(), 10)
+}
+
+// readingLine serializa una lectura en line protocol
+func readingLine(reading *sensor.SensorReading) string {
+	var b strings.Builder
+	b.WriteString(measurementReadings)
+	b.WriteString(",sensor_id=")
+	b.WriteString(escapeTag(reading.SensorID))
+	if reading.Type != "" {
+		b.WriteString(",type=")
+		b.WriteString(escapeTag(string(reading.Type)))
+	}
+
+	fmt.Fprintf(&b, " id=%s,value=%s,unit=%s", fieldString(reading.ID), formatFloat(reading.Value), fieldString(reading.Unit))
+	if reading.RawValue != nil {
+		fmt.Fprintf(&b, ",raw_value=%s", formatFloat(*reading.RawValue))
+	}
+	if reading.IsError() {
+		fmt.Fprintf(&b, ",error=%s", fieldString(*reading.Error))
+	}
+	fmt.Fprintf(&b, " %d", reading.Timestamp.UnixNano())
+
+	return b.String()
+}
+
+// tagEscaper escapa comas, iguales y espacios en claves y valores de tags
+var tagEscaper = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+
+// escapeTag escapa un valor de tag para line protocol
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
+
+// fieldString serializa un field de tipo string para line protocol
+func fieldString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// formatFloat serializa un float sin pérdida de precisión
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// fluxString genera un literal string de Flux escapando comillas e interpolaciones
+func fluxString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`).Replace(s) + `"`
+}
+
+// fluxTime genera un literal de fecha de Flux en UTC
+func fluxTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
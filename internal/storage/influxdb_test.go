@@ -0,0 +1,726 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+// fakeInflux simula la API HTTP de InfluxDB v2: registra escrituras, consultas y
+// borrados, y responde a las consultas Flux con el CSV que devuelva respond.
+type fakeInflux struct {
+	t       *testing.T
+	mu      sync.Mutex
+	writes  []string
+	queries []string
+	deletes []map[string]string
+	respond func(flux string) string
+}
+
+func newFakeInflux(t *testing.T) (*fakeInflux, *InfluxDBRepository) {
+	t.Helper()
+
+	fake := &fakeInflux{t: t, respond: func(string) string { return "" }}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	repo, err := NewInfluxDBRepository(server.URL, "secret-token", "iot", "sensors")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	return fake, repo
+}
+
+func (f *fakeInflux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/health" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if got := r.Header.Get("Authorization"); got != "Token secret-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"code":"unauthorized","message":"unauthorized access"}`))
+		return
+	}
+	if r.URL.Query().Get("org") != "iot" {
+		f.t.Errorf("expected org=iot, got %q", r.URL.RawQuery)
+	}
+
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/api/v2/write":
+		if r.URL.Query().Get("bucket") != "sensors" || r.URL.Query().Get("precision") != "ns" {
+			f.t.Errorf("unexpected write params: %s", r.URL.RawQuery)
+		}
+		f.writes = append(f.writes, strings.Split(string(body), "\n")...)
+		w.WriteHeader(http.StatusNoContent)
+
+	case "/api/v2/query":
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(body, &req); err != nil {
+			f.t.Errorf("invalid query body: %v", err)
+		}
+		f.queries = append(f.queries, req.Query)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte(f.respond(req.Query)))
+
+	case "/api/v2/delete":
+		var req map[string]string
+		if err := json.Unmarshal(body, &req); err != nil {
+			f.t.Errorf("invalid delete body: %v", err)
+		}
+		f.deletes = append(f.deletes, req)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestNewInfluxDBRepository_Validation(t *testing.T) {
+	if _, err := NewInfluxDBRepository("", "token", "iot", "sensors"); err == nil {
+		t.Error("expected error for empty url")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"code":"unavailable","message":"not ready"}`))
+	}))
+	defer server.Close()
+
+	_, err := NewInfluxDBRepository(server.URL, "token", "iot", "sensors")
+	if err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Errorf("expected health check error, got %v", err)
+	}
+}
+
+func TestInfluxDBRepository_SaveReadings(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	ctx := context.Background()
+
+	errMsg := `sensor "timeout"`
+	raw := 21.75
+	ts := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	readings := []*sensor.SensorReading{
+		{ID: "r1", SensorID: "temp 001", Type: sensor.SensorTypeTemperature, Value: 22.5, Unit: "°C", Timestamp: ts},
+		{ID: "r2", SensorID: "temp 001", Type: sensor.SensorTypeTemperature, Value: 0, Unit: "°C", Error: &errMsg, Timestamp: ts.Add(time.Second)},
+		{ID: "r3", SensorID: "temp 001", Type: sensor.SensorTypeTemperature, Value: 22, RawValue: &raw, Unit: "°C", Timestamp: ts.Add(2 * time.Second)},
+	}
+
+	if err := repo.SaveReadings(ctx, readings); err != nil {
+		t.Fatalf("SaveReadings failed: %v", err)
+	}
+	if err := repo.SaveReadings(ctx, nil); err != nil {
+		t.Fatalf("SaveReadings with empty batch failed: %v", err)
+	}
+
+	expected := []string{
+		`sensor_readings,sensor_id=temp\ 001,type=temperature id="r1",value=22.5,unit="°C" 1735725600000000000`,
+		`sensor_readings,sensor_id=temp\ 001,type=temperature id="r2",value=0,unit="°C",error="sensor \"timeout\"" 1735725601000000000`,
+		`sensor_readings,sensor_id=temp\ 001,type=temperature id="r3",value=22,unit="°C",raw_value=21.75 1735725602000000000`,
+	}
+	if len(fake.writes) != len(expected) {
+		t.Fatalf("expected %d lines in a single write, got %v", len(expected), fake.writes)
+	}
+	for i, line := range expected {
+		if fake.writes[i] != line {
+			t.Errorf("line %d:\n got  %s\n want %s", i, fake.writes[i], line)
+		}
+	}
+}
+
+func TestInfluxDBRepository_GetLatestReadings(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	fake.respond = func(string) string {
+		return ",result,table,_start,_stop,_time,_measurement,sensor_id,type,error,id,raw_value,unit,value\r\n" +
+			",_result,0,1970-01-01T00:00:00Z,2025-01-02T00:00:00Z,2025-01-01T10:00:05Z,sensor_readings,temp-001,temperature,,r2,22.9,°C,23.1\r\n" +
+			",_result,0,1970-01-01T00:00:00Z,2025-01-02T00:00:00Z,2025-01-01T10:00:00Z,sensor_readings,temp-001,temperature,timeout,r1,,°C,0\r\n"
+	}
+
+	readings, err := repo.GetLatestReadings(context.Background(), "temp-001", 2)
+	if err != nil {
+		t.Fatalf("GetLatestReadings failed: %v", err)
+	}
+
+	query := fake.queries[0]
+	for _, fragment := range []string{`from(bucket: "sensors")`, `r.sensor_id == "temp-001"`, `desc: true`, `limit(n: 2)`} {
+		if !strings.Contains(query, fragment) {
+			t.Errorf("query missing %q:\n%s", fragment, query)
+		}
+	}
+
+	if len(readings) != 2 {
+		t.Fatalf("expected 2 readings, got %d", len(readings))
+	}
+	if readings[0].ID != "r2" || readings[0].Value != 23.1 || readings[0].RawValue == nil || *readings[0].RawValue != 22.9 || readings[0].Error != nil {
+		t.Errorf("unexpected first reading: %+v", readings[0])
+	}
+	if readings[1].Error == nil || *readings[1].Error != "timeout" || readings[1].RawValue != nil {
+		t.Errorf("expected error on second reading, got %+v", readings[1])
+	}
+	if !readings[1].Timestamp.Equal(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected timestamp: %v", readings[1].Timestamp)
+	}
+	if readings[0].Type != sensor.SensorTypeTemperature || readings[0].Unit != "°C" {
+		t.Errorf("unexpected type/unit: %+v", readings[0])
+	}
+}
+
+func TestInfluxDBRepository_GetReadingsByTimeRange(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	readings, err := repo.GetReadingsByTimeRange(context.Background(), "temp-001", start, end)
+	if err != nil {
+		t.Fatalf("GetReadingsByTimeRange failed: %v", err)
+	}
+	if len(readings) != 0 {
+		t.Errorf("expected no readings, got %d", len(readings))
+	}
+
+	// stop es exclusivo en Flux: end + 1ns
+	if !strings.Contains(fake.queries[0], "range(start: 2025-01-01T10:00:00Z, stop: 2025-01-01T11:00:00.000000001Z)") {
+		t.Errorf("unexpected range:\n%s", fake.queries[0])
+	}
+}
+
+func TestInfluxDBRepository_ImportReadings(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	fake.respond = func(string) string {
+		return ",result,table,_time,_value,sensor_id,type\r\n" +
+			",_result,0,2025-01-01T10:00:00Z,r1,temp-001,temperature\r\n" +
+			",_result,0,2025-01-01T10:03:00Z,legacy-9,temp-001,temperature\r\n"
+	}
+
+	ts := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	readings := []*sensor.SensorReading{
+		{ID: "r2", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 22, Unit: "°C", Timestamp: ts.Add(time.Minute)},
+		{ID: "r1", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 21, Unit: "°C", Timestamp: ts},
+		{ID: "r2", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 23, Unit: "°C", Timestamp: ts.Add(2 * time.Minute)},
+		// Mismo sensor e instante que otra lectura del lote o ya guardada: serían el mismo punto
+		{ID: "r3", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 24, Unit: "°C", Timestamp: ts.Add(time.Minute)},
+		{ID: "r4", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 25, Unit: "°C", Timestamp: ts.Add(3 * time.Minute)},
+		// Otro sensor en el mismo instante es otra serie
+		{ID: "r5", SensorID: "temp-002", Type: sensor.SensorTypeTemperature, Value: 26, Unit: "°C", Timestamp: ts.Add(time.Minute)},
+	}
+
+	skipped, err := repo.ImportReadings(context.Background(), readings)
+	if err != nil {
+		t.Fatalf("ImportReadings failed: %v", err)
+	}
+	want := []repository.SkippedReading{
+		{ID: "r1", Reason: repository.SkipDuplicateID},
+		{ID: "r2", Reason: repository.SkipDuplicateID},
+		{ID: "r3", Reason: repository.SkipDuplicateTimestamp},
+		{ID: "r4", Reason: repository.SkipDuplicateTimestamp},
+	}
+	if !reflect.DeepEqual(skipped, want) {
+		t.Errorf("expected %v skipped, got %v", want, skipped)
+	}
+
+	// Los ids existentes se buscan en el rango temporal del lote
+	if !strings.Contains(fake.queries[0], "range(start: 2025-01-01T10:00:00Z, stop: 2025-01-01T10:03:00.000000001Z)") ||
+		!strings.Contains(fake.queries[0], `r._field == "id"`) {
+		t.Errorf("unexpected existing ids query:\n%s", fake.queries[0])
+	}
+	if len(fake.writes) != 2 || !strings.Contains(fake.writes[0], `id="r2",value=22`) || !strings.Contains(fake.writes[1], `id="r5"`) {
+		t.Errorf("expected only the first r2 and r5 to be written, got %v", fake.writes)
+	}
+}
+
+func TestInfluxDBRepository_SaveAndGetConfig(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	ctx := context.Background()
+
+	config := &sensor.SensorConfig{SensorID: "temp-001", Interval: 5000, Threshold: 30.5, Enabled: true}
+	changeCtx := repository.WithConfigChange(ctx, "alice", "set")
+	if err := repo.SaveConfig(changeCtx, config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	if !strings.HasPrefix(fake.writes[0], `sensor_configs,sensor_id=temp-001 interval=5000i,threshold=30.5,low_threshold=0,has_low_threshold=false,hysteresis=0,max_rate=0,calibration="",enabled=true,changed_by="alice",reason="set" `) {
+		t.Errorf("unexpected config line: %s", fake.writes[0])
+	}
+
+	fake.respond = func(string) string {
+		return ",result,table,_start,_stop,_time,_measurement,sensor_id,changed_by,enabled,interval,reason,threshold\r\n" +
+			",_result,0,1970-01-01T00:00:00Z,2025-01-02T00:00:00Z,2025-01-01T10:00:00Z,sensor_configs,temp-001,alice,true,5000,set,30.5\r\n"
+	}
+	got, err := repo.GetConfig(ctx, "temp-001")
+	if err != nil {
+		t.Fatalf("GetConfig failed: %v", err)
+	}
+	if *got != *config {
+		t.Errorf("expected %+v, got %+v", config, got)
+	}
+	if !strings.Contains(fake.queries[1], "last()") {
+		t.Errorf("expected last() in config query:\n%s", fake.queries[1])
+	}
+
+	// Guardar la misma config no genera un punto (revisión) nuevo
+	if err := repo.SaveConfig(ctx, config); err != nil {
+		t.Fatalf("SaveConfig (unchanged) failed: %v", err)
+	}
+	if len(fake.writes) != 1 {
+		t.Errorf("expected unchanged config to be skipped, got %d writes", len(fake.writes))
+	}
+
+	fake.respond = func(string) string { return "" }
+	if _, err := repo.GetConfig(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+}
+
+func TestInfluxDBRepository_GetConfigHistory(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	fake.respond = func(string) string {
+		return ",result,table,_time,sensor_id,changed_by,enabled,interval,reason,threshold\r\n" +
+			",_result,0,2025-01-01T10:00:00Z,temp-001,system,true,5000,,30\r\n" +
+			",_result,0,2025-01-01T11:00:00Z,temp-001,alice,true,2000,set,28\r\n" +
+			",_result,0,2025-01-01T12:00:00Z,temp-001,bob,false,2000,set,28\r\n"
+	}
+
+	history, err := repo.GetConfigHistory(context.Background(), "temp-001", 2)
+	if err != nil {
+		t.Fatalf("GetConfigHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(history))
+	}
+	if history[0].Revision != 3 || history[0].ChangedBy != "bob" || history[0].Config.Enabled {
+		t.Errorf("unexpected latest revision: %+v", history[0])
+	}
+	if history[1].Revision != 2 || history[1].Config.Interval != 2000 || history[1].Reason != "set" {
+		t.Errorf("unexpected previous revision: %+v", history[1])
+	}
+	if !history[1].ChangedAt.Equal(time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected changed_at: %v", history[1].ChangedAt)
+	}
+}
+
+func TestInfluxDBRepository_Sensors(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	ctx := context.Background()
+
+	s := &sensor.Sensor{ID: "temp-001", Type: sensor.SensorTypeTemperature, Name: `Sala "A"`, Location: "planta 1", Tags: []string{"critico"}}
+	if err := repo.SaveSensor(ctx, s); err != nil {
+		t.Fatalf("SaveSensor failed: %v", err)
+	}
+	if !strings.HasPrefix(fake.writes[0], `sensors,sensor_id=temp-001 type="temperature",name="Sala \"A\"",location="planta 1",source="register",tags="[\"critico\"]" `) {
+		t.Errorf("unexpected sensor line: %s", fake.writes[0])
+	}
+
+	fake.respond = func(string) string {
+		return ",result,table,_start,_stop,_time,_measurement,sensor_id,location,name,source,tags,type\r\n" +
+			",_result,0,1970-01-01T00:00:00Z,2025-01-02T00:00:00Z,2025-01-01T10:00:00Z,sensors,hum-001,,Humedad,,,humidity\r\n" +
+			",_result,0,1970-01-01T00:00:00Z,2025-01-02T00:00:00Z,2025-01-01T10:00:00Z,sensors,temp-001,planta 1,\"Sala \"\"A\"\"\",register,\"[\"\"critico\"\"]\",temperature\r\n"
+	}
+	sensors, err := repo.ListSensors(ctx)
+	if err != nil {
+		t.Fatalf("ListSensors failed: %v", err)
+	}
+	s.Source = sensor.SensorSourceRegister
+	if len(sensors) != 2 || !reflect.DeepEqual(sensors[1], s) || sensors[0].ID != "hum-001" || sensors[0].Source != sensor.SensorSourceRegister {
+		t.Errorf("unexpected sensors: %+v %+v", sensors[0], sensors[1])
+	}
+
+	fake.respond = func(string) string { return "" }
+	if _, err := repo.GetSensor(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	if err := repo.DeleteSensor(ctx, "temp-001"); err != nil {
+		t.Fatalf("DeleteSensor failed: %v", err)
+	}
+	if len(fake.deletes) != 1 || fake.deletes[0]["predicate"] != `_measurement="sensors" AND sensor_id="temp-001"` {
+		t.Errorf("unexpected delete: %v", fake.deletes)
+	}
+}
+
+func TestInfluxDBRepository_GetAggregatedReadings(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+
+	// Lecturas crudas en 10:00 y 11:00 + resumen consolidado de 09:00 y 10:00
+	fake.respond = func(flux string) string {
+		if strings.Contains(flux, `"sensor_readings_hourly"`) {
+			return ",result,table,_time,sensor_id,type,count,max_value,min_value,sum_squares,sum_value\r\n" +
+				",_result,0,2025-01-01T09:00:00Z,temp-001,temperature,2,12,8,208,20\r\n" +
+				",_result,0,2025-01-01T10:00:00Z,temp-001,temperature,1,30,30,900,30\r\n"
+		}
+		return ",result,table,_time,sensor_id,type,value\r\n" +
+			",_result,0,2025-01-01T10:15:00Z,temp-001,temperature,10\r\n" +
+			",_result,0,2025-01-01T10:45:00Z,temp-001,temperature,20\r\n" +
+			",_result,0,2025-01-01T11:30:00Z,temp-001,temperature,5\r\n"
+	}
+
+	start := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	aggs, err := repo.GetAggregatedReadings(context.Background(), "temp-001", start, end, time.Hour)
+	if err != nil {
+		t.Fatalf("GetAggregatedReadings failed: %v", err)
+	}
+
+	if !strings.Contains(fake.queries[0], `not exists r.error`) {
+		t.Errorf("expected error readings to be excluded:\n%s", fake.queries[0])
+	}
+	if len(aggs) != 3 {
+		t.Fatalf("expected 3 buckets, got %d", len(aggs))
+	}
+
+	// 10:00 = crudas (10, 20) + resumen (30)
+	mid := aggs[1]
+	if !mid.BucketStart.Equal(time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected bucket order: %v", mid.BucketStart)
+	}
+	if mid.Count != 3 || mid.Min != 10 || mid.Max != 30 || mid.Avg != 20 {
+		t.Errorf("unexpected merged bucket: %+v", mid)
+	}
+	if aggs[0].Count != 2 || aggs[0].Avg != 10 || aggs[0].StdDev != 2 {
+		t.Errorf("unexpected rollup bucket: %+v", aggs[0])
+	}
+	if aggs[2].Count != 1 || aggs[2].Avg != 5 {
+		t.Errorf("unexpected last bucket: %+v", aggs[2])
+	}
+
+	// Los buckets de 5m no consultan resúmenes
+	fake.queries = nil
+	if _, err := repo.GetAggregatedReadings(context.Background(), "temp-001", start, end, 5*time.Minute); err != nil {
+		t.Fatalf("GetAggregatedReadings (5m) failed: %v", err)
+	}
+	if len(fake.queries) != 1 {
+		t.Errorf("expected a single query for 5m buckets, got %d", len(fake.queries))
+	}
+}
+
+func TestInfluxDBRepository_ApplyRetention(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+
+	fake.respond = func(flux string) string {
+		switch {
+		case strings.Contains(flux, "schema.tagValues"):
+			return ",result,table,_value\r\n,_result,0,humidity\r\n,_result,0,pressure\r\n,_result,0,temperature\r\n"
+		case strings.Contains(flux, `"sensor_readings_daily"`):
+			return ",result,table,_time,sensor_id,type,count,max_value,min_value,sum_squares,sum_value\r\n" +
+				",_result,0,2025-01-01T00:00:00Z,temp-001,temperature,1,40,40,1600,40\r\n"
+		case strings.Contains(flux, `"sensor_readings_hourly"`):
+			return ""
+		case strings.Contains(flux, `r.type == "temperature"`):
+			return ",result,table,_time,sensor_id,type,error,value\r\n" +
+				",_result,0,2025-01-01T10:15:00Z,temp-001,temperature,,10\r\n" +
+				",_result,0,2025-01-01T10:20:00Z,temp-001,temperature,timeout,0\r\n" +
+				",_result,0,2025-01-01T11:00:00Z,temp-001,temperature,,20\r\n"
+		default:
+			return ""
+		}
+	}
+
+	before := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	deleted, err := repo.ApplyRetention(context.Background(), repository.RetentionPolicy{
+		ExcludeTypes: []sensor.SensorType{sensor.SensorTypePressure},
+		Before:       before,
+		Rollup:       true,
+	})
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 deleted readings, got %d", deleted)
+	}
+
+	// Solo temperature tenía lecturas expiradas; pressure está excluido. El borrado llega
+	// hasta la lectura más reciente leída (11:00), no hasta Before.
+	if len(fake.deletes) != 1 {
+		t.Fatalf("expected 1 delete, got %v", fake.deletes)
+	}
+	del := fake.deletes[0]
+	if del["predicate"] != `_measurement="sensor_readings" AND type="temperature"` || del["stop"] != "2025-01-01T11:00:00Z" {
+		t.Errorf("unexpected delete: %v", del)
+	}
+	for _, q := range fake.queries {
+		if strings.Contains(q, `"pressure"`) {
+			t.Errorf("excluded type was queried:\n%s", q)
+		}
+	}
+
+	writes := strings.Join(fake.writes, "\n")
+	for _, line := range []string{
+		// Horario: 10:00 (10) y 11:00 (20), la lectura con error no se consolida
+		"sensor_readings_hourly,sensor_id=temp-001,type=temperature count=1i,min_value=10,max_value=10,sum_value=10,sum_squares=100 1735725600000000000",
+		"sensor_readings_hourly,sensor_id=temp-001,type=temperature count=1i,min_value=20,max_value=20,sum_value=20,sum_squares=400 1735729200000000000",
+		// Diario: fusionado con el resumen existente (40)
+		"sensor_readings_daily,sensor_id=temp-001,type=temperature count=3i,min_value=10,max_value=40,sum_value=70,sum_squares=2100 1735689600000000000",
+	} {
+		if !strings.Contains(writes, line) {
+			t.Errorf("missing rollup line %s in:\n%s", line, writes)
+		}
+	}
+}
+
+func TestInfluxDBRepository_APIErrors(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	repo.token = "wrong-token"
+
+	err := repo.SaveReading(context.Background(), &sensor.SensorReading{ID: "r1", SensorID: "temp-001", Unit: "°C", Timestamp: time.Now()})
+	if err == nil || !strings.Contains(err.Error(), "unauthorized access") {
+		t.Errorf("expected unauthorized error, got %v", err)
+	}
+
+	repo.token = "secret-token"
+	fake.respond = func(string) string {
+		return ",error,reference\r\n,\"compilation failed: undefined identifier\",\r\n"
+	}
+	if _, err := repo.GetLatestReadings(context.Background(), "temp-001", 1); err == nil || !strings.Contains(err.Error(), "compilation failed") {
+		t.Errorf("expected flux error, got %v", err)
+	}
+}
+
+func TestInfluxDBRepository_Alerts(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	ctx := context.Background()
+
+	ts := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	alert := &sensor.Alert{
+		ID:        "alert-1",
+		SensorID:  "temp-001",
+		Type:      sensor.SensorTypeTemperature,
+		Severity:  sensor.AlertSeverityCritical,
+		Value:     40.5,
+		Threshold: 30,
+		Unit:      "°C",
+		Message:   "Sensor temp-001 exceeded threshold",
+		Timestamp: ts,
+	}
+	if err := repo.SaveAlert(ctx, alert); err != nil {
+		t.Fatalf("SaveAlert failed: %v", err)
+	}
+	want := `alerts,sensor_id=temp-001,type=temperature,severity=critical id="alert-1",value=40.5,threshold=30,unit="°C",message="Sensor temp-001 exceeded threshold" 1735725600000000000`
+	if fake.writes[0] != want {
+		t.Errorf("unexpected alert line:\n got %s\nwant %s", fake.writes[0], want)
+	}
+
+	fake.respond = func(string) string {
+		return ",result,table,_time,sensor_id,type,severity,id,value,threshold,unit,message\r\n" +
+			",_result,0,2025-01-01T10:00:00Z,temp-001,temperature,critical,alert-1,40.5,30,°C,Sensor temp-001 exceeded threshold\r\n"
+	}
+	alerts, err := repo.GetAlerts(ctx, repository.AlertFilter{
+		SensorID: "temp-001",
+		Severity: sensor.AlertSeverityCritical,
+		Start:    ts.Add(-time.Hour),
+		Limit:    5,
+	})
+	if err != nil {
+		t.Fatalf("GetAlerts failed: %v", err)
+	}
+	if len(alerts) != 1 || *alerts[0] != *alert {
+		t.Errorf("expected %+v, got %v", alert, alerts)
+	}
+
+	query := fake.queries[0]
+	for _, fragment := range []string{`r.sensor_id == "temp-001"`, `r.severity == "critical"`, "start: 2025-01-01T09:00:00Z", "stop: 2262-01-01T00:00:00Z", "limit(n: 5)"} {
+		if !strings.Contains(query, fragment) {
+			t.Errorf("expected %q in alerts query:\n%s", fragment, query)
+		}
+	}
+}
+
+func TestInfluxDBRepository_GetReadingsPage(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	fake.respond = func(string) string {
+		return ",result,table,_time,sensor_id,type,id,unit,value\r\n" +
+			",_result,0,2025-01-01T10:00:02Z,temp-001,temperature,r3,°C,23\r\n" +
+			",_result,0,2025-01-01T10:00:01Z,temp-001,temperature,r2,°C,22\r\n" +
+			",_result,0,2025-01-01T10:00:01Z,temp-001,temperature,r1,°C,21\r\n"
+	}
+
+	cursor := &repository.ReadingCursor{Timestamp: time.Date(2025, 1, 1, 10, 0, 3, 0, time.UTC), ID: "r4"}
+	readings, next, err := repo.GetReadingsPage(context.Background(), "temp-001", cursor, 2)
+	if err != nil {
+		t.Fatalf("GetReadingsPage failed: %v", err)
+	}
+	if len(readings) != 2 || readings[0].ID != "r3" || readings[1].ID != "r2" {
+		t.Errorf("unexpected page: %v", readings)
+	}
+	if next == nil || next.ID != "r2" || !next.Timestamp.Equal(time.Date(2025, 1, 1, 10, 0, 1, 0, time.UTC)) {
+		t.Errorf("unexpected next cursor: %+v", next)
+	}
+
+	query := fake.queries[0]
+	for _, fragment := range []string{
+		"stop: 2025-01-01T10:00:03.000000001Z",
+		`r._time < 2025-01-01T10:00:03Z or (r._time == 2025-01-01T10:00:03Z and r.id < "r4")`,
+		`sort(columns: ["_time", "id"], desc: true)`,
+		"limit(n: 3)",
+	} {
+		if !strings.Contains(query, fragment) {
+			t.Errorf("expected %q in page query:\n%s", fragment, query)
+		}
+	}
+
+	// Última página: sin cursor siguiente
+	fake.respond = func(string) string {
+		return ",result,table,_time,sensor_id,type,id,unit,value\r\n" +
+			",_result,0,2025-01-01T10:00:00Z,temp-001,temperature,r0,°C,20\r\n"
+	}
+	if _, next, _ := repo.GetReadingsPage(context.Background(), "temp-001", nil, 2); next != nil {
+		t.Errorf("expected no next cursor on the last page, got %+v", next)
+	}
+}
+
+func TestInfluxDBRepository_GetReadingsRangePage(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	fake.respond = func(string) string {
+		return ",result,table,_time,sensor_id,type,id,unit,value\r\n" +
+			",_result,0,2025-01-01T10:00:03Z,temp-001,temperature,r5,°C,23\r\n" +
+			",_result,0,2025-03-01T10:00:00Z,temp-001,temperature,r6,°C,22\r\n" +
+			",_result,0,2025-03-01T10:00:00Z,temp-001,temperature,r7,°C,21\r\n"
+	}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)
+	cursor := &repository.ReadingCursor{Timestamp: time.Date(2025, 1, 1, 10, 0, 3, 0, time.UTC), ID: "r4"}
+	readings, next, err := repo.GetReadingsRangePage(context.Background(), "temp-001", start, end, cursor, 2)
+	if err != nil {
+		t.Fatalf("GetReadingsRangePage failed: %v", err)
+	}
+	if len(readings) != 2 || readings[0].ID != "r5" || readings[1].ID != "r6" {
+		t.Errorf("unexpected page: %v", readings)
+	}
+	if next == nil || next.ID != "r6" {
+		t.Errorf("unexpected next cursor: %+v", next)
+	}
+
+	query := fake.queries[0]
+	for _, fragment := range []string{
+		"start: 2025-01-01T10:00:03Z",
+		"stop: 2025-12-31T00:00:00.000000001Z",
+		`r._time > 2025-01-01T10:00:03Z or (r._time == 2025-01-01T10:00:03Z and r.id > "r4")`,
+		`sort(columns: ["_time", "id"])`,
+		"limit(n: 3)",
+	} {
+		if !strings.Contains(query, fragment) {
+			t.Errorf("expected %q in range page query:\n%s", fragment, query)
+		}
+	}
+}
+
+func TestInfluxDBRepository_SearchReadings(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	fake.respond = func(flux string) string {
+		if strings.Contains(flux, `"sensors"`) && !strings.Contains(flux, "contains(") {
+			return ",result,table,_time,sensor_id,location,name,source,tags,type\r\n" +
+				",_result,0,2025-01-01T10:00:00Z,temp-001,almacen,,register,\"[\"\"critico\"\"]\",temperature\r\n" +
+				",_result,0,2025-01-01T10:00:00Z,temp-002,oficina,,register,,temperature\r\n" +
+				",_result,0,2025-01-01T10:00:00Z,temp-003,almacen,,config,,temperature\r\n"
+		}
+		return ",result,table,_time,sensor_id,type,id,unit,value\r\n" +
+			",_result,0,2025-01-01T10:00:02Z,temp-003,temperature,r3,°C,23\r\n" +
+			",_result,0,2025-01-01T10:00:01Z,temp-001,temperature,r2,°C,22\r\n"
+	}
+
+	query := repository.ReadingSearch{
+		Type:     sensor.SensorTypeTemperature,
+		Location: "almacen",
+		Start:    time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC),
+		Cursor:   &repository.ReadingCursor{Timestamp: time.Date(2025, 1, 1, 10, 0, 3, 0, time.UTC), ID: "r4"},
+		Limit:    1,
+	}
+	readings, next, err := repo.SearchReadings(context.Background(), query)
+	if err != nil {
+		t.Fatalf("SearchReadings failed: %v", err)
+	}
+	if len(readings) != 1 || readings[0].ID != "r3" || next == nil || next.ID != "r3" {
+		t.Errorf("unexpected page: %v (next %+v)", readings, next)
+	}
+
+	flux := fake.queries[1]
+	for _, fragment := range []string{
+		"start: 2025-01-01T09:00:00Z, stop: 2025-01-01T10:00:03.000000001Z",
+		`contains(value: r.sensor_id, set: ["temp-001", "temp-003"])`,
+		`r.id < "r4"`,
+		"limit(n: 2)",
+	} {
+		if !strings.Contains(flux, fragment) {
+			t.Errorf("expected %q in search query:\n%s", fragment, flux)
+		}
+	}
+
+	// Ningún sensor cumple las etiquetas: no se consultan lecturas
+	query.Tags = []string{"critico", "exterior"}
+	readings, _, err = repo.SearchReadings(context.Background(), query)
+	if err != nil || len(readings) != 0 || len(fake.queries) != 3 {
+		t.Errorf("expected an empty result without a readings query, got %v (%v, %d queries)", readings, err, len(fake.queries))
+	}
+}
+
+func TestInfluxDBRepository_DeleteConfigAndPurge(t *testing.T) {
+	fake, repo := newFakeInflux(t)
+	ctx := context.Background()
+
+	configRows := ",result,table,_time,sensor_id,changed_by,enabled,interval,reason,threshold\r\n" +
+		",_result,0,2025-01-01T10:00:00Z,temp-001,alice,true,5000,set,30\r\n"
+	fake.respond = func(string) string { return configRows }
+
+	if err := repo.DeleteConfig(repository.WithConfigChange(ctx, "bob", "remove"), "temp-001"); err != nil {
+		t.Fatalf("DeleteConfig failed: %v", err)
+	}
+	if !strings.HasPrefix(fake.writes[0], `sensor_configs,sensor_id=temp-001 interval=5000i,threshold=30,low_threshold=0,has_low_threshold=false,hysteresis=0,max_rate=0,calibration="",enabled=true,changed_by="bob",reason="deleted" `) {
+		t.Errorf("unexpected tombstone line: %s", fake.writes[0])
+	}
+
+	// Tras la baja GetConfig no encuentra la config y el historial ignora el punto de baja
+	configRows += ",_result,0,2025-01-01T11:00:00Z,temp-001,bob,true,5000,deleted,30\r\n"
+	fake.respond = func(flux string) string {
+		if strings.Contains(flux, "last()") {
+			return ",result,table,_time,sensor_id,changed_by,enabled,interval,reason,threshold\r\n" +
+				",_result,0,2025-01-01T11:00:00Z,temp-001,bob,true,5000,deleted,30\r\n"
+		}
+		return configRows
+	}
+	if _, err := repo.GetConfig(ctx, "temp-001"); err == nil {
+		t.Error("expected deleted config to be not found")
+	}
+	if history, _ := repo.GetConfigHistory(ctx, "temp-001", 0); len(history) != 1 || history[0].Reason != "set" {
+		t.Errorf("expected only the set revision in history, got %v", history)
+	}
+
+	fake.respond = func(string) string {
+		return ",result,table,_value\r\n,_result,0,42\r\n"
+	}
+	deleted, err := repo.PurgeSensorData(ctx, "temp-001")
+	if err != nil {
+		t.Fatalf("PurgeSensorData failed: %v", err)
+	}
+	if deleted != 42 {
+		t.Errorf("expected 42 purged readings, got %d", deleted)
+	}
+
+	purged := map[string]bool{}
+	for _, req := range fake.deletes {
+		if !strings.Contains(req["predicate"], `sensor_id="temp-001"`) {
+			t.Errorf("unexpected delete predicate: %s", req["predicate"])
+		}
+		purged[req["predicate"]] = true
+	}
+	for _, measurement := range []string{"sensor_readings", "sensor_readings_hourly", "sensor_readings_daily", "alerts", "sensor_configs"} {
+		if !purged[`_measurement="`+measurement+`" AND sensor_id="temp-001"`] {
+			t.Errorf("expected %s to be purged, got %v", measurement, fake.deletes)
+		}
+	}
+}
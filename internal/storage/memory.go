@@ -0,0 +1,827 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+// defaultMemoryCapacity es el número de lecturas por sensor si no se configura otro
+const defaultMemoryCapacity = 1000
+
+// MemoryRepository implementa repository.Repository en memoria, pensado para gateways
+// edge sin disco y para tests de consumidores. Conserva las últimas N lecturas de cada
+// sensor en un ring buffer (las más antiguas se descartan) y las configs en un mapa.
+// Las alertas se acotan igualmente a las últimas N por sensor.
+// Es seguro para uso concurrente. Si se indica snapshotPath, el estado se restaura del
+// fichero al crear el repositorio y se vuelca en él al cerrarlo.
+type MemoryRepository struct {
+	mu           sync.RWMutex
+	capacity     int
+	snapshotPath string
+	readings     map[string]*readingRing
+	ids          map[string]bool // Ids de las lecturas que siguen en algún ring
+	duplicates   int64           // Lecturas ignoradas por SaveReading/SaveReadings por id repetido
+	configs      map[string]sensor.SensorConfig
+	history      map[string][]sensor.ConfigRevision // Revisiones por sensor, de la más antigua a la más reciente
+	sensors      map[string]sensor.Sensor
+	alerts       map[string][]sensor.Alert   // Alertas por sensor en orden de llegada
+	rollups      map[time.Duration]bucketMap // Resúmenes de retención (1h, 1d)
+}
+
+// NewMemoryRepository crea un repositorio en memoria con capacity lecturas por sensor
+// (<= 0 usa el valor por defecto). snapshotPath vacío desactiva la persistencia.
+func NewMemoryRepository(capacity int, snapshotPath string) (*MemoryRepository, error) {
+	if capacity <= 0 {
+		capacity = defaultMemoryCapacity
+	}
+
+	r := &MemoryRepository{
+		capacity:     capacity,
+		snapshotPath: snapshotPath,
+		readings:     make(map[string]*readingRing),
+		ids:          make(map[string]bool),
+		configs:      make(map[string]sensor.SensorConfig),
+		history:      make(map[string][]sensor.ConfigRevision),
+		sensors:      make(map[string]sensor.Sensor),
+		alerts:       make(map[string][]sensor.Alert),
+		rollups:      make(map[time.Duration]bucketMap, len(rollupTables)),
+	}
+	for bucket := range rollupTables {
+		r.rollups[bucket] = make(bucketMap)
+	}
+
+	if snapshotPath != "" {
+		if err := r.loadSnapshot(); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// SaveReading guarda una copia de la lectura en el ring buffer de su sensor. Si el id
+// ya está guardado la lectura se ignora y se cuenta como duplicada.
+func (r *MemoryRepository) SaveReading(ctx context.Context, reading *sensor.SensorReading) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.push(reading) {
+		r.duplicates++
+	}
+	return nil
+}
+
+// SaveReadings guarda un lote de lecturas bajo un único lock
+func (r *MemoryRepository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, reading := range readings {
+		if !r.push(reading) {
+			r.duplicates++
+		}
+	}
+	return nil
+}
+
+// ImportReadings guarda bajo un único lock las lecturas cuyo id no está guardado ni
+// repetido en el lote
+func (r *MemoryRepository) ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]repository.SkippedReading, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var skipped []repository.SkippedReading
+	for _, reading := range readings {
+		if !r.push(reading) {
+			skipped = append(skipped, repository.SkippedReading{ID: reading.ID, Reason: repository.SkipDuplicateID})
+		}
+	}
+	return skipped, nil
+}
+
+// DuplicateReadings retorna cuántas lecturas han ignorado SaveReading/SaveReadings por
+// tener un id ya guardado
+func (r *MemoryRepository) DuplicateReadings() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.duplicates
+}
+
+// push añade una lectura al ring de su sensor; false si su id ya está guardado (requiere r.mu)
+func (r *MemoryRepository) push(reading *sensor.SensorReading) bool {
+	if r.ids[reading.ID] {
+		return false
+	}
+
+	ring, ok := r.readings[reading.SensorID]
+	if !ok {
+		ring = newReadingRing(r.capacity)
+		r.readings[reading.SensorID] = ring
+	}
+	if evicted := ring.push(copyReading(reading)); evicted != nil {
+		delete(r.ids, evicted.ID)
+	}
+	r.ids[reading.ID] = true
+	return true
+}
+
+// GetLatestReadings obtiene las últimas N lecturas de un sensor ordenadas por timestamp descendente
+func (r *MemoryRepository) GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error) {
+	readings := r.collect(sensorID, func(*sensor.SensorReading) bool { return true })
+	if limit >= 0 && len(readings) > limit {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+// GetReadingsPage obtiene una página de lecturas en orden (timestamp, id) descendente
+func (r *MemoryRepository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", limit)
+	}
+
+	readings := r.collect(sensorID, func(reading *sensor.SensorReading) bool {
+		return cursor == nil || cursor.Before(reading)
+	})
+	// collect ordena solo por timestamp; el id desempata como en el resto de backends
+	sort.SliceStable(readings, func(i, j int) bool {
+		if readings[i].Timestamp.Equal(readings[j].Timestamp) {
+			return readings[i].ID > readings[j].ID
+		}
+		return readings[i].Timestamp.After(readings[j].Timestamp)
+	})
+	if len(readings) > limit+1 {
+		readings = readings[:limit+1]
+	}
+
+	readings, next := paginate(readings, limit)
+	return readings, next, nil
+}
+
+// GetReadingsRangePage obtiene una página de lecturas del rango [start, end] en orden
+// (timestamp, id) ascendente
+func (r *MemoryRepository) GetReadingsRangePage(ctx context.Context, sensorID string, start, end time.Time, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", limit)
+	}
+
+	readings := r.collect(sensorID, func(reading *sensor.SensorReading) bool {
+		if reading.Timestamp.Before(start) || reading.Timestamp.After(end) {
+			return false
+		}
+		return cursor == nil || cursor.After(reading)
+	})
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].Timestamp.Equal(readings[j].Timestamp) {
+			return readings[i].ID < readings[j].ID
+		}
+		return readings[i].Timestamp.Before(readings[j].Timestamp)
+	})
+	if len(readings) > limit+1 {
+		readings = readings[:limit+1]
+	}
+
+	readings, next := paginate(readings, limit)
+	return readings, next, nil
+}
+
+// SearchReadings obtiene una página de lecturas de los sensores cuyos metadatos cumplen
+// la búsqueda, en orden (timestamp, id) descendente
+func (r *MemoryRepository) SearchReadings(ctx context.Context, q repository.ReadingSearch) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if q.Limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", q.Limit)
+	}
+
+	r.mu.RLock()
+	var readings []*sensor.SensorReading
+	for id, s := range r.sensors {
+		ring, ok := r.readings[id]
+		if !ok || !q.Matches(&s) {
+			continue
+		}
+		ring.each(func(reading *sensor.SensorReading) {
+			if !q.Start.IsZero() && reading.Timestamp.Before(q.Start) {
+				return
+			}
+			if !q.End.IsZero() && reading.Timestamp.After(q.End) {
+				return
+			}
+			if q.Cursor == nil || q.Cursor.Before(reading) {
+				readings = append(readings, copyReading(reading))
+			}
+		})
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].Timestamp.Equal(readings[j].Timestamp) {
+			return readings[i].ID > readings[j].ID
+		}
+		return readings[i].Timestamp.After(readings[j].Timestamp)
+	})
+	if len(readings) > q.Limit+1 {
+		readings = readings[:q.Limit+1]
+	}
+
+	readings, next := paginate(readings, q.Limit)
+	return readings, next, nil
+}
+
+// GetReadingsByTimeRange obtiene las lecturas en el rango [start, end]
+func (r *MemoryRepository) GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error) {
+	return r.collect(sensorID, func(reading *sensor.SensorReading) bool {
+		return !reading.Timestamp.Before(start) && !reading.Timestamp.After(end)
+	}), nil
+}
+
+// collect copia las lecturas de un sensor que cumplen match, ordenadas por timestamp descendente
+func (r *MemoryRepository) collect(sensorID string, match func(*sensor.SensorReading) bool) []*sensor.SensorReading {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ring, ok := r.readings[sensorID]
+	if !ok {
+		return nil
+	}
+
+	var readings []*sensor.SensorReading
+	ring.each(func(reading *sensor.SensorReading) {
+		if match(reading) {
+			readings = append(readings, copyReading(reading))
+		}
+	})
+
+	// Los workers pueden entregar lecturas fuera de orden
+	sort.SliceStable(readings, func(i, j int) bool {
+		return readings[i].Timestamp.After(readings[j].Timestamp)
+	})
+	return readings
+}
+
+// GetAggregatedReadings agrupa las lecturas válidas de un sensor en buckets alineados a epoch.
+// Para buckets de 1h y 1d se fusionan además los resúmenes generados por la retención.
+func (r *MemoryRepository) GetAggregatedReadings(ctx context.Context, sensorID string, start, end time.Time, bucket time.Duration) ([]*sensor.ReadingAggregate, error) {
+	bucketSecs := int64(bucket / time.Second)
+	if bucketSecs <= 0 {
+		return nil, fmt.Errorf("invalid bucket size: %s", bucket)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	buckets := make(bucketMap)
+	if ring, ok := r.readings[sensorID]; ok {
+		ring.each(func(reading *sensor.SensorReading) {
+			if reading.IsError() || reading.Timestamp.Before(start) || reading.Timestamp.After(end) {
+				return
+			}
+			key := rollupKey{sensorID: sensorID, start: reading.Timestamp.Unix() / bucketSecs * bucketSecs}
+			buckets.merge(key, valueStats(reading.Value))
+		})
+	}
+
+	r.mergeRollups(buckets, sensorID, start, end, bucket)
+
+	return buckets.aggregates(sensorID), nil
+}
+
+// mergeRollups fusiona en buckets los resúmenes de retención del sensor en [start, end]
+// si bucket es uno de los consolidados (requiere r.mu)
+func (r *MemoryRepository) mergeRollups(buckets bucketMap, sensorID string, start, end time.Time, bucket time.Duration) {
+	rollups, ok := r.rollups[bucket]
+	if !ok {
+		return
+	}
+	first, last := start.UTC().Truncate(bucket).Unix(), end.Unix()
+	for key, stats := range rollups {
+		if key.sensorID == sensorID && key.start >= first && key.start <= last {
+			buckets.merge(key, stats)
+		}
+	}
+}
+
+// ApplyRetention elimina las lecturas que cumplen la política, consolidándolas antes
+// en los resúmenes horario y diario si policy.Rollup. Se ejecuta bajo un único lock.
+func (r *MemoryRepository) ApplyRetention(ctx context.Context, policy repository.RetentionPolicy) (int64, error) {
+	excluded := make(map[sensor.SensorType]bool, len(policy.ExcludeTypes))
+	for _, t := range policy.ExcludeTypes {
+		excluded[t] = true
+	}
+
+	expired := func(reading *sensor.SensorReading) bool {
+		if !reading.Timestamp.Before(policy.Before) {
+			return false
+		}
+		if policy.SensorType != "" && reading.Type != policy.SensorType {
+			return false
+		}
+		return !excluded[reading.Type]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for sensorID, ring := range r.readings {
+		removed := ring.removeIf(expired)
+		deleted += int64(len(removed))
+		for _, reading := range removed {
+			delete(r.ids, reading.ID)
+		}
+
+		if policy.Rollup {
+			r.rollup(sensorID, removed)
+		}
+	}
+
+	return deleted, nil
+}
+
+// rollup consolida las lecturas válidas en los resúmenes horario y diario (requiere r.mu)
+func (r *MemoryRepository) rollup(sensorID string, readings []*sensor.SensorReading) {
+	for _, reading := range readings {
+		if reading.IsError() {
+			continue
+		}
+		for bucket, rollups := range r.rollups {
+			bucketSecs := int64(bucket / time.Second)
+			key := rollupKey{sensorID: sensorID, start: reading.Timestamp.Unix() / bucketSecs * bucketSecs}
+			rollups.merge(key, valueStats(reading.Value))
+		}
+	}
+}
+
+// rolledUp retorna, sin modificarlos, el valor que tendrían los resúmenes afectados tras
+// consolidar las lecturas de cada sensor (requiere r.mu)
+func (r *MemoryRepository) rolledUp(readings map[string][]*sensor.SensorReading) []snapshotRollup {
+	var result []snapshotRollup
+	for bucket, rollups := range r.rollups {
+		bucketSecs := int64(bucket / time.Second)
+		updated := make(bucketMap)
+		for sensorID, sensorReadings := range readings {
+			for _, reading := range sensorReadings {
+				if reading.IsError() {
+					continue
+				}
+				key := rollupKey{sensorID: sensorID, start: reading.Timestamp.Unix() / bucketSecs * bucketSecs}
+				if current, ok := rollups[key]; ok && updated[key] == nil {
+					updated.merge(key, current)
+				}
+				updated.merge(key, valueStats(reading.Value))
+			}
+		}
+		for key, stats := range updated {
+			result = append(result, newSnapshotRollup(bucket, key, stats))
+		}
+	}
+	return result
+}
+
+// setRollups fija el valor de los resúmenes dados, reemplazando el que tuvieran (requiere r.mu)
+func (r *MemoryRepository) setRollups(rollups []snapshotRollup) error {
+	for _, rollup := range rollups {
+		buckets, key, stats, err := r.parseRollup(rollup)
+		if err != nil {
+			return fmt.Errorf("invalid rollup: %w", err)
+		}
+		buckets[key] = stats
+	}
+	return nil
+}
+
+// SaveConfig guarda o actualiza la configuración de un sensor y registra una nueva
+// revisión en el historial si ha cambiado
+func (r *MemoryRepository) SaveConfig(ctx context.Context, config *sensor.SensorConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.configs[config.SensorID] = *config
+
+	history := r.history[config.SensorID]
+	if n := len(history); n > 0 && history[n-1].Config.Equal(*config) {
+		return nil
+	}
+
+	change := repository.ConfigChangeFromContext(ctx)
+	r.history[config.SensorID] = append(history, sensor.ConfigRevision{
+		Revision:  len(history) + 1,
+		Config:    *config,
+		ChangedBy: change.ChangedBy,
+		Reason:    change.Reason,
+		ChangedAt: time.Now().UTC(),
+	})
+	return nil
+}
+
+// GetConfig obtiene la configuración de un sensor
+func (r *MemoryRepository) GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	config, ok := r.configs[sensorID]
+	if !ok {
+		return nil, fmt.Errorf("config not found for sensor %s", sensorID)
+	}
+	return &config, nil
+}
+
+// DeleteConfig elimina la configuración actual de un sensor conservando su historial
+func (r *MemoryRepository) DeleteConfig(ctx context.Context, sensorID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.configs, sensorID)
+	return nil
+}
+
+// GetConfigHistory obtiene las revisiones de configuración de un sensor, la más reciente primero
+func (r *MemoryRepository) GetConfigHistory(ctx context.Context, sensorID string, limit int) ([]*sensor.ConfigRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := r.history[sensorID]
+	if limit <= 0 || limit > len(history) {
+		limit = len(history)
+	}
+
+	revisions := make([]*sensor.ConfigRevision, 0, limit)
+	for i := len(history) - 1; i >= len(history)-limit; i-- {
+		rev := history[i]
+		revisions = append(revisions, &rev)
+	}
+	return revisions, nil
+}
+
+// SaveAlert guarda una copia de la alerta, descartando las más antiguas del sensor
+// si se supera la capacidad
+func (r *MemoryRepository) SaveAlert(ctx context.Context, alert *sensor.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pushAlert(*alert)
+	return nil
+}
+
+// pushAlert añade una alerta a las de su sensor (requiere r.mu)
+func (r *MemoryRepository) pushAlert(alert sensor.Alert) {
+	alerts := append(r.alerts[alert.SensorID], alert)
+	if len(alerts) > r.capacity {
+		alerts = alerts[len(alerts)-r.capacity:]
+	}
+	r.alerts[alert.SensorID] = alerts
+}
+
+// GetAlerts obtiene las alertas que cumplen el filtro ordenadas por timestamp descendente
+func (r *MemoryRepository) GetAlerts(ctx context.Context, filter repository.AlertFilter) ([]*sensor.Alert, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var alerts []*sensor.Alert
+	for sensorID, stored := range r.alerts {
+		if filter.SensorID != "" && sensorID != filter.SensorID {
+			continue
+		}
+		for _, alert := range stored {
+			if filter.Severity != "" && alert.Severity != filter.Severity {
+				continue
+			}
+			if !filter.Start.IsZero() && alert.Timestamp.Before(filter.Start) {
+				continue
+			}
+			if !filter.End.IsZero() && alert.Timestamp.After(filter.End) {
+				continue
+			}
+			alerts = append(alerts, &alert)
+		}
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].Timestamp.After(alerts[j].Timestamp)
+	})
+	if filter.Limit > 0 && len(alerts) > filter.Limit {
+		alerts = alerts[:filter.Limit]
+	}
+	return alerts, nil
+}
+
+// SaveSensor guarda o actualiza los metadatos de un sensor
+func (r *MemoryRepository) SaveSensor(ctx context.Context, s *sensor.Sensor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *s
+	stored.Tags = slices.Clone(s.Tags)
+	if stored.Source == "" {
+		stored.Source = sensor.SensorSourceRegister
+	}
+	r.sensors[s.ID] = stored
+	return nil
+}
+
+// GetSensor obtiene los metadatos de un sensor registrado
+func (r *MemoryRepository) GetSensor(ctx context.Context, sensorID string) (*sensor.Sensor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.sensors[sensorID]
+	if !ok {
+		return nil, fmt.Errorf("sensor %s not found", sensorID)
+	}
+	return &s, nil
+}
+
+// ListSensors obtiene todos los sensores registrados ordenados por ID
+func (r *MemoryRepository) ListSensors(ctx context.Context) ([]*sensor.Sensor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sensors := make([]*sensor.Sensor, 0, len(r.sensors))
+	for _, s := range r.sensors {
+		sensors = append(sensors, &s)
+	}
+	sort.Slice(sensors, func(i, j int) bool { return sensors[i].ID < sensors[j].ID })
+
+	return sensors, nil
+}
+
+// DeleteSensor elimina los metadatos de un sensor registrado (idempotente)
+func (r *MemoryRepository) DeleteSensor(ctx context.Context, sensorID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sensors, sensorID)
+	return nil
+}
+
+// PurgeSensorData elimina las lecturas, resúmenes, alertas e historial de un sensor
+func (r *MemoryRepository) PurgeSensorData(ctx context.Context, sensorID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	if ring, ok := r.readings[sensorID]; ok {
+		deleted = int64(ring.size)
+		ring.each(func(reading *sensor.SensorReading) { delete(r.ids, reading.ID) })
+		delete(r.readings, sensorID)
+	}
+	for _, rollups := range r.rollups {
+		for key := range rollups {
+			if key.sensorID == sensorID {
+				delete(rollups, key)
+			}
+		}
+	}
+	delete(r.alerts, sensorID)
+	delete(r.history, sensorID)
+
+	return deleted, nil
+}
+
+// Close vuelca el estado al fichero de snapshot si está configurado
+func (r *MemoryRepository) Close() error {
+	if r.snapshotPath == "" {
+		return nil
+	}
+	return r.saveSnapshot()
+}
+
+// memorySnapshot es el formato JSON del fichero de snapshot
+type memorySnapshot struct {
+	Capacity int                                `json:"capacity"`
+	SavedAt  time.Time                          `json:"saved_at"`
+	Readings map[string][]*sensor.SensorReading `json:"readings"` // Orden de llegada
+	Configs  []sensor.SensorConfig              `json:"configs"`
+	History  map[string][]sensor.ConfigRevision `json:"history,omitempty"`
+	Sensors  []sensor.Sensor                    `json:"sensors"`
+	Alerts   map[string][]sensor.Alert          `json:"alerts,omitempty"`
+	Rollups  []snapshotRollup                   `json:"rollups,omitempty"`
+}
+
+// snapshotRollup serializa un bucket de resumen
+type snapshotRollup struct {
+	Bucket      string  `json:"bucket"` // "1h" o "1d"
+	SensorID    string  `json:"sensor_id"`
+	BucketStart int64   `json:"bucket_start"`
+	Count       int     `json:"count"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+	Sum         float64 `json:"sum"`
+	SumSquares  float64 `json:"sum_squares"`
+}
+
+// newSnapshotRollup serializa el resumen key del bucket dado
+func newSnapshotRollup(bucket time.Duration, key rollupKey, stats *bucketStats) snapshotRollup {
+	return snapshotRollup{
+		Bucket:      bucketName(bucket),
+		SensorID:    key.sensorID,
+		BucketStart: key.start,
+		Count:       stats.count,
+		Min:         stats.min,
+		Max:         stats.max,
+		Sum:         stats.sum,
+		SumSquares:  stats.sumSquares,
+	}
+}
+
+// parseRollup retorna los resúmenes del bucket de rollup, su clave y su valor
+func (r *MemoryRepository) parseRollup(rollup snapshotRollup) (bucketMap, rollupKey, *bucketStats, error) {
+	bucket, err := repository.ParseBucket(rollup.Bucket)
+	if err != nil {
+		return nil, rollupKey{}, nil, err
+	}
+	buckets, ok := r.rollups[bucket]
+	if !ok {
+		return nil, rollupKey{}, nil, fmt.Errorf("unsupported bucket %s", rollup.Bucket)
+	}
+	return buckets, rollupKey{sensorID: rollup.SensorID, start: rollup.BucketStart}, &bucketStats{
+		count:      rollup.Count,
+		min:        rollup.Min,
+		max:        rollup.Max,
+		sum:        rollup.Sum,
+		sumSquares: rollup.SumSquares,
+	}, nil
+}
+
+// saveSnapshot escribe el estado en un fichero temporal y lo renombra de forma atómica
+func (r *MemoryRepository) saveSnapshot() error {
+	r.mu.RLock()
+	snapshot := memorySnapshot{
+		Capacity: r.capacity,
+		SavedAt:  time.Now().UTC(),
+		Readings: make(map[string][]*sensor.SensorReading, len(r.readings)),
+	}
+	for sensorID, ring := range r.readings {
+		ring.each(func(reading *sensor.SensorReading) {
+			snapshot.Readings[sensorID] = append(snapshot.Readings[sensorID], reading)
+		})
+	}
+	for _, config := range r.configs {
+		snapshot.Configs = append(snapshot.Configs, config)
+	}
+	snapshot.History = r.history
+	for _, s := range r.sensors {
+		snapshot.Sensors = append(snapshot.Sensors, s)
+	}
+	snapshot.Alerts = r.alerts
+	for bucket, rollups := range r.rollups {
+		for key, stats := range rollups {
+			snapshot.Rollups = append(snapshot.Rollups, newSnapshotRollup(bucket, key, stats))
+		}
+	}
+	data, err := json.Marshal(snapshot)
+	r.mu.RUnlock()
+
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.snapshotPath), filepath.Base(r.snapshotPath)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), r.snapshotPath); err != nil {
+		return fmt.Errorf("failed to save snapshot %s: %w", r.snapshotPath, err)
+	}
+
+	return nil
+}
+
+// loadSnapshot restaura el estado desde el fichero de snapshot si existe
+func (r *MemoryRepository) loadSnapshot() error {
+	data, err := os.ReadFile(r.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot %s: %w", r.snapshotPath, err)
+	}
+
+	var snapshot memorySnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to decode snapshot %s: %w", r.snapshotPath, err)
+	}
+
+	// Si la capacidad ha bajado, push descarta las lecturas más antiguas
+	for _, readings := range snapshot.Readings {
+		for _, reading := range readings {
+			r.push(reading)
+		}
+	}
+	for _, config := range snapshot.Configs {
+		r.configs[config.SensorID] = config
+	}
+	for sensorID, history := range snapshot.History {
+		r.history[sensorID] = history
+	}
+	for _, s := range snapshot.Sensors {
+		r.sensors[s.ID] = s
+	}
+	for _, alerts := range snapshot.Alerts {
+		for _, alert := range alerts {
+			r.pushAlert(alert)
+		}
+	}
+	for _, rollup := range snapshot.Rollups {
+		buckets, key, stats, err := r.parseRollup(rollup)
+		if err != nil {
+			return fmt.Errorf("invalid rollup in snapshot: %w", err)
+		}
+		buckets.merge(key, stats)
+	}
+
+	return nil
+}
+
+// bucketName retorna el nombre textual de un bucket soportado
+func bucketName(bucket time.Duration) string {
+	for name, d := range repository.Buckets {
+		if d == bucket {
+			return name
+		}
+	}
+	return bucket.String()
+}
+
+// copyReading copia una lectura para que el llamante no comparta memoria con el repositorio
+func copyReading(reading *sensor.SensorReading) *sensor.SensorReading {
+	copied := *reading
+	if reading.Error != nil {
+		msg := *reading.Error
+		copied.Error = &msg
+	}
+	return &copied
+}
+
+// readingRing es un buffer circular de lecturas de capacidad fija
+type readingRing struct {
+	buf   []*sensor.SensorReading
+	start int // Posición de la lectura más antigua
+	size  int
+}
+
+func newReadingRing(capacity int) *readingRing {
+	return &readingRing{buf: make([]*sensor.SensorReading, capacity)}
+}
+
+// push añade una lectura, sobrescribiendo la más antigua si el buffer está lleno.
+// Retorna la lectura sobrescrita, o nil.
+func (b *readingRing) push(reading *sensor.SensorReading) *sensor.SensorReading {
+	if b.size < len(b.buf) {
+		b.buf[(b.start+b.size)%len(b.buf)] = reading
+		b.size++
+		return nil
+	}
+	evicted := b.buf[b.start]
+	b.buf[b.start] = reading
+	b.start = (b.start + 1) % len(b.buf)
+	return evicted
+}
+
+// each recorre las lecturas en orden de llegada
+func (b *readingRing) each(fn func(*sensor.SensorReading)) {
+	for i := 0; i < b.size; i++ {
+		fn(b.buf[(b.start+i)%len(b.buf)])
+	}
+}
+
+// removeIf elimina las lecturas que cumplen match, conserva el orden del resto
+// y retorna las eliminadas
+func (b *readingRing) removeIf(match func(*sensor.SensorReading) bool) []*sensor.SensorReading {
+	var kept, removed []*sensor.SensorReading
+	b.each(func(reading *sensor.SensorReading) {
+		if match(reading) {
+			removed = append(removed, reading)
+		} else {
+			kept = append(kept, reading)
+		}
+	})
+	if len(removed) == 0 {
+		return nil
+	}
+
+	clear(b.buf)
+	copy(b.buf, kept)
+	b.start, b.size = 0, len(kept)
+	return removed
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+func newMemoryReading(id, sensorID string, value float64, ts time.Time) *sensor.SensorReading {
+	return &sensor.SensorReading{
+		ID:        id,
+		SensorID:  sensorID,
+		Type:      sensor.SensorTypeTemperature,
+		Value:     value,
+		Unit:      "°C",
+		Timestamp: ts,
+	}
+}
+
+func TestMemoryRepository_RingBufferKeepsLastN(t *testing.T) {
+	repo, err := NewMemoryRepository(3, "")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		if err := repo.SaveReading(ctx, newMemoryReading(fmt.Sprintf("r%d", i), "temp-001", float64(i), base.Add(time.Duration(i)*time.Second))); err != nil {
+			t.Fatalf("SaveReading failed: %v", err)
+		}
+	}
+
+	readings, err := repo.GetLatestReadings(ctx, "temp-001", 10)
+	if err != nil {
+		t.Fatalf("GetLatestReadings failed: %v", err)
+	}
+	if len(readings) != 3 {
+		t.Fatalf("expected 3 readings (capacity), got %d", len(readings))
+	}
+	for i, want := range []string{"r4", "r3", "r2"} {
+		if readings[i].ID != want {
+			t.Errorf("readings[%d] = %s, want %s", i, readings[i].ID, want)
+		}
+	}
+
+	limited, _ := repo.GetLatestReadings(ctx, "temp-001", 1)
+	if len(limited) != 1 || limited[0].ID != "r4" {
+		t.Errorf("expected only r4 with limit 1, got %v", limited)
+	}
+}
+
+func TestMemoryRepository_DuplicateIDs(t *testing.T) {
+	repo, _ := NewMemoryRepository(2, "")
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	repo.SaveReading(ctx, newMemoryReading("r1", "temp-001", 1, base))
+	repo.SaveReading(ctx, newMemoryReading("r1", "temp-002", 1, base)) // el id es global, no por sensor
+	repo.SaveReadings(ctx, []*sensor.SensorReading{
+		newMemoryReading("r1", "temp-001", 1, base),
+		newMemoryReading("r2", "temp-001", 2, base.Add(time.Second)),
+	})
+	if got := repo.DuplicateReadings(); got != 2 {
+		t.Errorf("expected 2 duplicates, got %d", got)
+	}
+
+	// Una lectura descartada por el ring deja libre su id
+	repo.SaveReading(ctx, newMemoryReading("r3", "temp-001", 3, base.Add(2*time.Second)))
+	repo.SaveReading(ctx, newMemoryReading("r1", "temp-001", 1, base.Add(3*time.Second)))
+	if got := repo.DuplicateReadings(); got != 2 {
+		t.Errorf("expected the evicted id to be accepted again, got %d duplicates", got)
+	}
+
+	if _, err := repo.PurgeSensorData(ctx, "temp-001"); err != nil {
+		t.Fatalf("PurgeSensorData failed: %v", err)
+	}
+	if skipped, _ := repo.ImportReadings(ctx, []*sensor.SensorReading{newMemoryReading("r3", "temp-001", 3, base)}); len(skipped) != 0 {
+		t.Errorf("expected purged ids to be importable, got skipped %v", skipped)
+	}
+}
+
+func TestMemoryRepository_OutOfOrderAndTimeRange(t *testing.T) {
+	repo, _ := NewMemoryRepository(10, "")
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	repo.SaveReadings(ctx, []*sensor.SensorReading{
+		newMemoryReading("late", "temp-001", 3, base.Add(30*time.Minute)),
+		newMemoryReading("early", "temp-001", 1, base),
+		newMemoryReading("mid", "temp-001", 2, base.Add(10*time.Minute)),
+	})
+
+	readings, _ := repo.GetLatestReadings(ctx, "temp-001", 10)
+	if readings[0].ID != "late" || readings[2].ID != "early" {
+		t.Errorf("expected readings ordered by timestamp desc, got %s, %s, %s", readings[0].ID, readings[1].ID, readings[2].ID)
+	}
+
+	ranged, err := repo.GetReadingsByTimeRange(ctx, "temp-001", base, base.Add(10*time.Minute))
+	if err != nil {
+		t.Fatalf("GetReadingsByTimeRange failed: %v", err)
+	}
+	if len(ranged) != 2 || ranged[0].ID != "mid" || ranged[1].ID != "early" {
+		t.Errorf("expected [mid early] (inclusive range), got %v", ranged)
+	}
+}
+
+func TestMemoryRepository_ReturnsCopies(t *testing.T) {
+	repo, _ := NewMemoryRepository(10, "")
+	ctx := context.Background()
+
+	reading := newMemoryReading("r1", "temp-001", 20, time.Now())
+	repo.SaveReading(ctx, reading)
+	reading.Value = 99
+
+	readings, _ := repo.GetLatestReadings(ctx, "temp-001", 1)
+	if readings[0].Value != 20 {
+		t.Errorf("stored reading was modified through the caller's pointer: %v", readings[0].Value)
+	}
+	readings[0].Value = 50
+
+	again, _ := repo.GetLatestReadings(ctx, "temp-001", 1)
+	if again[0].Value != 20 {
+		t.Errorf("stored reading was modified through a returned pointer: %v", again[0].Value)
+	}
+}
+
+func TestMemoryRepository_ConcurrentAccess(t *testing.T) {
+	repo, _ := NewMemoryRepository(50, "")
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			sensorID := fmt.Sprintf("sensor-%d", w%2)
+			for i := 0; i < 100; i++ {
+				repo.SaveReading(ctx, newMemoryReading(fmt.Sprintf("%d-%d", w, i), sensorID, float64(i), time.Now()))
+				repo.GetLatestReadings(ctx, sensorID, 5)
+				repo.SaveConfig(ctx, &sensor.SensorConfig{SensorID: sensorID, Interval: 1000 + i})
+				repo.GetConfig(ctx, sensorID)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for _, sensorID := range []string{"sensor-0", "sensor-1"} {
+		readings, _ := repo.GetLatestReadings(ctx, sensorID, 1000)
+		if len(readings) != 50 {
+			t.Errorf("%s: expected 50 readings (capacity), got %d", sensorID, len(readings))
+		}
+	}
+}
+
+func TestMemoryRepository_RetentionAndAggregates(t *testing.T) {
+	repo, _ := NewMemoryRepository(100, "")
+	ctx := context.Background()
+
+	errMsg := "timeout"
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	pressure := newMemoryReading("p1", "press-001", 1000, base)
+	pressure.Type = sensor.SensorTypePressure
+	failed := newMemoryReading("r3", "temp-001", 0, base.Add(20*time.Minute))
+	failed.Error = &errMsg
+
+	repo.SaveReadings(ctx, []*sensor.SensorReading{
+		newMemoryReading("r1", "temp-001", 10, base),
+		newMemoryReading("r2", "temp-001", 20, base.Add(10*time.Minute)),
+		failed,
+		newMemoryReading("r4", "temp-001", 40, base.Add(2*time.Hour)),
+		pressure,
+	})
+
+	deleted, err := repo.ApplyRetention(ctx, repository.RetentionPolicy{
+		ExcludeTypes: []sensor.SensorType{sensor.SensorTypePressure},
+		Before:       base.Add(time.Hour),
+		Rollup:       true,
+	})
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("expected 3 deleted readings, got %d", deleted)
+	}
+
+	remaining, _ := repo.GetLatestReadings(ctx, "temp-001", 10)
+	if len(remaining) != 1 || remaining[0].ID != "r4" {
+		t.Errorf("expected only r4 to remain, got %v", remaining)
+	}
+	if kept, _ := repo.GetLatestReadings(ctx, "press-001", 10); len(kept) != 1 {
+		t.Errorf("excluded type should keep its readings, got %d", len(kept))
+	}
+
+	// Horario: 10:00 consolidado (10, 20) + 12:00 crudo (40)
+	aggs, err := repo.GetAggregatedReadings(ctx, "temp-001", base, base.Add(3*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatalf("GetAggregatedReadings failed: %v", err)
+	}
+	if len(aggs) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(aggs))
+	}
+	if aggs[0].Count != 2 || aggs[0].Avg != 15 || aggs[0].StdDev != 5 || !aggs[0].BucketStart.Equal(base) {
+		t.Errorf("unexpected rolled up bucket: %+v", aggs[0])
+	}
+	if aggs[1].Count != 1 || aggs[1].Max != 40 {
+		t.Errorf("unexpected raw bucket: %+v", aggs[1])
+	}
+
+	// 5m solo ve lecturas crudas
+	aggs, _ = repo.GetAggregatedReadings(ctx, "temp-001", base, base.Add(3*time.Hour), 5*time.Minute)
+	if len(aggs) != 1 || aggs[0].Count != 1 {
+		t.Errorf("expected a single raw 5m bucket, got %v", aggs)
+	}
+}
+
+func TestMemoryRepository_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	ctx := context.Background()
+
+	repo, err := NewMemoryRepository(10, path)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	repo.SaveReadings(ctx, []*sensor.SensorReading{
+		newMemoryReading("r1", "temp-001", 10, base),
+		newMemoryReading("r2", "temp-001", 20, base.Add(2*time.Hour)),
+	})
+	repo.SaveConfig(ctx, &sensor.SensorConfig{SensorID: "temp-001", Interval: 5000, Threshold: 30, Enabled: true})
+	repo.SaveSensor(ctx, &sensor.Sensor{ID: "temp-001", Type: sensor.SensorTypeTemperature, Name: "Sala"})
+	repo.ApplyRetention(ctx, repository.RetentionPolicy{Before: base.Add(time.Hour), Rollup: true})
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	restored, err := NewMemoryRepository(10, path)
+	if err != nil {
+		t.Fatalf("failed to restore snapshot: %v", err)
+	}
+
+	readings, _ := restored.GetLatestReadings(ctx, "temp-001", 10)
+	if len(readings) != 1 || readings[0].ID != "r2" || !readings[0].Timestamp.Equal(base.Add(2*time.Hour)) {
+		t.Errorf("unexpected restored readings: %v", readings)
+	}
+	if config, err := restored.GetConfig(ctx, "temp-001"); err != nil || config.Interval != 5000 {
+		t.Errorf("unexpected restored config: %v, %v", config, err)
+	}
+	if s, err := restored.GetSensor(ctx, "temp-001"); err != nil || s.Name != "Sala" {
+		t.Errorf("unexpected restored sensor: %v, %v", s, err)
+	}
+
+	daily, _ := restored.GetAggregatedReadings(ctx, "temp-001", base.Add(-time.Hour), base.Add(3*time.Hour), 24*time.Hour)
+	if len(daily) != 1 || daily[0].Count != 2 {
+		t.Errorf("expected rollup + raw reading in daily bucket, got %v", daily)
+	}
+}
+
+func TestMemoryRepository_SnapshotMissingFile(t *testing.T) {
+	repo, err := NewMemoryRepository(10, filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatalf("missing snapshot should start empty, got %v", err)
+	}
+
+	sensors, _ := repo.ListSensors(context.Background())
+	if len(sensors) != 0 {
+		t.Errorf("expected no sensors, got %d", len(sensors))
+	}
+}
+
+func TestMemoryRepository_ConfigHistory(t *testing.T) {
+	repo, _ := NewMemoryRepository(10, "")
+	ctx := context.Background()
+
+	config := &sensor.SensorConfig{SensorID: "temp-001", Interval: 5000, Threshold: 30, Enabled: true}
+	repo.SaveConfig(ctx, config)
+	repo.SaveConfig(ctx, config)
+	repo.SaveConfig(repository.WithConfigChange(ctx, "bob", "rollback"), &sensor.SensorConfig{SensorID: "temp-001", Interval: 1000, Enabled: false})
+
+	history, err := repo.GetConfigHistory(ctx, "temp-001", 0)
+	if err != nil {
+		t.Fatalf("GetConfigHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(history))
+	}
+	if history[0].Revision != 2 || history[0].ChangedBy != "bob" || history[0].Reason != "rollback" {
+		t.Errorf("unexpected latest revision: %+v", history[0])
+	}
+	if history[1].Revision != 1 || history[1].Config != *config {
+		t.Errorf("unexpected first revision: %+v", history[1])
+	}
+}
+
+func TestMemoryRepository_Alerts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	repo, _ := NewMemoryRepository(2, path)
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, severity := range []sensor.AlertSeverity{sensor.AlertSeverityWarning, sensor.AlertSeverityCritical, sensor.AlertSeverityWarning} {
+		repo.SaveAlert(ctx, &sensor.Alert{ID: fmt.Sprintf("a%d", i), SensorID: "temp-001", Severity: severity, Timestamp: base.Add(time.Duration(i) * time.Minute)})
+	}
+	repo.SaveAlert(ctx, &sensor.Alert{ID: "h0", SensorID: "hum-001", Severity: sensor.AlertSeverityCritical, Timestamp: base.Add(time.Hour)})
+
+	// La capacidad descarta la alerta más antigua de temp-001
+	alerts, _ := repo.GetAlerts(ctx, repository.AlertFilter{})
+	if len(alerts) != 3 || alerts[0].ID != "h0" || alerts[2].ID != "a1" {
+		t.Errorf("expected [h0 a2 a1], got %v", alerts)
+	}
+
+	critical, _ := repo.GetAlerts(ctx, repository.AlertFilter{Severity: sensor.AlertSeverityCritical, End: base.Add(time.Minute)})
+	if len(critical) != 1 || critical[0].ID != "a1" {
+		t.Errorf("expected only a1, got %v", critical)
+	}
+
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	restored, _ := NewMemoryRepository(2, path)
+	if got, _ := restored.GetAlerts(ctx, repository.AlertFilter{SensorID: "temp-001", Limit: 1}); len(got) != 1 || got[0].ID != "a2" {
+		t.Errorf("expected a2 restored from snapshot, got %v", got)
+	}
+}
+
+func TestMemoryRepository_DeleteConfigAndPurge(t *testing.T) {
+	repo, _ := NewMemoryRepository(10, "")
+	ctx := context.Background()
+
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	repo.SaveConfig(ctx, &sensor.SensorConfig{SensorID: "temp-001", Interval: 1000, Enabled: true})
+	repo.SaveAlert(ctx, &sensor.Alert{ID: "a1", SensorID: "temp-001", Timestamp: base})
+	repo.SaveReadings(ctx, []*sensor.SensorReading{
+		newMemoryReading("r1", "temp-001", 10, base),
+		newMemoryReading("r2", "temp-001", 20, base.Add(2*time.Hour)),
+		newMemoryReading("r3", "temp-001", 30, base.Add(3*time.Hour)),
+		newMemoryReading("o1", "temp-002", 30, base),
+	})
+	repo.ApplyRetention(ctx, repository.RetentionPolicy{SensorType: sensor.SensorTypeTemperature, Before: base.Add(time.Hour), Rollup: true})
+
+	repo.DeleteConfig(ctx, "temp-001")
+	if _, err := repo.GetConfig(ctx, "temp-001"); err == nil {
+		t.Error("expected config to be deleted")
+	}
+	if history, _ := repo.GetConfigHistory(ctx, "temp-001", 0); len(history) != 1 {
+		t.Errorf("expected config history to be kept, got %d", len(history))
+	}
+
+	deleted, _ := repo.PurgeSensorData(ctx, "temp-001")
+	if deleted != 2 {
+		t.Errorf("expected 2 purged readings, got %d", deleted)
+	}
+	if aggs, _ := repo.GetAggregatedReadings(ctx, "temp-001", base.Add(-time.Hour), base.Add(4*time.Hour), time.Hour); len(aggs) != 0 {
+		t.Errorf("expected no data left for temp-001, got %v", aggs)
+	}
+	if alerts, _ := repo.GetAlerts(ctx, repository.AlertFilter{SensorID: "temp-001"}); len(alerts) != 0 {
+		t.Errorf("expected alerts to be purged, got %d", len(alerts))
+	}
+	if aggs, _ := repo.GetAggregatedReadings(ctx, "temp-002", base.Add(-time.Hour), base.Add(time.Hour), time.Hour); len(aggs) != 1 {
+		t.Errorf("expected temp-002 rollup to be kept, got %v", aggs)
+	}
+}
@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS)
+	if err != nil {
+		t.Fatalf("loadMigrations failed: %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+
+	// Versiones consecutivas empezando en 1, todas reversibles
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("expected version %d, got %d (%s)", i+1, m.Version, m.Name)
+		}
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("migration %04d_%s must have up and down scripts", m.Version, m.Name)
+		}
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "missing direction",
+			fsys: fstest.MapFS{"migrations/0001_init.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "non numeric version",
+			fsys: fstest.MapFS{"migrations/abc_init.up.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "down without up",
+			fsys: fstest.MapFS{"migrations/0001_init.down.sql": {Data: []byte("SELECT 1;")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadMigrations(tt.fsys); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	migrator, err := OpenMigrator(":memory:")
+	if err != nil {
+		t.Fatalf("OpenMigrator failed: %v", err)
+	}
+	defer migrator.Close()
+
+	ctx := context.Background()
+	total := len(migrator.migrations)
+
+	// Aplicar solo la primera
+	applied, err := migrator.Up(ctx, 1)
+	if err != nil {
+		t.Fatalf("Up(1) failed: %v", err)
+	}
+	if len(applied) != 1 {
+		t.Fatalf("expected 1 applied migration, got %d", len(applied))
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if !status[0].Applied || status[0].AppliedAt == nil || status[1].Applied {
+		t.Errorf("unexpected status after Up(1): %+v", status)
+	}
+
+	// Aplicar el resto
+	applied, err = migrator.Up(ctx, 0)
+	if err != nil {
+		t.Fatalf("Up(0) failed: %v", err)
+	}
+	if len(applied) != total-1 {
+		t.Errorf("expected %d applied migrations, got %d", total-1, len(applied))
+	}
+
+	version, _ := migrator.Version(ctx)
+	if version != total {
+		t.Errorf("expected version %d, got %d", total, version)
+	}
+
+	// Idempotente
+	applied, _ = migrator.Up(ctx, 0)
+	if len(applied) != 0 {
+		t.Errorf("expected no migrations on second Up, got %d", len(applied))
+	}
+
+	// Revertir la última
+	reverted, err := migrator.Down(ctx, 1)
+	if err != nil {
+		t.Fatalf("Down(1) failed: %v", err)
+	}
+	if len(reverted) != 1 || reverted[0].Version != total {
+		t.Errorf("expected to roll back version %d, got %+v", total, reverted)
+	}
+
+	version, _ = migrator.Version(ctx)
+	if version != total-1 {
+		t.Errorf("expected version %d after Down, got %d", total-1, version)
+	}
+
+	// Revertir todo y volver a aplicar
+	if _, err := migrator.Down(ctx, total); err != nil {
+		t.Fatalf("Down(all) failed: %v", err)
+	}
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatalf("Up after full rollback failed: %v", err)
+	}
+}
+
+func TestMigrator_AdoptsLegacyDatabase(t *testing.T) {
+	db, err := openSQLite(":memory:")
+	if err != nil {
+		t.Fatalf("openSQLite failed: %v", err)
+	}
+	defer db.Close()
+
+	// Base de datos creada antes del sistema de migraciones (sin schema_migrations)
+	_, err = db.Exec(`
+		CREATE TABLE sensor_configs (sensor_id TEXT PRIMARY KEY, interval INTEGER NOT NULL, threshold REAL NOT NULL, enabled INTEGER NOT NULL DEFAULT 1, updated_at TIMESTAMP);
+		CREATE TABLE sensor_readings (id TEXT PRIMARY KEY, sensor_id TEXT NOT NULL, type TEXT NOT NULL, value REAL NOT NULL, unit TEXT NOT NULL, error TEXT, timestamp TIMESTAMP NOT NULL);
+		INSERT INTO sensor_configs (sensor_id, interval, threshold) VALUES ('temp-001', 1000, 30.0);
+	`)
+	if err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator failed: %v", err)
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		t.Fatalf("Up on legacy database failed: %v", err)
+	}
+
+	// Los datos existentes se conservan
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sensor_configs`).Scan(&count); err != nil || count != 1 {
+		t.Errorf("expected legacy config to survive migrations, got count=%d err=%v", count, err)
+	}
+}
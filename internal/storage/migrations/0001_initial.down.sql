@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_readings_timestamp;
+DROP INDEX IF EXISTS idx_readings_sensor_time;
+DROP TABLE IF EXISTS sensor_readings;
+DROP TABLE IF EXISTS sensor_configs;
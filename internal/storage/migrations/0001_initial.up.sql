@@ -1,5 +1,6 @@
--- Schema para almacenamiento de datos de sensores IoT
--- Diseñado para ser compatible con SQLite y fácilmente migrable a TimescaleDB/PostgreSQL
+-- Schema inicial para almacenamiento de datos de sensores IoT
+-- Diseñado para ser compatible con SQLite y fácilmente migrable a TimescaleDB/PostgreSQL.
+-- Usa IF NOT EXISTS para adoptar bases de datos creadas antes del sistema de migraciones.
 
 -- Tabla de configuraciones de sensores
 CREATE TABLE IF NOT EXISTS sensor_configs (
@@ -35,4 +36,3 @@ CREATE INDEX IF NOT EXISTS idx_readings_timestamp
 -- 2. Añadir: SELECT create_hypertable('sensor_readings', 'timestamp');
 -- 3. Añadir retention policy: SELECT add_retention_policy('sensor_readings', INTERVAL '30 days');
 -- 4. Los índices se gestionan automáticamente con hypertables
-
@@ -0,0 +1,10 @@
+-- Tabla de sensores registrados (metadatos: tipo, nombre, ubicación)
+-- Permite rehidratar el simulador con los sensores dados de alta vía sensor.register
+CREATE TABLE IF NOT EXISTS sensors (
+    id TEXT PRIMARY KEY,
+    type TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    location TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
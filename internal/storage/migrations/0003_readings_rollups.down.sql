@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS sensor_readings_daily;
+DROP TABLE IF EXISTS sensor_readings_hourly;
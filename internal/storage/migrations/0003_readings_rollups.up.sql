@@ -0,0 +1,26 @@
+-- Resúmenes de lecturas consolidadas por la política de retención.
+-- Guardan sumas (no medias) para poder fusionar consolidaciones parciales del mismo bucket.
+-- bucket_start en segundos epoch UTC.
+CREATE TABLE IF NOT EXISTS sensor_readings_hourly (
+    sensor_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    bucket_start INTEGER NOT NULL,
+    count INTEGER NOT NULL,
+    min_value REAL NOT NULL,
+    max_value REAL NOT NULL,
+    sum_value REAL NOT NULL,
+    sum_squares REAL NOT NULL,
+    PRIMARY KEY (sensor_id, bucket_start)
+);
+
+CREATE TABLE IF NOT EXISTS sensor_readings_daily (
+    sensor_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    bucket_start INTEGER NOT NULL,
+    count INTEGER NOT NULL,
+    min_value REAL NOT NULL,
+    max_value REAL NOT NULL,
+    sum_value REAL NOT NULL,
+    sum_squares REAL NOT NULL,
+    PRIMARY KEY (sensor_id, bucket_start)
+);
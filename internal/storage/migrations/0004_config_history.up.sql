@@ -0,0 +1,19 @@
+-- Historial de cambios de configuración: una revisión por cada cambio real de config
+-- (SaveConfig con los mismos valores que la última revisión no genera fila nueva)
+CREATE TABLE sensor_config_history (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sensor_id TEXT NOT NULL,
+    revision INTEGER NOT NULL,
+    interval INTEGER NOT NULL,
+    threshold REAL NOT NULL,
+    enabled INTEGER NOT NULL,
+    changed_by TEXT NOT NULL DEFAULT '',
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP NOT NULL,
+    UNIQUE(sensor_id, revision)
+);
+
+-- Las configs existentes pasan a ser la revisión 1 de su sensor
+INSERT INTO sensor_config_history (sensor_id, revision, interval, threshold, enabled, changed_by, reason, changed_at)
+SELECT sensor_id, 1, interval, threshold, enabled, 'system', 'initial', COALESCE(updated_at, CURRENT_TIMESTAMP)
+FROM sensor_configs;
@@ -0,0 +1,17 @@
+-- Alertas de umbral generadas por el simulador. Se persisten además de publicarse
+-- en sensor.alerts.<type>.<id> para no perderlas si no hay suscriptores.
+CREATE TABLE alerts (
+    id TEXT PRIMARY KEY,
+    sensor_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    value REAL NOT NULL,
+    threshold REAL NOT NULL,
+    unit TEXT NOT NULL,
+    message TEXT NOT NULL,
+    timestamp TIMESTAMP NOT NULL
+);
+
+-- Consultas por sensor y por rango temporal global, ordenadas por timestamp DESC
+CREATE INDEX idx_alerts_sensor_time ON alerts(sensor_id, timestamp DESC);
+CREATE INDEX idx_alerts_timestamp ON alerts(timestamp DESC);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sensors_type_location;
+DROP TABLE IF EXISTS sensor_tags;
+ALTER TABLE sensors DROP COLUMN source;
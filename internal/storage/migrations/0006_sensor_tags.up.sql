@@ -0,0 +1,13 @@
+-- Origen de los metadatos: solo los sensores dados de alta con sensor.register se
+-- rehidratan al arrancar; los del YAML se guardan para poder buscar sus lecturas
+ALTER TABLE sensors ADD COLUMN source TEXT NOT NULL DEFAULT 'register';
+
+-- Etiquetas libres de cada sensor para las búsquedas entre sensores
+CREATE TABLE sensor_tags (
+    sensor_id TEXT NOT NULL,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (sensor_id, tag)
+);
+
+CREATE INDEX idx_sensor_tags_tag ON sensor_tags(tag);
+CREATE INDEX idx_sensors_type_location ON sensors(type, location);
@@ -0,0 +1,9 @@
+ALTER TABLE alerts DROP COLUMN kind;
+
+ALTER TABLE sensor_config_history DROP COLUMN max_rate;
+ALTER TABLE sensor_config_history DROP COLUMN hysteresis;
+ALTER TABLE sensor_config_history DROP COLUMN low_threshold;
+
+ALTER TABLE sensor_configs DROP COLUMN max_rate;
+ALTER TABLE sensor_configs DROP COLUMN hysteresis;
+ALTER TABLE sensor_configs DROP COLUMN low_threshold;
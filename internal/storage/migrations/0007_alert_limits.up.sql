@@ -0,0 +1,12 @@
+-- Límites de alerta adicionales: umbral inferior (NULL = sin límite), histéresis para
+-- rearmar las alertas de umbral y variación máxima por minuto (0 = sin límite)
+ALTER TABLE sensor_configs ADD COLUMN low_threshold REAL;
+ALTER TABLE sensor_configs ADD COLUMN hysteresis REAL NOT NULL DEFAULT 0;
+ALTER TABLE sensor_configs ADD COLUMN max_rate REAL NOT NULL DEFAULT 0;
+
+ALTER TABLE sensor_config_history ADD COLUMN low_threshold REAL;
+ALTER TABLE sensor_config_history ADD COLUMN hysteresis REAL NOT NULL DEFAULT 0;
+ALTER TABLE sensor_config_history ADD COLUMN max_rate REAL NOT NULL DEFAULT 0;
+
+-- Límite que ha disparado cada alerta: high, low o rate
+ALTER TABLE alerts ADD COLUMN kind TEXT NOT NULL DEFAULT 'high';
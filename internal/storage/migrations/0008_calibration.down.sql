@@ -0,0 +1,4 @@
+ALTER TABLE sensor_readings DROP COLUMN raw_value;
+
+ALTER TABLE sensor_config_history DROP COLUMN calibration;
+ALTER TABLE sensor_configs DROP COLUMN calibration;
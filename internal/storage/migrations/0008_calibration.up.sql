@@ -0,0 +1,6 @@
+-- Calibración de cada sensor (JSON con offset, gain y polynomial; NULL = sin calibración)
+ALTER TABLE sensor_configs ADD COLUMN calibration TEXT;
+ALTER TABLE sensor_config_history ADD COLUMN calibration TEXT;
+
+-- Valor medido antes de calibrar (NULL = lectura sin calibración, value es el valor medido)
+ALTER TABLE sensor_readings ADD COLUMN raw_value REAL;
@@ -0,0 +1,18 @@
+package storage
+
+import (
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+// paginate recorta a limit las lecturas de una página (consultada con limit+1) y retorna
+// el cursor de la última lectura si sobra alguna, es decir, si hay otra página
+func paginate(readings []*sensor.SensorReading, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor) {
+	if len(readings) <= limit {
+		return readings, nil
+	}
+
+	readings = readings[:limit]
+	last := readings[limit-1]
+	return readings, &repository.ReadingCursor{Timestamp: last.Timestamp, ID: last.ID}
+}
@@ -3,62 +3,237 @@ package storage
 import (
 	"context"
 	"database/sql"
-	_ "embed"
+	"encoding/json"
 	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "modernc.org/sqlite" // Driver SQLite puro Go (sin CGO)
 
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
 	"github.com/alejandro/technical_test_uvigo/internal/sensor"
 )
 
-//go:embed schema.sql
-var schemaSQL string
-
 // SQLiteRepository implementa repository.Repository usando SQLite.
 // Esta implementación es específica para SQLite pero respeta el contrato
 // definido en repository.Repository, permitiendo intercambiar con otras bases
 // de datos (ej: TimescaleDB) sin modificar código de negocio.
+//
+// Usa journaling WAL con una única conexión de escritura (SQLite serializa las
+// escrituras) y un pool de conexiones de solo lectura, de forma que las consultas
+// no esperan a las escrituras del simulador ni al revés.
 type SQLiteRepository struct {
-	db *sql.DB
+	db         *sql.DB       // Escritor único (también migraciones)
+	readDB     *sql.DB       // Pool de solo lectura (el mismo que db en ":memory:")
+	dbPath     string        // Fichero de la base de datos ("" en ":memory:")
+	opts       SQLiteOptions // Pragmas del escritor, reutilizados por la conexión de backup
+	duplicates atomic.Int64  // Lecturas ignoradas por SaveReading/SaveReadings por id repetido
+}
+
+// SQLiteOptions ajusta los pragmas y el pool de lectura de SQLite.
+// Los valores cero aplican los valores por defecto.
+type SQLiteOptions struct {
+	BusyTimeout time.Duration // Espera ante una base de datos bloqueada (0 = 5s)
+	Synchronous string        // OFF, NORMAL, FULL o EXTRA ("" = NORMAL, seguro con WAL)
+	CacheSize   int           // PRAGMA cache_size: >0 páginas, <0 KiB (0 = valor de SQLite)
+	ReadConns   int           // Conexiones de solo lectura (0 = 4)
+}
+
+const (
+	defaultBusyTimeout = 5 * time.Second
+	defaultSynchronous = "NORMAL"
+	defaultReadConns   = 4
+)
+
+// withDefaults valida las opciones y completa los valores no indicados
+func (o SQLiteOptions) withDefaults() (SQLiteOptions, error) {
+	if o.BusyTimeout < 0 {
+		return o, fmt.Errorf("busy_timeout must not be negative")
+	}
+	if o.ReadConns < 0 {
+		return o, fmt.Errorf("read_conns must not be negative")
+	}
+	if o.BusyTimeout == 0 {
+		o.BusyTimeout = defaultBusyTimeout
+	}
+	if o.ReadConns == 0 {
+		o.ReadConns = defaultReadConns
+	}
+
+	o.Synchronous = strings.ToUpper(o.Synchronous)
+	switch o.Synchronous {
+	case "":
+		o.Synchronous = defaultSynchronous
+	case "OFF", "NORMAL", "FULL", "EXTRA":
+	default:
+		return o, fmt.Errorf("invalid synchronous mode %q (must be OFF, NORMAL, FULL or EXTRA)", o.Synchronous)
+	}
+
+	return o, nil
 }
 
-// NewSQLiteRepository crea una nueva instancia del repositorio SQLite.
+// NewSQLiteRepository crea una nueva instancia del repositorio SQLite con las opciones por defecto.
 // dbPath puede ser un archivo (ej: "./data/sensors.db") o ":memory:" para testing.
+// Aplica automáticamente las migraciones pendientes.
 func NewSQLiteRepository(dbPath string) (*SQLiteRepository, error) {
-	// Abrir conexión
-	db, err := sql.Open("sqlite", dbPath)
+	return NewSQLiteRepositoryWithOptions(dbPath, SQLiteOptions{})
+}
+
+// NewSQLiteRepositoryWithOptions crea el repositorio SQLite con pragmas y pool de lectura configurables.
+// En ":memory:" cada conexión tendría su propia base de datos, así que lecturas y
+// escrituras comparten la única conexión.
+func NewSQLiteRepositoryWithOptions(dbPath string, opts SQLiteOptions) (*SQLiteRepository, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, fmt.Errorf("invalid sqlite options: %w", err)
+	}
+
+	db, err := openSQLiteWriter(dbPath, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Aplicar migraciones pendientes
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	if isMemoryDSN(dbPath) {
+		return &SQLiteRepository{db: db, readDB: db}, nil
+	}
+
+	readDB, err := openSQLiteReaders(dbPath, opts)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteRepository{db: db, readDB: readDB, dbPath: dbPath, opts: opts}, nil
+}
+
+// OpenMigrator abre la base de datos sin aplicar migraciones, para gestionarlas
+// manualmente (iot-server migrate). El llamante debe cerrar la conexión con Close.
+func OpenMigrator(dbPath string) (*Migrator, error) {
+	db, err := openSQLite(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	migrator.ownsDB = true
+
+	return migrator, nil
+}
+
+// openSQLite abre la conexión de escritura con las opciones por defecto
+func openSQLite(dbPath string) (*sql.DB, error) {
+	opts, _ := SQLiteOptions{}.withDefaults()
+	return openSQLiteWriter(dbPath, opts)
+}
+
+// openSQLiteWriter abre la conexión de escritura (single-writer) y activa WAL.
+// journal_mode es persistente en el fichero, así que los lectores no necesitan fijarlo.
+func openSQLiteWriter(dbPath string, opts SQLiteOptions) (*sql.DB, error) {
+	pragmas := sqlitePragmas(opts)
+	if !isMemoryDSN(dbPath) {
+		pragmas = append(pragmas, "journal_mode(WAL)")
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(dbPath, pragmas))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	// Configurar límites de conexión para SQLite (single-writer)
 	db.SetMaxOpenConns(1)
 	db.SetMaxIdleConns(1)
 
-	// Aplicar schema
-	if _, err := db.Exec(schemaSQL); err != nil {
+	// Abrir ya la conexión para que WAL esté activo antes de crear los lectores
+	if err := db.Ping(); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to apply schema: %w", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
 
-	return &SQLiteRepository{db: db}, nil
+	return db, nil
 }
 
-// SaveReading guarda una lectura de sensor en la base de datos.
-func (r *SQLiteRepository) SaveReading(ctx context.Context, reading *sensor.SensorReading) error {
-	query := `
-		INSERT INTO sensor_readings (id, sensor_id, type, value, unit, error, timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`
+// openSQLiteReaders abre el pool de conexiones de solo lectura
+func openSQLiteReaders(dbPath string, opts SQLiteOptions) (*sql.DB, error) {
+	pragmas := append(sqlitePragmas(opts), "query_only(1)")
 
-	_, err := r.db.ExecContext(
+	db, err := sql.Open("sqlite", sqliteDSN(dbPath, pragmas))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open read pool: %w", err)
+	}
+
+	db.SetMaxOpenConns(opts.ReadConns)
+	db.SetMaxIdleConns(opts.ReadConns)
+
+	return db, nil
+}
+
+// sqlitePragmas retorna los pragmas comunes a escritor y lectores
+func sqlitePragmas(opts SQLiteOptions) []string {
+	pragmas := []string{
+		fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()),
+		fmt.Sprintf("synchronous(%s)", opts.Synchronous),
+	}
+	if opts.CacheSize != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("cache_size(%d)", opts.CacheSize))
+	}
+	return pragmas
+}
+
+// sqliteDSN añade los pragmas como parámetros _pragma que el driver ejecuta en cada conexión nueva
+func sqliteDSN(dbPath string, pragmas []string) string {
+	params := make([]string, 0, len(pragmas))
+	for _, pragma := range pragmas {
+		params = append(params, "_pragma="+url.QueryEscape(pragma))
+	}
+
+	separator := "?"
+	if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return dbPath + separator + strings.Join(params, "&")
+}
+
+// isMemoryDSN indica si la base de datos vive solo en memoria de la conexión
+func isMemoryDSN(dbPath string) bool {
+	return dbPath == ":memory:" || strings.Contains(dbPath, "mode=memory")
+}
+
+// insertReadingSQL inserta una lectura; un id ya guardado no modifica nada (RowsAffected = 0)
+const insertReadingSQL = `
+	INSERT INTO sensor_readings (id, sensor_id, type, value, raw_value, unit, error, timestamp)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO NOTHING
+`
+
+// SaveReading guarda una lectura de sensor en la base de datos. Es idempotente: si el id
+// ya existe la lectura se ignora y se cuenta como duplicada.
+func (r *SQLiteRepository) SaveReading(ctx context.Context, reading *sensor.SensorReading) error {
+	result, err := r.db.ExecContext(
 		ctx,
-		query,
+		insertReadingSQL,
 		reading.ID,
 		reading.SensorID,
 		reading.Type,
 		reading.Value,
+		reading.RawValue, // NULL si la lectura no está calibrada
 		reading.Unit,
 		reading.Error, // NULL si no hay error
 		reading.Timestamp.UTC(),
@@ -67,26 +242,299 @@ func (r *SQLiteRepository) SaveReading(ctx context.Context, reading *sensor.Sens
 	if err != nil {
 		return fmt.Errorf("failed to save reading %s: %w", reading.ID, err)
 	}
+	r.countDuplicate(result)
 
 	return nil
 }
 
+// SaveReadings guarda un lote de lecturas en una única transacción con una sentencia
+// preparada. Si alguna lectura falla se deshace el lote completo; los ids ya guardados
+// se ignoran como en SaveReading.
+func (r *SQLiteRepository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	if len(readings) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin batch transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insertReadingSQL)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch insert: %w", err)
+	}
+	defer stmt.Close()
+
+	var duplicates int64
+	for _, reading := range readings {
+		result, err := stmt.ExecContext(
+			ctx,
+			reading.ID,
+			reading.SensorID,
+			reading.Type,
+			reading.Value,
+			reading.RawValue,
+			reading.Unit,
+			reading.Error,
+			reading.Timestamp.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save reading %s in batch: %w", reading.ID, err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			duplicates++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit batch of %d readings: %w", len(readings), err)
+	}
+	r.duplicates.Add(duplicates)
+
+	return nil
+}
+
+// countDuplicate cuenta la lectura como duplicada si el INSERT no ha afectado a ninguna fila
+func (r *SQLiteRepository) countDuplicate(result sql.Result) {
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		r.duplicates.Add(1)
+	}
+}
+
+// DuplicateReadings retorna cuántas lecturas han ignorado SaveReading/SaveReadings por
+// tener un id ya guardado
+func (r *SQLiteRepository) DuplicateReadings() int64 {
+	return r.duplicates.Load()
+}
+
+// ImportReadings guarda un lote de lecturas en una única transacción; ON CONFLICT omite
+// los ids que ya existen y RowsAffected indica qué lecturas se han omitido (se reportan
+// al cliente, no cuentan como duplicadas)
+func (r *SQLiteRepository) ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]repository.SkippedReading, error) {
+	if len(readings) == 0 {
+		return nil, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin import transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, insertReadingSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare import insert: %w", err)
+	}
+	defer stmt.Close()
+
+	var skipped []repository.SkippedReading
+	for _, reading := range readings {
+		result, err := stmt.ExecContext(
+			ctx,
+			reading.ID,
+			reading.SensorID,
+			reading.Type,
+			reading.Value,
+			reading.RawValue,
+			reading.Unit,
+			reading.Error,
+			reading.Timestamp.UTC(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to import reading %s: %w", reading.ID, err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			skipped = append(skipped, repository.SkippedReading{ID: reading.ID, Reason: repository.SkipDuplicateID})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit import of %d readings: %w", len(readings), err)
+	}
+
+	return skipped, nil
+}
+
 // GetLatestReadings obtiene las últimas N lecturas de un sensor ordenadas por timestamp descendente.
 func (r *SQLiteRepository) GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error) {
 	query := `
-		SELECT id, sensor_id, type, value, unit, error, timestamp
+		SELECT id, sensor_id, type, value, raw_value, unit, error, timestamp
 		FROM sensor_readings
 		WHERE sensor_id = ?
 		ORDER BY timestamp DESC
 		LIMIT ?
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, sensorID, limit)
+	rows, err := r.readDB.QueryContext(ctx, query, sensorID, limit)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query readings for sensor %s: %w", sensorID, err)
 	}
 	defer rows.Close()
 
+	return scanReadings(rows)
+}
+
+// GetReadingsByTimeRange obtiene lecturas en un rango temporal específico.
+func (r *SQLiteRepository) GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error) {
+	query := `
+		SELECT id, sensor_id, type, value, raw_value, unit, error, timestamp
+		FROM sensor_readings
+		WHERE sensor_id = ? AND timestamp >= ? AND timestamp <= ?
+		ORDER BY timestamp DESC
+	`
+
+	rows, err := r.readDB.QueryContext(ctx, query, sensorID, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query readings in time range: %w", err)
+	}
+	defer rows.Close()
+
+	return scanReadings(rows)
+}
+
+// GetReadingsPage obtiene una página de lecturas en orden (timestamp, id) descendente.
+// Pide limit+1 filas para saber si queda otra página sin una consulta COUNT.
+func (r *SQLiteRepository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", limit)
+	}
+
+	query := `
+		SELECT id, sensor_id, type, value, raw_value, unit, error, timestamp
+		FROM sensor_readings
+		WHERE sensor_id = ?`
+	args := []interface{}{sensorID}
+	if cursor != nil {
+		// Los timestamps se guardan como texto UTC, cuyo orden lexicográfico coincide
+		// con el temporal (igual que en GetReadingsByTimeRange)
+		query += ` AND (timestamp < ? OR (timestamp = ? AND id < ?))`
+		ts := cursor.Timestamp.UTC()
+		args = append(args, ts, ts, cursor.ID)
+	}
+	query += `
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := r.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query readings page for sensor %s: %w", sensorID, err)
+	}
+	defer rows.Close()
+
+	readings, err := scanReadings(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readings, next := paginate(readings, limit)
+	return readings, next, nil
+}
+
+// GetReadingsRangePage obtiene una página de lecturas del rango [start, end] en orden
+// (timestamp, id) ascendente, la de las exportaciones. Como GetReadingsPage, pide limit+1 filas.
+func (r *SQLiteRepository) GetReadingsRangePage(ctx context.Context, sensorID string, start, end time.Time, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", limit)
+	}
+
+	query := `
+		SELECT id, sensor_id, type, value, raw_value, unit, error, timestamp
+		FROM sensor_readings
+		WHERE sensor_id = ? AND timestamp >= ? AND timestamp <= ?`
+	args := []interface{}{sensorID, start.UTC(), end.UTC()}
+	if cursor != nil {
+		query += ` AND (timestamp > ? OR (timestamp = ? AND id > ?))`
+		ts := cursor.Timestamp.UTC()
+		args = append(args, ts, ts, cursor.ID)
+	}
+	query += `
+		ORDER BY timestamp ASC, id ASC
+		LIMIT ?`
+	args = append(args, limit+1)
+
+	rows, err := r.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query readings range page for sensor %s: %w", sensorID, err)
+	}
+	defer rows.Close()
+
+	readings, err := scanReadings(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readings, next := paginate(readings, limit)
+	return readings, next, nil
+}
+
+// SearchReadings obtiene una página de lecturas de varios sensores uniendo sensor_readings
+// con los metadatos de sensors (y sensor_tags si se filtra por etiquetas)
+func (r *SQLiteRepository) SearchReadings(ctx context.Context, q repository.ReadingSearch) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if q.Limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", q.Limit)
+	}
+
+	query := `
+		SELECT r.id, r.sensor_id, r.type, r.value, r.raw_value, r.unit, r.error, r.timestamp
+		FROM sensor_readings r
+		JOIN sensors s ON s.id = r.sensor_id
+		WHERE 1 = 1`
+	var args []interface{}
+	if q.Type != "" {
+		query += ` AND s.type = ?`
+		args = append(args, q.Type)
+	}
+	if q.Location != "" {
+		query += ` AND s.location = ?`
+		args = append(args, q.Location)
+	}
+	if len(q.Tags) > 0 {
+		// El sensor debe tener todas las etiquetas (las repetidas en el filtro cuentan una vez)
+		tags := slices.Compact(slices.Sorted(slices.Values(q.Tags)))
+		query += ` AND (SELECT COUNT(*) FROM sensor_tags t WHERE t.sensor_id = s.id AND t.tag IN (?` + strings.Repeat(`, ?`, len(tags)-1) + `)) = ?`
+		for _, tag := range tags {
+			args = append(args, tag)
+		}
+		args = append(args, len(tags))
+	}
+	if !q.Start.IsZero() {
+		query += ` AND r.timestamp >= ?`
+		args = append(args, q.Start.UTC())
+	}
+	if !q.End.IsZero() {
+		query += ` AND r.timestamp <= ?`
+		args = append(args, q.End.UTC())
+	}
+	if q.Cursor != nil {
+		query += ` AND (r.timestamp < ? OR (r.timestamp = ? AND r.id < ?))`
+		ts := q.Cursor.Timestamp.UTC()
+		args = append(args, ts, ts, q.Cursor.ID)
+	}
+	query += `
+		ORDER BY r.timestamp DESC, r.id DESC
+		LIMIT ?`
+	args = append(args, q.Limit+1)
+
+	rows, err := r.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search readings: %w", err)
+	}
+	defer rows.Close()
+
+	readings, err := scanReadings(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readings, next := paginate(readings, q.Limit)
+	return readings, next, nil
+}
+
+// scanReadings convierte las filas (id, sensor_id, type, value, raw_value, unit, error, timestamp) en lecturas
+func scanReadings(rows *sql.Rows) ([]*sensor.SensorReading, error) {
 	var readings []*sensor.SensorReading
 	for rows.Next() {
 		var r sensor.SensorReading
@@ -98,6 +546,7 @@ func (r *SQLiteRepository) GetLatestReadings(ctx context.Context, sensorID strin
 			&r.SensorID,
 			&sType,
 			&r.Value,
+			&r.RawValue,
 			&r.Unit,
 			&r.Error,
 			&timestamp,
@@ -129,81 +578,196 @@ func (r *SQLiteRepository) GetLatestReadings(ctx context.Context, sensorID strin
 	return readings, nil
 }
 
-// GetReadingsByTimeRange obtiene lecturas en un rango temporal específico.
-func (r *SQLiteRepository) GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error) {
-	query := `
-		SELECT id, sensor_id, type, value, unit, error, timestamp
+// epochSecondsSQL convierte la columna timestamp a segundos epoch.
+// Los timestamps se guardan como texto "YYYY-MM-DD HH:MM:SS..." en UTC;
+// los primeros 19 caracteres son parseables por strftime.
+const epochSecondsSQL = `CAST(strftime('%s', substr(timestamp, 1, 19)) AS INTEGER)`
+
+// rollupTables asocia cada bucket con la tabla de resúmenes que lo materializa
+var rollupTables = map[time.Duration]string{
+	time.Hour:      "sensor_readings_hourly",
+	24 * time.Hour: "sensor_readings_daily",
+}
+
+// GetAggregatedReadings agrupa las lecturas válidas de un sensor en buckets de tamaño fijo.
+// El bucket se calcula sobre el epoch en segundos, por lo que queda alineado en UTC.
+// Para buckets de 1h y 1d se fusionan además los resúmenes generados por la política de
+// retención, de modo que las tendencias siguen disponibles tras purgar las lecturas crudas.
+// SQLite no tiene STDDEV, así que se devuelven las sumas y se calcula en Go.
+func (r *SQLiteRepository) GetAggregatedReadings(ctx context.Context, sensorID string, start, end time.Time, bucket time.Duration) ([]*sensor.ReadingAggregate, error) {
+	bucketSecs := int64(bucket / time.Second)
+	if bucketSecs <= 0 {
+		return nil, fmt.Errorf("invalid bucket size: %s", bucket)
+	}
+
+	rawQuery := `
+		SELECT
+			(` + epochSecondsSQL + ` / ?) * ? AS bucket,
+			COUNT(*) AS cnt,
+			MIN(value) AS min_value,
+			MAX(value) AS max_value,
+			SUM(value) AS sum_value,
+			SUM(value * value) AS sum_squares
 		FROM sensor_readings
 		WHERE sensor_id = ? AND timestamp >= ? AND timestamp <= ?
-		ORDER BY timestamp DESC
+			AND (error IS NULL OR error = '')
+		GROUP BY bucket
 	`
+	args := []interface{}{bucketSecs, bucketSecs, sensorID, start.UTC(), end.UTC()}
 
-	rows, err := r.db.QueryContext(ctx, query, sensorID, start.UTC(), end.UTC())
+	query := rawQuery + ` ORDER BY bucket ASC`
+	if table, ok := rollupTables[bucket]; ok {
+		query = `
+			SELECT bucket, SUM(cnt), MIN(min_value), MAX(max_value), SUM(sum_value), SUM(sum_squares)
+			FROM (` + rawQuery + `
+				UNION ALL
+				SELECT bucket_start, count, min_value, max_value, sum_value, sum_squares
+				FROM ` + table + `
+				WHERE sensor_id = ? AND bucket_start >= ? AND bucket_start <= ?
+			)
+			GROUP BY bucket
+			ORDER BY bucket ASC
+		`
+		args = append(args, sensorID, start.UTC().Truncate(bucket).Unix(), end.UTC().Unix())
+	}
+
+	rows, err := r.readDB.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query readings in time range: %w", err)
+		return nil, fmt.Errorf("failed to aggregate readings for sensor %s: %w", sensorID, err)
 	}
 	defer rows.Close()
 
-	var readings []*sensor.SensorReading
+	var aggregates []*sensor.ReadingAggregate
 	for rows.Next() {
-		var r sensor.SensorReading
-		var sType string
-		var timestamp string
+		var bucketStart int64
+		var sum, sumSquares float64
+		agg := sensor.ReadingAggregate{SensorID: sensorID}
 
-		err := rows.Scan(
-			&r.ID,
-			&r.SensorID,
-			&sType,
-			&r.Value,
-			&r.Unit,
-			&r.Error,
-			&timestamp,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan reading: %w", err)
+		if err := rows.Scan(&bucketStart, &agg.Count, &agg.Min, &agg.Max, &sum, &sumSquares); err != nil {
+			return nil, fmt.Errorf("failed to scan aggregate: %w", err)
 		}
 
-		r.Type = sensor.SensorType(sType)
+		n := float64(agg.Count)
+		agg.BucketStart = time.Unix(bucketStart, 0).UTC()
+		agg.Avg = sum / n
+		// Var = E[x²] - E[x]², acotada a 0 por errores de redondeo
+		agg.StdDev = math.Sqrt(math.Max(0, sumSquares/n-agg.Avg*agg.Avg))
 
-		// Parsear timestamp (SQLite guarda en formato RFC3339)
-		r.Timestamp, err = time.Parse(time.RFC3339Nano, timestamp)
-		if err != nil {
-			// Intentar sin nanosegundos
-			r.Timestamp, err = time.Parse(time.RFC3339, timestamp)
-			if err != nil {
-				return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		aggregates = append(aggregates, &agg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating aggregates: %w", err)
+	}
+
+	return aggregates, nil
+}
+
+// ApplyRetention elimina las lecturas crudas que cumplen la política dentro de una transacción.
+// Si policy.Rollup está activo, antes de borrar consolida las lecturas válidas en las tablas
+// de resúmenes horario y diario, fusionando con los buckets ya existentes.
+func (r *SQLiteRepository) ApplyRetention(ctx context.Context, policy repository.RetentionPolicy) (int64, error) {
+	where := `timestamp < ?`
+	args := []interface{}{policy.Before.UTC()}
+
+	if policy.SensorType != "" {
+		where += ` AND type = ?`
+		args = append(args, policy.SensorType)
+	}
+	if len(policy.ExcludeTypes) > 0 {
+		placeholders := strings.Repeat("?, ", len(policy.ExcludeTypes)-1) + "?"
+		where += ` AND type NOT IN (` + placeholders + `)`
+		for _, t := range policy.ExcludeTypes {
+			args = append(args, t)
+		}
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin retention transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if policy.Rollup {
+		for bucket, table := range rollupTables {
+			bucketSecs := int64(bucket / time.Second)
+			query := `
+				INSERT INTO ` + table + ` (sensor_id, type, bucket_start, count, min_value, max_value, sum_value, sum_squares)
+				SELECT sensor_id, type, (` + epochSecondsSQL + ` / ?) * ? AS bucket,
+					COUNT(*), MIN(value), MAX(value), SUM(value), SUM(value * value)
+				FROM sensor_readings
+				WHERE ` + where + ` AND (error IS NULL OR error = '')
+				GROUP BY sensor_id, bucket
+				ON CONFLICT(sensor_id, bucket_start) DO UPDATE SET
+					count = count + excluded.count,
+					min_value = MIN(min_value, excluded.min_value),
+					max_value = MAX(max_value, excluded.max_value),
+					sum_value = sum_value + excluded.sum_value,
+					sum_squares = sum_squares + excluded.sum_squares
+			`
+			rollupArgs := append([]interface{}{bucketSecs, bucketSecs}, args...)
+			if _, err := tx.ExecContext(ctx, query, rollupArgs...); err != nil {
+				return 0, fmt.Errorf("failed to roll up readings into %s: %w", table, err)
 			}
 		}
+	}
 
-		readings = append(readings, &r)
+	result, err := tx.ExecContext(ctx, `DELETE FROM sensor_readings WHERE `+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge expired readings: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating readings: %w", err)
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count purged readings: %w", err)
 	}
 
-	return readings, nil
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit retention: %w", err)
+	}
+
+	return deleted, nil
 }
 
 // SaveConfig guarda o actualiza la configuración de un sensor.
-// Usa UPSERT (INSERT ... ON CONFLICT) para actualizar si ya existe.
+// Usa UPSERT (INSERT ... ON CONFLICT) para actualizar si ya existe y, en la misma
+// transacción, registra una nueva revisión en el historial si la config ha cambiado.
 func (r *SQLiteRepository) SaveConfig(ctx context.Context, config *sensor.SensorConfig) error {
+	calibration, err := calibrationJSON(config.Calibration)
+	if err != nil {
+		return fmt.Errorf("failed to encode calibration for sensor %s: %w", config.SensorID, err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin config transaction: %w", err)
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO sensor_configs (sensor_id, interval, threshold, enabled, updated_at)
-		VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)
+		INSERT INTO sensor_configs (sensor_id, interval, threshold, low_threshold, hysteresis, max_rate, calibration, enabled, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)
 		ON CONFLICT(sensor_id) DO UPDATE SET
 			interval = excluded.interval,
 			threshold = excluded.threshold,
+			low_threshold = excluded.low_threshold,
+			hysteresis = excluded.hysteresis,
+			max_rate = excluded.max_rate,
+			calibration = excluded.calibration,
 			enabled = excluded.enabled,
 			updated_at = CURRENT_TIMESTAMP
 	`
 
-	_, err := r.db.ExecContext(
+	_, err = tx.ExecContext(
 		ctx,
 		query,
 		config.SensorID,
 		config.Interval,
 		config.Threshold,
+		config.LowThreshold,
+		config.Hysteresis,
+		config.MaxRate,
+		calibration,
 		config.Enabled,
 	)
 
@@ -211,13 +775,51 @@ func (r *SQLiteRepository) SaveConfig(ctx context.Context, config *sensor.Sensor
 		return fmt.Errorf("failed to save config for sensor %s: %w", config.SensorID, err)
 	}
 
+	if err := r.recordConfigRevision(ctx, tx, config, calibration); err != nil {
+		return fmt.Errorf("failed to record config history for sensor %s: %w", config.SensorID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit config for sensor %s: %w", config.SensorID, err)
+	}
+
 	return nil
 }
 
+// recordConfigRevision añade una revisión al historial salvo que la config coincida con la
+// última; calibration es la calibración ya serializada para la columna
+func (r *SQLiteRepository) recordConfigRevision(ctx context.Context, tx *sql.Tx, config *sensor.SensorConfig, calibration interface{}) error {
+	var last sensor.SensorConfig
+	var revision int
+	err := tx.QueryRowContext(ctx, `
+		SELECT revision, interval, threshold, low_threshold, hysteresis, max_rate, calibration, enabled
+		FROM sensor_config_history
+		WHERE sensor_id = ?
+		ORDER BY revision DESC
+		LIMIT 1
+	`, config.SensorID).Scan(&revision, &last.Interval, &last.Threshold, &last.LowThreshold, &last.Hysteresis, &last.MaxRate, calibrationColumn{&last.Calibration}, &last.Enabled)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	last.SensorID = config.SensorID
+	if err == nil && last.Equal(*config) {
+		return nil
+	}
+
+	change := repository.ConfigChangeFromContext(ctx)
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO sensor_config_history (sensor_id, revision, interval, threshold, low_threshold, hysteresis, max_rate, calibration, enabled, changed_by, reason, changed_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, config.SensorID, revision+1, config.Interval, config.Threshold, config.LowThreshold, config.Hysteresis, config.MaxRate, calibration, config.Enabled,
+		change.ChangedBy, change.Reason, time.Now().UTC())
+	return err
+}
+
 // GetConfig obtiene la configuración de un sensor.
 func (r *SQLiteRepository) GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
 	query := `
-		SELECT sensor_id, interval, threshold, enabled
+		SELECT sensor_id, interval, threshold, low_threshold, hysteresis, max_rate, calibration, enabled
 		FROM sensor_configs
 		WHERE sensor_id = ?
 	`
@@ -225,10 +827,14 @@ func (r *SQLiteRepository) GetConfig(ctx context.Context, sensorID string) (*sen
 	var config sensor.SensorConfig
 	var enabled int // SQLite guarda bool como INTEGER
 
-	err := r.db.QueryRowContext(ctx, query, sensorID).Scan(
+	err := r.readDB.QueryRowContext(ctx, query, sensorID).Scan(
 		&config.SensorID,
 		&config.Interval,
 		&config.Threshold,
+		&config.LowThreshold,
+		&config.Hysteresis,
+		&config.MaxRate,
+		calibrationColumn{&config.Calibration},
 		&enabled,
 	)
 
@@ -245,8 +851,396 @@ func (r *SQLiteRepository) GetConfig(ctx context.Context, sensorID string) (*sen
 	return &config, nil
 }
 
+// DeleteConfig elimina la configuración actual de un sensor (idempotente).
+// El historial se conserva en sensor_config_history.
+func (r *SQLiteRepository) DeleteConfig(ctx context.Context, sensorID string) error {
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM sensor_configs WHERE sensor_id = ?`, sensorID); err != nil {
+		return fmt.Errorf("failed to delete config for sensor %s: %w", sensorID, err)
+	}
+	return nil
+}
+
+// GetConfigHistory obtiene las revisiones de configuración de un sensor, la más reciente primero.
+func (r *SQLiteRepository) GetConfigHistory(ctx context.Context, sensorID string, limit int) ([]*sensor.ConfigRevision, error) {
+	if limit <= 0 {
+		limit = -1 // Sin límite en SQLite
+	}
+
+	query := `
+		SELECT revision, interval, threshold, low_threshold, hysteresis, max_rate, calibration, enabled, changed_by, reason, changed_at
+		FROM sensor_config_history
+		WHERE sensor_id = ?
+		ORDER BY revision DESC
+		LIMIT ?
+	`
+
+	rows, err := r.readDB.QueryContext(ctx, query, sensorID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query config history for sensor %s: %w", sensorID, err)
+	}
+	defer rows.Close()
+
+	var history []*sensor.ConfigRevision
+	for rows.Next() {
+		rev := sensor.ConfigRevision{Config: sensor.SensorConfig{SensorID: sensorID}}
+
+		err := rows.Scan(
+			&rev.Revision,
+			&rev.Config.Interval,
+			&rev.Config.Threshold,
+			&rev.Config.LowThreshold,
+			&rev.Config.Hysteresis,
+			&rev.Config.MaxRate,
+			calibrationColumn{&rev.Config.Calibration},
+			&rev.Config.Enabled,
+			&rev.ChangedBy,
+			&rev.Reason,
+			&rev.ChangedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan config revision: %w", err)
+		}
+
+		rev.ChangedAt = rev.ChangedAt.UTC()
+		history = append(history, &rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating config history: %w", err)
+	}
+
+	return history, nil
+}
+
+// calibrationJSON serializa una calibración para la columna calibration (NULL si no
+// corrige los valores)
+func calibrationJSON(c *sensor.Calibration) (interface{}, error) {
+	if c.IsIdentity() {
+		return nil, nil
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// calibrationColumn lee la columna calibration (JSON o NULL) en dest
+type calibrationColumn struct {
+	dest **sensor.Calibration
+}
+
+// Scan implementa sql.Scanner
+func (c calibrationColumn) Scan(src interface{}) error {
+	*c.dest = nil
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		return nil
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	default:
+		return fmt.Errorf("unexpected calibration column type %T", src)
+	}
+	var calibration sensor.Calibration
+	if err := json.Unmarshal(data, &calibration); err != nil {
+		return fmt.Errorf("invalid calibration: %w", err)
+	}
+	*c.dest = &calibration
+	return nil
+}
+
+// SaveAlert guarda una alerta generada por el simulador.
+func (r *SQLiteRepository) SaveAlert(ctx context.Context, alert *sensor.Alert) error {
+	query := `
+		INSERT INTO alerts (id, sensor_id, type, kind, severity, value, threshold, unit, message, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+
+	_, err := r.db.ExecContext(
+		ctx,
+		query,
+		alert.ID,
+		alert.SensorID,
+		alert.Type,
+		alert.Kind,
+		alert.Severity,
+		alert.Value,
+		alert.Threshold,
+		alert.Unit,
+		alert.Message,
+		alert.Timestamp.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save alert %s: %w", alert.ID, err)
+	}
+
+	return nil
+}
+
+// GetAlerts obtiene las alertas que cumplen el filtro ordenadas por timestamp descendente.
+func (r *SQLiteRepository) GetAlerts(ctx context.Context, filter repository.AlertFilter) ([]*sensor.Alert, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.SensorID != "" {
+		conditions = append(conditions, "sensor_id = ?")
+		args = append(args, filter.SensorID)
+	}
+	if filter.Severity != "" {
+		conditions = append(conditions, "severity = ?")
+		args = append(args, filter.Severity)
+	}
+	if !filter.Start.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, filter.Start.UTC())
+	}
+	if !filter.End.IsZero() {
+		conditions = append(conditions, "timestamp <= ?")
+		args = append(args, filter.End.UTC())
+	}
+
+	query := `
+		SELECT id, sensor_id, type, kind, severity, value, threshold, unit, message, timestamp
+		FROM alerts`
+	if len(conditions) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+	query += "\n\t\tORDER BY timestamp DESC\n\t\tLIMIT ?"
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1 // Sin límite en SQLite
+	}
+	args = append(args, limit)
+
+	rows, err := r.readDB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []*sensor.Alert
+	for rows.Next() {
+		var a sensor.Alert
+		var sType, kind, severity string
+
+		err := rows.Scan(
+			&a.ID,
+			&a.SensorID,
+			&sType,
+			&kind,
+			&severity,
+			&a.Value,
+			&a.Threshold,
+			&a.Unit,
+			&a.Message,
+			&a.Timestamp,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert: %w", err)
+		}
+
+		a.Type = sensor.SensorType(sType)
+		a.Kind = sensor.AlertKind(kind)
+		a.Severity = sensor.AlertSeverity(severity)
+		a.Timestamp = a.Timestamp.UTC()
+		alerts = append(alerts, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating alerts: %w", err)
+	}
+
+	return alerts, nil
+}
+
+// SaveSensor guarda o actualiza los metadatos de un sensor y sustituye sus etiquetas
+// en una transacción. Usa UPSERT para que un re-registro no pierda created_at.
+func (r *SQLiteRepository) SaveSensor(ctx context.Context, s *sensor.Sensor) error {
+	source := s.Source
+	if source == "" {
+		source = sensor.SensorSourceRegister
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin sensor transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO sensors (id, type, name, location, source, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT(id) DO UPDATE SET
+			type = excluded.type,
+			name = excluded.name,
+			location = excluded.location,
+			source = excluded.source,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	if _, err := tx.ExecContext(ctx, query, s.ID, s.Type, s.Name, s.Location, source); err != nil {
+		return fmt.Errorf("failed to save sensor %s: %w", s.ID, err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sensor_tags WHERE sensor_id = ?`, s.ID); err != nil {
+		return fmt.Errorf("failed to replace tags of sensor %s: %w", s.ID, err)
+	}
+	for _, tag := range s.Tags {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO sensor_tags (sensor_id, tag) VALUES (?, ?)`, s.ID, tag); err != nil {
+			return fmt.Errorf("failed to save tag %q of sensor %s: %w", tag, s.ID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sensor %s: %w", s.ID, err)
+	}
+	return nil
+}
+
+// GetSensor obtiene los metadatos de un sensor registrado.
+func (r *SQLiteRepository) GetSensor(ctx context.Context, sensorID string) (*sensor.Sensor, error) {
+	sensors, err := r.querySensors(ctx, `WHERE id = ?`, sensorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sensor %s: %w", sensorID, err)
+	}
+	if len(sensors) == 0 {
+		return nil, fmt.Errorf("sensor %s not found", sensorID)
+	}
+	return sensors[0], nil
+}
+
+// ListSensors obtiene todos los sensores registrados ordenados por ID.
+func (r *SQLiteRepository) ListSensors(ctx context.Context) ([]*sensor.Sensor, error) {
+	sensors, err := r.querySensors(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensors: %w", err)
+	}
+	return sensors, nil
+}
+
+// querySensors obtiene los sensores que cumplen where (vacío = todos) con sus etiquetas,
+// ordenados por ID
+func (r *SQLiteRepository) querySensors(ctx context.Context, where string, args ...interface{}) ([]*sensor.Sensor, error) {
+	rows, err := r.readDB.QueryContext(ctx, `
+		SELECT id, type, name, location, source
+		FROM sensors `+where+`
+		ORDER BY id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sensors []*sensor.Sensor
+	byID := make(map[string]*sensor.Sensor)
+	for rows.Next() {
+		var s sensor.Sensor
+		var sType string
+
+		if err := rows.Scan(&s.ID, &sType, &s.Name, &s.Location, &s.Source); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor: %w", err)
+		}
+
+		s.Type = sensor.SensorType(sType)
+		sensors = append(sensors, &s)
+		byID[s.ID] = &s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sensors: %w", err)
+	}
+	if len(sensors) == 0 {
+		return nil, nil
+	}
+
+	tagRows, err := r.readDB.QueryContext(ctx, `SELECT sensor_id, tag FROM sensor_tags ORDER BY sensor_id, tag`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor tags: %w", err)
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var sensorID, tag string
+		if err := tagRows.Scan(&sensorID, &tag); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor tag: %w", err)
+		}
+		if s, ok := byID[sensorID]; ok {
+			s.Tags = append(s.Tags, tag)
+		}
+	}
+	if err := tagRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sensor tags: %w", err)
+	}
+
+	return sensors, nil
+}
+
+// DeleteSensor elimina los metadatos y etiquetas de un sensor registrado.
+// No falla si el sensor no existe (operación idempotente).
+func (r *SQLiteRepository) DeleteSensor(ctx context.Context, sensorID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin sensor transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sensor_tags WHERE sensor_id = ?`, sensorID); err != nil {
+		return fmt.Errorf("failed to delete tags of sensor %s: %w", sensorID, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sensors WHERE id = ?`, sensorID); err != nil {
+		return fmt.Errorf("failed to delete sensor %s: %w", sensorID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sensor deletion %s: %w", sensorID, err)
+	}
+	return nil
+}
+
+// PurgeSensorData elimina en una transacción las lecturas, resúmenes, alertas e historial
+// de configuración de un sensor.
+func (r *SQLiteRepository) PurgeSensorData(ctx context.Context, sensorID string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin purge transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM sensor_readings WHERE sensor_id = ?`, sensorID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge readings of sensor %s: %w", sensorID, err)
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count purged readings: %w", err)
+	}
+
+	tables := []string{"alerts", "sensor_config_history"}
+	for _, table := range rollupTables {
+		tables = append(tables, table)
+	}
+	for _, table := range tables {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE sensor_id = ?`, sensorID); err != nil {
+			return 0, fmt.Errorf("failed to purge %s of sensor %s: %w", table, sensorID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit purge of sensor %s: %w", sensorID, err)
+	}
+
+	return deleted, nil
+}
+
 // Close cierra la conexión a la base de datos.
 func (r *SQLiteRepository) Close() error {
+	if r.readDB != r.db {
+		if err := r.readDB.Close(); err != nil {
+			return fmt.Errorf("failed to close read pool: %w", err)
+		}
+	}
 	if err := r.db.Close(); err != nil {
 		return fmt.Errorf("failed to close database: %w", err)
 	}
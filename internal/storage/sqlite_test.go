@@ -2,9 +2,15 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
 	"github.com/alejandro/technical_test_uvigo/internal/sensor"
 )
 
@@ -326,3 +332,585 @@ func TestSQLiteRepository_GetReadingsByTimeRange(t *testing.T) {
 		t.Error("expected read-002 and read-003 in time range")
 	}
 }
+
+func TestSQLiteRepository_SaveAndListSensors(t *testing.T) {
+	repo, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	sensors := []*sensor.Sensor{
+		{ID: "temp-010", Type: sensor.SensorTypeTemperature, Name: "Temp 10", Location: "almacen"},
+		{ID: "hum-010", Type: sensor.SensorTypeHumidity, Name: "Hum 10"},
+	}
+	for _, s := range sensors {
+		if err := repo.SaveSensor(ctx, s); err != nil {
+			t.Fatalf("SaveSensor failed: %v", err)
+		}
+	}
+
+	// Re-registro: debe actualizar metadatos sin duplicar
+	sensors[0].Location = "sala-principal"
+	if err := repo.SaveSensor(ctx, sensors[0]); err != nil {
+		t.Fatalf("SaveSensor (update) failed: %v", err)
+	}
+
+	listed, err := repo.ListSensors(ctx)
+	if err != nil {
+		t.Fatalf("ListSensors failed: %v", err)
+	}
+
+	if len(listed) != 2 {
+		t.Fatalf("expected 2 sensors, got %d", len(listed))
+	}
+
+	// Ordenados por ID
+	if listed[0].ID != "hum-010" || listed[1].ID != "temp-010" {
+		t.Errorf("expected sensors ordered by id, got %s, %s", listed[0].ID, listed[1].ID)
+	}
+
+	if listed[1].Location != "sala-principal" {
+		t.Errorf("expected updated location sala-principal, got %s", listed[1].Location)
+	}
+
+	if listed[1].Type != sensor.SensorTypeTemperature {
+		t.Errorf("expected type temperature, got %s", listed[1].Type)
+	}
+}
+
+func TestSQLiteRepository_GetSensorNotFound(t *testing.T) {
+	repo, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	if _, err := repo.GetSensor(context.Background(), "nonexistent"); err == nil {
+		t.Error("expected error for nonexistent sensor")
+	}
+}
+
+func TestSQLiteRepository_GetAggregatedReadings(t *testing.T) {
+	repo, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	// Dos buckets de 5 minutos: 12:00 (20, 22, 24) y 12:05 (30) + una lectura con error
+	baseTime := time.Date(2025, 10, 16, 12, 0, 0, 0, time.UTC)
+	errorMsg := "sensor timeout"
+	readings := []*sensor.SensorReading{
+		{ID: "read-001", Value: 20.0, Timestamp: baseTime.Add(30 * time.Second)},
+		{ID: "read-002", Value: 22.0, Timestamp: baseTime.Add(2 * time.Minute)},
+		{ID: "read-003", Value: 24.0, Timestamp: baseTime.Add(4*time.Minute + 500*time.Millisecond)},
+		{ID: "read-004", Value: 0.0, Timestamp: baseTime.Add(3 * time.Minute), Error: &errorMsg},
+		{ID: "read-005", Value: 30.0, Timestamp: baseTime.Add(6 * time.Minute)},
+	}
+	for _, r := range readings {
+		r.SensorID = "temp-001"
+		r.Type = sensor.SensorTypeTemperature
+		r.Unit = "°C"
+		if err := repo.SaveReading(ctx, r); err != nil {
+			t.Fatalf("SaveReading failed: %v", err)
+		}
+	}
+
+	aggregates, err := repo.GetAggregatedReadings(ctx, "temp-001", baseTime, baseTime.Add(time.Hour), 5*time.Minute)
+	if err != nil {
+		t.Fatalf("GetAggregatedReadings failed: %v", err)
+	}
+
+	if len(aggregates) != 2 {
+		t.Fatalf("expected 2 buckets, got %d", len(aggregates))
+	}
+
+	first := aggregates[0]
+	if !first.BucketStart.Equal(baseTime) {
+		t.Errorf("expected first bucket at %v, got %v", baseTime, first.BucketStart)
+	}
+	// La lectura con error no debe contar
+	if first.Count != 3 {
+		t.Errorf("expected count 3, got %d", first.Count)
+	}
+	if first.Min != 20.0 || first.Max != 24.0 || first.Avg != 22.0 {
+		t.Errorf("unexpected min/max/avg: %.2f/%.2f/%.2f", first.Min, first.Max, first.Avg)
+	}
+	// stddev poblacional de {20, 22, 24} = sqrt(8/3)
+	if diff := first.StdDev - 1.633; diff > 0.001 || diff < -0.001 {
+		t.Errorf("expected stddev ~1.633, got %.4f", first.StdDev)
+	}
+
+	second := aggregates[1]
+	if !second.BucketStart.Equal(baseTime.Add(5*time.Minute)) || second.Count != 1 || second.StdDev != 0 {
+		t.Errorf("unexpected second bucket: %+v", second)
+	}
+}
+
+func TestSQLiteRepository_ApplyRetention(t *testing.T) {
+	repo, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	// Lecturas antiguas (10:00-10:59) y recientes (12:00) de temperatura, y una antigua de presión
+	baseTime := time.Date(2025, 10, 16, 10, 0, 0, 0, time.UTC)
+	readings := []*sensor.SensorReading{
+		{ID: "read-001", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 20.0, Timestamp: baseTime.Add(10 * time.Minute)},
+		{ID: "read-002", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 24.0, Timestamp: baseTime.Add(40 * time.Minute)},
+		{ID: "read-003", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 30.0, Timestamp: baseTime.Add(2 * time.Hour)},
+		{ID: "read-004", SensorID: "press-001", Type: sensor.SensorTypePressure, Value: 1010.0, Timestamp: baseTime.Add(5 * time.Minute)},
+	}
+	for _, r := range readings {
+		r.Unit = "u"
+		if err := repo.SaveReading(ctx, r); err != nil {
+			t.Fatalf("SaveReading failed: %v", err)
+		}
+	}
+
+	// Purgar temperatura anterior a las 11:00 consolidando, excluyendo presión
+	deleted, err := repo.ApplyRetention(ctx, repository.RetentionPolicy{
+		ExcludeTypes: []sensor.SensorType{sensor.SensorTypePressure},
+		Before:       baseTime.Add(time.Hour),
+		Rollup:       true,
+	})
+	if err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted readings, got %d", deleted)
+	}
+
+	// Las lecturas crudas antiguas ya no existen, la reciente sí
+	raw, err := repo.GetReadingsByTimeRange(ctx, "temp-001", baseTime.Add(-time.Hour), baseTime.Add(3*time.Hour))
+	if err != nil {
+		t.Fatalf("GetReadingsByTimeRange failed: %v", err)
+	}
+	if len(raw) != 1 || raw[0].ID != "read-003" {
+		t.Errorf("expected only read-003 to survive, got %d readings", len(raw))
+	}
+
+	// La lectura de presión está excluida
+	press, _ := repo.GetLatestReadings(ctx, "press-001", 10)
+	if len(press) != 1 {
+		t.Errorf("expected pressure reading to be kept, got %d", len(press))
+	}
+
+	// El agregado horario sigue disponible a partir del resumen
+	hourly, err := repo.GetAggregatedReadings(ctx, "temp-001", baseTime, baseTime.Add(3*time.Hour), time.Hour)
+	if err != nil {
+		t.Fatalf("GetAggregatedReadings failed: %v", err)
+	}
+	if len(hourly) != 2 {
+		t.Fatalf("expected 2 hourly buckets (rollup + raw), got %d", len(hourly))
+	}
+	if hourly[0].Count != 2 || hourly[0].Min != 20.0 || hourly[0].Max != 24.0 || hourly[0].Avg != 22.0 {
+		t.Errorf("unexpected rolled-up bucket: %+v", hourly[0])
+	}
+
+	// El agregado diario fusiona resumen (2 lecturas) y crudas (1 lectura)
+	daily, err := repo.GetAggregatedReadings(ctx, "temp-001", baseTime, baseTime.Add(3*time.Hour), 24*time.Hour)
+	if err != nil {
+		t.Fatalf("GetAggregatedReadings failed: %v", err)
+	}
+	if len(daily) != 1 || daily[0].Count != 3 || daily[0].Max != 30.0 {
+		t.Errorf("unexpected daily aggregate: %+v", daily)
+	}
+
+	// Una segunda consolidación del mismo bucket se fusiona en lugar de duplicarse
+	if err := repo.SaveReading(ctx, &sensor.SensorReading{
+		ID: "read-005", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 16.0, Unit: "u",
+		Timestamp: baseTime.Add(50 * time.Minute),
+	}); err != nil {
+		t.Fatalf("SaveReading failed: %v", err)
+	}
+	if _, err := repo.ApplyRetention(ctx, repository.RetentionPolicy{
+		SensorType: sensor.SensorTypeTemperature,
+		Before:     baseTime.Add(time.Hour),
+		Rollup:     true,
+	}); err != nil {
+		t.Fatalf("ApplyRetention failed: %v", err)
+	}
+
+	hourly, _ = repo.GetAggregatedReadings(ctx, "temp-001", baseTime, baseTime.Add(time.Hour), time.Hour)
+	if len(hourly) != 1 || hourly[0].Count != 3 || hourly[0].Min != 16.0 || hourly[0].Avg != 20.0 {
+		t.Errorf("expected merged bucket with 3 readings, got %+v", hourly)
+	}
+}
+
+func TestSQLiteRepository_SaveReadings(t *testing.T) {
+	repo, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	baseTime := time.Now().UTC()
+
+	batch := make([]*sensor.SensorReading, 0, 50)
+	for i := 0; i < 50; i++ {
+		batch = append(batch, &sensor.SensorReading{
+			ID:        fmt.Sprintf("read-%03d", i),
+			SensorID:  "temp-001",
+			Type:      sensor.SensorTypeTemperature,
+			Value:     float64(i),
+			Unit:      "°C",
+			Timestamp: baseTime.Add(time.Duration(i) * time.Second),
+		})
+	}
+
+	if err := repo.SaveReadings(ctx, batch); err != nil {
+		t.Fatalf("SaveReadings failed: %v", err)
+	}
+
+	readings, err := repo.GetLatestReadings(ctx, "temp-001", 100)
+	if err != nil {
+		t.Fatalf("GetLatestReadings failed: %v", err)
+	}
+	if len(readings) != 50 {
+		t.Fatalf("expected 50 readings, got %d", len(readings))
+	}
+
+	// Un lote con un ID ya guardado (reintento) no falla: se ignora y se cuenta
+	retried := []*sensor.SensorReading{
+		{ID: "read-new", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Unit: "°C", Timestamp: baseTime},
+		{ID: "read-000", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Unit: "°C", Timestamp: baseTime},
+	}
+	if err := repo.SaveReadings(ctx, retried); err != nil {
+		t.Fatalf("SaveReadings with duplicate id failed: %v", err)
+	}
+	if got := repo.DuplicateReadings(); got != 1 {
+		t.Errorf("expected 1 duplicate, got %d", got)
+	}
+
+	// Un lote con una lectura que falla se deshace por completo
+	repo.db.Exec(`CREATE TRIGGER reject_bad BEFORE INSERT ON sensor_readings WHEN NEW.id = 'read-bad'
+		BEGIN SELECT RAISE(ABORT, 'rejected'); END`)
+	failing := []*sensor.SensorReading{
+		{ID: "read-ok", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Unit: "°C", Timestamp: baseTime},
+		{ID: "read-bad", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Unit: "°C", Timestamp: baseTime},
+	}
+	if err := repo.SaveReadings(ctx, failing); err == nil {
+		t.Fatal("expected error for batch with a failing reading")
+	}
+
+	readings, _ = repo.GetLatestReadings(ctx, "temp-001", 100)
+	if len(readings) != 51 {
+		t.Errorf("expected failed batch to be rolled back, got %d readings", len(readings))
+	}
+}
+
+func TestSQLiteRepository_ConfigHistory(t *testing.T) {
+	repo, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	config := &sensor.SensorConfig{SensorID: "temp-001", Interval: 5000, Threshold: 30, Enabled: true}
+	if err := repo.SaveConfig(ctx, config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	// Guardar la misma config (p. ej. al reiniciar) no crea revisión
+	if err := repo.SaveConfig(ctx, config); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	updated := &sensor.SensorConfig{SensorID: "temp-001", Interval: 2000, Threshold: 25, Enabled: true}
+	if err := repo.SaveConfig(repository.WithConfigChange(ctx, "alice", "set"), updated); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	history, err := repo.GetConfigHistory(ctx, "temp-001", 0)
+	if err != nil {
+		t.Fatalf("GetConfigHistory failed: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("expected 2 revisions, got %d", len(history))
+	}
+
+	latest, first := history[0], history[1]
+	if latest.Revision != 2 || latest.Config != *updated || latest.ChangedBy != "alice" || latest.Reason != "set" {
+		t.Errorf("unexpected latest revision: %+v", latest)
+	}
+	if first.Revision != 1 || first.Config != *config || first.ChangedBy != "system" {
+		t.Errorf("unexpected first revision: %+v", first)
+	}
+	if time.Since(latest.ChangedAt) > time.Minute {
+		t.Errorf("unexpected changed_at: %v", latest.ChangedAt)
+	}
+
+	limited, _ := repo.GetConfigHistory(ctx, "temp-001", 1)
+	if len(limited) != 1 || limited[0].Revision != 2 {
+		t.Errorf("expected only the latest revision with limit 1, got %v", limited)
+	}
+
+	if none, _ := repo.GetConfigHistory(ctx, "unknown", 0); len(none) != 0 {
+		t.Errorf("expected no history for unknown sensor, got %d", len(none))
+	}
+}
+
+func TestSQLiteRepository_Alerts(t *testing.T) {
+	repo, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	alerts := []*sensor.Alert{
+		{ID: "a1", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Severity: sensor.AlertSeverityWarning, Value: 31, Threshold: 30, Unit: "°C", Message: "m1", Timestamp: base},
+		{ID: "a2", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Kind: sensor.AlertKindHigh, Severity: sensor.AlertSeverityCritical, Value: 40, Threshold: 30, Unit: "°C", Message: "m2", Timestamp: base.Add(time.Hour)},
+		{ID: "a3", SensorID: "hum-001", Type: sensor.SensorTypeHumidity, Severity: sensor.AlertSeverityWarning, Value: 81, Threshold: 80, Unit: "%", Message: "m3", Timestamp: base.Add(2 * time.Hour)},
+	}
+	for _, alert := range alerts {
+		if err := repo.SaveAlert(ctx, alert); err != nil {
+			t.Fatalf("SaveAlert failed: %v", err)
+		}
+	}
+
+	all, err := repo.GetAlerts(ctx, repository.AlertFilter{})
+	if err != nil {
+		t.Fatalf("GetAlerts failed: %v", err)
+	}
+	if len(all) != 3 || all[0].ID != "a3" || all[2].ID != "a1" {
+		t.Fatalf("expected all alerts newest first, got %v", all)
+	}
+	if *all[1] != *alerts[1] {
+		t.Errorf("expected %+v, got %+v", alerts[1], all[1])
+	}
+
+	tests := []struct {
+		name   string
+		filter repository.AlertFilter
+		want   []string
+	}{
+		{"by sensor", repository.AlertFilter{SensorID: "temp-001"}, []string{"a2", "a1"}},
+		{"by severity", repository.AlertFilter{Severity: sensor.AlertSeverityWarning}, []string{"a3", "a1"}},
+		{"by time range (inclusive)", repository.AlertFilter{Start: base.Add(time.Hour), End: base.Add(2 * time.Hour)}, []string{"a3", "a2"}},
+		{"with limit", repository.AlertFilter{Limit: 1}, []string{"a3"}},
+		{"no match", repository.AlertFilter{SensorID: "press-001"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.GetAlerts(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("GetAlerts failed: %v", err)
+			}
+			var ids []string
+			for _, alert := range got {
+				ids = append(ids, alert.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected %v, got %v", tt.want, ids)
+			}
+		})
+	}
+}
+
+func TestSQLiteRepository_DeleteConfigAndPurge(t *testing.T) {
+	repo, err := NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, id := range []string{"temp-001", "temp-002"} {
+		repo.SaveConfig(ctx, &sensor.SensorConfig{SensorID: id, Interval: 1000, Threshold: 30, Enabled: true})
+		repo.SaveAlert(ctx, &sensor.Alert{ID: id + "-a", SensorID: id, Severity: sensor.AlertSeverityWarning, Timestamp: base})
+		repo.SaveReadings(ctx, []*sensor.SensorReading{
+			{ID: id + "-r1", SensorID: id, Type: sensor.SensorTypeTemperature, Value: 20, Unit: "°C", Timestamp: base},
+			{ID: id + "-r2", SensorID: id, Type: sensor.SensorTypeTemperature, Value: 21, Unit: "°C", Timestamp: base.Add(2 * time.Hour)},
+		})
+	}
+	repo.ApplyRetention(ctx, repository.RetentionPolicy{Before: base.Add(time.Hour), Rollup: true})
+
+	// DeleteConfig conserva el historial
+	if err := repo.DeleteConfig(ctx, "temp-001"); err != nil {
+		t.Fatalf("DeleteConfig failed: %v", err)
+	}
+	if _, err := repo.GetConfig(ctx, "temp-001"); err == nil {
+		t.Error("expected config to be deleted")
+	}
+	if history, _ := repo.GetConfigHistory(ctx, "temp-001", 0); len(history) != 1 {
+		t.Errorf("expected config history to be kept, got %d revisions", len(history))
+	}
+
+	deleted, err := repo.PurgeSensorData(ctx, "temp-001")
+	if err != nil {
+		t.Fatalf("PurgeSensorData failed: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("expected 1 purged reading (the other was rolled up), got %d", deleted)
+	}
+
+	if readings, _ := repo.GetLatestReadings(ctx, "temp-001", 10); len(readings) != 0 {
+		t.Errorf("expected no readings, got %d", len(readings))
+	}
+	if aggs, _ := repo.GetAggregatedReadings(ctx, "temp-001", base.Add(-time.Hour), base.Add(3*time.Hour), time.Hour); len(aggs) != 0 {
+		t.Errorf("expected rollups to be purged, got %v", aggs)
+	}
+	if alerts, _ := repo.GetAlerts(ctx, repository.AlertFilter{SensorID: "temp-001"}); len(alerts) != 0 {
+		t.Errorf("expected alerts to be purged, got %d", len(alerts))
+	}
+	if history, _ := repo.GetConfigHistory(ctx, "temp-001", 0); len(history) != 0 {
+		t.Errorf("expected config history to be purged, got %d", len(history))
+	}
+
+	// El resto de sensores no se ve afectado
+	if readings, _ := repo.GetLatestReadings(ctx, "temp-002", 10); len(readings) != 1 {
+		t.Errorf("expected temp-002 readings to be kept, got %d", len(readings))
+	}
+	if aggs, _ := repo.GetAggregatedReadings(ctx, "temp-002", base.Add(-time.Hour), base.Add(3*time.Hour), time.Hour); len(aggs) != 2 {
+		t.Errorf("expected temp-002 rollup + raw bucket, got %v", aggs)
+	}
+	if _, err := repo.GetConfig(ctx, "temp-002"); err != nil {
+		t.Errorf("expected temp-002 config to be kept: %v", err)
+	}
+}
+
+func TestSQLiteRepository_WALReadPool(t *testing.T) {
+	repo, err := NewSQLiteRepositoryWithOptions(filepath.Join(t.TempDir(), "sensors.db"), SQLiteOptions{
+		BusyTimeout: time.Second,
+		Synchronous: "full",
+		CacheSize:   -4000,
+		ReadConns:   2,
+	})
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	ctx := context.Background()
+
+	var journalMode string
+	if err := repo.readDB.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil || journalMode != "wal" {
+		t.Errorf("expected WAL journal mode, got %q (%v)", journalMode, err)
+	}
+	var synchronous, cacheSize int
+	repo.readDB.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&synchronous)
+	repo.readDB.QueryRowContext(ctx, "PRAGMA cache_size").Scan(&cacheSize)
+	if synchronous != 2 || cacheSize != -4000 {
+		t.Errorf("expected synchronous=FULL(2) and cache_size=-4000, got %d and %d", synchronous, cacheSize)
+	}
+
+	// El pool de lectura no puede escribir
+	if _, err := repo.readDB.ExecContext(ctx, "DELETE FROM sensor_readings"); err == nil {
+		t.Error("expected read pool to reject writes")
+	}
+
+	repo.SaveReading(ctx, &sensor.SensorReading{ID: "r1", SensorID: "temp-001", Type: sensor.SensorTypeTemperature, Value: 20, Unit: "°C", Timestamp: time.Now()})
+
+	// Una transacción de escritura abierta no bloquea las lecturas
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, "DELETE FROM sensor_readings"); err != nil {
+		t.Fatalf("failed to delete in transaction: %v", err)
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	readings, err := repo.GetLatestReadings(readCtx, "temp-001", 10)
+	if err != nil {
+		t.Fatalf("read blocked by writer: %v", err)
+	}
+	if len(readings) != 1 {
+		t.Errorf("expected the committed reading to be visible, got %d", len(readings))
+	}
+}
+
+func TestSQLiteRepository_InvalidOptions(t *testing.T) {
+	for _, opts := range []SQLiteOptions{
+		{Synchronous: "sometimes"},
+		{BusyTimeout: -time.Second},
+		{ReadConns: -1},
+	} {
+		if _, err := NewSQLiteRepositoryWithOptions(":memory:", opts); err == nil {
+			t.Errorf("expected error for options %+v", opts)
+		}
+	}
+}
+
+// BenchmarkSQLiteRepository_ConcurrentReadWrite mide las consultas por segundo mientras
+// un escritor inserta lotes en paralelo (como el write-behind del simulador).
+func BenchmarkSQLiteRepository_ConcurrentReadWrite(b *testing.B) {
+	for _, readConns := range []int{1, 4} {
+		b.Run(fmt.Sprintf("read_conns=%d", readConns), func(b *testing.B) {
+			repo, err := NewSQLiteRepositoryWithOptions(filepath.Join(b.TempDir(), "bench.db"), SQLiteOptions{ReadConns: readConns})
+			if err != nil {
+				b.Fatalf("failed to create repository: %v", err)
+			}
+			defer repo.Close()
+
+			ctx := context.Background()
+			base := time.Now().UTC()
+
+			var writes atomic.Int64
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for batch := 0; ; batch++ {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					readings := make([]*sensor.SensorReading, 50)
+					for i := range readings {
+						readings[i] = &sensor.SensorReading{
+							ID:        fmt.Sprintf("w-%d-%d", batch, i),
+							SensorID:  fmt.Sprintf("sensor-%d", i%4),
+							Type:      sensor.SensorTypeTemperature,
+							Value:     float64(i),
+							Unit:      "°C",
+							Timestamp: base.Add(time.Duration(batch*50+i) * time.Millisecond),
+						}
+					}
+					if err := repo.SaveReadings(ctx, readings); err != nil {
+						b.Errorf("SaveReadings failed: %v", err)
+						return
+					}
+					writes.Add(int64(len(readings)))
+				}
+			}()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := repo.GetLatestReadings(ctx, "sensor-1", 10); err != nil {
+						b.Errorf("GetLatestReadings failed: %v", err)
+						return
+					}
+				}
+			})
+			b.StopTimer()
+
+			close(stop)
+			wg.Wait()
+			b.ReportMetric(float64(writes.Load())/b.Elapsed().Seconds(), "writes/s")
+		})
+	}
+}
@@ -0,0 +1,1106 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+const (
+	defaultTSFileBlockSize   = 1024     // Lecturas por bloque
+	defaultTSFileSegmentSize = 4 << 20  // Bytes a partir de los que se sella un segmento
+	defaultTSFileWALSize     = 16 << 20 // Bytes de WAL que fuerzan un checkpoint
+
+	// tsfileAlertsPerSensor es el número de alertas por sensor que se conservan en meta.json
+	tsfileAlertsPerSensor = 10000
+)
+
+// TSFileOptions ajusta el backend tsfile (0 = valores por defecto)
+type TSFileOptions struct {
+	BlockSize   int   // Lecturas por bloque comprimido (0 = 1024)
+	SegmentSize int64 // Bytes a partir de los que se sella un segmento (0 = 4 MiB)
+	WALSize     int64 // Bytes de WAL que fuerzan un checkpoint (0 = 16 MiB)
+}
+
+// TSFileRepository implementa repository.Repository con un motor de series temporales
+// propio en un directorio:
+//
+//	wal.log                        lecturas y alertas desde el último checkpoint
+//	meta.json                      configs, historial, sensores, alertas y resúmenes de retención
+//	series/<sensor>/000001.seg     segmentos de solo escritura al final con bloques comprimidos
+//
+// Las lecturas se escriben primero en el WAL y se acumulan por sensor; cada BlockSize
+// lecturas se comprimen en un bloque (delta-of-delta + XOR, ver tsfile_encoding.go) que se
+// añade al segmento activo del sensor, y al superar SegmentSize el segmento se sella con
+// su índice de bloques. Las consultas por rango solo leen los bloques cuyo intervalo
+// [min, max] del índice se solapa con el pedido. Un checkpoint (al llenarse el WAL, al
+// borrar datos y al cerrar) comprime lo pendiente, hace fsync y vacía el WAL; al abrir
+// se reproduce el WAL sobre lo ya guardado.
+//
+// Los metadatos se gestionan con un MemoryRepository que se vuelca en meta.json. Los ids
+// de todas las lecturas se mantienen en memoria para que guardar sea idempotente.
+type TSFileRepository struct {
+	dir  string
+	opts TSFileOptions
+
+	mu         sync.RWMutex
+	series     map[string]*tsSeries
+	ids        map[string]struct{}
+	duplicates int64 // Lecturas ignoradas por SaveReading/SaveReadings por id repetido
+	wal        *tsWAL
+	meta       *MemoryRepository
+	closed     bool
+}
+
+// tsSeries son los segmentos y las lecturas pendientes de comprimir de un sensor
+type tsSeries struct {
+	dir      string
+	segments []*tsSegment // En orden; solo el último puede estar activo
+	nextSeq  int
+	head     []*sensor.SensorReading // Lecturas que solo están en el WAL
+}
+
+// tsRetentionStep es el cambio que una retención hace en un segmento
+type tsRetentionStep struct {
+	sensorID    string
+	series      *tsSeries
+	segment     *tsSegment
+	replacement *tsSegment // Escrito en <seg>.tmp; nil si el segmento queda vacío y se borra
+	removed     []*sensor.SensorReading
+}
+
+// Asegurar que TSFileRepository implementa repository.Repository
+var _ repository.Repository = (*TSFileRepository)(nil)
+
+// NewTSFileRepository abre (o crea) el backend tsfile en dir, recuperando los segmentos
+// y el WAL que una caída haya dejado a medio escribir
+func NewTSFileRepository(dir string, opts TSFileOptions) (*TSFileRepository, error) {
+	if opts.BlockSize <= 0 {
+		opts.BlockSize = defaultTSFileBlockSize
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = defaultTSFileSegmentSize
+	}
+	if opts.WALSize <= 0 {
+		opts.WALSize = defaultTSFileWALSize
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "series"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create tsfile directory: %w", err)
+	}
+	meta, err := NewMemoryRepository(tsfileAlertsPerSensor, filepath.Join(dir, "meta.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	wal, records, err := openWAL(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		return nil, err
+	}
+	r := &TSFileRepository{
+		dir:    dir,
+		opts:   opts,
+		series: make(map[string]*tsSeries),
+		ids:    make(map[string]struct{}),
+		wal:    wal,
+		meta:   meta,
+	}
+	fail := func(err error) (*TSFileRepository, error) {
+		r.closeSegments()
+		wal.close()
+		return nil, err
+	}
+
+	// Los segmentos de una retención registrada se completan antes de que loadSeries
+	// descarte los .tmp como reescrituras interrumpidas
+	retained := false
+	for _, record := range records {
+		if record.Retention != nil {
+			if err := r.finishRetention(record.Retention); err != nil {
+				return fail(err)
+			}
+			retained = true
+		}
+	}
+	if err := r.loadSeries(); err != nil {
+		return fail(err)
+	}
+	if err := r.replay(records); err != nil {
+		return fail(err)
+	}
+	// Completada la retención, su registro no debe seguir en el WAL
+	if retained {
+		if err := r.checkpoint(); err != nil {
+			return fail(err)
+		}
+	}
+
+	return r, nil
+}
+
+// finishRetention instala los reemplazos y borra los segmentos de una retención
+// registrada en el WAL. Los que ya se hicieron antes de la caída se omiten.
+func (r *TSFileRepository) finishRetention(record *tsRetention) error {
+	dirs := make(map[string]bool)
+	for _, rel := range record.Replace {
+		path := filepath.Join(r.dir, rel)
+		if err := installReplacement(path); err != nil {
+			return err
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+	for _, rel := range record.Remove {
+		path := filepath.Join(r.dir, rel)
+		if err := removeSegment(path); err != nil {
+			return err
+		}
+		dirs[filepath.Dir(path)] = true
+	}
+	for dir := range dirs {
+		// La serie puede haberse purgado después de la retención
+		if err := syncDir(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadSeries abre los segmentos de cada sensor y carga los ids de sus lecturas
+func (r *TSFileRepository) loadSeries() error {
+	root := filepath.Join(r.dir, "series")
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("failed to read tsfile directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(root, entry.Name())
+		// seriesDirName escapa los puntos: un nombre con punto es un borrado interrumpido
+		if strings.Contains(entry.Name(), ".") {
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("failed to remove purged series %s: %w", path, err)
+			}
+			continue
+		}
+		sensorID, err := url.PathUnescape(entry.Name())
+		if err != nil {
+			return fmt.Errorf("invalid series directory %s: %w", path, err)
+		}
+
+		s, err := openSeries(path)
+		r.series[sensorID] = s
+		if err != nil {
+			return err
+		}
+		for _, seg := range s.segments {
+			for _, ref := range seg.blocks {
+				block, err := readBlock(seg, ref)
+				if err != nil {
+					return err
+				}
+				for _, id := range block.ids {
+					r.ids[id] = struct{}{}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// openSeries abre los segmentos de un directorio de serie en orden
+func openSeries(dir string) (*tsSeries, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read series directory %s: %w", dir, err)
+	}
+
+	s := &tsSeries{dir: dir, nextSeq: 1}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		switch {
+		case strings.HasSuffix(entry.Name(), ".tmp"):
+			// Reescritura de retención interrumpida: el segmento original sigue intacto
+			if err := os.Remove(path); err != nil {
+				return s, fmt.Errorf("failed to remove %s: %w", path, err)
+			}
+		case strings.HasSuffix(entry.Name(), ".seg"):
+			var seq int
+			if _, err := fmt.Sscanf(entry.Name(), "%06d.seg", &seq); err != nil {
+				return s, fmt.Errorf("invalid segment name %s", path)
+			}
+			seg, err := openSegment(path)
+			if err != nil {
+				return s, err
+			}
+			s.segments = append(s.segments, seg)
+			s.nextSeq = max(s.nextSeq, seq+1)
+		}
+	}
+
+	// Solo el último segmento puede seguir activo
+	for i := 0; i < len(s.segments)-1; i++ {
+		if !s.segments[i].sealed {
+			if err := s.segments[i].seal(); err != nil {
+				return s, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// replay añade a las series las lecturas del WAL que no llegaron a comprimirse y a los
+// metadatos las alertas y los resúmenes de retención que no llegaron a meta.json
+func (r *TSFileRepository) replay(records []tsWALRecord) error {
+	ctx := context.Background()
+	alerts, err := r.meta.GetAlerts(ctx, repository.AlertFilter{})
+	if err != nil {
+		return err
+	}
+	saved := make(map[string]bool, len(alerts))
+	for _, alert := range alerts {
+		saved[alert.ID] = true
+	}
+
+	for _, record := range records {
+		for _, reading := range record.Readings {
+			if _, ok := r.ids[reading.ID]; ok {
+				continue // Ya comprimida en un bloque antes de la caída
+			}
+			if err := r.add(reading); err != nil {
+				return err
+			}
+		}
+		if alert := record.Alert; alert != nil && !saved[alert.ID] {
+			if err := r.meta.SaveAlert(ctx, alert); err != nil {
+				return err
+			}
+		}
+		if record.Retention != nil {
+			r.meta.mu.Lock()
+			err := r.meta.setRollups(record.Retention.Rollups)
+			r.meta.mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SaveReading guarda una lectura. Si el id ya está guardado se ignora y se cuenta como duplicada.
+func (r *TSFileRepository) SaveReading(ctx context.Context, reading *sensor.SensorReading) error {
+	return r.SaveReadings(ctx, []*sensor.SensorReading{reading})
+}
+
+// SaveReadings guarda un lote de lecturas con una única entrada del WAL
+func (r *TSFileRepository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	skipped, err := r.write(readings)
+	r.duplicates += int64(len(skipped))
+	return err
+}
+
+// ImportReadings guarda las lecturas cuyo id no está guardado ni repetido en el lote
+func (r *TSFileRepository) ImportReadings(ctx context.Context, readings []*sensor.SensorReading) ([]repository.SkippedReading, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	skipped, err := r.write(readings)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]repository.SkippedReading, 0, len(skipped))
+	for _, reading := range skipped {
+		result = append(result, repository.SkippedReading{ID: reading.ID, Reason: repository.SkipDuplicateID})
+	}
+	return result, nil
+}
+
+// DuplicateReadings retorna cuántas lecturas han ignorado SaveReading/SaveReadings por
+// tener un id ya guardado
+func (r *TSFileRepository) DuplicateReadings() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.duplicates
+}
+
+// write registra en el WAL las lecturas nuevas y las añade a sus series. Retorna las
+// omitidas por tener un id ya guardado o repetido en el lote (requiere r.mu).
+func (r *TSFileRepository) write(readings []*sensor.SensorReading) ([]*sensor.SensorReading, error) {
+	var fresh, skipped []*sensor.SensorReading
+	batch := make(map[string]bool, len(readings))
+	for _, reading := range readings {
+		if reading.SensorID == "" {
+			return nil, fmt.Errorf("reading %s has no sensor_id", reading.ID)
+		}
+		if _, ok := r.ids[reading.ID]; ok || batch[reading.ID] {
+			skipped = append(skipped, reading)
+			continue
+		}
+		batch[reading.ID] = true
+		fresh = append(fresh, reading)
+	}
+	if len(fresh) == 0 {
+		return skipped, nil
+	}
+
+	if err := r.wal.append(tsWALRecord{Readings: fresh}); err != nil {
+		return nil, err
+	}
+	// Las lecturas ya están en el WAL: aunque falle la compresión de un bloque se añaden
+	// todas y el bloque se reintenta en la siguiente escritura o checkpoint
+	var errs []error
+	for _, reading := range fresh {
+		errs = append(errs, r.add(reading))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return skipped, err
+	}
+
+	if r.wal.size >= r.opts.WALSize {
+		return skipped, r.checkpoint()
+	}
+	return skipped, nil
+}
+
+// add añade una lectura ya registrada en el WAL a su serie y comprime las pendientes en un
+// bloque al llegar a BlockSize (requiere r.mu)
+func (r *TSFileRepository) add(reading *sensor.SensorReading) error {
+	s, ok := r.series[reading.SensorID]
+	if !ok {
+		dir := filepath.Join(r.dir, "series", seriesDirName(reading.SensorID))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create series directory: %w", err)
+		}
+		s = &tsSeries{dir: dir, nextSeq: 1}
+		r.series[reading.SensorID] = s
+	}
+
+	r.ids[reading.ID] = struct{}{}
+	s.head = append(s.head, copyReading(reading))
+	if len(s.head) >= r.opts.BlockSize {
+		return r.flush(s)
+	}
+	return nil
+}
+
+// flush comprime las lecturas pendientes de la serie en un bloque del segmento activo y
+// sella el segmento si supera SegmentSize (requiere r.mu)
+func (r *TSFileRepository) flush(s *tsSeries) error {
+	if len(s.head) == 0 {
+		return nil
+	}
+
+	active := s.active()
+	if active == nil {
+		seg, err := createSegment(filepath.Join(s.dir, fmt.Sprintf("%06d.seg", s.nextSeq)))
+		if err != nil {
+			return err
+		}
+		s.segments = append(s.segments, seg)
+		s.nextSeq++
+		active = seg
+	}
+
+	if err := active.appendBlock(encodeBlock(s.head)); err != nil {
+		return err
+	}
+	s.head = nil
+
+	if active.size >= r.opts.SegmentSize {
+		return active.seal()
+	}
+	return nil
+}
+
+// active retorna el segmento activo de la serie, o nil si no hay o el último está sellado
+func (s *tsSeries) active() *tsSegment {
+	if len(s.segments) == 0 || s.segments[len(s.segments)-1].sealed {
+		return nil
+	}
+	return s.segments[len(s.segments)-1]
+}
+
+// checkpoint comprime las lecturas pendientes de todas las series, hace fsync de los
+// segmentos activos, vuelca meta.json y vacía el WAL (requiere r.mu)
+func (r *TSFileRepository) checkpoint() error {
+	for _, s := range r.series {
+		if err := r.flush(s); err != nil {
+			return err
+		}
+		if active := s.active(); active != nil {
+			if err := active.sync(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := r.meta.saveSnapshot(); err != nil {
+		return err
+	}
+	return r.wal.reset()
+}
+
+// GetLatestReadings obtiene las últimas N lecturas de un sensor ordenadas por timestamp descendente
+func (r *TSFileRepository) GetLatestReadings(ctx context.Context, sensorID string, limit int) ([]*sensor.SensorReading, error) {
+	if limit == 0 {
+		return nil, nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.scan(sensorID, math.MinInt64, math.MaxInt64, nil, max(limit, 0))
+}
+
+// GetReadingsPage obtiene una página de lecturas en orden (timestamp, id) descendente
+func (r *TSFileRepository) GetReadingsPage(ctx context.Context, sensorID string, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", limit)
+	}
+
+	to, match := cursorBound(cursor, math.MaxInt64)
+
+	r.mu.RLock()
+	readings, err := r.scan(sensorID, math.MinInt64, to, match, limit+1)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readings, next := paginate(readings, limit)
+	return readings, next, nil
+}
+
+// GetReadingsRangePage obtiene una página de lecturas del rango [start, end] en orden
+// (timestamp, id) ascendente
+func (r *TSFileRepository) GetReadingsRangePage(ctx context.Context, sensorID string, start, end time.Time, cursor *repository.ReadingCursor, limit int) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", limit)
+	}
+
+	from := start.UnixNano()
+	var match func(*sensor.SensorReading) bool
+	if cursor != nil {
+		from = max(from, cursor.Timestamp.UnixNano())
+		match = cursor.After
+	}
+
+	r.mu.RLock()
+	readings, err := r.scanOrdered(sensorID, from, end.UnixNano(), match, limit+1, true)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	readings, next := paginate(readings, limit)
+	return readings, next, nil
+}
+
+// GetReadingsByTimeRange obtiene las lecturas en el rango [start, end] ordenadas por timestamp descendente
+func (r *TSFileRepository) GetReadingsByTimeRange(ctx context.Context, sensorID string, start, end time.Time) ([]*sensor.SensorReading, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.scan(sensorID, start.UnixNano(), end.UnixNano(), nil, 0)
+}
+
+// SearchReadings obtiene una página de lecturas de los sensores cuyos metadatos cumplen
+// la búsqueda, en orden (timestamp, id) descendente
+func (r *TSFileRepository) SearchReadings(ctx context.Context, q repository.ReadingSearch) ([]*sensor.SensorReading, *repository.ReadingCursor, error) {
+	if q.Limit <= 0 {
+		return nil, nil, fmt.Errorf("invalid page size: %d", q.Limit)
+	}
+
+	sensors, err := r.meta.ListSensors(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	from, to := int64(math.MinInt64), int64(math.MaxInt64)
+	if !q.Start.IsZero() {
+		from = q.Start.UnixNano()
+	}
+	if !q.End.IsZero() {
+		to = q.End.UnixNano()
+	}
+	to, match := cursorBound(q.Cursor, to)
+
+	r.mu.RLock()
+	var readings []*sensor.SensorReading
+	for _, s := range sensors {
+		if !q.Matches(s) {
+			continue
+		}
+		found, err := r.scan(s.ID, from, to, match, q.Limit+1)
+		if err != nil {
+			r.mu.RUnlock()
+			return nil, nil, err
+		}
+		readings = append(readings, found...)
+	}
+	r.mu.RUnlock()
+
+	sortReadingsDesc(readings)
+	if len(readings) > q.Limit+1 {
+		readings = readings[:q.Limit+1]
+	}
+
+	readings, next := paginate(readings, q.Limit)
+	return readings, next, nil
+}
+
+// cursorBound limita to al timestamp del cursor y retorna el filtro de las lecturas que
+// van después de él (nil sin cursor)
+func cursorBound(cursor *repository.ReadingCursor, to int64) (int64, func(*sensor.SensorReading) bool) {
+	if cursor == nil {
+		return to, nil
+	}
+	return min(to, cursor.Timestamp.UnixNano()), cursor.Before
+}
+
+// scan retorna las lecturas del sensor con timestamp en [from, to] (nanosegundos) que
+// cumplen match, en orden (timestamp, id) descendente (ver scanOrdered; requiere r.mu)
+func (r *TSFileRepository) scan(sensorID string, from, to int64, match func(*sensor.SensorReading) bool, limit int) ([]*sensor.SensorReading, error) {
+	return r.scanOrdered(sensorID, from, to, match, limit, false)
+}
+
+// scanOrdered retorna las lecturas del sensor con timestamp en [from, to] (nanosegundos)
+// que cumplen match, en orden (timestamp, id) descendente o ascendente. Recorre los bloques
+// del índice que se solapan con el rango empezando por el extremo del orden pedido; con
+// limit > 0 retorna como mucho limit lecturas y deja de leer en cuanto ningún bloque
+// restante puede mejorarlas (requiere r.mu).
+func (r *TSFileRepository) scanOrdered(sensorID string, from, to int64, match func(*sensor.SensorReading) bool, limit int, ascending bool) ([]*sensor.SensorReading, error) {
+	s, ok := r.series[sensorID]
+	if !ok {
+		return nil, nil
+	}
+
+	keep := func(reading *sensor.SensorReading) bool {
+		ts := reading.Timestamp.UnixNano()
+		return ts >= from && ts <= to && (match == nil || match(reading))
+	}
+
+	var readings []*sensor.SensorReading
+	for _, reading := range s.head {
+		if keep(reading) {
+			readings = append(readings, copyReading(reading))
+		}
+	}
+
+	type candidate struct {
+		seg *tsSegment
+		ref blockRef
+	}
+	var candidates []candidate
+	for _, seg := range s.segments {
+		for _, ref := range seg.blocks {
+			if ref.maxTs >= from && ref.minTs <= to {
+				candidates = append(candidates, candidate{seg: seg, ref: ref})
+			}
+		}
+	}
+	sortReadings, beyond := sortReadingsDesc, func(ref blockRef, ts int64) bool { return ref.maxTs < ts }
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ref.maxTs > candidates[j].ref.maxTs })
+	if ascending {
+		sortReadings, beyond = sortReadingsAsc, func(ref blockRef, ts int64) bool { return ref.minTs > ts }
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].ref.minTs < candidates[j].ref.minTs })
+	}
+
+	for _, c := range candidates {
+		if limit > 0 && len(readings) >= limit {
+			sortReadings(readings)
+			readings = readings[:limit]
+			if beyond(c.ref, readings[limit-1].Timestamp.UnixNano()) {
+				break
+			}
+		}
+
+		block, err := readBlock(c.seg, c.ref)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := block.readings(sensorID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode block of %s: %w", c.seg.path, err)
+		}
+		for _, reading := range decoded {
+			if keep(reading) {
+				readings = append(readings, reading)
+			}
+		}
+	}
+
+	sortReadings(readings)
+	if limit > 0 && len(readings) > limit {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+// GetAggregatedReadings agrupa las lecturas válidas de un sensor en buckets alineados a epoch.
+// Para buckets de 1h y 1d se fusionan además los resúmenes generados por la retención.
+func (r *TSFileRepository) GetAggregatedReadings(ctx context.Context, sensorID string, start, end time.Time, bucket time.Duration) ([]*sensor.ReadingAggregate, error) {
+	bucketSecs := int64(bucket / time.Second)
+	if bucketSecs <= 0 {
+		return nil, fmt.Errorf("invalid bucket size: %s", bucket)
+	}
+
+	r.mu.RLock()
+	readings, err := r.scan(sensorID, start.UnixNano(), end.UnixNano(), func(reading *sensor.SensorReading) bool {
+		return !reading.IsError()
+	}, 0)
+	r.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make(bucketMap)
+	for _, reading := range readings {
+		key := rollupKey{sensorID: sensorID, start: reading.Timestamp.Unix() / bucketSecs * bucketSecs}
+		buckets.merge(key, valueStats(reading.Value))
+	}
+
+	r.meta.mu.RLock()
+	r.meta.mergeRollups(buckets, sensorID, start, end, bucket)
+	r.meta.mu.RUnlock()
+
+	return buckets.aggregates(sensorID), nil
+}
+
+// ApplyRetention elimina las lecturas que cumplen la política, consolidándolas antes en
+// los resúmenes horario y diario si policy.Rollup. Los reemplazos de los segmentos
+// afectados se escriben en ficheros temporales y la retención se registra en el WAL
+// antes de instalarlos, de modo que una caída a medias se completa al abrir.
+func (r *TSFileRepository) ApplyRetention(ctx context.Context, policy repository.RetentionPolicy) (int64, error) {
+	excluded := make(map[sensor.SensorType]bool, len(policy.ExcludeTypes))
+	for _, t := range policy.ExcludeTypes {
+		excluded[t] = true
+	}
+
+	expired := func(reading *sensor.SensorReading) bool {
+		if !reading.Timestamp.Before(policy.Before) {
+			return false
+		}
+		if policy.SensorType != "" && reading.Type != policy.SensorType {
+			return false
+		}
+		return !excluded[reading.Type]
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Las lecturas pendientes pasan a bloques y el WAL queda vacío: al reproducirlo no
+	// pueden reaparecer lecturas eliminadas
+	if err := r.checkpoint(); err != nil {
+		return 0, err
+	}
+	steps, err := r.planRetention(policy.Before.UnixNano(), expired)
+	if err != nil || len(steps) == 0 {
+		return 0, err
+	}
+
+	record := &tsRetention{}
+	for _, step := range steps {
+		rel, err := filepath.Rel(r.dir, step.segment.path)
+		if err != nil {
+			discardReplacements(steps)
+			return 0, fmt.Errorf("failed to apply retention: %w", err)
+		}
+		if step.replacement != nil {
+			record.Replace = append(record.Replace, rel)
+		} else {
+			record.Remove = append(record.Remove, rel)
+		}
+	}
+	if policy.Rollup {
+		record.Rollups = r.rolledUp(steps)
+	}
+
+	// A partir de aquí la retención está confirmada aunque el proceso caiga
+	err = r.wal.append(tsWALRecord{Retention: record})
+	if err == nil {
+		err = r.wal.sync()
+	}
+	if err != nil {
+		discardReplacements(steps)
+		return 0, errors.Join(fmt.Errorf("failed to apply retention: %w", err), r.wal.reset())
+	}
+
+	applied := 0
+	for ; applied < len(steps); applied++ {
+		if err = r.applyRetentionStep(steps[applied]); err != nil {
+			err = fmt.Errorf("failed to apply retention to sensor %s: %w", steps[applied].sensorID, err)
+			break
+		}
+	}
+	dirs := make(map[string]bool)
+	var deleted int64
+	for _, step := range steps[:applied] {
+		deleted += int64(len(step.removed))
+		dirs[step.series.dir] = true
+	}
+	for dir := range dirs {
+		err = errors.Join(err, syncDir(dir))
+	}
+
+	// Los segmentos que no se han podido cambiar conservan sus lecturas: solo se
+	// consolidan las eliminadas
+	if applied < len(steps) && policy.Rollup {
+		record.Rollups = r.rolledUp(steps[:applied])
+	}
+	r.meta.mu.Lock()
+	err = errors.Join(err, r.meta.setRollups(record.Rollups))
+	r.meta.mu.Unlock()
+
+	// Si el checkpoint falla el registro sigue en el WAL y sus .tmp deben conservarse
+	// para completarlo al abrir
+	if cerr := r.checkpoint(); cerr != nil {
+		for _, step := range steps[applied:] {
+			if step.replacement != nil {
+				step.replacement.close()
+			}
+		}
+		return deleted, errors.Join(err, cerr)
+	}
+	discardReplacements(steps[applied:])
+	return deleted, err
+}
+
+// planRetention escribe el reemplazo de cada segmento con lecturas que cumplen expired
+// (todas anteriores a before) y retorna los cambios a aplicar. No modifica las series
+// (requiere r.mu).
+func (r *TSFileRepository) planRetention(before int64, expired func(*sensor.SensorReading) bool) ([]*tsRetentionStep, error) {
+	var steps []*tsRetentionStep
+	for sensorID, s := range r.series {
+		for _, seg := range s.segments {
+			blocks, removed, err := expireSegment(seg, sensorID, before, expired)
+			if err == nil && len(removed) > 0 {
+				step := &tsRetentionStep{sensorID: sensorID, series: s, segment: seg, removed: removed}
+				if len(blocks) > 0 || !seg.sealed {
+					step.replacement, err = prepareReplacement(seg, blocks)
+				}
+				steps = append(steps, step)
+			}
+			if err != nil {
+				discardReplacements(steps)
+				return nil, fmt.Errorf("failed to apply retention to sensor %s: %w", sensorID, err)
+			}
+		}
+	}
+	return steps, nil
+}
+
+// applyRetentionStep instala el reemplazo del segmento (o lo borra si queda vacío) y lo
+// refleja en la serie (requiere r.mu)
+func (r *TSFileRepository) applyRetentionStep(step *tsRetentionStep) error {
+	s, seg := step.series, step.segment
+	i := slices.Index(s.segments, seg)
+	if step.replacement == nil {
+		if err := removeSegment(seg.path); err != nil {
+			return err
+		}
+		s.segments = slices.Delete(s.segments, i, i+1)
+	} else {
+		if err := installReplacement(seg.path); err != nil {
+			return err
+		}
+		step.replacement.path = seg.path
+		s.segments[i] = step.replacement
+	}
+	seg.close()
+
+	for _, reading := range step.removed {
+		delete(r.ids, reading.ID)
+	}
+	return nil
+}
+
+// rolledUp retorna el valor final de los resúmenes tras consolidar las lecturas
+// eliminadas por los pasos dados (requiere r.mu)
+func (r *TSFileRepository) rolledUp(steps []*tsRetentionStep) []snapshotRollup {
+	removed := make(map[string][]*sensor.SensorReading)
+	for _, step := range steps {
+		removed[step.sensorID] = append(removed[step.sensorID], step.removed...)
+	}
+	r.meta.mu.RLock()
+	defer r.meta.mu.RUnlock()
+	return r.meta.rolledUp(removed)
+}
+
+// discardReplacements cierra y borra los reemplazos que no se han instalado
+func discardReplacements(steps []*tsRetentionStep) {
+	for _, step := range steps {
+		if step.replacement != nil {
+			step.replacement.close()
+			os.Remove(step.replacement.path)
+		}
+	}
+}
+
+// expireSegment retorna los bloques que quedan en el segmento tras quitar las lecturas
+// que cumplen expired, y las lecturas quitadas. Solo lee el segmento si algún bloque
+// empieza antes de before.
+func expireSegment(seg *tsSegment, sensorID string, before int64, expired func(*sensor.SensorReading) bool) ([][]byte, []*sensor.SensorReading, error) {
+	if !slices.ContainsFunc(seg.blocks, func(ref blockRef) bool { return ref.minTs < before }) {
+		return nil, nil, nil
+	}
+
+	var blocks [][]byte
+	var removed []*sensor.SensorReading
+	for _, ref := range seg.blocks {
+		payload, err := seg.readBlock(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ref.minTs >= before {
+			blocks = append(blocks, payload)
+			continue
+		}
+
+		block, err := parseBlock(payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode block of %s: %w", seg.path, err)
+		}
+		readings, err := block.readings(sensorID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode block of %s: %w", seg.path, err)
+		}
+
+		var kept []*sensor.SensorReading
+		for _, reading := range readings {
+			if expired(reading) {
+				removed = append(removed, reading)
+			} else {
+				kept = append(kept, reading)
+			}
+		}
+		switch {
+		case len(kept) == len(readings):
+			blocks = append(blocks, payload)
+		case len(kept) > 0:
+			blocks = append(blocks, encodeBlock(kept))
+		}
+	}
+	return blocks, removed, nil
+}
+
+// SaveConfig guarda la configuración y vuelca meta.json
+func (r *TSFileRepository) SaveConfig(ctx context.Context, config *sensor.SensorConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.meta.SaveConfig(ctx, config); err != nil {
+		return err
+	}
+	return r.meta.saveSnapshot()
+}
+
+// GetConfig obtiene la configuración de un sensor
+func (r *TSFileRepository) GetConfig(ctx context.Context, sensorID string) (*sensor.SensorConfig, error) {
+	return r.meta.GetConfig(ctx, sensorID)
+}
+
+// DeleteConfig elimina la configuración actual conservando su historial y vuelca meta.json
+func (r *TSFileRepository) DeleteConfig(ctx context.Context, sensorID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.meta.DeleteConfig(ctx, sensorID); err != nil {
+		return err
+	}
+	return r.meta.saveSnapshot()
+}
+
+// GetConfigHistory obtiene las revisiones de configuración de un sensor, la más reciente primero
+func (r *TSFileRepository) GetConfigHistory(ctx context.Context, sensorID string, limit int) ([]*sensor.ConfigRevision, error) {
+	return r.meta.GetConfigHistory(ctx, sensorID, limit)
+}
+
+// SaveAlert registra la alerta en el WAL; llega a meta.json en el siguiente checkpoint
+func (r *TSFileRepository) SaveAlert(ctx context.Context, alert *sensor.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.wal.append(tsWALRecord{Alert: alert}); err != nil {
+		return err
+	}
+	return r.meta.SaveAlert(ctx, alert)
+}
+
+// GetAlerts obtiene las alertas que cumplen el filtro ordenadas por timestamp descendente
+func (r *TSFileRepository) GetAlerts(ctx context.Context, filter repository.AlertFilter) ([]*sensor.Alert, error) {
+	return r.meta.GetAlerts(ctx, filter)
+}
+
+// SaveSensor guarda los metadatos de un sensor y vuelca meta.json
+func (r *TSFileRepository) SaveSensor(ctx context.Context, s *sensor.Sensor) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.meta.SaveSensor(ctx, s); err != nil {
+		return err
+	}
+	return r.meta.saveSnapshot()
+}
+
+// GetSensor obtiene los metadatos de un sensor registrado
+func (r *TSFileRepository) GetSensor(ctx context.Context, sensorID string) (*sensor.Sensor, error) {
+	return r.meta.GetSensor(ctx, sensorID)
+}
+
+// ListSensors obtiene todos los sensores registrados ordenados por ID
+func (r *TSFileRepository) ListSensors(ctx context.Context) ([]*sensor.Sensor, error) {
+	return r.meta.ListSensors(ctx)
+}
+
+// DeleteSensor elimina los metadatos de un sensor registrado y vuelca meta.json
+func (r *TSFileRepository) DeleteSensor(ctx context.Context, sensorID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.meta.DeleteSensor(ctx, sensorID); err != nil {
+		return err
+	}
+	return r.meta.saveSnapshot()
+}
+
+// PurgeSensorData elimina la serie del sensor y sus resúmenes, alertas e historial. El
+// directorio se renombra antes de borrarlo para que un borrado interrumpido no deje
+// segmentos a medias.
+func (r *TSFileRepository) PurgeSensorData(ctx context.Context, sensorID string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	if s, ok := r.series[sensorID]; ok {
+		ids := make([]string, 0, len(s.head))
+		for _, reading := range s.head {
+			ids = append(ids, reading.ID)
+		}
+		for _, seg := range s.segments {
+			for _, ref := range seg.blocks {
+				block, err := readBlock(seg, ref)
+				if err != nil {
+					return 0, err
+				}
+				ids = append(ids, block.ids...)
+			}
+		}
+
+		purged := s.dir + ".purged"
+		if err := os.Rename(s.dir, purged); err != nil {
+			return 0, fmt.Errorf("failed to purge series of sensor %s: %w", sensorID, err)
+		}
+		for _, seg := range s.segments {
+			seg.close()
+		}
+		delete(r.series, sensorID)
+		for _, id := range ids {
+			delete(r.ids, id)
+		}
+		deleted = int64(len(ids))
+
+		if err := os.RemoveAll(purged); err != nil {
+			return deleted, fmt.Errorf("failed to purge series of sensor %s: %w", sensorID, err)
+		}
+	}
+
+	if _, err := r.meta.PurgeSensorData(ctx, sensorID); err != nil {
+		return 0, err
+	}
+	// El WAL aún contiene las lecturas eliminadas que no se habían comprimido
+	return deleted, r.checkpoint()
+}
+
+// Close hace un checkpoint y cierra los segmentos y el WAL. Las llamadas posteriores no
+// hacen nada.
+func (r *TSFileRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.checkpoint()
+	err = errors.Join(err, r.closeSegments())
+	return errors.Join(err, r.wal.close())
+}
+
+// closeSegments cierra los segmentos activos de todas las series
+func (r *TSFileRepository) closeSegments() error {
+	var errs []error
+	for _, s := range r.series {
+		if s == nil {
+			continue
+		}
+		for _, seg := range s.segments {
+			errs = append(errs, seg.close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readBlock lee y separa las columnas de un bloque
+func readBlock(seg *tsSegment, ref blockRef) (*decodedBlock, error) {
+	payload, err := seg.readBlock(ref)
+	if err != nil {
+		return nil, err
+	}
+	block, err := parseBlock(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode block of %s: %w", seg.path, err)
+	}
+	return block, nil
+}
+
+// sortReadingsDesc ordena lecturas por (timestamp, id) descendente
+func sortReadingsDesc(readings []*sensor.SensorReading) {
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].Timestamp.Equal(readings[j].Timestamp) {
+			return readings[i].ID > readings[j].ID
+		}
+		return readings[i].Timestamp.After(readings[j].Timestamp)
+	})
+}
+
+// sortReadingsAsc ordena lecturas por (timestamp, id) ascendente
+func sortReadingsAsc(readings []*sensor.SensorReading) {
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].Timestamp.Equal(readings[j].Timestamp) {
+			return readings[i].ID < readings[j].ID
+		}
+		return readings[i].Timestamp.Before(readings[j].Timestamp)
+	})
+}
+
+// seriesDirName escapa el id de un sensor como nombre de directorio: solo letras, dígitos,
+// '-' y '_' se conservan; el resto (incluido '.') se escribe como %XX
+func seriesDirName(sensorID string) string {
+	var b strings.Builder
+	for i := 0; i < len(sensorID); i++ {
+		c := sensorID[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
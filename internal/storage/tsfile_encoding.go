@@ -0,0 +1,545 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+// Codificación de los bloques del backend tsfile, al estilo de Gorilla (Facebook, 2015):
+// timestamps con delta-of-delta y valores float con XOR respecto al anterior. Los ids,
+// tipo, unidad y errores de cada lectura se guardan aparte en columnas compactas.
+
+var errCorruptBlock = errors.New("corrupt block")
+
+// bitWriter escribe bits en orden MSB primero
+type bitWriter struct {
+	buf  []byte
+	free uint8 // Bits libres en el último byte
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if bit {
+		w.writeBits(1, 1)
+	} else {
+		w.writeBits(0, 1)
+	}
+}
+
+// writeBits escribe los nbits bits menos significativos de v
+func (w *bitWriter) writeBits(v uint64, nbits int) {
+	for nbits > 0 {
+		if w.free == 0 {
+			w.buf = append(w.buf, 0)
+			w.free = 8
+		}
+		take := min(nbits, int(w.free))
+		chunk := byte(v>>(nbits-take)) & byte(1<<take-1)
+		w.buf[len(w.buf)-1] |= chunk << (int(w.free) - take)
+		w.free -= uint8(take)
+		nbits -= take
+	}
+}
+
+// bitReader lee los bits escritos por bitWriter
+type bitReader struct {
+	buf []byte
+	pos int // Posición en bits
+}
+
+func (r *bitReader) readBit() (bool, error) {
+	v, err := r.readBits(1)
+	return v == 1, err
+}
+
+func (r *bitReader) readBits(nbits int) (uint64, error) {
+	if r.pos+nbits > len(r.buf)*8 {
+		return 0, errCorruptBlock
+	}
+	var v uint64
+	for nbits > 0 {
+		avail := 8 - r.pos%8
+		take := min(nbits, avail)
+		chunk := r.buf[r.pos/8] >> (avail - take) & byte(1<<take-1)
+		v = v<<take | uint64(chunk)
+		r.pos += take
+		nbits -= take
+	}
+	return v, nil
+}
+
+// dodBuckets son los tramos de delta-of-delta: n unos seguidos de un cero (cinco unos en
+// el último) indican cuántos bits ocupa el valor con signo. dod == 0 se escribe como un cero.
+var dodBuckets = [...]int{7, 9, 12, 32, 64}
+
+// timestampEncoder codifica timestamps enteros con delta-of-delta
+type timestampEncoder struct {
+	w         bitWriter
+	n         int
+	prev      int64
+	prevDelta int64
+}
+
+func (e *timestampEncoder) write(t int64) {
+	defer func() { e.n++ }()
+	if e.n == 0 {
+		e.w.writeBits(uint64(t), 64)
+		e.prev = t
+		return
+	}
+
+	delta := t - e.prev
+	dod := delta - e.prevDelta
+	e.prev, e.prevDelta = t, delta
+	if dod == 0 {
+		e.w.writeBit(false)
+		return
+	}
+	for i, nbits := range dodBuckets {
+		if nbits == 64 || (dod >= -(1<<(nbits-1)) && dod < 1<<(nbits-1)) {
+			ones := i + 1
+			if ones < len(dodBuckets) {
+				e.w.writeBits((1<<ones-1)<<1, ones+1)
+			} else {
+				e.w.writeBits(1<<ones-1, ones)
+			}
+			e.w.writeBits(uint64(dod), nbits)
+			return
+		}
+	}
+}
+
+// timestampDecoder decodifica lo escrito por timestampEncoder
+type timestampDecoder struct {
+	r         bitReader
+	n         int
+	prev      int64
+	prevDelta int64
+}
+
+func (d *timestampDecoder) next() (int64, error) {
+	defer func() { d.n++ }()
+	if d.n == 0 {
+		v, err := d.r.readBits(64)
+		d.prev = int64(v)
+		return d.prev, err
+	}
+
+	ones := 0
+	for ones < len(dodBuckets) {
+		bit, err := d.r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		ones++
+	}
+
+	var dod int64
+	if ones > 0 {
+		nbits := dodBuckets[ones-1]
+		v, err := d.r.readBits(nbits)
+		if err != nil {
+			return 0, err
+		}
+		dod = int64(v<<(64-nbits)) >> (64 - nbits) // Extensión de signo
+	}
+	d.prevDelta += dod
+	d.prev += d.prevDelta
+	return d.prev, nil
+}
+
+// valueEncoder codifica floats con XOR respecto al valor anterior, reutilizando la
+// ventana de bits significativos mientras el nuevo XOR quepa en ella
+type valueEncoder struct {
+	w         bitWriter
+	n         int
+	prev      uint64
+	leading   int
+	trailing  int
+	hasWindow bool
+}
+
+func (e *valueEncoder) write(v float64) {
+	raw := math.Float64bits(v)
+	defer func() { e.n++; e.prev = raw }()
+	if e.n == 0 {
+		e.w.writeBits(raw, 64)
+		return
+	}
+
+	xor := raw ^ e.prev
+	if xor == 0 {
+		e.w.writeBit(false)
+		return
+	}
+	e.w.writeBit(true)
+
+	leading, trailing := min(bits.LeadingZeros64(xor), 31), bits.TrailingZeros64(xor)
+	if e.hasWindow && leading >= e.leading && trailing >= e.trailing {
+		e.w.writeBit(false)
+		e.w.writeBits(xor>>e.trailing, 64-e.leading-e.trailing)
+		return
+	}
+
+	significant := 64 - leading - trailing
+	e.w.writeBit(true)
+	e.w.writeBits(uint64(leading), 5)
+	e.w.writeBits(uint64(significant)&63, 6) // 64 se escribe como 0
+	e.w.writeBits(xor>>trailing, significant)
+	e.leading, e.trailing, e.hasWindow = leading, trailing, true
+}
+
+// valueDecoder decodifica lo escrito por valueEncoder
+type valueDecoder struct {
+	r        bitReader
+	n        int
+	prev     uint64
+	leading  int
+	trailing int
+}
+
+func (d *valueDecoder) next() (float64, error) {
+	defer func() { d.n++ }()
+	if d.n == 0 {
+		v, err := d.r.readBits(64)
+		d.prev = v
+		return math.Float64frombits(v), err
+	}
+
+	changed, err := d.r.readBit()
+	if err != nil || !changed {
+		return math.Float64frombits(d.prev), err
+	}
+	newWindow, err := d.r.readBit()
+	if err != nil {
+		return 0, err
+	}
+	if newWindow {
+		leading, err := d.r.readBits(5)
+		if err != nil {
+			return 0, err
+		}
+		significant, err := d.r.readBits(6)
+		if err != nil {
+			return 0, err
+		}
+		if significant == 0 {
+			significant = 64
+		}
+		d.leading = int(leading)
+		d.trailing = 64 - d.leading - int(significant)
+		if d.trailing < 0 {
+			return 0, errCorruptBlock
+		}
+	}
+
+	xor, err := d.r.readBits(64 - d.leading - d.trailing)
+	if err != nil {
+		return 0, err
+	}
+	d.prev ^= xor << d.trailing
+	return math.Float64frombits(d.prev), nil
+}
+
+// timestampUnits son las precisiones posibles de los timestamps de un bloque, de mayor a
+// menor. Se codifican en la unidad más gruesa que divide a todos para que los deltas sean pequeños.
+var timestampUnits = [...]int64{int64(time.Second), int64(time.Millisecond), int64(time.Microsecond), 1}
+
+// blockHeaderSize es el tamaño de la cabecera de un bloque: timestamp mínimo, máximo
+// (nanosegundos) y número de lecturas
+const blockHeaderSize = 8 + 8 + 4
+
+// blockHeader resume un bloque para el índice sin decodificarlo
+type blockHeader struct {
+	minTs, maxTs int64
+	count        int
+}
+
+// encodeBlock ordena las lecturas de un sensor por (timestamp, id) y las codifica en un bloque:
+//
+//	cabecera | unidad | timestamps | valores | ids | tramos de tipo/unidad | errores | valores brutos
+//
+// Los ids se guardan como prefijo compartido con el anterior + sufijo (los ids estilo
+// ULID consecutivos comparten el prefijo temporal). Los valores brutos son los de las
+// lecturas calibradas (posición + valores comprimidos); los bloques anteriores a la
+// calibración terminan en los errores.
+func encodeBlock(readings []*sensor.SensorReading) []byte {
+	sort.Slice(readings, func(i, j int) bool {
+		if readings[i].Timestamp.Equal(readings[j].Timestamp) {
+			return readings[i].ID < readings[j].ID
+		}
+		return readings[i].Timestamp.Before(readings[j].Timestamp)
+	})
+
+	unit := timestampUnits[0]
+	for _, reading := range readings {
+		for reading.Timestamp.UnixNano()%unit != 0 {
+			unit /= 1000
+		}
+	}
+
+	var ts timestampEncoder
+	var values valueEncoder
+	for _, reading := range readings {
+		ts.write(reading.Timestamp.UnixNano() / unit)
+		values.write(reading.Value)
+	}
+
+	buf := make([]byte, blockHeaderSize, blockHeaderSize+len(ts.w.buf)+len(values.w.buf)+len(readings)*8)
+	binary.BigEndian.PutUint64(buf[0:], uint64(readings[0].Timestamp.UnixNano()))
+	binary.BigEndian.PutUint64(buf[8:], uint64(readings[len(readings)-1].Timestamp.UnixNano()))
+	binary.BigEndian.PutUint32(buf[16:], uint32(len(readings)))
+
+	buf = binary.AppendUvarint(buf, uint64(unit))
+	buf = appendBytes(buf, ts.w.buf)
+	buf = appendBytes(buf, values.w.buf)
+
+	prevID := ""
+	for _, reading := range readings {
+		shared := commonPrefix(prevID, reading.ID)
+		buf = binary.AppendUvarint(buf, uint64(shared))
+		buf = appendBytes(buf, []byte(reading.ID[shared:]))
+		prevID = reading.ID
+	}
+
+	type run struct {
+		length     int
+		typ, units string
+	}
+	var runs []run
+	for _, reading := range readings {
+		if n := len(runs); n > 0 && runs[n-1].typ == string(reading.Type) && runs[n-1].units == reading.Unit {
+			runs[n-1].length++
+			continue
+		}
+		runs = append(runs, run{length: 1, typ: string(reading.Type), units: reading.Unit})
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(runs)))
+	for _, r := range runs {
+		buf = binary.AppendUvarint(buf, uint64(r.length))
+		buf = appendBytes(buf, []byte(r.typ))
+		buf = appendBytes(buf, []byte(r.units))
+	}
+
+	var errs []int
+	for i, reading := range readings {
+		if reading.Error != nil {
+			errs = append(errs, i)
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(errs)))
+	for _, i := range errs {
+		buf = binary.AppendUvarint(buf, uint64(i))
+		buf = appendBytes(buf, []byte(*readings[i].Error))
+	}
+
+	var raws valueEncoder
+	var rawIdx []int
+	for i, reading := range readings {
+		if reading.RawValue != nil {
+			rawIdx = append(rawIdx, i)
+			raws.write(*reading.RawValue)
+		}
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(rawIdx)))
+	for _, i := range rawIdx {
+		buf = binary.AppendUvarint(buf, uint64(i))
+	}
+	if len(rawIdx) > 0 {
+		buf = appendBytes(buf, raws.w.buf)
+	}
+
+	return buf
+}
+
+// parseBlockHeader lee la cabecera de un bloque codificado
+func parseBlockHeader(data []byte) (blockHeader, error) {
+	if len(data) < blockHeaderSize {
+		return blockHeader{}, errCorruptBlock
+	}
+	return blockHeader{
+		minTs: int64(binary.BigEndian.Uint64(data[0:])),
+		maxTs: int64(binary.BigEndian.Uint64(data[8:])),
+		count: int(binary.BigEndian.Uint32(data[16:])),
+	}, nil
+}
+
+// decodedBlock son las columnas de un bloque; timestamps y valores se decodifican
+// solo al pedir las lecturas
+type decodedBlock struct {
+	header blockHeader
+	unit   int64
+	ts     []byte
+	values []byte
+	ids    []string
+	types  []sensor.SensorType
+	units  []string
+	errors map[int]string
+	rawIdx []int  // Posiciones de las lecturas con valor bruto
+	raws   []byte // Valores brutos comprimidos, en el orden de rawIdx
+}
+
+// parseBlock separa las columnas de un bloque codificado por encodeBlock
+func parseBlock(data []byte) (*decodedBlock, error) {
+	header, err := parseBlockHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	r := blockReader{buf: data[blockHeaderSize:]}
+	b := &decodedBlock{header: header}
+
+	b.unit = int64(r.uvarint())
+	b.ts = r.bytes()
+	b.values = r.bytes()
+
+	b.ids = make([]string, 0, header.count)
+	prevID := ""
+	for i := 0; i < header.count && r.err == nil; i++ {
+		shared := int(r.uvarint())
+		if shared > len(prevID) {
+			return nil, fmt.Errorf("%w: invalid id prefix", errCorruptBlock)
+		}
+		prevID = prevID[:shared] + string(r.bytes())
+		b.ids = append(b.ids, prevID)
+	}
+
+	runs := int(r.uvarint())
+	for i := 0; i < runs && r.err == nil; i++ {
+		length := int(r.uvarint())
+		typ, units := sensor.SensorType(r.bytes()), string(r.bytes())
+		for j := 0; j < length && len(b.types) < header.count; j++ {
+			b.types = append(b.types, typ)
+			b.units = append(b.units, units)
+		}
+	}
+
+	errs := int(r.uvarint())
+	for i := 0; i < errs && r.err == nil; i++ {
+		if b.errors == nil {
+			b.errors = make(map[int]string, errs)
+		}
+		idx := int(r.uvarint())
+		b.errors[idx] = string(r.bytes())
+	}
+
+	if len(r.buf) > 0 && r.err == nil {
+		n := int(r.uvarint())
+		for i := 0; i < n && r.err == nil; i++ {
+			idx := int(r.uvarint())
+			if idx >= header.count || (i > 0 && idx <= b.rawIdx[i-1]) {
+				return nil, fmt.Errorf("%w: invalid raw value position", errCorruptBlock)
+			}
+			b.rawIdx = append(b.rawIdx, idx)
+		}
+		if n > 0 {
+			b.raws = r.bytes()
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	if b.unit <= 0 || len(b.types) != header.count {
+		return nil, errCorruptBlock
+	}
+	return b, nil
+}
+
+// readings decodifica las lecturas del bloque en orden (timestamp, id) ascendente
+func (b *decodedBlock) readings(sensorID string) ([]*sensor.SensorReading, error) {
+	ts := timestampDecoder{r: bitReader{buf: b.ts}}
+	values := valueDecoder{r: bitReader{buf: b.values}}
+	raws := valueDecoder{r: bitReader{buf: b.raws}}
+	nextRaw := 0
+
+	readings := make([]*sensor.SensorReading, b.header.count)
+	for i := range readings {
+		t, err := ts.next()
+		if err != nil {
+			return nil, err
+		}
+		v, err := values.next()
+		if err != nil {
+			return nil, err
+		}
+		readings[i] = &sensor.SensorReading{
+			ID:        b.ids[i],
+			SensorID:  sensorID,
+			Type:      b.types[i],
+			Value:     v,
+			Unit:      b.units[i],
+			Timestamp: time.Unix(0, t*b.unit).UTC(),
+		}
+		if msg, ok := b.errors[i]; ok {
+			readings[i].Error = &msg
+		}
+		if nextRaw < len(b.rawIdx) && b.rawIdx[nextRaw] == i {
+			raw, err := raws.next()
+			if err != nil {
+				return nil, err
+			}
+			readings[i].RawValue = &raw
+			nextRaw++
+		}
+	}
+	return readings, nil
+}
+
+// blockReader lee varints y cadenas con prefijo de longitud, recordando el primer error
+type blockReader struct {
+	buf []byte
+	err error
+}
+
+func (r *blockReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 {
+		r.err = errCorruptBlock
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *blockReader) bytes() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.buf)) {
+		r.err = errCorruptBlock
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+// appendBytes añade b con su longitud como prefijo
+func appendBytes(buf, b []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+// commonPrefix retorna la longitud del prefijo común de a y b
+func commonPrefix(a, b string) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
@@ -0,0 +1,342 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+)
+
+// Formato de un segmento del backend tsfile:
+//
+//	"TSFSEG01" | frame de bloque | frame de bloque | ... [| frame de índice | offset del índice | "TSFSEAL1"]
+//
+// Cada frame es tipo (1 byte) | longitud (uint32) | CRC-32C (uint32) | payload. Los bloques se
+// añaden al final del segmento activo; al sellarlo se escriben el índice de bloques y la
+// cola con su offset, y se hace fsync. Al abrir un segmento sin cola válida se recorren
+// sus frames y se trunca a partir del primero incompleto o corrupto (escritura o sellado
+// interrumpidos por una caída).
+
+const (
+	segmentMagic = "TSFSEG01"
+	sealMagic    = "TSFSEAL1"
+
+	frameHeaderSize = 1 + 4 + 4
+	sealTrailerSize = 8 + len(sealMagic)
+	indexEntrySize  = 8 + 4 + blockHeaderSize
+
+	frameBlock byte = 1
+	frameIndex byte = 2
+	frameWAL   byte = 3
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// blockRef localiza un bloque dentro de su segmento
+type blockRef struct {
+	blockHeader
+	offset int64  // Inicio del frame
+	length uint32 // Longitud del payload
+}
+
+// tsSegment es un fichero de segmento abierto. Solo el segmento activo (no sellado)
+// mantiene el fichero abierto para escritura.
+type tsSegment struct {
+	path   string
+	sealed bool
+	size   int64
+	blocks []blockRef
+	file   *os.File
+	dirty  bool // Hay bloques escritos sin fsync
+}
+
+// createSegment crea un segmento vacío en path
+func createSegment(path string) (*tsSegment, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create segment %s: %w", path, err)
+	}
+	if _, err := file.Write([]byte(segmentMagic)); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write segment %s: %w", path, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to sync segment %s: %w", path, err)
+	}
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &tsSegment{path: path, size: int64(len(segmentMagic)), file: file}, nil
+}
+
+// openSegment abre un segmento existente. Los sellados cargan el índice de la cola; los
+// demás se recorren frame a frame y se truncan tras el último bloque válido.
+func openSegment(path string) (*tsSegment, error) {
+	if s, err := openSealedSegment(path); err != nil || s != nil {
+		return s, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read segment %s: %w", path, err)
+	}
+	if len(data) < len(segmentMagic) || string(data[:len(segmentMagic)]) != segmentMagic {
+		return nil, fmt.Errorf("invalid segment %s: bad magic", path)
+	}
+
+	s := &tsSegment{path: path, size: int64(len(segmentMagic))}
+	for {
+		kind, payload, ok := readFrame(data[s.size:])
+		if !ok || kind != frameBlock {
+			break // Fin, frame truncado o índice de un sellado interrumpido
+		}
+		header, err := parseBlockHeader(payload)
+		if err != nil {
+			break
+		}
+		s.blocks = append(s.blocks, blockRef{blockHeader: header, offset: s.size, length: uint32(len(payload))})
+		s.size += int64(frameHeaderSize + len(payload))
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment %s: %w", path, err)
+	}
+	if s.size < int64(len(data)) {
+		if err := file.Truncate(s.size); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to truncate segment %s: %w", path, err)
+		}
+	}
+	s.file = file
+	return s, nil
+}
+
+// openSealedSegment carga el índice de un segmento sellado; retorna nil si la cola no es válida
+func openSealedSegment(path string) (*tsSegment, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat segment %s: %w", path, err)
+	}
+	size := info.Size()
+	if size < int64(len(segmentMagic)+frameHeaderSize+sealTrailerSize) {
+		return nil, nil
+	}
+
+	trailer := make([]byte, sealTrailerSize)
+	if _, err := file.ReadAt(trailer, size-int64(sealTrailerSize)); err != nil {
+		return nil, fmt.Errorf("failed to read segment %s: %w", path, err)
+	}
+	if string(trailer[8:]) != sealMagic {
+		return nil, nil
+	}
+	indexOffset := int64(binary.BigEndian.Uint64(trailer))
+	if indexOffset < int64(len(segmentMagic)) || indexOffset > size-int64(sealTrailerSize+frameHeaderSize) {
+		return nil, nil
+	}
+
+	index := make([]byte, size-int64(sealTrailerSize)-indexOffset)
+	if _, err := file.ReadAt(index, indexOffset); err != nil {
+		return nil, fmt.Errorf("failed to read segment %s: %w", path, err)
+	}
+	kind, payload, ok := readFrame(index)
+	if !ok || kind != frameIndex || frameHeaderSize+len(payload) != len(index) || len(payload)%indexEntrySize != 0 {
+		return nil, nil
+	}
+
+	s := &tsSegment{path: path, sealed: true, size: size}
+	for entry := payload; len(entry) > 0; entry = entry[indexEntrySize:] {
+		header, _ := parseBlockHeader(entry[12:])
+		s.blocks = append(s.blocks, blockRef{
+			blockHeader: header,
+			offset:      int64(binary.BigEndian.Uint64(entry)),
+			length:      binary.BigEndian.Uint32(entry[8:]),
+		})
+	}
+	return s, nil
+}
+
+// appendBlock añade un bloque codificado al final del segmento activo
+func (s *tsSegment) appendBlock(block []byte) error {
+	if s.sealed {
+		return fmt.Errorf("segment %s is sealed", s.path)
+	}
+	header, err := parseBlockHeader(block)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.file.WriteAt(appendFrame(nil, frameBlock, block), s.size); err != nil {
+		return fmt.Errorf("failed to write block to %s: %w", s.path, err)
+	}
+	s.blocks = append(s.blocks, blockRef{blockHeader: header, offset: s.size, length: uint32(len(block))})
+	s.size += int64(frameHeaderSize + len(block))
+	s.dirty = true
+	return nil
+}
+
+// readBlock lee y verifica el payload de un bloque
+func (s *tsSegment) readBlock(ref blockRef) ([]byte, error) {
+	file := s.file
+	if file == nil {
+		var err error
+		if file, err = os.Open(s.path); err != nil {
+			return nil, fmt.Errorf("failed to open segment %s: %w", s.path, err)
+		}
+		defer file.Close()
+	}
+
+	frame := make([]byte, frameHeaderSize+int(ref.length))
+	if _, err := file.ReadAt(frame, ref.offset); err != nil {
+		return nil, fmt.Errorf("failed to read block from %s: %w", s.path, err)
+	}
+	kind, payload, ok := readFrame(frame)
+	if !ok || kind != frameBlock {
+		return nil, fmt.Errorf("failed to read block from %s at offset %d: %w", s.path, ref.offset, errCorruptBlock)
+	}
+	return payload, nil
+}
+
+// sync hace fsync de los bloques escritos desde el último
+func (s *tsSegment) sync() error {
+	if !s.dirty {
+		return nil
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync segment %s: %w", s.path, err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// seal escribe el índice de bloques y la cola, hace fsync y cierra el fichero. Si se
+// interrumpe, openSegment descarta el índice incompleto y el segmento sigue activo.
+func (s *tsSegment) seal() error {
+	index := make([]byte, 0, len(s.blocks)*indexEntrySize)
+	for _, ref := range s.blocks {
+		index = binary.BigEndian.AppendUint64(index, uint64(ref.offset))
+		index = binary.BigEndian.AppendUint32(index, ref.length)
+		index = binary.BigEndian.AppendUint64(index, uint64(ref.minTs))
+		index = binary.BigEndian.AppendUint64(index, uint64(ref.maxTs))
+		index = binary.BigEndian.AppendUint32(index, uint32(ref.count))
+	}
+
+	tail := appendFrame(nil, frameIndex, index)
+	tail = binary.BigEndian.AppendUint64(tail, uint64(s.size))
+	tail = append(tail, sealMagic...)
+	if _, err := s.file.WriteAt(tail, s.size); err != nil {
+		return fmt.Errorf("failed to seal segment %s: %w", s.path, err)
+	}
+	s.dirty = true
+	if err := s.sync(); err != nil {
+		return err
+	}
+
+	s.size += int64(len(tail))
+	s.sealed = true
+	return s.close()
+}
+
+// close cierra el fichero del segmento activo
+func (s *tsSegment) close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("failed to close segment %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// prepareReplacement escribe en <s>.tmp un segmento con los bloques dados (sellado si s
+// lo estaba). Retorna el segmento nuevo, que installReplacement coloca en lugar de s.
+func prepareReplacement(s *tsSegment, blocks [][]byte) (*tsSegment, error) {
+	tmp := s.path + ".tmp"
+	replaced, err := createSegment(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, block := range blocks {
+		if err = replaced.appendBlock(block); err != nil {
+			break
+		}
+	}
+	if err == nil && s.sealed {
+		err = replaced.seal()
+	} else if err == nil {
+		err = replaced.sync()
+	}
+	if err != nil {
+		replaced.close()
+		os.Remove(tmp)
+		return nil, err
+	}
+	return replaced, nil
+}
+
+// installReplacement renombra de forma atómica <path>.tmp sobre el segmento path. Si el
+// temporal ya no existe es que se instaló antes y no hace nada.
+func installReplacement(path string) error {
+	if err := os.Rename(path+".tmp", path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to replace segment %s: %w", path, err)
+	}
+	return nil
+}
+
+// removeSegment borra el fichero del segmento path si todavía existe
+func removeSegment(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove segment %s: %w", path, err)
+	}
+	return nil
+}
+
+// appendFrame añade a buf un frame con su cabecera
+func appendFrame(buf []byte, kind byte, payload []byte) []byte {
+	buf = append(buf, kind)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(payload)))
+	buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(payload, crcTable))
+	return append(buf, payload...)
+}
+
+// readFrame lee el frame al inicio de data; ok es false si está incompleto o su CRC no coincide
+func readFrame(data []byte) (kind byte, payload []byte, ok bool) {
+	if len(data) < frameHeaderSize {
+		return 0, nil, false
+	}
+	length := binary.BigEndian.Uint32(data[1:])
+	if uint64(length) > uint64(len(data)-frameHeaderSize) {
+		return 0, nil, false
+	}
+	payload = data[frameHeaderSize : frameHeaderSize+int(length)]
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(data[5:]) {
+		return 0, nil, false
+	}
+	return data[0], payload, true
+}
+
+// syncDir hace fsync de un directorio para que las creaciones y renombrados sobrevivan a una caída
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory %s: %w", dir, err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,422 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+func TestGorillaEncoding_RoundTrip(t *testing.T) {
+	timestamps := []int64{
+		1_700_000_000, 1_700_000_001, 1_700_000_002, 1_700_000_002, // delta 0
+		1_700_000_010, 1_699_999_000, // delta negativo
+		1_700_100_000, 1_800_000_000, math.MaxInt64 / 2, // saltos de 32 y 64 bits
+	}
+	values := []float64{21.5, 21.5, 21.75, -3, 0, math.Inf(1), math.NaN(), 1e-300, 21.5}
+
+	var ts timestampEncoder
+	var vs valueEncoder
+	for i := range timestamps {
+		ts.write(timestamps[i])
+		vs.write(values[i])
+	}
+
+	tsDec := timestampDecoder{r: bitReader{buf: ts.w.buf}}
+	vsDec := valueDecoder{r: bitReader{buf: vs.w.buf}}
+	for i := range timestamps {
+		got, err := tsDec.next()
+		if err != nil || got != timestamps[i] {
+			t.Errorf("timestamp %d = %d (%v), want %d", i, got, err, timestamps[i])
+		}
+		v, err := vsDec.next()
+		if err != nil || math.Float64bits(v) != math.Float64bits(values[i]) {
+			t.Errorf("value %d = %v (%v), want %v", i, v, err, values[i])
+		}
+	}
+
+	// El bloque guarda cuántos valores hay; un flujo truncado debe dar error, no basura
+	truncated := timestampDecoder{r: bitReader{buf: ts.w.buf[:len(ts.w.buf)-9]}}
+	var err error
+	for i := 0; i < len(timestamps) && err == nil; i++ {
+		_, err = truncated.next()
+	}
+	if err == nil {
+		t.Error("expected error decoding a truncated stream")
+	}
+}
+
+func TestEncodeBlock_RoundTripAndSize(t *testing.T) {
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	msg := "sensor timeout"
+	var readings []*sensor.SensorReading
+	for i := 0; i < 1000; i++ {
+		reading := &sensor.SensorReading{
+			ID:        sensor.NewID(),
+			SensorID:  "temp-001",
+			Type:      sensor.SensorTypeTemperature,
+			Value:     20 + float64(i%10)/4,
+			Unit:      "°C",
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+		}
+		if i == 500 {
+			reading.Error = &msg
+		}
+		if i%3 == 0 {
+			raw := reading.Value - 0.25
+			reading.RawValue = &raw
+		}
+		if i >= 900 {
+			reading.Type, reading.Unit = sensor.SensorTypeHumidity, "%"
+		}
+		readings = append(readings, reading)
+	}
+
+	// Desordenadas, como las entregan los workers
+	shuffled := append([]*sensor.SensorReading{readings[999]}, readings[:999]...)
+	data := encodeBlock(shuffled)
+	block, err := parseBlock(data)
+	if err != nil {
+		t.Fatalf("parseBlock failed: %v", err)
+	}
+	if block.unit != int64(time.Second) {
+		t.Errorf("expected second precision, got unit %d", block.unit)
+	}
+	decoded, err := block.readings("temp-001")
+	if err != nil {
+		t.Fatalf("readings failed: %v", err)
+	}
+
+	for i, got := range decoded {
+		want := readings[i]
+		if got.ID != want.ID || got.Value != want.Value || !got.Timestamp.Equal(want.Timestamp) || got.Type != want.Type || got.Unit != want.Unit {
+			t.Fatalf("reading %d = %+v, want %+v", i, got, want)
+		}
+		if (got.Error != nil) != (want.Error != nil) {
+			t.Fatalf("reading %d error = %v, want %v", i, got.Error, want.Error)
+		}
+		if (got.RawValue != nil) != (want.RawValue != nil) || (got.RawValue != nil && *got.RawValue != *want.RawValue) {
+			t.Fatalf("reading %d raw value = %v, want %v", i, got.RawValue, want.RawValue)
+		}
+	}
+
+	// Los bloques anteriores a la calibración terminan en los errores
+	old := encodeBlock([]*sensor.SensorReading{newTSFileReading("r1", 1, base)})
+	block, err = parseBlock(old[:len(old)-1])
+	if err != nil {
+		t.Fatalf("parseBlock of a block without raw values failed: %v", err)
+	}
+	if decoded, err := block.readings("temp-001"); err != nil || len(decoded) != 1 || decoded[0].RawValue != nil {
+		t.Errorf("unexpected readings of a block without raw values: %v, %v", decoded, err)
+	}
+
+	// Timestamps y valores ocupan una fracción de los 16 bytes por lectura sin comprimir
+	if n := len(block.ts) + len(block.values); n > 1000*4 {
+		t.Errorf("expected compressed timestamps and values under 4 bytes per reading, got %d bytes", n)
+	}
+}
+
+func newTSFileReading(id string, value float64, ts time.Time) *sensor.SensorReading {
+	return &sensor.SensorReading{
+		ID:        id,
+		SensorID:  "temp-001",
+		Type:      sensor.SensorTypeTemperature,
+		Value:     value,
+		Unit:      "°C",
+		Timestamp: ts,
+	}
+}
+
+func TestTSFileRepository_ReopenAndSeal(t *testing.T) {
+	dir := t.TempDir()
+	opts := TSFileOptions{BlockSize: 50, SegmentSize: 1024}
+	ctx := context.Background()
+
+	repo, err := NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 1000; i += 100 {
+		var batch []*sensor.SensorReading
+		for j := i; j < i+100; j++ {
+			batch = append(batch, newTSFileReading(fmt.Sprintf("r%04d", j), float64(j), base.Add(time.Duration(j)*time.Second)))
+		}
+		if err := repo.SaveReadings(ctx, batch); err != nil {
+			t.Fatalf("SaveReadings failed: %v", err)
+		}
+	}
+	repo.SaveReading(ctx, newTSFileReading("r-head", -1, base.Add(time.Hour))) // Solo en el WAL
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	repo, err = NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer repo.Close()
+
+	series := repo.series["temp-001"]
+	if len(series.segments) < 2 || !series.segments[0].sealed {
+		t.Fatalf("expected several segments with the first sealed, got %d", len(series.segments))
+	}
+
+	readings, err := repo.GetReadingsByTimeRange(ctx, "temp-001", base.Add(100*time.Second), base.Add(199*time.Second))
+	if err != nil || len(readings) != 100 || readings[0].ID != "r0199" || readings[99].ID != "r0100" {
+		t.Fatalf("unexpected range after reopen: %d readings, %v", len(readings), err)
+	}
+	latest, _ := repo.GetLatestReadings(ctx, "temp-001", 2)
+	if len(latest) != 2 || latest[0].ID != "r-head" || latest[1].ID != "r0999" {
+		t.Errorf("unexpected latest readings: %v", latest)
+	}
+
+	// Los ids guardados antes de cerrar siguen siendo duplicados
+	repo.SaveReading(ctx, newTSFileReading("r0005", 99, base))
+	if repo.DuplicateReadings() != 1 {
+		t.Errorf("expected 1 duplicate after reopen, got %d", repo.DuplicateReadings())
+	}
+}
+
+func TestTSFileRepository_RecoversTornWrites(t *testing.T) {
+	dir := t.TempDir()
+	opts := TSFileOptions{BlockSize: 10}
+	ctx := context.Background()
+
+	repo, err := NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 25; i++ {
+		repo.SaveReading(ctx, newTSFileReading(fmt.Sprintf("r%02d", i), float64(i), base.Add(time.Duration(i)*time.Second)))
+	}
+	repo.SaveAlert(ctx, &sensor.Alert{ID: "a1", SensorID: "temp-001", Timestamp: base})
+
+	// Caída sin Close: 20 lecturas en bloques, 5 solo en el WAL, y escrituras a medias
+	repo.closeSegments()
+	repo.wal.close()
+	segment := filepath.Join(dir, "series", "temp-001", "000001.seg")
+	for _, path := range []string{segment, filepath.Join(dir, "wal.log")} {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", path, err)
+		}
+		f.Write([]byte{frameBlock, 0, 0, 1, 0, 0xde, 0xad})
+		f.Close()
+	}
+
+	reopened, err := NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer reopened.Close()
+
+	readings, _ := reopened.GetLatestReadings(ctx, "temp-001", 100)
+	if len(readings) != 25 {
+		t.Errorf("expected 25 readings after recovery, got %d", len(readings))
+	}
+	if alerts, _ := reopened.GetAlerts(ctx, repository.AlertFilter{}); len(alerts) != 1 {
+		t.Errorf("expected the alert to be replayed from the WAL, got %d", len(alerts))
+	}
+	if info, _ := os.Stat(segment); info.Size() != reopened.series["temp-001"].segments[0].size {
+		t.Errorf("expected the torn block to be truncated, file has %d bytes", info.Size())
+	}
+}
+
+func TestTSFileSegment_TornSeal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "000001.seg")
+	seg, err := createSegment(path)
+	if err != nil {
+		t.Fatalf("createSegment failed: %v", err)
+	}
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	block := encodeBlock([]*sensor.SensorReading{newTSFileReading("r1", 1, base), newTSFileReading("r2", 2, base.Add(time.Second))})
+	if err := seg.appendBlock(block); err != nil {
+		t.Fatalf("appendBlock failed: %v", err)
+	}
+	size := seg.size
+
+	// Índice escrito sin la cola: el sellado no llegó a completarse
+	seg.file.WriteAt(appendFrame(nil, frameIndex, make([]byte, indexEntrySize)), size)
+	seg.close()
+
+	seg, err = openSegment(path)
+	if err != nil {
+		t.Fatalf("openSegment failed: %v", err)
+	}
+	if seg.sealed || len(seg.blocks) != 1 || seg.size != size {
+		t.Fatalf("expected an active segment with 1 block and %d bytes, got sealed=%v blocks=%d size=%d", size, seg.sealed, len(seg.blocks), seg.size)
+	}
+
+	if err := seg.seal(); err != nil {
+		t.Fatalf("seal failed: %v", err)
+	}
+	seg, err = openSegment(path)
+	if err != nil || !seg.sealed || len(seg.blocks) != 1 || seg.blocks[0].count != 2 {
+		t.Fatalf("expected a sealed segment with 1 block of 2 readings, got %+v, %v", seg, err)
+	}
+	if payload, err := seg.readBlock(seg.blocks[0]); err != nil || string(payload) != string(block) {
+		t.Errorf("readBlock = %v", err)
+	}
+}
+
+func TestTSFileRepository_RetentionAndPurge(t *testing.T) {
+	dir := t.TempDir()
+	opts := TSFileOptions{BlockSize: 10, SegmentSize: 256}
+	ctx := context.Background()
+
+	repo, err := NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 45; i++ {
+		repo.SaveReading(ctx, newTSFileReading(fmt.Sprintf("r%02d", i), float64(i), base.Add(time.Duration(i)*time.Minute)))
+	}
+
+	deleted, err := repo.ApplyRetention(ctx, repository.RetentionPolicy{Before: base.Add(25 * time.Minute), Rollup: true})
+	if err != nil || deleted != 25 {
+		t.Fatalf("ApplyRetention = %d, %v; want 25", deleted, err)
+	}
+	readings, _ := repo.GetLatestReadings(ctx, "temp-001", -1)
+	if len(readings) != 20 || readings[19].ID != "r25" {
+		t.Errorf("expected readings r25..r44 to remain, got %d", len(readings))
+	}
+
+	// Los resúmenes cubren lo eliminado: 1h de 10:00 = r00..r24 (crudas r25..r44 van aparte)
+	aggregates, _ := repo.GetAggregatedReadings(ctx, "temp-001", base, base.Add(time.Hour), time.Hour)
+	if len(aggregates) != 1 || aggregates[0].Count != 45 {
+		t.Errorf("expected 1 hourly aggregate of 45 readings, got %+v", aggregates)
+	}
+	repo.Close()
+
+	repo, err = NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	defer repo.Close()
+	if readings, _ := repo.GetLatestReadings(ctx, "temp-001", -1); len(readings) != 20 {
+		t.Errorf("expected 20 readings after reopen, got %d", len(readings))
+	}
+	// Los ids eliminados se pueden volver a guardar
+	repo.SaveReading(ctx, newTSFileReading("r00", 0, base))
+	if repo.DuplicateReadings() != 0 {
+		t.Errorf("expected expired ids to be forgotten, got %d duplicates", repo.DuplicateReadings())
+	}
+
+	deleted, err = repo.PurgeSensorData(ctx, "temp-001")
+	if err != nil || deleted != 21 {
+		t.Fatalf("PurgeSensorData = %d, %v; want 21", deleted, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "series", "temp-001")); !os.IsNotExist(err) {
+		t.Errorf("expected the series directory to be removed, got %v", err)
+	}
+	if aggregates, _ := repo.GetAggregatedReadings(ctx, "temp-001", base, base.Add(time.Hour), time.Hour); len(aggregates) != 0 {
+		t.Errorf("expected no aggregates after purge, got %+v", aggregates)
+	}
+}
+
+func TestTSFileRepository_RetentionCrashRecovery(t *testing.T) {
+	dir := t.TempDir()
+	opts := TSFileOptions{BlockSize: 10, SegmentSize: 256}
+	ctx := context.Background()
+	// Simula una caída: cierra los ficheros sin checkpoint
+	crash := func(repo *TSFileRepository) {
+		repo.closeSegments()
+		repo.wal.close()
+	}
+
+	repo, err := NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	base := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 25; i++ {
+		repo.SaveReading(ctx, newTSFileReading(fmt.Sprintf("r%02d", i), float64(i), base.Add(time.Duration(i)*time.Minute)))
+	}
+
+	// r20..r24 solo están en el WAL: tras la retención y una caída no deben reaparecer
+	deleted, err := repo.ApplyRetention(ctx, repository.RetentionPolicy{Before: base.Add(22 * time.Minute), Rollup: true})
+	if err != nil || deleted != 22 {
+		t.Fatalf("ApplyRetention = %d, %v; want 22", deleted, err)
+	}
+	repo.SaveReading(ctx, newTSFileReading("r25", 25, base.Add(25*time.Minute)))
+	crash(repo)
+
+	repo, err = NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	if readings, _ := repo.GetLatestReadings(ctx, "temp-001", -1); len(readings) != 4 || readings[3].ID != "r22" {
+		t.Fatalf("expected readings r22..r25 after reopen, got %d", len(readings))
+	}
+
+	// Caída después de registrar la retención en el WAL y antes de instalar los segmentos
+	expired := func(reading *sensor.SensorReading) bool { return reading.Timestamp.Before(base.Add(24 * time.Minute)) }
+	repo.mu.Lock()
+	if err := repo.checkpoint(); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	steps, err := repo.planRetention(base.Add(24*time.Minute).UnixNano(), expired)
+	if err != nil || len(steps) == 0 {
+		t.Fatalf("planRetention = %d steps, %v", len(steps), err)
+	}
+	record := &tsRetention{Rollups: repo.rolledUp(steps)}
+	for _, step := range steps {
+		rel, _ := filepath.Rel(dir, step.segment.path)
+		if step.replacement != nil {
+			record.Replace = append(record.Replace, rel)
+			step.replacement.close()
+		} else {
+			record.Remove = append(record.Remove, rel)
+		}
+	}
+	if err := repo.wal.append(tsWALRecord{Retention: record}); err != nil {
+		t.Fatalf("failed to append retention record: %v", err)
+	}
+	repo.mu.Unlock()
+	crash(repo)
+
+	repo, err = NewTSFileRepository(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to reopen repository: %v", err)
+	}
+	if readings, _ := repo.GetLatestReadings(ctx, "temp-001", -1); len(readings) != 2 || readings[1].ID != "r24" {
+		t.Errorf("expected readings r24..r25 after recovering the retention, got %d", len(readings))
+	}
+	aggregates, _ := repo.GetAggregatedReadings(ctx, "temp-001", base, base.Add(time.Hour), time.Hour)
+	if len(aggregates) != 1 || aggregates[0].Count != 26 {
+		t.Errorf("expected 1 hourly aggregate of 26 readings, got %+v", aggregates)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "series", "*", "*.tmp")); len(matches) != 0 {
+		t.Errorf("expected no temporary segments left, got %v", matches)
+	}
+
+	// app.Server cierra el repositorio dos veces
+	if err := repo.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := repo.Close(); err != nil {
+		t.Errorf("second Close = %v, want nil", err)
+	}
+}
+
+func TestSeriesDirName(t *testing.T) {
+	tests := map[string]string{
+		"temp-001":   "temp-001",
+		"../etc":     "%2E%2E%2Fetc",
+		"sala 1/a.b": "sala%201%2Fa%2Eb",
+	}
+	for id, want := range tests {
+		if got := seriesDirName(id); got != want {
+			t.Errorf("seriesDirName(%q) = %q, want %q", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+)
+
+// tsWALRecord es una entrada del WAL del backend tsfile: un lote de lecturas aún no
+// comprimidas en bloques, una alerta aún no volcada a meta.json o una retención en curso
+type tsWALRecord struct {
+	Readings  []*sensor.SensorReading `json:"readings,omitempty"`
+	Alert     *sensor.Alert           `json:"alert,omitempty"`
+	Retention *tsRetention            `json:"retention,omitempty"`
+}
+
+// tsRetention registra una retención antes de tocar los segmentos: los que se reemplazan
+// por su <seg>.tmp ya escrito, los que se borran (rutas relativas al directorio del
+// backend) y el valor final de los resúmenes afectados. Aplicarla es idempotente, así
+// que al abrir se completa la que una caída haya dejado a medias.
+type tsRetention struct {
+	Replace []string         `json:"replace,omitempty"`
+	Remove  []string         `json:"remove,omitempty"`
+	Rollups []snapshotRollup `json:"rollups,omitempty"`
+}
+
+// tsWAL es el log de escritura anticipada: frames con CRC (mismo formato que los de los
+// segmentos) añadidos al final del fichero. Se vacía en cada checkpoint.
+type tsWAL struct {
+	path string
+	file *os.File
+	size int64
+}
+
+// openWAL abre el WAL de path (lo crea si no existe) y retorna sus entradas. Un frame
+// final incompleto o corrupto (escritura interrumpida) se descarta truncando el fichero.
+func openWAL(path string) (*tsWAL, []tsWALRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("failed to read WAL %s: %w", path, err)
+	}
+
+	var records []tsWALRecord
+	var size int64
+	for {
+		kind, payload, ok := readFrame(data[size:])
+		if !ok || kind != frameWAL {
+			break
+		}
+		var record tsWALRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode WAL %s at offset %d: %w", path, size, err)
+		}
+		records = append(records, record)
+		size += int64(frameHeaderSize + len(payload))
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open WAL %s: %w", path, err)
+	}
+	if size < int64(len(data)) {
+		if err := file.Truncate(size); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to truncate WAL %s: %w", path, err)
+		}
+	}
+
+	return &tsWAL{path: path, file: file, size: size}, records, nil
+}
+
+// append añade una entrada al WAL. No hace fsync: una caída del proceso no pierde datos,
+// una del sistema operativo puede perder las últimas entradas desde el último checkpoint.
+func (w *tsWAL) append(record tsWALRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode WAL record: %w", err)
+	}
+	frame := appendFrame(nil, frameWAL, payload)
+	if _, err := w.file.WriteAt(frame, w.size); err != nil {
+		return fmt.Errorf("failed to write WAL %s: %w", w.path, err)
+	}
+	w.size += int64(len(frame))
+	return nil
+}
+
+// sync hace fsync del WAL
+func (w *tsWAL) sync() error {
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL %s: %w", w.path, err)
+	}
+	return nil
+}
+
+// reset vacía el WAL una vez que todo su contenido está en los segmentos y en meta.json
+func (w *tsWAL) reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate WAL %s: %w", w.path, err)
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync WAL %s: %w", w.path, err)
+	}
+	w.size = 0
+	return nil
+}
+
+// close cierra el fichero del WAL
+func (w *tsWAL) close() error {
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("failed to close WAL %s: %w", w.path, err)
+	}
+	return nil
+}
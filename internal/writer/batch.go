@@ -0,0 +1,282 @@
+package writer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/logger"
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxBatchSize  = 100         // Lecturas por transacción
+	defaultFlushInterval = time.Second // Latencia máxima de persistencia
+	defaultMaxPending    = 10000       // Lecturas en memoria (buffer + lotes por reintentar)
+	defaultMaxRetries    = 3           // Reintentos de un lote fallido antes de descartarlo
+)
+
+// Options configura el tamaño y la frecuencia de los flush
+type Options struct {
+	MaxBatchSize  int           // Flush al alcanzar este número de lecturas pendientes
+	FlushInterval time.Duration // Flush periódico aunque no se alcance el tamaño
+	MaxPending    int           // Write descarta lecturas nuevas por encima de este límite
+	MaxRetries    int           // Reintentos de un lote fallido, con espera creciente entre ellos
+}
+
+// Stats contiene métricas acumuladas del writer
+type Stats struct {
+	Flushes       int64   `json:"flushes"`
+	Written       int64   `json:"written"`
+	Failed        int64   `json:"failed"`  // Descartadas tras agotar los reintentos
+	Retries       int64   `json:"retries"` // Flush fallidos que se han vuelto a encolar
+	Dropped       int64   `json:"dropped"` // Descartadas por Write con MaxPending alcanzado
+	Pending       int     `json:"pending"`
+	LastBatchSize int     `json:"last_batch_size"`
+	MaxBatchSize  int     `json:"max_batch_size"`
+	AvgBatchSize  float64 `json:"avg_batch_size"`
+	LastFlushMs   float64 `json:"last_flush_ms"`
+	MaxFlushMs    float64 `json:"max_flush_ms"`
+	AvgFlushMs    float64 `json:"avg_flush_ms"`
+}
+
+// retryBatch es un lote cuyo flush ha fallado, pendiente de reintento. Se reintenta
+// entero para que las lecturas de una misma llamada a Write sigan en la misma transacción.
+type retryBatch struct {
+	readings []*sensor.SensorReading
+	attempts int       // Flush fallidos
+	next     time.Time // No se reintenta antes
+}
+
+// BatchWriter acumula lecturas en memoria y las persiste con Repository.SaveReadings
+// en una única transacción cuando se alcanza MaxBatchSize o vence FlushInterval.
+// Los lotes fallidos se reintentan hasta MaxRetries veces antes de descartarlos.
+type BatchWriter struct {
+	repo     repository.Repository
+	opts     Options
+	mu       sync.Mutex // Protege buffer, retries, retrying y stats
+	flushMu  sync.Mutex // Serializa los flush
+	buffer   []*sensor.SensorReading
+	retries  []retryBatch
+	retrying int // Lecturas en retries
+	stats    Stats
+	total    time.Duration // Latencia acumulada para calcular la media
+	flushCh  chan struct{}
+	done     chan struct{}
+	wg       sync.WaitGroup
+	closed   bool
+}
+
+// NewBatchWriter crea el writer y arranca su goroutine de flush periódico.
+// Los valores de opts <= 0 se sustituyen por los valores por defecto.
+func NewBatchWriter(repo repository.Repository, opts Options) *BatchWriter {
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = defaultMaxBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = defaultMaxPending
+	}
+	opts.MaxPending = max(opts.MaxPending, opts.MaxBatchSize)
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultMaxRetries
+	}
+
+	w := &BatchWriter{
+		repo:    repo,
+		opts:    opts,
+		buffer:  make([]*sensor.SensorReading, 0, opts.MaxBatchSize),
+		flushCh: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	w.wg.Add(1)
+	go w.loop()
+
+	return w
+}
+
+// Write encola lecturas para su persistencia. Nunca bloquea por I/O. Las lecturas de
+// una misma llamada van siempre en el mismo lote, así que se guardan en la misma
+// transacción. Si hay MaxPending lecturas en memoria (la base de datos no responde)
+// se descartan las nuevas. Tras Close las lecturas se persisten de forma síncrona.
+func (w *BatchWriter) Write(readings ...*sensor.SensorReading) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		w.flushBatch(readings, 0, true)
+		return
+	}
+	if len(w.buffer)+w.retrying+len(readings) > w.opts.MaxPending {
+		w.stats.Dropped += int64(len(readings))
+		w.mu.Unlock()
+		logger.WithField("readings", len(readings)).Warn("[Writer] Too many pending readings, readings dropped")
+		return
+	}
+	w.buffer = append(w.buffer, readings...)
+	full := len(w.buffer) >= w.opts.MaxBatchSize
+	w.mu.Unlock()
+
+	if full {
+		// Señal no bloqueante: si ya hay un flush pendiente basta con ese
+		select {
+		case w.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush persiste inmediatamente las lecturas pendientes y reintenta los lotes fallidos
+// cuya espera ha vencido
+func (w *BatchWriter) Flush() {
+	w.flush(false)
+}
+
+// flush persiste el buffer y los lotes por reintentar. Con final se reintentan todos sin
+// esperar y los que vuelven a fallar se descartan (cierre del writer).
+func (w *BatchWriter) flush(final bool) {
+	now := time.Now()
+	w.mu.Lock()
+	var due []retryBatch
+	pending := w.retries[:0]
+	for _, retry := range w.retries {
+		if final || !now.Before(retry.next) {
+			due = append(due, retry)
+			w.retrying -= len(retry.readings)
+		} else {
+			pending = append(pending, retry)
+		}
+	}
+	w.retries = pending
+	batch := w.buffer
+	w.buffer = make([]*sensor.SensorReading, 0, w.opts.MaxBatchSize)
+	w.mu.Unlock()
+
+	// Los lotes más antiguos primero
+	for _, retry := range due {
+		w.flushBatch(retry.readings, retry.attempts, final)
+	}
+	w.flushBatch(batch, 0, final)
+}
+
+// Close detiene el flush periódico y persiste las lecturas pendientes
+func (w *BatchWriter) Close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.done)
+	w.wg.Wait()
+
+	w.flush(true)
+	logger.Info("[Writer] Batch writer closed")
+}
+
+// Stats retorna una copia de las métricas actuales
+func (w *BatchWriter) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	stats.Pending = len(w.buffer) + w.retrying
+	return stats
+}
+
+// loop dispara flush por tiempo o por tamaño hasta que se cierra el writer
+func (w *BatchWriter) loop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.Flush()
+		case <-w.flushCh:
+			w.Flush()
+		}
+	}
+}
+
+// flushBatch guarda un lote en una transacción y actualiza las métricas. attempts es el
+// número de flush fallidos previos del lote. Si falla, el lote se vuelve a encolar con una
+// espera que se duplica en cada intento; se descarta y se contabiliza como fallido al
+// superar MaxRetries o si final.
+func (w *BatchWriter) flushBatch(batch []*sensor.SensorReading, attempts int, final bool) {
+	if len(batch) == 0 {
+		return
+	}
+
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	start := time.Now()
+	err := w.repo.SaveReadings(context.Background(), batch)
+	latency := time.Since(start)
+
+	attempts++
+	retry := err != nil && !final && attempts <= w.opts.MaxRetries
+	w.mu.Lock()
+	switch {
+	case retry:
+		w.stats.Retries++
+		w.retries = append(w.retries, retryBatch{
+			readings: batch,
+			attempts: attempts,
+			next:     time.Now().Add(w.opts.FlushInterval << (attempts - 1)),
+		})
+		w.retrying += len(batch)
+	case err != nil:
+		w.stats.Failed += int64(len(batch))
+	default:
+		w.stats.Flushes++
+		w.stats.Written += int64(len(batch))
+		w.stats.LastBatchSize = len(batch)
+		w.stats.MaxBatchSize = max(w.stats.MaxBatchSize, len(batch))
+		w.stats.AvgBatchSize = float64(w.stats.Written) / float64(w.stats.Flushes)
+
+		w.total += latency
+		w.stats.LastFlushMs = msec(latency)
+		w.stats.MaxFlushMs = max(w.stats.MaxFlushMs, msec(latency))
+		w.stats.AvgFlushMs = msec(w.total) / float64(w.stats.Flushes)
+	}
+	w.mu.Unlock()
+
+	if retry {
+		logger.WithFields(logrus.Fields{
+			"batch_size": len(batch),
+			"attempt":    attempts,
+			"error":      err,
+		}).Warn("[Writer] Error saving batch, will retry")
+		return
+	}
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"batch_size": len(batch),
+			"attempts":   attempts,
+			"error":      err,
+		}).Error("[Writer] Error saving batch, readings dropped")
+		return
+	}
+
+	logger.WithFields(logrus.Fields{
+		"batch_size": len(batch),
+		"latency":    latency,
+	}).Debug("[Writer] Batch flushed")
+}
+
+// msec convierte una duración a milisegundos con decimales
+func msec(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,230 @@
+package writer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alejandro/technical_test_uvigo/internal/repository"
+	"github.com/alejandro/technical_test_uvigo/internal/sensor"
+	"github.com/alejandro/technical_test_uvigo/internal/storage"
+)
+
+func newTestReading(i int) *sensor.SensorReading {
+	return &sensor.SensorReading{
+		ID:        fmt.Sprintf("read-%03d", i),
+		SensorID:  "temp-001",
+		Type:      sensor.SensorTypeTemperature,
+		Value:     float64(20 + i),
+		Unit:      "°C",
+		Timestamp: time.Now().UTC(),
+	}
+}
+
+func TestBatchWriter_FlushBySize(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	// Intervalo largo: solo el tamaño puede disparar el flush
+	w := NewBatchWriter(repo, Options{MaxBatchSize: 5, FlushInterval: time.Hour})
+	defer w.Close()
+
+	for i := 0; i < 5; i++ {
+		w.Write(newTestReading(i))
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for w.Stats().Written < 5 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stats := w.Stats()
+	if stats.Written != 5 || stats.Flushes != 1 {
+		t.Fatalf("expected 1 flush of 5 readings, got %+v", stats)
+	}
+	if stats.LastBatchSize != 5 || stats.MaxBatchSize != 5 || stats.AvgBatchSize != 5 {
+		t.Errorf("unexpected batch size metrics: %+v", stats)
+	}
+
+	readings, _ := repo.GetLatestReadings(context.Background(), "temp-001", 10)
+	if len(readings) != 5 {
+		t.Errorf("expected 5 persisted readings, got %d", len(readings))
+	}
+}
+
+func TestBatchWriter_FlushByInterval(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	w := NewBatchWriter(repo, Options{MaxBatchSize: 100, FlushInterval: 20 * time.Millisecond})
+	defer w.Close()
+
+	w.Write(newTestReading(1))
+	w.Write(newTestReading(2))
+
+	if w.Stats().Pending != 2 {
+		t.Errorf("expected 2 pending readings before flush, got %d", w.Stats().Pending)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	stats := w.Stats()
+	if stats.Written != 2 || stats.Pending != 0 {
+		t.Errorf("expected 2 readings flushed by interval, got %+v", stats)
+	}
+}
+
+func TestBatchWriter_CloseFlushesPending(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	w := NewBatchWriter(repo, Options{MaxBatchSize: 100, FlushInterval: time.Hour})
+	for i := 0; i < 3; i++ {
+		w.Write(newTestReading(i))
+	}
+
+	w.Close()
+
+	readings, _ := repo.GetLatestReadings(context.Background(), "temp-001", 10)
+	if len(readings) != 3 {
+		t.Errorf("expected 3 readings persisted on Close, got %d", len(readings))
+	}
+
+	// Escrituras tras Close se persisten de forma síncrona
+	w.Write(newTestReading(10))
+	if w.Stats().Written != 4 {
+		t.Errorf("expected write after Close to be persisted, got %+v", w.Stats())
+	}
+}
+
+func TestBatchWriter_FailedBatch(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	repo.Close() // Toda escritura fallará
+
+	w := NewBatchWriter(repo, Options{MaxBatchSize: 100, FlushInterval: time.Hour})
+	w.Write(newTestReading(1))
+	w.Write(newTestReading(2))
+	w.Close()
+
+	stats := w.Stats()
+	if stats.Failed != 2 || stats.Written != 0 || stats.Flushes != 0 {
+		t.Errorf("expected 2 failed readings, got %+v", stats)
+	}
+}
+
+func TestBatchWriter_WriteGroupInOneBatch(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer repo.Close()
+
+	w := NewBatchWriter(repo, Options{MaxBatchSize: 2, FlushInterval: time.Hour})
+	defer w.Close()
+
+	// Una muestra de 3 canales supera MaxBatchSize pero no se parte en dos lotes
+	w.Write(newTestReading(0), newTestReading(1), newTestReading(2))
+
+	deadline := time.Now().Add(time.Second)
+	for w.Stats().Written < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if stats := w.Stats(); stats.Flushes != 1 || stats.LastBatchSize != 3 {
+		t.Errorf("expected 1 flush of 3 readings, got %+v", stats)
+	}
+}
+
+// flakyRepository hace fallar las primeras fails llamadas a SaveReadings
+type flakyRepository struct {
+	repository.Repository
+	mu    sync.Mutex
+	fails int
+}
+
+func (r *flakyRepository) SaveReadings(ctx context.Context, readings []*sensor.SensorReading) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fails > 0 {
+		r.fails--
+		return errors.New("database is locked")
+	}
+	return r.Repository.SaveReadings(ctx, readings)
+}
+
+func TestBatchWriter_RetryFailedBatch(t *testing.T) {
+	sqlite, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	defer sqlite.Close()
+	repo := &flakyRepository{Repository: sqlite, fails: 1}
+
+	w := NewBatchWriter(repo, Options{MaxBatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer w.Close()
+
+	w.Write(newTestReading(1), newTestReading(2))
+	w.Flush()
+	if stats := w.Stats(); stats.Retries != 1 || stats.Failed != 0 || stats.Pending != 2 {
+		t.Fatalf("expected the failed batch to be queued for retry, got %+v", stats)
+	}
+
+	// El flush periódico lo reintenta cuando vence la espera
+	deadline := time.Now().Add(time.Second)
+	for w.Stats().Written < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if stats := w.Stats(); stats.Written != 2 || stats.Flushes != 1 || stats.Failed != 0 || stats.Pending != 0 {
+		t.Errorf("expected the batch to be written on retry, got %+v", stats)
+	}
+
+	readings, _ := sqlite.GetLatestReadings(context.Background(), "temp-001", 10)
+	if len(readings) != 2 {
+		t.Errorf("expected 2 persisted readings, got %d", len(readings))
+	}
+}
+
+func TestBatchWriter_MaxRetriesAndMaxPending(t *testing.T) {
+	repo, err := storage.NewSQLiteRepository(":memory:")
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	repo.Close() // Toda escritura fallará
+
+	w := NewBatchWriter(repo, Options{MaxBatchSize: 2, FlushInterval: time.Hour, MaxPending: 3, MaxRetries: 2})
+	defer w.Close()
+
+	w.Write(newTestReading(1), newTestReading(2))
+	w.Write(newTestReading(3), newTestReading(4)) // Supera MaxPending
+	if stats := w.Stats(); stats.Dropped != 2 || stats.Pending != 2 {
+		t.Fatalf("expected 2 readings dropped by the pending limit, got %+v", stats)
+	}
+
+	// Se descarta en el flush que supera MaxRetries (la espera se salta forzando el reintento)
+	for i := 0; i < 3; i++ {
+		w.mu.Lock()
+		for j := range w.retries {
+			w.retries[j].next = time.Time{}
+		}
+		w.mu.Unlock()
+		w.Flush()
+	}
+	if stats := w.Stats(); stats.Retries != 2 || stats.Failed != 2 || stats.Pending != 0 {
+		t.Errorf("expected the batch to be dropped after 2 retries, got %+v", stats)
+	}
+}
@@ -268,9 +268,13 @@ func testQuerySensorReadings(t *testing.T, nc *nats.Conn, sensorID string) {
 	msg, err := nc.Request(subject, payload, 5*time.Second)
 	require.NoError(t, err, "Error consultando lecturas")
 
-	var readings []map[string]interface{}
-	err = json.Unmarshal(msg.Data, &readings)
+	var page struct {
+		Readings   []map[string]interface{} `json:"readings"`
+		NextCursor string                   `json:"next_cursor"`
+	}
+	err = json.Unmarshal(msg.Data, &page)
 	require.NoError(t, err, "Error parseando lecturas")
+	readings := page.Readings
 
 	// Nota: El sensor puede no tener lecturas todavía si acaba de ser registrado
 	// Por eso hacemos una aserción más permisiva